@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -36,6 +37,7 @@ func main() {
 	}))
 
 	pkg.InitInfrastructure()
+	pkg.StartWorkers(context.Background())
 	pkg.InitRoutes(router)
 
 	// Start API and WebSocket orchestrator
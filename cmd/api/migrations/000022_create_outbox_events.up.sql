@@ -0,0 +1,26 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    event_key VARCHAR(255) DEFAULT NULL,
+    dedup_key VARCHAR(191) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSON NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT DEFAULT NULL,
+    -- Set while a relay publishes the event; another relay may take it over once leased_until passes
+    lease_id CHAR(36) DEFAULT NULL,
+    leased_until DATETIME DEFAULT NULL,
+    available_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at DATETIME DEFAULT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_outbox_events_dedup_key UNIQUE (dedup_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+-- The relay polls pending events in creation order
+CREATE INDEX idx_outbox_events_status_available ON outbox_events (status, available_at);
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at);
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS outbox_dead_letters (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    -- Not a foreign key: dead letters outlive the purge of their event
+    event_id CHAR(36) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    event_key VARCHAR(255) DEFAULT NULL,
+    dedup_key VARCHAR(191) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSON NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT DEFAULT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+CREATE INDEX idx_outbox_dead_letters_event_id ON outbox_dead_letters (event_id);
+CREATE INDEX idx_outbox_dead_letters_event_type ON outbox_dead_letters (event_type);
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +migrate Down
+DROP INDEX idx_comments_post_depth ON comments;
+DROP INDEX idx_comments_post_left ON comments;
+ALTER TABLE comments
+    DROP FOREIGN KEY fk_comments_parent,
+    DROP COLUMN depth,
+    DROP COLUMN parent_id;
\ No newline at end of file
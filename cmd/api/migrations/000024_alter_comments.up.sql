@@ -0,0 +1,11 @@
+-- +migrate Up
+-- Thread columns for the nested set: the direct parent and the depth below the post (0 = top level)
+ALTER TABLE comments
+    ADD COLUMN parent_id CHAR(36) DEFAULT NULL AFTER user_id,
+    ADD COLUMN depth INT NOT NULL DEFAULT 0 AFTER `right`,
+    ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+-- Indexing
+-- The nested set is scoped per post
+CREATE INDEX idx_comments_post_left ON comments (post_id, `left`);
+CREATE INDEX idx_comments_post_depth ON comments (post_id, depth);
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE posts
+    DROP FOREIGN KEY fk_posts_shared_post,
+    DROP INDEX idx_posts_shared_post_id,
+    DROP COLUMN shared_post_id;
\ No newline at end of file
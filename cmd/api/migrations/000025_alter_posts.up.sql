@@ -0,0 +1,8 @@
+-- +migrate Up
+-- A quote repost is a regular post pointing at the post it quotes
+ALTER TABLE posts
+    ADD COLUMN shared_post_id CHAR(36) DEFAULT NULL AFTER user_id,
+    ADD CONSTRAINT fk_posts_shared_post FOREIGN KEY (shared_post_id) REFERENCES posts(id) ON DELETE SET NULL;
+
+-- Indexing
+CREATE INDEX idx_posts_shared_post_id ON posts (shared_post_id);
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +migrate Down
+DROP INDEX idx_post_shares_user_created ON post_shares;
+DROP INDEX uq_post_shares_plain ON post_shares;
+ALTER TABLE post_shares
+    DROP FOREIGN KEY fk_post_shares_quote_post,
+    DROP COLUMN plain_share_user_id,
+    DROP COLUMN quote_post_id;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- quote_post_id is set when the share is a quote repost; plain shares are unique per user and post
+ALTER TABLE post_shares
+    ADD COLUMN quote_post_id CHAR(36) DEFAULT NULL AFTER user_id,
+    ADD COLUMN plain_share_user_id CHAR(36) AS (IF(quote_post_id IS NULL, user_id, NULL)) STORED,
+    ADD CONSTRAINT fk_post_shares_quote_post FOREIGN KEY (quote_post_id) REFERENCES posts(id) ON DELETE CASCADE;
+
+-- Indexing
+CREATE UNIQUE INDEX uq_post_shares_plain ON post_shares (post_id, plain_share_user_id);
+CREATE INDEX idx_post_shares_user_created ON post_shares (user_id, created_at);
\ No newline at end of file
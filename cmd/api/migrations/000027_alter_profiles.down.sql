@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE profiles
+    DROP COLUMN is_private;
\ No newline at end of file
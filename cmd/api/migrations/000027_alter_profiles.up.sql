@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE profiles
+    ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE AFTER social_links;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS follow_requests;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS follow_requests (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    requester_id CHAR(36) NOT NULL,
+    target_id CHAR(36) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_follow_requests_pair UNIQUE (requester_id, target_id),
+    CONSTRAINT fk_follow_requests_requester FOREIGN KEY (requester_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_follow_requests_target FOREIGN KEY (target_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+CREATE INDEX idx_follow_requests_target_created ON follow_requests (target_id, created_at);
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +migrate Down
+DROP INDEX idx_friends_friend_to_status ON friends;
+DROP INDEX idx_friends_user_status ON friends;
+ALTER TABLE friends
+    DROP INDEX uq_friends_pair,
+    DROP COLUMN pair_high,
+    DROP COLUMN pair_low;
\ No newline at end of file
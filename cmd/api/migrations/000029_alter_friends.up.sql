@@ -0,0 +1,10 @@
+-- +migrate Up
+-- One row per pair regardless of who asked, so crossing requests cannot both be inserted
+ALTER TABLE friends
+    ADD COLUMN pair_low CHAR(36) AS (LEAST(user_id, friend_to)) STORED,
+    ADD COLUMN pair_high CHAR(36) AS (GREATEST(user_id, friend_to)) STORED,
+    ADD CONSTRAINT uq_friends_pair UNIQUE (pair_low, pair_high);
+
+-- Indexing
+CREATE INDEX idx_friends_user_status ON friends (user_id, status);
+CREATE INDEX idx_friends_friend_to_status ON friends (friend_to, status);
\ No newline at end of file
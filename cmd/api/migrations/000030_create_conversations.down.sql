@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS conversations;
\ No newline at end of file
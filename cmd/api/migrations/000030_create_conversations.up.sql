@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS conversations (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    kind VARCHAR(16) NOT NULL DEFAULT 'direct',
+    direct_key VARCHAR(73) DEFAULT NULL,
+    last_seq BIGINT NOT NULL DEFAULT 0,
+    last_message_at DATETIME DEFAULT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_conversations_direct_key UNIQUE (direct_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
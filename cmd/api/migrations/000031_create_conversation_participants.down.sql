@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS conversation_participants;
\ No newline at end of file
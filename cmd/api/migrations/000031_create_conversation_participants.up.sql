@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    last_read_seq BIGINT NOT NULL DEFAULT 0,
+    joined_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (conversation_id, user_id),
+
+    CONSTRAINT fk_conversation_participants_conversation FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    CONSTRAINT fk_conversation_participants_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+CREATE INDEX idx_conversation_participants_user_id ON conversation_participants (user_id);
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +migrate Down
+ALTER TABLE messages
+    DROP FOREIGN KEY fk_messages_conversation,
+    DROP INDEX uq_messages_conversation_seq,
+    DROP COLUMN deleted_at,
+    DROP COLUMN edited_at,
+    DROP COLUMN attachments,
+    DROP COLUMN body,
+    DROP COLUMN seq,
+    DROP COLUMN conversation_id;
\ No newline at end of file
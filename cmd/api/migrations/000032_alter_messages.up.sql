@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE messages
+    ADD COLUMN conversation_id CHAR(36) DEFAULT NULL AFTER id,
+    ADD COLUMN seq BIGINT NOT NULL DEFAULT 0 AFTER conversation_id,
+    ADD COLUMN body TEXT DEFAULT NULL AFTER receiver_id,
+    ADD COLUMN attachments JSON DEFAULT NULL AFTER body,
+    ADD COLUMN edited_at DATETIME DEFAULT NULL AFTER attachments,
+    ADD COLUMN deleted_at DATETIME DEFAULT NULL AFTER edited_at,
+    ADD CONSTRAINT fk_messages_conversation FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    ADD CONSTRAINT uq_messages_conversation_seq UNIQUE (conversation_id, seq);
\ No newline at end of file
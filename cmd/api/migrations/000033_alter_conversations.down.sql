@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE conversations
+    DROP FOREIGN KEY fk_conversations_created_by,
+    DROP COLUMN created_by,
+    DROP COLUMN title;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE conversations
+    ADD COLUMN title VARCHAR(100) DEFAULT NULL AFTER direct_key,
+    ADD COLUMN created_by CHAR(36) DEFAULT NULL AFTER title,
+    ADD CONSTRAINT fk_conversations_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL;
\ No newline at end of file
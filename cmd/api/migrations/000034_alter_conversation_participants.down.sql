@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE conversation_participants
+    DROP COLUMN role;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE conversation_participants
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'member' AFTER user_id;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS conversation_pins;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS conversation_pins (
+    conversation_id CHAR(36) NOT NULL,
+    message_id CHAR(36) NOT NULL,
+    pinned_by CHAR(36) DEFAULT NULL,
+    pinned_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (conversation_id, message_id),
+
+    CONSTRAINT fk_conversation_pins_conversation FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    CONSTRAINT fk_conversation_pins_message FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    CONSTRAINT fk_conversation_pins_pinned_by FOREIGN KEY (pinned_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
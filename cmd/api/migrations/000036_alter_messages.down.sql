@@ -0,0 +1,4 @@
+-- +migrate Down
+DELETE FROM messages WHERE receiver_id IS NULL;
+ALTER TABLE messages
+    MODIFY receiver_id CHAR(36) NOT NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- +migrate Up
+-- Group messages have no single receiver
+ALTER TABLE messages
+    MODIFY receiver_id CHAR(36) DEFAULT NULL;
\ No newline at end of file
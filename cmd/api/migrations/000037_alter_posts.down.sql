@@ -0,0 +1,6 @@
+-- +migrate Down
+ALTER TABLE posts
+    DROP FOREIGN KEY fk_posts_deleted_by,
+    DROP INDEX idx_posts_deleted_at,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- Deleted posts are hidden at once and purged with everything attached once the restore window has passed
+ALTER TABLE posts
+    ADD COLUMN deleted_at DATETIME DEFAULT NULL AFTER updated_at,
+    ADD COLUMN deleted_by CHAR(36) DEFAULT NULL AFTER deleted_at,
+    ADD CONSTRAINT fk_posts_deleted_by FOREIGN KEY (deleted_by) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Indexing
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at);
\ No newline at end of file
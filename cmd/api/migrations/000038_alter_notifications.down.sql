@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE notifications
+    DROP FOREIGN KEY fk_notifications_post,
+    DROP INDEX idx_notifications_post_id,
+    DROP COLUMN post_id;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- post_id links a notification to the post it is about so purging the post removes it
+ALTER TABLE notifications
+    ADD COLUMN post_id CHAR(36) DEFAULT NULL AFTER receiver_id,
+    ADD CONSTRAINT fk_notifications_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE;
+
+-- Indexing
+CREATE INDEX idx_notifications_post_id ON notifications (post_id);
\ No newline at end of file
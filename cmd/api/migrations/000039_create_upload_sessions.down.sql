@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS upload_sessions;
\ No newline at end of file
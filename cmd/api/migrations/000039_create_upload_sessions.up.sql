@@ -0,0 +1,23 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    user_id CHAR(36) NOT NULL,
+    object_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    -- Set when the file is sent in parts through a multipart upload
+    multipart_upload_id VARCHAR(255) DEFAULT NULL,
+    part_count INT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    expires_at DATETIME NOT NULL,
+    completed_at DATETIME DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_upload_sessions_object_key (object_key),
+    CONSTRAINT fk_upload_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+CREATE INDEX idx_upload_sessions_user_id ON upload_sessions (user_id);
+CREATE INDEX idx_upload_sessions_expires_at ON upload_sessions (expires_at);
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +migrate Down
+ALTER TABLE media
+    DROP INDEX idx_media_processing_status,
+    DROP COLUMN processing_attempts,
+    DROP COLUMN processing_status,
+    DROP COLUMN blurhash,
+    DROP COLUMN height,
+    DROP COLUMN width;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- Images are re-encoded without metadata and resized in the background; processing_status tracks that pipeline
+ALTER TABLE media
+    ADD COLUMN width INT DEFAULT NULL AFTER type,
+    ADD COLUMN height INT DEFAULT NULL AFTER width,
+    ADD COLUMN blurhash VARCHAR(64) DEFAULT NULL AFTER height,
+    ADD COLUMN processing_status VARCHAR(16) NOT NULL DEFAULT 'ready' AFTER status,
+    ADD COLUMN processing_attempts INT NOT NULL DEFAULT 0 AFTER processing_status;
+
+-- Images stored before the pipeline existed still carry their metadata
+UPDATE media SET processing_status = 'pending' WHERE type LIKE 'image/%' AND path NOT LIKE 'uploads/%';
+
+-- Indexing
+CREATE INDEX idx_media_processing_status ON media (processing_status);
\ No newline at end of file
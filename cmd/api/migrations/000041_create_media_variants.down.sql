@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS media_variants;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS media_variants (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    media_id CHAR(36) NOT NULL,
+    -- Size name such as 'small'; one variant per name and media
+    name VARCHAR(16) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_media_variants_media_name (media_id, name),
+    CONSTRAINT fk_media_variants_media FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE upload_sessions
+    DROP INDEX idx_upload_sessions_object_key,
+    ADD UNIQUE KEY uq_upload_sessions_object_key (object_key);
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +migrate Up
+-- Completed uploads move to content-addressed keys, which sessions uploading the same file share
+ALTER TABLE upload_sessions
+    DROP INDEX uq_upload_sessions_object_key,
+    ADD INDEX idx_upload_sessions_object_key (object_key);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX idx_comments_post_depth_created_at_id ON comments;
+DROP INDEX idx_notifications_receiver_created_at_id ON notifications;
+DROP INDEX idx_posts_created_at_id ON posts;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +migrate Up
+-- Cursor pagination walks lists in (created_at, id) order
+CREATE INDEX idx_posts_created_at_id ON posts (created_at, id);
+CREATE INDEX idx_notifications_receiver_created_at_id ON notifications (receiver_id, created_at, id);
+CREATE INDEX idx_comments_post_depth_created_at_id ON comments (post_id, depth, created_at, id);
\ No newline at end of file
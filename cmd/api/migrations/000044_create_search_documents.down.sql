@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS search_documents;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- +migrate Up
+-- Documents the search index ranks with FULLTEXT; the API keeps them in step with posts, users and categories
+CREATE TABLE IF NOT EXISTS search_documents (
+    kind VARCHAR(16) NOT NULL,
+    doc_id CHAR(36) NOT NULL,
+    -- Username or category name, empty for posts
+    title VARCHAR(255) DEFAULT NULL,
+    -- Post content or user bio
+    body TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (kind, doc_id),
+    FULLTEXT KEY ft_search_documents_title (title),
+    FULLTEXT KEY ft_search_documents_body (body)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Index what already exists
+INSERT IGNORE INTO search_documents (kind, doc_id, title, body, created_at)
+SELECT 'post', posts.id, '', posts.content, posts.created_at FROM posts WHERE posts.deleted_at IS NULL;
+INSERT IGNORE INTO search_documents (kind, doc_id, title, body, created_at)
+SELECT 'user', users.id, users.username, profiles.bio, users.created_at
+FROM users LEFT JOIN profiles ON profiles.user_id = users.id WHERE users.status = true;
+INSERT IGNORE INTO search_documents (kind, doc_id, title, body, created_at)
+SELECT 'category', categories.id, categories.name, '', categories.created_at FROM categories;
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- Hashtags, stored once under their normalized name
+CREATE TABLE IF NOT EXISTS tags (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    name VARCHAR(191) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_tags_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- Uses of a tag in a post, or in one of its comments when comment_id is set
+CREATE TABLE IF NOT EXISTS post_tags (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    tag_id CHAR(36) NOT NULL,
+    post_id CHAR(36) NOT NULL,
+    comment_id CHAR(36) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_post_tags_tag FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_tags_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_tags_comment FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Tag pages walk a tag's posts, trending counts the uses of a time window
+CREATE INDEX idx_post_tags_tag_post ON post_tags (tag_id, post_id);
+CREATE INDEX idx_post_tags_created_at_tag ON post_tags (created_at, tag_id);
+CREATE INDEX idx_post_tags_post_comment ON post_tags (post_id, comment_id);
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +migrate Up
+-- Users named as @username in a post, or in one of its comments when comment_id is set
+CREATE TABLE IF NOT EXISTS mentions (
+    id CHAR(36) NOT NULL PRIMARY KEY DEFAULT (UUID()),
+    post_id CHAR(36) NOT NULL,
+    comment_id CHAR(36) DEFAULT NULL,
+    user_id CHAR(36) NOT NULL,
+    author_id CHAR(36) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_mentions_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    CONSTRAINT fk_mentions_comment FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    CONSTRAINT fk_mentions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_mentions_author FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Indexing
+CREATE INDEX idx_mentions_post_comment ON mentions (post_id, comment_id);
+CREATE INDEX idx_mentions_user_created_at ON mentions (user_id, created_at);
\ No newline at end of file
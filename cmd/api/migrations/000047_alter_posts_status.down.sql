@@ -0,0 +1,6 @@
+-- +migrate Down
+ALTER TABLE posts
+    DROP INDEX idx_posts_user_status_updated_at,
+    DROP INDEX idx_posts_status_publish_at,
+    DROP COLUMN publish_at,
+    DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- Posts are drafts, scheduled for publish_at, published or archived by their author; only published posts are listed
+ALTER TABLE posts
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published' AFTER shared_post_id,
+    ADD COLUMN publish_at DATETIME DEFAULT NULL AFTER status;
+
+-- Indexing
+CREATE INDEX idx_posts_status_publish_at ON posts (status, publish_at);
+CREATE INDEX idx_posts_user_status_updated_at ON posts (user_id, status, updated_at);
\ No newline at end of file
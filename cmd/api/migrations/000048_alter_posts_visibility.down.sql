@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE posts
+    DROP COLUMN visibility;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- +migrate Up
+-- Who besides the author sees a post: public, followers, friends, only_me or team
+ALTER TABLE posts
+    ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public' AFTER publish_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS stored_files;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- Content-addressed files being written or reused by an upload that is not referenced by a row yet.
+-- Deleting a file locks its row, so it waits for a claim in progress and keeps claimed files.
+CREATE TABLE IF NOT EXISTS stored_files (
+    path VARCHAR(255) NOT NULL PRIMARY KEY,
+    claims INT NOT NULL DEFAULT 0,
+    claimed_until DATETIME DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
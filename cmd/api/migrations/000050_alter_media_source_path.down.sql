@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE media
+    DROP INDEX idx_media_source_path,
+    DROP COLUMN source_path;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Processed images are stored under the key of their own content; source_path is the upload they came from,
+-- so identical uploads reuse the result
+ALTER TABLE media
+    ADD COLUMN source_path VARCHAR(255) DEFAULT NULL AFTER path;
+
+-- Indexing
+CREATE INDEX idx_media_source_path ON media (source_path);
\ No newline at end of file
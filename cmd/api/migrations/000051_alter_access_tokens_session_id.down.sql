@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE access_tokens
+    DROP INDEX idx_access_tokens_session_id,
+    DROP COLUMN session_id;
\ No newline at end of file
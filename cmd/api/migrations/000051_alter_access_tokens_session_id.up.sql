@@ -0,0 +1,13 @@
+-- +migrate Up
+-- The session an access token was issued for, which sockets authenticated by the token are bound to
+ALTER TABLE access_tokens
+    ADD COLUMN session_id CHAR(36) DEFAULT NULL AFTER user_id;
+
+-- Tokens issued before belong to their user's session, a user has at most one
+UPDATE access_tokens
+    JOIN user_sessions ON user_sessions.user_id = access_tokens.user_id
+    SET access_tokens.session_id = user_sessions.session_id
+    WHERE access_tokens.session_id IS NULL;
+
+-- Indexing
+CREATE INDEX idx_access_tokens_session_id ON access_tokens (session_id);
\ No newline at end of file
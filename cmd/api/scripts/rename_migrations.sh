@@ -1,6 +1,8 @@
 #!/bin/bash
 set -e
 
+# Tables are numbered by their position here. The create migrations that came after the first alter ones are
+# pinned to the number they were created with as "name:number", and the ones following them count from there.
 FILES=(
   "roles"
   "users"
@@ -23,10 +25,22 @@ FILES=(
   "notifications"
   "follows"
   "user_sessions"
+  "outbox_events"
+  "outbox_dead_letters"
+  "follow_requests:28"
+  "conversations:30"
+  "conversation_participants:31"
+  "conversation_pins:35"
+  "upload_sessions:39"
+  "media_variants:41"
 )
 
 i=1
-for name in "${FILES[@]}"; do
+for entry in "${FILES[@]}"; do
+  name=${entry%%:*}
+  if [ "$name" != "$entry" ]; then
+    i=$((10#${entry#*:}))
+  fi
   num=$(printf "%06d" $i)
 
   up_file=$(ls | grep -E "[0-9]+_create_${name}\.up\.sql$" || true)
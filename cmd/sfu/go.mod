@@ -1,3 +1,5 @@
 module univia-sfu
 
 go 1.25.0
+
+require github.com/unarya/univia v0.0.1-alpha.1
@@ -1 +1,31 @@
 package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/unarya/univia/pkg/sfu"
+)
+
+func main() {
+	secret := os.Getenv("SFU_SECRET")
+	if secret == "" {
+		log.Fatal("SFU_SECRET is required to authenticate against the signaling server")
+	}
+	var iceServers []string
+	if urls := os.Getenv("SFU_ICE_SERVERS"); urls != "" {
+		iceServers = strings.Split(urls, ",")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := sfu.NewServer(os.Getenv("SIGNALING_URL"), secret, iceServers)
+	if err := server.Start(ctx); err != nil {
+		log.Fatalf("SFU failed: %v", err)
+	}
+}
@@ -14,7 +14,7 @@ func main() {
 		port = "2112"
 	}
 
-	pkg.ConnectRedis()
+	pkg.InitSignalingInfrastructure()
 	server := signaling.NewServer(port)
 	if err := server.Start(); err != nil {
 		log.Fatalf("Signaling orchestrator failed: %v", err)
@@ -0,0 +1,218 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CommentRow is a comment joined with its author and like counts
+type CommentRow struct {
+	ID         uuid.UUID
+	PostID     uuid.UUID
+	ParentID   uuid.NullUUID
+	UserID     uuid.UUID
+	Text       string
+	Left       int
+	Right      int
+	Depth      int
+	Username   string
+	ProfilePic string
+	LikesCount int64
+	IsLiked    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// LockPostForComments takes a row lock on the post so nested-set updates of its comments are serialized
+func LockPostForComments(tx *gorm.DB, postID uuid.UUID) (*posts.Post, *utils.ServiceError) {
+	var post posts.Post
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "user_id").
+		Where("id = ? AND deleted_at IS NULL AND status = 'published'", postID).
+		Take(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to lock post"}
+	}
+	return &post, nil
+}
+
+// GetComment loads a comment by id
+func GetComment(tx *gorm.DB, commentID uuid.UUID) (*posts.Comment, *utils.ServiceError) {
+	var comment posts.Comment
+	err := tx.Where("id = ?", commentID).Take(&comment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Comment not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get comment"}
+	}
+	return &comment, nil
+}
+
+// InsertComment places the comment in the post's nested set, as the last top-level comment or the last reply of parent.
+// The caller must hold the post lock from LockPostForComments.
+func InsertComment(tx *gorm.DB, comment *posts.Comment, parent *posts.Comment) *utils.ServiceError {
+	if parent == nil {
+		var maxRight int
+		if err := tx.Model(&posts.Comment{}).
+			Select("COALESCE(MAX(`right`), 0)").
+			Where("post_id = ?", comment.PostID).
+			Scan(&maxRight).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to read comment tree"}
+		}
+		comment.Left = maxRight + 1
+		comment.Right = maxRight + 2
+		comment.Depth = 0
+		comment.ParentID = nil
+	} else {
+		// Open a gap of two at the parent's right edge
+		edge := parent.Right
+		if err := tx.Model(&posts.Comment{}).
+			Where("post_id = ? AND `right` >= ?", comment.PostID, edge).
+			Update("right", gorm.Expr("`right` + 2")).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update comment tree"}
+		}
+		if err := tx.Model(&posts.Comment{}).
+			Where("post_id = ? AND `left` > ?", comment.PostID, edge).
+			Update("left", gorm.Expr("`left` + 2")).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update comment tree"}
+		}
+		comment.Left = edge
+		comment.Right = edge + 1
+		comment.Depth = parent.Depth + 1
+		comment.ParentID = &parent.ID
+	}
+
+	if err := tx.Create(comment).Error; err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create comment"}
+	}
+	return nil
+}
+
+// DeleteCommentSubtree removes the comment with all its replies and closes the gap in the nested set.
+// The caller must hold the post lock from LockPostForComments.
+func DeleteCommentSubtree(tx *gorm.DB, comment *posts.Comment) (int64, *utils.ServiceError) {
+	width := comment.Right - comment.Left + 1
+
+	result := tx.Where("post_id = ? AND `left` BETWEEN ? AND ?", comment.PostID, comment.Left, comment.Right).
+		Delete(&posts.Comment{})
+	if result.Error != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to delete comment"}
+	}
+	if err := tx.Model(&posts.Comment{}).
+		Where("post_id = ? AND `right` > ?", comment.PostID, comment.Right).
+		Update("right", gorm.Expr("`right` - ?", width)).Error; err != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update comment tree"}
+	}
+	if err := tx.Model(&posts.Comment{}).
+		Where("post_id = ? AND `left` > ?", comment.PostID, comment.Right).
+		Update("left", gorm.Expr("`left` - ?", width)).Error; err != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update comment tree"}
+	}
+	return result.RowsAffected, nil
+}
+
+// selectCommentRows is the base query of comment listings, viewerID decides is_liked
+func selectCommentRows(tx *gorm.DB, viewerID uuid.UUID) *gorm.DB {
+	return tx.Table("comments").
+		Select(`
+			comments.id, comments.post_id, comments.parent_id, comments.user_id, comments.text,
+			comments.left, comments.right, comments.depth,
+			users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			(SELECT COUNT(*) FROM comment_likes WHERE comment_likes.comment_id = comments.id) AS likes_count,
+			EXISTS(SELECT 1 FROM comment_likes WHERE comment_likes.comment_id = comments.id AND comment_likes.user_id = ?) AS is_liked,
+			comments.created_at, comments.updated_at
+		`, viewerID).
+		Joins(`
+			LEFT JOIN users ON users.id = comments.user_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`)
+}
+
+// SelectTopLevelComments returns one page of a post's top-level comments in thread order and the total count
+func SelectTopLevelComments(tx *gorm.DB, postID, viewerID uuid.UUID, offset, limit int) ([]CommentRow, int64, *utils.ServiceError) {
+	var total int64
+	if err := tx.Model(&posts.Comment{}).
+		Where("post_id = ? AND depth = 0", postID).
+		Count(&total).Error; err != nil {
+		return nil, 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to count comments"}
+	}
+
+	var rows []CommentRow
+	if err := selectCommentRows(tx, viewerID).
+		Where("comments.post_id = ? AND comments.depth = 0", postID).
+		Order("comments.left ASC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list comments"}
+	}
+	return rows, total, nil
+}
+
+// SelectTopLevelCommentPage returns a keyset page of a post's top-level comments, oldest first,
+// and whether the list goes on in the direction of the page
+func SelectTopLevelCommentPage(tx *gorm.DB, postID, viewerID uuid.UUID, page utils.CursorPage) ([]CommentRow, bool, *utils.ServiceError) {
+	var rows []CommentRow
+	if err := KeysetPage(selectCommentRows(tx, viewerID), "comments", page, true).
+		Where("comments.post_id = ? AND comments.depth = 0", postID).
+		Scan(&rows).Error; err != nil {
+		return nil, false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list comments"}
+	}
+	rows, hasMore := utils.TrimCursorPage(rows, page)
+	return rows, hasMore, nil
+}
+
+// SelectCommentDescendants returns the comments strictly inside [left, right] of a post down to maxDepth, in thread order
+func SelectCommentDescendants(tx *gorm.DB, postID, viewerID uuid.UUID, left, right, maxDepth int) ([]CommentRow, *utils.ServiceError) {
+	var rows []CommentRow
+	if err := selectCommentRows(tx, viewerID).
+		Where("comments.post_id = ? AND comments.left > ? AND comments.right < ? AND comments.depth <= ?",
+			postID, left, right, maxDepth).
+		Order("comments.left ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list replies"}
+	}
+	return rows, nil
+}
+
+// SelectComment returns a single comment row
+func SelectComment(tx *gorm.DB, commentID, viewerID uuid.UUID) (*CommentRow, *utils.ServiceError) {
+	var rows []CommentRow
+	if err := selectCommentRows(tx, viewerID).
+		Where("comments.id = ?", commentID).
+		Limit(1).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get comment"}
+	}
+	if len(rows) == 0 {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Comment not found"}
+	}
+	return &rows[0], nil
+}
+
+// CheckIsCommentLiked reports whether the user liked the comment
+func CheckIsCommentLiked(tx *gorm.DB, userID, commentID uuid.UUID) (bool, *utils.ServiceError) {
+	var liked bool
+	if err := tx.Model(&posts.CommentLike{}).
+		Select("count(*) > 0").
+		Where("user_id = ? AND comment_id = ?", userID, commentID).
+		Find(&liked).Error; err != nil {
+		return false, &utils.ServiceError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Database error while checking comment like status",
+		}
+	}
+	return liked, nil
+}
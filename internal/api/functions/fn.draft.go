@@ -0,0 +1,94 @@
+package functions
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DraftRow is an unpublished post of its author
+type DraftRow struct {
+	ID          uuid.UUID
+	Content     sql.NullString
+	Status      string
+	PublishAt   *time.Time
+	CategoryIDs sql.NullString
+	MediaCount  int64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	TotalCount  int64
+}
+
+// SelectDrafts returns the user's posts in the given states, last edited first
+func SelectDrafts(userID uuid.UUID, statuses []string, offset, limit int) ([]DraftRow, *utils.ServiceError) {
+	var rows []DraftRow
+	if err := mysql.DB.Table("posts").
+		Select(`
+			posts.id, posts.content, posts.status, posts.publish_at, posts.created_at, posts.updated_at,
+			(SELECT GROUP_CONCAT(post_categories.category_id ORDER BY post_categories.category_id SEPARATOR ',')
+				FROM post_categories WHERE post_categories.post_id = posts.id) AS category_ids,
+			(SELECT COUNT(*) FROM media WHERE media.post_id = posts.id) AS media_count,
+			COUNT(posts.id) OVER() AS total_count
+		`).
+		Where("posts.user_id = ? AND posts.status IN ? AND posts.deleted_at IS NULL", userID, statuses).
+		Order("posts.updated_at DESC, posts.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list drafts"}
+	}
+	return rows, nil
+}
+
+// UpdatePostStatus moves a post from one state to another. It reports false when the post was not in the from
+// state anymore, so of two racing changes only the first applies.
+func UpdatePostStatus(tx *gorm.DB, postID uuid.UUID, from, to string, publishAt *time.Time) (bool, *utils.ServiceError) {
+	result := tx.Model(&posts.Post{}).
+		Where("id = ? AND status = ? AND deleted_at IS NULL", postID, from).
+		Updates(map[string]interface{}{"status": to, "publish_at": publishAt})
+	if result.Error != nil {
+		return false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update post status"}
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// PublishPost publishes a draft or scheduled post. The post is dated at its publication, lists and feeds
+// order by created_at and it is new to everyone else. It reports false when the post was published,
+// archived or deleted in the meantime.
+func PublishPost(tx *gorm.DB, postID uuid.UUID, now time.Time) (bool, *utils.ServiceError) {
+	result := tx.Model(&posts.Post{}).
+		Where("id = ? AND status IN ? AND deleted_at IS NULL", postID, []string{posts.PostStatusDraft, posts.PostStatusScheduled}).
+		Updates(map[string]interface{}{"status": posts.PostStatusPublished, "publish_at": nil, "created_at": now})
+	if result.Error != nil {
+		return false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to publish post"}
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ClaimDuePost locks the earliest scheduled post whose publish_at has passed, or returns nil when none is left.
+// Posts locked by another transaction are skipped, so replicas publishing at the same time claim different posts,
+// and so are the posts in skip.
+func ClaimDuePost(tx *gorm.DB, now time.Time, skip []uuid.UUID) (*posts.Post, *utils.ServiceError) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Select("id", "user_id", "content", "publish_at").
+		Where("status = ? AND publish_at <= ? AND deleted_at IS NULL", posts.PostStatusScheduled, now)
+	if len(skip) > 0 {
+		query = query.Where("id NOT IN ?", skip)
+	}
+	var due []posts.Post
+	if err := query.Order("publish_at ASC").Limit(1).Find(&due).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to claim scheduled posts"}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	return &due[0], nil
+}
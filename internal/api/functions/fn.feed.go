@@ -0,0 +1,254 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FeedEntry is a post in a home feed, either published or shared (SharerID) by someone in the user's network
+type FeedEntry struct {
+	PostID     uuid.UUID
+	SharerID   uuid.NullUUID
+	ActivityAt time.Time
+}
+
+// PostEngagement counts the reactions that rank a post in feeds
+type PostEngagement struct {
+	PostID   uuid.UUID
+	Likes    int64
+	Comments int64
+	Shares   int64
+}
+
+// FeedWindow selects feed entries around Bound: older ones newest first, or newer ones oldest first when Newer is set.
+// Bound is inclusive and a nil Bound starts from the newest entry.
+type FeedWindow struct {
+	Bound *time.Time
+	Newer bool
+	Limit int
+}
+
+// networkAuthors is the user's network: the users they follow, their friends and themselves
+const networkAuthors = `
+	network AS (
+		SELECT following_id AS id FROM follows WHERE follower_id = @user
+		UNION SELECT friend_to FROM friends WHERE user_id = @user AND status = TRUE
+		UNION SELECT user_id FROM friends WHERE friend_to = @user AND status = TRUE
+		UNION SELECT @user
+	)`
+
+// SelectNetworkEntries returns the posts and plain shares of the user's network in window. Authors with more than
+// maxFollowers followers are selected alone when popular is set and left out otherwise.
+func SelectNetworkEntries(userID uuid.UUID, window FeedWindow, maxFollowers int64, popular bool) ([]FeedEntry, *utils.ServiceError) {
+	popularity := "<="
+	if popular {
+		popularity = ">"
+	}
+	condition := feedWindowCondition(window, "entries.activity_at")
+	order := feedWindowOrder(window, "entries.activity_at", "entries.post_id")
+
+	var entries []FeedEntry
+	if err := mysql.DB.Raw(`
+		WITH`+networkAuthors+`,
+		authors AS (
+			SELECT network.id FROM network
+			WHERE (SELECT COUNT(*) FROM follows WHERE follows.following_id = network.id) `+popularity+` @max_followers
+		)
+		SELECT entries.post_id, entries.sharer_id, entries.activity_at FROM (
+			SELECT posts.id AS post_id, NULL AS sharer_id, posts.created_at AS activity_at
+			FROM posts JOIN authors ON authors.id = posts.user_id
+			WHERE posts.deleted_at IS NULL AND posts.status = 'published' AND `+postVisibleCondition+`
+			UNION ALL
+			SELECT post_shares.post_id, post_shares.user_id, post_shares.created_at
+			FROM post_shares
+				JOIN authors ON authors.id = post_shares.user_id
+				JOIN posts ON posts.id = post_shares.post_id
+			WHERE post_shares.quote_post_id IS NULL AND posts.deleted_at IS NULL AND posts.status = 'published'
+				AND `+postVisibleCondition+`
+		) entries
+		WHERE `+condition+`
+		ORDER BY `+order+`
+		LIMIT @limit
+	`, map[string]interface{}{
+		"user":          userID,
+		"viewer":        userID,
+		"max_followers": maxFollowers,
+		"bound":         feedWindowBound(window),
+		"limit":         window.Limit,
+	}).Scan(&entries).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load the feed"}
+	}
+	return entries, nil
+}
+
+// SelectInterestEntries returns the posts in window filed under the given categories, by name or id,
+// that come from outside the user's network and the user sees. Private accounts are followed by the
+// people who see their posts, so theirs are never interests.
+func SelectInterestEntries(userID uuid.UUID, interests []string, window FeedWindow) ([]FeedEntry, *utils.ServiceError) {
+	if len(interests) == 0 {
+		return nil, nil
+	}
+	condition := feedWindowCondition(window, "posts.created_at")
+	order := feedWindowOrder(window, "activity_at", "post_id")
+
+	var entries []FeedEntry
+	if err := mysql.DB.Raw(`
+		WITH`+networkAuthors+`
+		SELECT DISTINCT posts.id AS post_id, posts.created_at AS activity_at
+		FROM posts
+			JOIN post_categories ON post_categories.post_id = posts.id
+			JOIN categories ON categories.id = post_categories.category_id
+		WHERE posts.deleted_at IS NULL AND posts.status = 'published' AND `+postVisibleCondition+`
+			AND (categories.name IN @interests OR categories.id IN @interests)
+			AND posts.user_id NOT IN (SELECT id FROM network)
+			AND `+condition+`
+		ORDER BY `+order+`
+		LIMIT @limit
+	`, map[string]interface{}{
+		"user":      userID,
+		"viewer":    userID,
+		"interests": interests,
+		"bound":     feedWindowBound(window),
+		"limit":     window.Limit,
+	}).Scan(&entries).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load the feed"}
+	}
+	return entries, nil
+}
+
+func feedWindowCondition(window FeedWindow, column string) string {
+	switch {
+	case window.Bound == nil:
+		return "TRUE"
+	case window.Newer:
+		return column + " >= @bound"
+	default:
+		return column + " <= @bound"
+	}
+}
+
+func feedWindowOrder(window FeedWindow, timeColumn, idColumn string) string {
+	if window.Newer {
+		return fmt.Sprintf("%s ASC, %s ASC", timeColumn, idColumn)
+	}
+	return fmt.Sprintf("%s DESC, %s DESC", timeColumn, idColumn)
+}
+
+func feedWindowBound(window FeedWindow) interface{} {
+	if window.Bound == nil {
+		return nil
+	}
+	return *window.Bound
+}
+
+// SelectFeedRecipients returns the users whose timelines show what authorID publishes: their followers, friends
+// and themselves. It returns false instead when the author has more than maxFollowers followers.
+func SelectFeedRecipients(authorID uuid.UUID, maxFollowers int64) ([]uuid.UUID, bool, *utils.ServiceError) {
+	var followers int64
+	if err := mysql.DB.Table("follows").Where("following_id = ?", authorID).Count(&followers).Error; err != nil {
+		return nil, false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to count followers"}
+	}
+	if followers > maxFollowers {
+		return nil, false, nil
+	}
+
+	var recipients []uuid.UUID
+	if err := mysql.DB.Raw(`
+		SELECT follower_id FROM follows WHERE following_id = @user
+		UNION SELECT friend_to FROM friends WHERE user_id = @user AND status = TRUE
+		UNION SELECT user_id FROM friends WHERE friend_to = @user AND status = TRUE
+		UNION SELECT @user
+	`, map[string]interface{}{"user": authorID}).Scan(&recipients).Error; err != nil {
+		return nil, false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load followers"}
+	}
+	return recipients, true, nil
+}
+
+// SelectPostEngagement counts the likes, comments and shares of the posts, keyed by id. Deleted posts and posts
+// the viewer does not see are left out.
+func SelectPostEngagement(postIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]PostEngagement, *utils.ServiceError) {
+	engagement := make(map[uuid.UUID]PostEngagement, len(postIDs))
+	if len(postIDs) == 0 {
+		return engagement, nil
+	}
+
+	var rows []PostEngagement
+	if err := VisiblePosts(mysql.DB.Table("posts"), viewerID).
+		Select(`
+			posts.id AS post_id,
+			(SELECT COUNT(*) FROM post_likes WHERE post_likes.post_id = posts.id) AS likes,
+			(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id) AS comments,
+			(SELECT COUNT(*) FROM post_shares WHERE post_shares.post_id = posts.id) AS shares
+		`).
+		Where("posts.id IN ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load engagement"}
+	}
+	for _, row := range rows {
+		engagement[row.PostID] = row
+	}
+	return engagement, nil
+}
+
+// SelectUserSummaries loads the name and picture of the users, keyed by id
+func SelectUserSummaries(userIDs []uuid.UUID) (map[uuid.UUID]gin.H, *utils.ServiceError) {
+	summaries := make(map[uuid.UUID]gin.H, len(userIDs))
+	if len(userIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		ID         uuid.UUID
+		Username   string
+		ProfilePic string
+	}
+	if err := mysql.DB.Table("users").
+		Select("users.id, users.username, COALESCE(profiles.profile_pic, '') AS profile_pic").
+		Joins("LEFT JOIN profiles ON profiles.user_id = users.id").
+		Where("users.id IN ?", userIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load users"}
+	}
+	for _, row := range rows {
+		summaries[row.ID] = gin.H{"id": row.ID, "name": row.Username, "profile_pic": row.ProfilePic}
+	}
+	return summaries, nil
+}
+
+// GetProfileInterests returns the categories the user picked on their profile
+func GetProfileInterests(userID uuid.UUID) ([]string, *utils.ServiceError) {
+	var raw []byte
+	if err := mysql.DB.Table("profiles").Select("interests").Where("user_id = ?", userID).Scan(&raw).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load interests"}
+	}
+	var interests []string
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &interests); err != nil {
+			log.Printf("Ignoring malformed interests of user %s: %v", userID, err)
+			return nil, nil
+		}
+	}
+	return interests, nil
+}
+
+// InvalidateTimelines drops the cached home timelines of the users, so they are rebuilt from their current network
+func InvalidateTimelines(userIDs ...uuid.UUID) {
+	if redis.Redis == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		if err := redis.Redis.Delete(redis.TimelineCacheKey(userID)); err != nil {
+			log.Printf("Failed to invalidate timeline %s: %v", userID, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	Profiles "github.com/unarya/univia/internal/api/modules/profile/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowRow is a user in a follower or following list, flagged relative to the viewer
+type FollowRow struct {
+	UserID      uuid.UUID
+	Username    string
+	ProfilePic  string
+	FollowedAt  time.Time
+	IsFollowing bool // the viewer follows this user
+	FollowsYou  bool // this user follows the viewer
+	TotalCount  int64
+}
+
+// FollowRequestRow is a pending request to follow the viewer
+type FollowRequestRow struct {
+	RequesterID uuid.UUID
+	Username    string
+	ProfilePic  string
+	CreatedAt   time.Time
+	TotalCount  int64
+}
+
+// GetProfileByUserID loads the profile of a user, locking it when lock is set
+func GetProfileByUserID(tx *gorm.DB, userID uuid.UUID, lock bool) (*Profiles.Profile, *utils.ServiceError) {
+	var profile Profiles.Profile
+	query := tx.Select("id", "user_id", "is_private").Where("user_id = ?", userID)
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := query.Take(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "User not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get profile"}
+	}
+	return &profile, nil
+}
+
+// CheckIsFollowing reports whether followerID follows followingID
+func CheckIsFollowing(tx *gorm.DB, followerID, followingID uuid.UUID) (bool, *utils.ServiceError) {
+	var following bool
+	if err := tx.Table("follows").
+		Select("count(*) > 0").
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Find(&following).Error; err != nil {
+		return false, &utils.ServiceError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Database error while checking follow status",
+		}
+	}
+	return following, nil
+}
+
+// CountFollows returns how many users follow userID and how many userID follows
+func CountFollows(tx *gorm.DB, userID uuid.UUID) (followers int64, followings int64, err error) {
+	if err = tx.Table("follows").Where("following_id = ?", userID).Count(&followers).Error; err != nil {
+		return 0, 0, err
+	}
+	err = tx.Table("follows").Where("follower_id = ?", userID).Count(&followings).Error
+	return followers, followings, err
+}
+
+// SelectFollowers lists the users following userID, newest first
+func SelectFollowers(userID, viewerID uuid.UUID, offset, limit int) ([]FollowRow, *utils.ServiceError) {
+	return selectFollowRows("follows.following_id = ?", "follows.follower_id", userID, viewerID, offset, limit)
+}
+
+// SelectFollowings lists the users userID follows, newest first
+func SelectFollowings(userID, viewerID uuid.UUID, offset, limit int) ([]FollowRow, *utils.ServiceError) {
+	return selectFollowRows("follows.follower_id = ?", "follows.following_id", userID, viewerID, offset, limit)
+}
+
+func selectFollowRows(where, otherColumn string, userID, viewerID uuid.UUID, offset, limit int) ([]FollowRow, *utils.ServiceError) {
+	var rows []FollowRow
+	if err := mysql.DB.Table("follows").
+		Select(`
+			users.id AS user_id, users.username AS username,
+			COALESCE(profiles.profile_pic, '') AS profile_pic,
+			follows.created_at AS followed_at,
+			EXISTS(SELECT 1 FROM follows f WHERE f.follower_id = ? AND f.following_id = users.id) AS is_following,
+			EXISTS(SELECT 1 FROM follows f WHERE f.follower_id = users.id AND f.following_id = ?) AS follows_you,
+			COUNT(*) OVER() AS total_count
+		`, viewerID, viewerID).
+		Joins(`
+			JOIN users ON users.id = `+otherColumn+`
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where(where, userID).
+		Order("follows.created_at DESC, users.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list follows"}
+	}
+	return rows, nil
+}
+
+// SelectFollowRequests lists the pending requests to follow targetID, oldest first
+func SelectFollowRequests(targetID uuid.UUID, offset, limit int) ([]FollowRequestRow, *utils.ServiceError) {
+	var rows []FollowRequestRow
+	if err := mysql.DB.Table("follow_requests").
+		Select(`
+			users.id AS requester_id, users.username AS username,
+			COALESCE(profiles.profile_pic, '') AS profile_pic,
+			follow_requests.created_at, COUNT(*) OVER() AS total_count
+		`).
+		Joins(`
+			JOIN users ON users.id = follow_requests.requester_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where("follow_requests.target_id = ?", targetID).
+		Order("follow_requests.created_at ASC, follow_requests.id ASC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list follow requests"}
+	}
+	return rows, nil
+}
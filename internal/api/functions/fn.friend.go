@@ -0,0 +1,152 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FriendRow is a friend or a pending request, seen from the viewer's side
+type FriendRow struct {
+	UserID      uuid.UUID
+	Username    string
+	ProfilePic  string
+	RequestedOn time.Time
+	AcceptedOn  *time.Time
+	Description string
+	TotalCount  int64
+}
+
+// FriendSuggestionRow is a friend of a friend, ranked by how many friends they share with the viewer
+type FriendSuggestionRow struct {
+	UserID      uuid.UUID
+	Username    string
+	ProfilePic  string
+	MutualCount int64
+}
+
+// LockFriendship loads the row between two users in either direction, locked for update; nil when there is none
+func LockFriendship(tx *gorm.DB, userID, otherID uuid.UUID) (*Users.Friend, *utils.ServiceError) {
+	var friendship Users.Friend
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "user_id", "friend_to", "status").
+		Where("(user_id = ? AND friend_to = ?) OR (user_id = ? AND friend_to = ?)", userID, otherID, otherID, userID).
+		Take(&friendship).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get friendship"}
+	}
+	return &friendship, nil
+}
+
+// CheckUserExists returns a 404 service error when there is no such user
+func CheckUserExists(tx *gorm.DB, userID uuid.UUID) *utils.ServiceError {
+	var exists bool
+	if err := tx.Model(&Users.User{}).Select("count(*) > 0").Where("id = ?", userID).Find(&exists).Error; err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get user"}
+	}
+	if !exists {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "User not found"}
+	}
+	return nil
+}
+
+// SelectFriends lists the accepted friends of userID, most recent first
+func SelectFriends(userID uuid.UUID, offset, limit int) ([]FriendRow, *utils.ServiceError) {
+	var rows []FriendRow
+	if err := mysql.DB.Table("friends").
+		Select(`
+			users.id AS user_id, users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			friends.requested_on, friends.accepted_on, COALESCE(friends.description, '') AS description,
+			COUNT(*) OVER() AS total_count
+		`).
+		Joins(`
+			JOIN users ON users.id = IF(friends.user_id = ?, friends.friend_to, friends.user_id)
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`, userID).
+		Where("(friends.user_id = ? OR friends.friend_to = ?) AND friends.status = TRUE", userID, userID).
+		Order("friends.accepted_on DESC, users.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list friends"}
+	}
+	return rows, nil
+}
+
+// SelectFriendRequests lists pending requests sent to userID when incoming, or sent by userID otherwise
+func SelectFriendRequests(userID uuid.UUID, incoming bool, offset, limit int) ([]FriendRow, *utils.ServiceError) {
+	ownColumn, otherColumn := "friends.user_id", "friends.friend_to"
+	if incoming {
+		ownColumn, otherColumn = otherColumn, ownColumn
+	}
+
+	var rows []FriendRow
+	if err := mysql.DB.Table("friends").
+		Select(`
+			users.id AS user_id, users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			friends.requested_on, COALESCE(friends.description, '') AS description,
+			COUNT(*) OVER() AS total_count
+		`).
+		Joins(`
+			JOIN users ON users.id = `+otherColumn+`
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where(ownColumn+" = ? AND friends.status = FALSE", userID).
+		Order("friends.requested_on DESC, users.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list friend requests"}
+	}
+	return rows, nil
+}
+
+// SelectFriendSuggestions ranks friends of friends by mutual friend count, skipping anyone
+// the user is already friends with or has a pending request with
+func SelectFriendSuggestions(userID uuid.UUID, limit int) ([]FriendSuggestionRow, *utils.ServiceError) {
+	var rows []FriendSuggestionRow
+	if err := mysql.DB.Raw(`
+		WITH my_friends AS (
+			SELECT friend_to AS id FROM friends WHERE user_id = ? AND status = TRUE
+			UNION
+			SELECT user_id AS id FROM friends WHERE friend_to = ? AND status = TRUE
+		),
+		friends_of_friends AS (
+			SELECT friends.friend_to AS candidate_id, my_friends.id AS via_id
+			FROM friends JOIN my_friends ON friends.user_id = my_friends.id
+			WHERE friends.status = TRUE
+			UNION ALL
+			SELECT friends.user_id AS candidate_id, my_friends.id AS via_id
+			FROM friends JOIN my_friends ON friends.friend_to = my_friends.id
+			WHERE friends.status = TRUE
+		)
+		SELECT users.id AS user_id, users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			COUNT(DISTINCT friends_of_friends.via_id) AS mutual_count
+		FROM friends_of_friends
+		JOIN users ON users.id = friends_of_friends.candidate_id
+		LEFT JOIN profiles ON profiles.user_id = users.id
+		WHERE friends_of_friends.candidate_id <> ?
+			AND NOT EXISTS (
+				SELECT 1 FROM friends existing
+				WHERE (existing.user_id = ? AND existing.friend_to = friends_of_friends.candidate_id)
+					OR (existing.user_id = friends_of_friends.candidate_id AND existing.friend_to = ?)
+			)
+		GROUP BY users.id, users.username, profiles.profile_pic
+		ORDER BY mutual_count DESC, users.id
+		LIMIT ?
+	`, userID, userID, userID, userID, userID, limit).Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list friend suggestions"}
+	}
+	return rows, nil
+}
@@ -0,0 +1,295 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConversationRow is a conversation of the viewer with its latest message and unread count
+type ConversationRow struct {
+	ID                uuid.UUID
+	Kind              string
+	Title             string
+	MemberCount       int64
+	LastSeq           int64
+	LastMessageAt     *time.Time
+	LastReadSeq       int64
+	UnreadCount       int64
+	PeerID            uuid.NullUUID
+	PeerUsername      string
+	PeerProfilePic    string
+	LastMessageID     uuid.NullUUID
+	LastMessageSender uuid.NullUUID
+	LastMessageBody   string
+	LastMessageGone   bool
+	TotalCount        int64
+}
+
+// MessageRow is a message joined with its sender
+type MessageRow struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	Seq            int64
+	SenderID       uuid.UUID
+	Username       string
+	ProfilePic     string
+	Body           string
+	Attachments    datatypes.JSON
+	EditedAt       *time.Time
+	DeletedAt      *time.Time
+	CreatedAt      time.Time
+}
+
+// DirectConversationKey orders the two user ids so both directions map to the same conversation
+func DirectConversationKey(userID, otherID uuid.UUID) string {
+	a, b := userID.String(), otherID.String()
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%s:%s", a, b)
+}
+
+// LockDirectConversation returns the direct conversation of the two users, creating it and its participants
+// when needed. The conversation row stays locked until tx ends, which serializes message sequence numbers.
+func LockDirectConversation(tx *gorm.DB, userID, otherID uuid.UUID) (*Users.Conversation, *utils.ServiceError) {
+	key := DirectConversationKey(userID, otherID)
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Users.Conversation{
+		ID:        uuid.New(),
+		Kind:      Users.ConversationDirect,
+		DirectKey: &key,
+	}).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create conversation"}
+	}
+
+	var conversation Users.Conversation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("direct_key = ?", key).
+		Take(&conversation).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get conversation"}
+	}
+
+	participants := []Users.ConversationParticipant{
+		{ConversationID: conversation.ID, UserID: userID},
+		{ConversationID: conversation.ID, UserID: otherID},
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&participants).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to add participants"}
+	}
+	return &conversation, nil
+}
+
+// LockConversation loads a conversation locked for update
+func LockConversation(tx *gorm.DB, conversationID uuid.UUID) (*Users.Conversation, *utils.ServiceError) {
+	var conversation Users.Conversation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", conversationID).Take(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Conversation not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get conversation"}
+	}
+	return &conversation, nil
+}
+
+// GetConversationParticipant returns the user's membership, or a 404 when they are not in the conversation
+func GetConversationParticipant(tx *gorm.DB, conversationID, userID uuid.UUID) (*Users.ConversationParticipant, *utils.ServiceError) {
+	var participant Users.ConversationParticipant
+	err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, userID).Take(&participant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Conversation not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get conversation"}
+	}
+	return &participant, nil
+}
+
+// ConversationMemberRow is a participant of a conversation with their profile
+type ConversationMemberRow struct {
+	UserID      uuid.UUID
+	Username    string
+	ProfilePic  string
+	Role        string
+	LastReadSeq int64
+	JoinedAt    time.Time
+}
+
+// PinnedMessageRow is a pinned message with who pinned it
+type PinnedMessageRow struct {
+	MessageRow
+	PinnedBy uuid.NullUUID
+	PinnedAt time.Time
+}
+
+// SelectConversationMembers lists the participants of a conversation, owner and admins first
+func SelectConversationMembers(conversationID uuid.UUID) ([]ConversationMemberRow, *utils.ServiceError) {
+	var rows []ConversationMemberRow
+	if err := mysql.DB.Table("conversation_participants").
+		Select(`
+			users.id AS user_id, users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			conversation_participants.role, conversation_participants.last_read_seq, conversation_participants.joined_at
+		`).
+		Joins(`
+			JOIN users ON users.id = conversation_participants.user_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where("conversation_participants.conversation_id = ?", conversationID).
+		Order("FIELD(conversation_participants.role, 'owner', 'admin', 'member'), conversation_participants.joined_at, users.id").
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list members"}
+	}
+	return rows, nil
+}
+
+// CountConversationMembers counts the participants of a conversation
+func CountConversationMembers(tx *gorm.DB, conversationID uuid.UUID) (int64, error) {
+	var total int64
+	err := tx.Model(&Users.ConversationParticipant{}).Where("conversation_id = ?", conversationID).Count(&total).Error
+	return total, err
+}
+
+// SelectPinnedMessages lists the pinned messages of a conversation, most recently pinned first
+func SelectPinnedMessages(conversationID uuid.UUID) ([]PinnedMessageRow, *utils.ServiceError) {
+	var rows []PinnedMessageRow
+	if err := mysql.DB.Table("conversation_pins").
+		Select(`
+			messages.id, messages.conversation_id, messages.seq, messages.sender_id,
+			users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			COALESCE(messages.body, '') AS body, messages.attachments,
+			messages.edited_at, messages.deleted_at, messages.created_at,
+			conversation_pins.pinned_by, conversation_pins.pinned_at
+		`).
+		Joins(`
+			JOIN messages ON messages.id = conversation_pins.message_id
+			LEFT JOIN users ON users.id = messages.sender_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where("conversation_pins.conversation_id = ?", conversationID).
+		Order("conversation_pins.pinned_at DESC, messages.seq DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list pinned messages"}
+	}
+	return rows, nil
+}
+
+// SelectConversationPeers returns the participants of the conversation other than userID
+func SelectConversationPeers(tx *gorm.DB, conversationID, userID uuid.UUID) ([]uuid.UUID, error) {
+	var peers []uuid.UUID
+	err := tx.Model(&Users.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id <> ?", conversationID, userID).
+		Pluck("user_id", &peers).Error
+	return peers, err
+}
+
+// LockMessage loads a message locked for update
+func LockMessage(tx *gorm.DB, messageID uuid.UUID) (*Users.Message, *utils.ServiceError) {
+	var message Users.Message
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", messageID).Take(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Message not found"}
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get message"}
+	}
+	return &message, nil
+}
+
+// SelectConversations lists the user's conversations, most recently active first
+func SelectConversations(userID uuid.UUID, offset, limit int) ([]ConversationRow, *utils.ServiceError) {
+	var rows []ConversationRow
+	if err := mysql.DB.Table("conversation_participants AS me").
+		Select(`
+			conversations.id, conversations.kind, COALESCE(conversations.title, '') AS title,
+			conversations.last_seq, conversations.last_message_at, me.last_read_seq,
+			(SELECT COUNT(*) FROM conversation_participants members WHERE members.conversation_id = conversations.id) AS member_count,
+			(
+				SELECT COUNT(*) FROM messages unread
+				WHERE unread.conversation_id = conversations.id AND unread.seq > me.last_read_seq
+					AND unread.sender_id <> me.user_id AND unread.deleted_at IS NULL
+			) AS unread_count,
+			peers.id AS peer_id, COALESCE(peers.username, '') AS peer_username,
+			COALESCE(peer_profiles.profile_pic, '') AS peer_profile_pic,
+			last_message.id AS last_message_id, last_message.sender_id AS last_message_sender,
+			COALESCE(last_message.body, '') AS last_message_body,
+			last_message.deleted_at IS NOT NULL AS last_message_gone,
+			COUNT(*) OVER() AS total_count
+		`).
+		Joins(`
+			JOIN conversations ON conversations.id = me.conversation_id
+			LEFT JOIN conversation_participants peer
+				ON peer.conversation_id = conversations.id AND peer.user_id <> me.user_id AND conversations.kind = ?
+			LEFT JOIN users peers ON peers.id = peer.user_id
+			LEFT JOIN profiles peer_profiles ON peer_profiles.user_id = peers.id
+			LEFT JOIN messages last_message
+				ON last_message.conversation_id = conversations.id AND last_message.seq = conversations.last_seq
+		`, Users.ConversationDirect).
+		Where("me.user_id = ? AND (conversations.last_seq > 0 OR conversations.kind <> ?)", userID, Users.ConversationDirect).
+		Order("COALESCE(conversations.last_message_at, conversations.created_at) DESC, conversations.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list conversations"}
+	}
+	return rows, nil
+}
+
+// SelectMessages loads up to limit messages of a conversation: older than before when set, newer than after
+// when set (oldest first), otherwise the latest ones. Results are always ordered by ascending seq.
+func SelectMessages(conversationID uuid.UUID, before, after int64, limit int) ([]MessageRow, *utils.ServiceError) {
+	query := mysql.DB.Table("messages").
+		Select(`
+			messages.id, messages.conversation_id, messages.seq, messages.sender_id,
+			users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic,
+			COALESCE(messages.body, '') AS body, messages.attachments,
+			messages.edited_at, messages.deleted_at, messages.created_at
+		`).
+		Joins(`
+			LEFT JOIN users ON users.id = messages.sender_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where("messages.conversation_id = ?", conversationID)
+
+	ascending := after > 0
+	switch {
+	case ascending:
+		query = query.Where("messages.seq > ?", after).Order("messages.seq ASC")
+	case before > 0:
+		query = query.Where("messages.seq < ?", before).Order("messages.seq DESC")
+	default:
+		query = query.Order("messages.seq DESC")
+	}
+
+	var rows []MessageRow
+	if err := query.Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load messages"}
+	}
+	if !ascending {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	return rows, nil
+}
+
+// CountUnreadMessages counts the messages the user has not read across all conversations
+func CountUnreadMessages(userID uuid.UUID) (int64, *utils.ServiceError) {
+	var total int64
+	if err := mysql.DB.Table("messages").
+		Joins("JOIN conversation_participants me ON me.conversation_id = messages.conversation_id AND me.user_id = ?", userID).
+		Where("messages.seq > me.last_read_seq AND messages.sender_id <> ? AND messages.deleted_at IS NULL", userID).
+		Count(&total).Error; err != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to count unread messages"}
+	}
+	return total, nil
+}
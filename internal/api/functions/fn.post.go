@@ -4,27 +4,27 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/unarya/univia/internal/api/modules/post/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/pkg/utils"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// CreatePost is the function will create post with userID and content
-func CreatePost(content string, userID uuid.UUID) (postID uuid.UUID, errService *utils.ServiceError) {
-	db := mysql.DB
-	post := posts.Post{UserID: userID, Content: content}
-	if err := db.Create(&post).Error; err != nil {
+// CreatePost is the function will create post with userID and content, in the given state and visibility
+func CreatePost(tx *gorm.DB, content string, userID uuid.UUID, status string, publishAt *time.Time, visibility string) (postID uuid.UUID, errService *utils.ServiceError) {
+	post := posts.Post{UserID: userID, Content: content, Status: status, PublishAt: publishAt, Visibility: visibility}
+	if err := tx.Create(&post).Error; err != nil {
 		return uuid.Nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create post"}
 	}
 	return post.ID, nil
 }
 
 // SaveCategoriesToPost is the function will save post categories to mysql
-func SaveCategoriesToPost(categoryIDs []uuid.UUID, postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
+func SaveCategoriesToPost(tx *gorm.DB, categoryIDs []uuid.UUID, postID uuid.UUID) *utils.ServiceError {
 	var postCategories []posts.PostCategory
 
 	for _, categoryID := range categoryIDs {
@@ -35,7 +35,7 @@ func SaveCategoriesToPost(categoryIDs []uuid.UUID, postID uuid.UUID) *utils.Serv
 	}
 
 	if len(postCategories) > 0 {
-		if err := db.Create(&postCategories).Error; err != nil {
+		if err := tx.Create(&postCategories).Error; err != nil {
 			return &utils.ServiceError{
 				StatusCode: http.StatusInternalServerError,
 				Message:    "Failed to associate categories",
@@ -46,11 +46,11 @@ func SaveCategoriesToPost(categoryIDs []uuid.UUID, postID uuid.UUID) *utils.Serv
 }
 
 // SaveMediaRecords is the function will save from media path into mysql
-func SaveMediaRecords(savedMedia []posts.Media, postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
+func SaveMediaRecords(tx *gorm.DB, savedMedia []posts.Media, postID uuid.UUID) *utils.ServiceError {
 	for _, media := range savedMedia {
 		media.PostID = postID
-		if err := db.Create(&media).Error; err != nil {
+		media.ProcessingStatus = MediaProcessingStatus(media.Type)
+		if err := tx.Create(&media).Error; err != nil {
 			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save media"}
 		}
 	}
@@ -71,10 +71,8 @@ func DeletePostRecord(postID uint) *utils.ServiceError {
 }
 
 // DeleteMediaRecords is the function to delete media records following to the post
-func DeleteMediaRecords(postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
-
-	if err := db.Where("post_id = ?", postID).Delete(&posts.Media{}).Error; err != nil {
+func DeleteMediaRecords(tx *gorm.DB, postID uuid.UUID) *utils.ServiceError {
+	if err := tx.Where("post_id = ?", postID).Delete(&posts.Media{}).Error; err != nil {
 		return &utils.ServiceError{
 			StatusCode: http.StatusInternalServerError,
 			Message:    "Failed to remove old media records",
@@ -84,9 +82,8 @@ func DeleteMediaRecords(postID uuid.UUID) *utils.ServiceError {
 }
 
 // DeleteCategoryRecords is the function to delete categories following to the post
-func DeleteCategoryRecords(postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
-	if err := db.Where("post_id = ?", postID).Delete(&posts.PostCategory{}).Error; err != nil {
+func DeleteCategoryRecords(tx *gorm.DB, postID uuid.UUID) *utils.ServiceError {
+	if err := tx.Where("post_id = ?", postID).Delete(&posts.PostCategory{}).Error; err != nil {
 		return &utils.ServiceError{
 			StatusCode: http.StatusInternalServerError,
 			Message:    "Failed to remove old category associations",
@@ -96,9 +93,8 @@ func DeleteCategoryRecords(postID uuid.UUID) *utils.ServiceError {
 }
 
 // UpdatePostContent is the function to update the content of post by given postID
-func UpdatePostContent(content string, postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
-	if err := db.Model(&posts.Post{}).Where("id = ?", postID).Updates(posts.Post{
+func UpdatePostContent(tx *gorm.DB, content string, postID uuid.UUID) *utils.ServiceError {
+	if err := tx.Model(&posts.Post{}).Where("id = ?", postID).Updates(posts.Post{
 		Content: content,
 	}).Error; err != nil {
 		return &utils.ServiceError{
@@ -109,20 +105,12 @@ func UpdatePostContent(content string, postID uuid.UUID) *utils.ServiceError {
 	return nil
 }
 
-// CheckPostExits is the function will check post was valid on mysql or not
-func CheckPostExits(postID uuid.UUID) *utils.ServiceError {
-	db := mysql.DB
-	// Check if Post Exists
-	var exists bool
-	if err := db.Model(&posts.Post{}).
-		Select("count(*) > 0").
-		Where("id = ?", postID).
-		Find(&exists).Error; err != nil {
-
-		return &utils.ServiceError{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to check post existence",
-		}
+// CheckPostExits is the function will check post was valid on mysql and seen by the viewer or not
+func CheckPostExits(postID, viewerID uuid.UUID) *utils.ServiceError {
+	// Check if Post Exists; a post the viewer may not see does not exist for them
+	exists, serviceErr := CanViewPost(mysql.DB, viewerID, postID)
+	if serviceErr != nil {
+		return serviceErr
 	}
 
 	// If the post does not exist, return an error
@@ -135,20 +123,20 @@ func CheckPostExits(postID uuid.UUID) *utils.ServiceError {
 	return nil
 }
 
-// SelectPosts is the function will execute the sql queries with given parameters and return rows
-func SelectPosts(searchValue, orderBy, sortBy string, offset, limit int) (*sql.Rows, *utils.ServiceError) {
-	rows, err := mysql.DB.Table("posts").
-		Select(`
-			posts.id, posts.content, posts.created_at, posts.updated_at,
-			users.id AS user_id, users.username AS username, profiles.profile_pic,
-			GROUP_CONCAT(DISTINCT categories.id ORDER BY categories.id ASC SEPARATOR ',') AS category_ids,
-			GROUP_CONCAT(DISTINCT categories.name ORDER BY categories.id ASC SEPARATOR ',') AS category_names,
-			media.id AS media_id, media.path, media.type, media.status,
-			COUNT(DISTINCT comments.id) AS comment_count,
-			COUNT(DISTINCT post_likes.id) AS likes_count,
-			COUNT(DISTINCT post_shares.id) AS shares_count,
-			COUNT(posts.id) OVER() AS total_count
-		`).
+// postListColumns are the columns List scans for every row of a post list, one row per post and media
+const postListColumns = `
+	posts.id, posts.content, posts.created_at, posts.updated_at, posts.shared_post_id,
+	users.id AS user_id, users.username AS username, profiles.profile_pic,
+	GROUP_CONCAT(DISTINCT categories.id ORDER BY categories.id ASC SEPARATOR ',') AS category_ids,
+	GROUP_CONCAT(DISTINCT categories.name ORDER BY categories.id ASC SEPARATOR ',') AS category_names,
+	media.id AS media_id, media.path, media.type, media.status,
+	media.width, media.height, media.blurhash, media.processing_status,
+	COUNT(DISTINCT comments.id) AS comment_count,
+	COUNT(DISTINCT post_likes.id) AS likes_count,
+	COUNT(DISTINCT post_shares.id) AS shares_count`
+
+func postListQuery() *gorm.DB {
+	return mysql.DB.Table("posts").
 		Joins(`
 			LEFT JOIN post_categories ON post_categories.post_id = posts.id
 			LEFT JOIN categories ON categories.id = post_categories.category_id
@@ -159,8 +147,17 @@ func SelectPosts(searchValue, orderBy, sortBy string, offset, limit int) (*sql.R
 			LEFT JOIN post_likes ON post_likes.post_id = posts.id
 			LEFT JOIN post_shares ON post_shares.post_id = posts.id
 		`).
-		Where("LOWER(posts.content) LIKE LOWER(?)", "%"+searchValue+"%").
-		Group("posts.id, users.id, users.username, profiles.profile_pic, media.id").
+		Group("posts.id, users.id, users.username, profiles.profile_pic, media.id")
+}
+
+// SelectPosts is the function will execute the sql queries with given parameters and return rows.
+// matchIDs restricts the list to the posts a search matched; nil lists every post the viewer sees.
+func SelectPosts(matchIDs []uuid.UUID, viewerID uuid.UUID, orderBy, sortBy string, offset, limit int) (*sql.Rows, *utils.ServiceError) {
+	rows, err := VisiblePosts(matchingPosts(postListQuery(), matchIDs), viewerID).
+		Select(postListColumns + `,
+			COUNT(posts.id) OVER() AS total_count
+		`).
+		Where("posts.deleted_at IS NULL AND posts.status = 'published'").
 		Order(fmt.Sprintf("posts.%s %s", orderBy, sortBy)).
 		Offset(offset).
 		Limit(limit).
@@ -174,18 +171,85 @@ func SelectPosts(searchValue, orderBy, sortBy string, offset, limit int) (*sql.R
 	return rows, nil
 }
 
-// ListNotifications returns a row query for list of notifications
-func ListNotifications(searchValue, orderBy, sortBy string, offset, limit int, isSeen bool, receiverID uuid.UUID, all bool) (*sql.Rows, *utils.ServiceError) {
-	query := mysql.DB.Table("notifications").
-		Select(`*,
-            COUNT(notifications.id) OVER() AS total_count
-        `).
-		Where("LOWER(notifications.message) LIKE LOWER(?) AND receiver_id = ?", "%"+searchValue+"%", receiverID)
+// SelectPostPage returns the rows of a page of posts, newest first, in the columns of SelectPosts.
+// The page is picked on the posts alone, so posts with several media count once. total_count is always 0.
+func SelectPostPage(matchIDs []uuid.UUID, viewerID uuid.UUID, page utils.CursorPage) (*sql.Rows, bool, *utils.ServiceError) {
+	return selectPostKeyPage(matchingPosts(mysql.DB.Table("posts"), matchIDs), viewerID, page)
+}
 
-	// Only add is_seen filter if all=false
-	if !all {
-		query = query.Where("is_seen = ?", isSeen)
+// selectPostKeyPage picks a page of the posts query selects from that the viewer sees, then returns their rows
+// like SelectPostPage
+func selectPostKeyPage(query *gorm.DB, viewerID uuid.UUID, page utils.CursorPage) (*sql.Rows, bool, *utils.ServiceError) {
+	var keys []struct {
+		ID        uuid.UUID
+		CreatedAt time.Time
+	}
+	if err := KeysetPage(VisiblePosts(query.Select("posts.id, posts.created_at"), viewerID), "posts", page, false).
+		Where("posts.deleted_at IS NULL AND posts.status = 'published'").
+		Scan(&keys).Error; err != nil {
+		return nil, false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	keys, hasMore := utils.TrimCursorPage(keys, page)
+
+	postIDs := make([]uuid.UUID, len(keys))
+	for i, key := range keys {
+		postIDs[i] = key.ID
+	}
+	rows, serviceErr := SelectPostsByIDs(postIDs, viewerID)
+	if serviceErr != nil {
+		return nil, false, serviceErr
 	}
+	return rows, hasMore, nil
+}
+
+func matchingPosts(query *gorm.DB, matchIDs []uuid.UUID) *gorm.DB {
+	if matchIDs == nil {
+		return query
+	}
+	return query.Where("posts.id IN ?", matchIDs)
+}
+
+// SelectPostsByIDs returns the rows of the posts that are not deleted and the viewer sees, newest first, in the
+// columns of SelectPosts. total_count is always 0.
+func SelectPostsByIDs(postIDs []uuid.UUID, viewerID uuid.UUID) (*sql.Rows, *utils.ServiceError) {
+	rows, err := VisiblePosts(postListQuery(), viewerID).
+		Select(postListColumns+`,
+			0 AS total_count
+		`).
+		Where("posts.id IN ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postIDs).
+		Order("posts.created_at DESC, posts.id DESC").
+		Rows()
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return rows, nil
+}
+
+// KeysetPage restricts query to the page of rows after page.Cursor in (created_at, id) order, newest first unless
+// ascending. Rows come in the direction of the page with one extra row; pass them through utils.TrimCursorPage.
+func KeysetPage(query *gorm.DB, table string, page utils.CursorPage, ascending bool) *gorm.DB {
+	// A backward page walks the list in reverse from its cursor
+	op, direction := "<", "DESC"
+	if ascending != page.Backward() {
+		op, direction = ">", "ASC"
+	}
+	if page.Cursor != nil {
+		query = query.Where(
+			fmt.Sprintf("(%[1]s.created_at %[2]s ? OR (%[1]s.created_at = ? AND %[1]s.id %[2]s ?))", table, op),
+			page.Cursor.CreatedAt, page.Cursor.CreatedAt, page.Cursor.ID,
+		)
+	}
+	return query.
+		Order(fmt.Sprintf("%[1]s.created_at %[2]s, %[1]s.id %[2]s", table, direction)).
+		Limit(page.Limit + 1)
+}
+
+// ListNotifications returns a row query for list of notifications
+func ListNotifications(searchValue, orderBy, sortBy string, offset, limit int, isSeen bool, receiverID uuid.UUID, all bool) (*sql.Rows, *utils.ServiceError) {
+	query := notificationListQuery(searchValue, isSeen, receiverID, all).
+		Select(notificationListColumns + `,
+			COUNT(notifications.id) OVER() AS total_count
+		`)
 
 	rows, err := query.
 		Order(fmt.Sprintf("notifications.%s %s", orderBy, sortBy)).
@@ -201,3 +265,33 @@ func ListNotifications(searchValue, orderBy, sortBy string, offset, limit int, i
 	}
 	return rows, nil
 }
+
+// ListNotificationPage is ListNotifications for a keyset page, newest first. total_count is always 0.
+// Rows come in the direction of the page with one extra row; pass them through utils.TrimCursorPage.
+func ListNotificationPage(searchValue string, page utils.CursorPage, isSeen bool, receiverID uuid.UUID, all bool) (*sql.Rows, *utils.ServiceError) {
+	query := notificationListQuery(searchValue, isSeen, receiverID, all).
+		Select(notificationListColumns + `,
+			0 AS total_count
+		`)
+
+	rows, err := KeysetPage(query, "notifications", page, false).Rows()
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return rows, nil
+}
+
+const notificationListColumns = `
+	notifications.id, notifications.sender_id, notifications.receiver_id, notifications.message,
+	notifications.created_at, notifications.updated_at, notifications.is_seen, notifications.noti_type`
+
+func notificationListQuery(searchValue string, isSeen bool, receiverID uuid.UUID, all bool) *gorm.DB {
+	query := mysql.DB.Table("notifications").
+		Where("LOWER(notifications.message) LIKE LOWER(?) AND receiver_id = ?", "%"+searchValue+"%", receiverID)
+
+	// Only add is_seen filter if all=false
+	if !all {
+		query = query.Where("is_seen = ?", isSeen)
+	}
+	return query
+}
@@ -0,0 +1,31 @@
+package functions
+
+import (
+	"net/http"
+
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// SelectPresenceVisible keeps the users whose presence viewerID may watch: their friends and the users they follow
+func SelectPresenceVisible(viewerID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, *utils.ServiceError) {
+	visible := []uuid.UUID{}
+	if len(userIDs) == 0 {
+		return visible, nil
+	}
+	if err := mysql.DB.Table("users").
+		Where("users.id IN ? AND users.id <> ?", userIDs, viewerID).
+		Where(`
+			EXISTS (SELECT 1 FROM follows WHERE follows.follower_id = ? AND follows.following_id = users.id)
+			OR EXISTS (
+				SELECT 1 FROM friends WHERE friends.status = TRUE
+					AND ((friends.user_id = ? AND friends.friend_to = users.id) OR (friends.user_id = users.id AND friends.friend_to = ?))
+			)
+		`, viewerID, viewerID, viewerID).
+		Pluck("users.id", &visible).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check presence visibility"}
+	}
+	return visible, nil
+}
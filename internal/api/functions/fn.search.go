@@ -0,0 +1,120 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
+	kafkaClient "github.com/unarya/univia/internal/infrastructure/kafka"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchChange names a document whose row changed. The indexer reloads the document rather than trusting
+// the event, so changes applied out of order still leave the index up to date.
+type SearchChange struct {
+	Kind string    `json:"kind"`
+	ID   uuid.UUID `json:"id"`
+}
+
+// QueueSearchChange tells the search indexer, once tx commits, that the document of kind and id changed.
+// eventType is one of the outbox.Event* types.
+func QueueSearchChange(tx *gorm.DB, kind string, id uuid.UUID, eventType string) *utils.ServiceError {
+	if err := outbox.Enqueue(tx, outbox.Event{
+		Topic:    kafkaClient.SearchTopic,
+		Key:      id.String(),
+		DedupKey: fmt.Sprintf("search:%s:%s:%s", kind, id, uuid.New()),
+		Type:     eventType,
+		Payload:  SearchChange{Kind: kind, ID: id},
+	}); err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return nil
+}
+
+// searchTables are the tables the documents of each kind come from
+var searchTables = map[string]string{
+	search.KindPost:     "posts",
+	search.KindUser:     "users",
+	search.KindCategory: "categories",
+}
+
+// searchDocumentQueries select the searchable rows of each kind as kind, id, title, body and created_at
+var searchDocumentQueries = map[string]func() *gorm.DB{
+	search.KindPost: func() *gorm.DB {
+		return mysql.DB.Table("posts").
+			Select("'post' AS kind, posts.id, '' AS title, posts.content AS body, posts.created_at").
+			Where("posts.deleted_at IS NULL AND posts.status = 'published'")
+	},
+	search.KindUser: func() *gorm.DB {
+		return mysql.DB.Table("users").
+			Select("'user' AS kind, users.id, users.username AS title, profiles.bio AS body, users.created_at").
+			Joins("LEFT JOIN profiles ON profiles.user_id = users.id").
+			Where("users.status = true")
+	},
+	search.KindCategory: func() *gorm.DB {
+		return mysql.DB.Table("categories").
+			Select("'category' AS kind, categories.id, categories.name AS title, '' AS body, categories.created_at")
+	},
+}
+
+type searchDocumentRow struct {
+	Kind      string
+	ID        uuid.UUID
+	Title     *string
+	Body      *string
+	CreatedAt time.Time
+}
+
+func (row searchDocumentRow) document() search.Document {
+	doc := search.Document{Kind: row.Kind, ID: row.ID, CreatedAt: row.CreatedAt}
+	if row.Title != nil {
+		doc.Title = *row.Title
+	}
+	if row.Body != nil {
+		doc.Body = *row.Body
+	}
+	return doc
+}
+
+// LoadSearchDocument returns what the index keeps of a document, or nil when it is gone or hidden
+func LoadSearchDocument(kind string, id uuid.UUID) (*search.Document, *utils.ServiceError) {
+	query, ok := searchDocumentQueries[kind]
+	if !ok {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Unknown search kind %q", kind)}
+	}
+	var row searchDocumentRow
+	err := query().Where(fmt.Sprintf("%s.id = ?", searchTables[kind]), id).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	doc := row.document()
+	return &doc, nil
+}
+
+// SelectSearchDocuments returns the next batch of searchable documents of kind after the id afterID, in id order
+func SelectSearchDocuments(kind string, afterID uuid.UUID, limit int) ([]search.Document, *utils.ServiceError) {
+	table := searchTables[kind]
+	var rows []searchDocumentRow
+	if err := searchDocumentQueries[kind]().
+		Where(fmt.Sprintf("%s.id > ?", table), afterID).
+		Order(fmt.Sprintf("%s.id ASC", table)).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	docs := make([]search.Document, len(rows))
+	for i, row := range rows {
+		docs[i] = row.document()
+	}
+	return docs, nil
+}
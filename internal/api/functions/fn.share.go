@@ -0,0 +1,142 @@
+package functions
+
+import (
+	"net/http"
+	"time"
+
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SharedPostRow is the summary of a shared or quoted post embedded in listings
+type SharedPostRow struct {
+	ID         uuid.UUID
+	Content    string
+	UserID     uuid.UUID
+	Username   string
+	ProfilePic string
+	CreatedAt  time.Time
+}
+
+// ShareFeedRow is one share made by someone the viewer follows
+type ShareFeedRow struct {
+	ShareID          uuid.UUID
+	SharedAt         time.Time
+	SharerID         uuid.UUID
+	SharerUsername   string
+	SharerProfilePic string
+	QuotePostID      uuid.NullUUID
+	QuoteContent     string
+	PostID           uuid.UUID
+	PostContent      string
+	PostCreatedAt    time.Time
+	AuthorID         uuid.UUID
+	AuthorUsername   string
+	AuthorProfilePic string
+	SharesCount      int64
+	LikesCount       int64
+	CommentsCount    int64
+	TotalCount       int64
+}
+
+// CheckIsShared reports whether the user has a plain share of the post
+func CheckIsShared(tx *gorm.DB, userID, postID uuid.UUID) (bool, *utils.ServiceError) {
+	var shared bool
+	if err := tx.Model(&posts.PostShare{}).
+		Select("count(*) > 0").
+		Where("user_id = ? AND post_id = ? AND quote_post_id IS NULL", userID, postID).
+		Find(&shared).Error; err != nil {
+		return false, &utils.ServiceError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Database error while checking share status",
+		}
+	}
+	return shared, nil
+}
+
+// CountShares counts plain shares and quote reposts of the post
+func CountShares(tx *gorm.DB, postID uuid.UUID) (int64, error) {
+	var counts int64
+	err := tx.Model(&posts.PostShare{}).Where("post_id = ?", postID).Count(&counts).Error
+	return counts, err
+}
+
+// SelectSharedPostSummaries loads the posts quoted by a listing that the viewer sees, keyed by id
+func SelectSharedPostSummaries(postIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]gin.H, *utils.ServiceError) {
+	summaries := make(map[uuid.UUID]gin.H, len(postIDs))
+	if len(postIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []SharedPostRow
+	if err := VisiblePosts(mysql.DB.Table("posts"), viewerID).
+		Select(`
+			posts.id, COALESCE(posts.content, '') AS content, posts.created_at,
+			users.id AS user_id, users.username AS username, COALESCE(profiles.profile_pic, '') AS profile_pic
+		`).
+		Joins(`
+			LEFT JOIN users ON users.id = posts.user_id
+			LEFT JOIN profiles ON profiles.user_id = users.id
+		`).
+		Where("posts.id IN ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to load shared posts"}
+	}
+	for _, row := range rows {
+		summaries[row.ID] = SharedPostSummary(row)
+	}
+	return summaries, nil
+}
+
+func SharedPostSummary(row SharedPostRow) gin.H {
+	return gin.H{
+		"id":         row.ID,
+		"content":    row.Content,
+		"created_at": row.CreatedAt,
+		"user":       gin.H{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+	}
+}
+
+// SelectFollowingShares returns the shares made by the users the viewer follows, newest first. Shares of posts
+// the viewer does not see, or with a quote they do not see, are left out.
+func SelectFollowingShares(viewerID uuid.UUID, offset, limit int) ([]ShareFeedRow, *utils.ServiceError) {
+	var rows []ShareFeedRow
+	if err := mysql.DB.Table("post_shares").
+		Select(`
+			post_shares.id AS share_id, post_shares.created_at AS shared_at,
+			sharers.id AS sharer_id, sharers.username AS sharer_username,
+			COALESCE(sharer_profiles.profile_pic, '') AS sharer_profile_pic,
+			post_shares.quote_post_id, COALESCE(quotes.content, '') AS quote_content,
+			posts.id AS post_id, COALESCE(posts.content, '') AS post_content, posts.created_at AS post_created_at,
+			authors.id AS author_id, authors.username AS author_username,
+			COALESCE(author_profiles.profile_pic, '') AS author_profile_pic,
+			(SELECT COUNT(*) FROM post_shares s WHERE s.post_id = posts.id) AS shares_count,
+			(SELECT COUNT(*) FROM post_likes WHERE post_likes.post_id = posts.id) AS likes_count,
+			(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id) AS comments_count,
+			COUNT(post_shares.id) OVER() AS total_count
+		`).
+		Joins(`
+			JOIN follows ON follows.following_id = post_shares.user_id AND follows.follower_id = ?
+			JOIN posts ON posts.id = post_shares.post_id
+			LEFT JOIN posts quotes ON quotes.id = post_shares.quote_post_id
+			LEFT JOIN users sharers ON sharers.id = post_shares.user_id
+			LEFT JOIN profiles sharer_profiles ON sharer_profiles.user_id = sharers.id
+			LEFT JOIN users authors ON authors.id = posts.user_id
+			LEFT JOIN profiles author_profiles ON author_profiles.user_id = authors.id
+		`, viewerID).
+		Where("posts.deleted_at IS NULL AND posts.status = 'published' AND (post_shares.quote_post_id IS NULL OR (quotes.deleted_at IS NULL AND quotes.status = 'published'))").
+		Where(PostVisibleSQL("posts")+" AND (post_shares.quote_post_id IS NULL OR "+PostVisibleSQL("quotes")+")",
+			map[string]interface{}{"viewer": viewerID}).
+		Order("post_shares.created_at DESC, post_shares.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to list shares"}
+	}
+	return rows, nil
+}
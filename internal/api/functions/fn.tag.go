@@ -0,0 +1,195 @@
+package functions
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TrendingTagRow is a tag used in a time window. Uses and Authors count the window, PreviousUses the window before.
+type TrendingTagRow struct {
+	Name         string
+	Uses         int64
+	PreviousUses int64
+	Authors      int64
+}
+
+// SaveContentEntities replaces the hashtags and mentions stored for a post, or for one of its comments when
+// commentID is set, with those of text. Entities still in the text keep their rows, so an edit does not count
+// as a new use. It returns the users mentioned for the first time, never the author.
+func SaveContentEntities(tx *gorm.DB, postID uuid.UUID, commentID *uuid.UUID, authorID uuid.UUID, text string) ([]uuid.UUID, *utils.ServiceError) {
+	if serviceErr := saveTags(tx, postID, commentID, utils.ParseHashtags(text)); serviceErr != nil {
+		return nil, serviceErr
+	}
+	return saveMentions(tx, postID, commentID, authorID, utils.ParseMentions(text))
+}
+
+// entitySource restricts query to the rows of the post itself, or of one of its comments
+func entitySource(query *gorm.DB, postID uuid.UUID, commentID *uuid.UUID) *gorm.DB {
+	if commentID == nil {
+		return query.Where("post_id = ? AND comment_id IS NULL", postID)
+	}
+	return query.Where("post_id = ? AND comment_id = ?", postID, *commentID)
+}
+
+func saveTags(tx *gorm.DB, postID uuid.UUID, commentID *uuid.UUID, names []string) *utils.ServiceError {
+	tagIDs := make(map[uuid.UUID]bool)
+	if len(names) > 0 {
+		tags := make([]posts.Tag, len(names))
+		for i, name := range names {
+			tags[i] = posts.Tag{ID: uuid.New(), Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save tags"}
+		}
+		var ids []uuid.UUID
+		if err := tx.Model(&posts.Tag{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save tags"}
+		}
+		for _, id := range ids {
+			tagIDs[id] = true
+		}
+	}
+
+	var existing []posts.PostTag
+	if err := entitySource(tx.Model(&posts.PostTag{}), postID, commentID).Find(&existing).Error; err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save tags"}
+	}
+	var removed []uuid.UUID
+	for _, row := range existing {
+		if tagIDs[row.TagID] {
+			delete(tagIDs, row.TagID)
+			continue
+		}
+		removed = append(removed, row.ID)
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("id IN ?", removed).Delete(&posts.PostTag{}).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save tags"}
+		}
+	}
+
+	added := make([]posts.PostTag, 0, len(tagIDs))
+	for tagID := range tagIDs {
+		added = append(added, posts.PostTag{ID: uuid.New(), TagID: tagID, PostID: postID, CommentID: commentID})
+	}
+	if len(added) > 0 {
+		if err := tx.Create(&added).Error; err != nil {
+			return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save tags"}
+		}
+	}
+	return nil
+}
+
+func saveMentions(tx *gorm.DB, postID uuid.UUID, commentID *uuid.UUID, authorID uuid.UUID, usernames []string) ([]uuid.UUID, *utils.ServiceError) {
+	// Usernames are not unique; a mention reaches every active user of that name
+	mentioned := make(map[uuid.UUID]bool)
+	if len(usernames) > 0 {
+		var ids []uuid.UUID
+		if err := tx.Model(&Users.User{}).
+			Where("username IN ? AND status = true", usernames).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save mentions"}
+		}
+		for _, id := range ids {
+			if id != authorID {
+				mentioned[id] = true
+			}
+		}
+	}
+
+	var existing []posts.Mention
+	if err := entitySource(tx.Model(&posts.Mention{}), postID, commentID).Find(&existing).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save mentions"}
+	}
+	var removed []uuid.UUID
+	for _, row := range existing {
+		if mentioned[row.UserID] {
+			delete(mentioned, row.UserID)
+			continue
+		}
+		removed = append(removed, row.ID)
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("id IN ?", removed).Delete(&posts.Mention{}).Error; err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save mentions"}
+		}
+	}
+
+	added := make([]posts.Mention, 0, len(mentioned))
+	newlyMentioned := make([]uuid.UUID, 0, len(mentioned))
+	for userID := range mentioned {
+		added = append(added, posts.Mention{ID: uuid.New(), PostID: postID, CommentID: commentID, UserID: userID, AuthorID: authorID})
+		newlyMentioned = append(newlyMentioned, userID)
+	}
+	if len(added) > 0 {
+		if err := tx.Create(&added).Error; err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save mentions"}
+		}
+	}
+	return newlyMentioned, nil
+}
+
+// GetTag loads a tag by its normalized name, with the number of posts the viewer sees that use it
+func GetTag(name string, viewerID uuid.UUID) (*posts.Tag, int64, *utils.ServiceError) {
+	var tag posts.Tag
+	err := mysql.DB.Where("name = ?", name).First(&tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Tag not found"}
+	}
+	if err != nil {
+		return nil, 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	var count int64
+	if err := VisiblePosts(mysql.DB.Table("post_tags").
+		Joins("JOIN posts ON posts.id = post_tags.post_id").
+		Where("post_tags.tag_id = ? AND post_tags.comment_id IS NULL AND posts.deleted_at IS NULL AND posts.status = 'published'", tag.ID), viewerID).
+		Count(&count).Error; err != nil {
+		return nil, 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return &tag, count, nil
+}
+
+// SelectTagPostPage returns the rows of a page of the posts the viewer sees whose content uses the tag,
+// newest first, in the columns of SelectPosts
+func SelectTagPostPage(tagID, viewerID uuid.UUID, page utils.CursorPage) (*sql.Rows, bool, *utils.ServiceError) {
+	return selectPostKeyPage(mysql.DB.Table("posts").
+		Joins("JOIN post_tags ON post_tags.post_id = posts.id AND post_tags.comment_id IS NULL").
+		Where("post_tags.tag_id = ?", tagID), viewerID, page)
+}
+
+// SelectTrendingTags ranks the tags used in public posts and their comments during the window that ends at now.
+// A tag ranks by how many people used it, boosted by how much its use grew since the window before.
+func SelectTrendingTags(window time.Duration, now time.Time, limit int) ([]TrendingTagRow, *utils.ServiceError) {
+	start := now.Add(-window)
+	var rows []TrendingTagRow
+	if err := mysql.DB.Table("post_tags").
+		Select(`tags.name,
+			SUM(post_tags.created_at >= @start) AS uses,
+			SUM(post_tags.created_at < @start) AS previous_uses,
+			COUNT(DISTINCT CASE WHEN post_tags.created_at >= @start THEN posts.user_id END) AS authors`,
+			map[string]interface{}{"start": start}).
+		Joins("JOIN tags ON tags.id = post_tags.tag_id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = 'published'").
+		Where("post_tags.created_at >= ? AND post_tags.created_at <= ?", start.Add(-window), now).
+		Where(publicPostCondition).
+		Group("tags.id, tags.name").
+		Having("uses > 0").
+		Order("authors * (uses + 1) / (previous_uses + 1) DESC, uses DESC, tags.name ASC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return rows, nil
+}
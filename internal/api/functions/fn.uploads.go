@@ -1,85 +1,420 @@
 package functions
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/antivirus"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/storage"
 	"github.com/unarya/univia/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// Allowed Media Types
-var allowedMediaTypes = map[string]bool{
-	"image/jpeg": true,
-	"image/png":  true,
-	"image/gif":  true,
-	"image/webp": true,
-	"video/mp4":  true,
-	"video/avi":  true,
-	"video/mov":  true,
+// legacyUploadDir holds media saved on the API's disk before the storage backends existed
+const legacyUploadDir = "uploads/"
+
+// StoredFileClaimTTL is how long a claim on a stored file holds if its upload dies before releasing it
+const StoredFileClaimTTL = time.Hour
+
+// mediaTypes are the media posts accept, by the type detected from the file content
+var mediaTypes = map[string]struct {
+	Extension string
+	MaxSize   int64
+}{
+	"image/jpeg": {Extension: ".jpg", MaxSize: 20 << 20},
+	"image/png":  {Extension: ".png", MaxSize: 20 << 20},
+	"image/gif":  {Extension: ".gif", MaxSize: 15 << 20},
+	"image/webp": {Extension: ".webp", MaxSize: 20 << 20},
+	"video/mp4":  {Extension: ".mp4", MaxSize: 2 << 30},
+	"video/avi":  {Extension: ".avi", MaxSize: 2 << 30},
+	"video/mov":  {Extension: ".mov", MaxSize: 2 << 30},
 }
 
-func SaveMediaToServer(files []*multipart.FileHeader) ([]posts.Media, *utils.ServiceError) {
-	// Handle file uploads
-	var savedMedia []posts.Media
+const InvalidMediaTypeMessage = "Invalid file format. Allowed: JPEG, PNG, GIF, WEBP, MP4, AVI, MOV"
 
-	for _, file := range files {
-		fileType := file.Header.Get("Content-Type")
+// sniffLen is how much of a file content type detection looks at
+const sniffLen = 512
 
-		// Validate file type
-		if !allowedMediaTypes[fileType] {
-			return nil, &utils.ServiceError{
-				StatusCode: http.StatusBadRequest,
-				Message:    "Invalid file format. Allowed: JPEG, PNG, GIF, MP4, AVI, MOV",
+// IsAllowedMediaType reports whether posts accept media of contentType
+func IsAllowedMediaType(contentType string) bool {
+	_, ok := mediaTypes[contentType]
+	return ok
+}
+
+// MaxMediaSize returns the largest file accepted for contentType, 0 when the type is not accepted
+func MaxMediaSize(contentType string) int64 {
+	return mediaTypes[contentType].MaxSize
+}
+
+// MediaExtension returns the file extension media of contentType are stored with
+func MediaExtension(contentType string) string {
+	return mediaTypes[contentType].Extension
+}
+
+// DetectMediaType returns the type of a file from its first bytes, ignoring what the client claims it is
+func DetectMediaType(head []byte) string {
+	// ISO media files start with an ftyp box listing the brands they conform to
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		size := min(int(binary.BigEndian.Uint32(head)), len(head))
+		for i := 8; i+4 <= size; i += 4 {
+			// Bytes 12 to 16 hold the minor version, not a brand
+			if i == 12 {
+				continue
+			}
+			switch string(head[i : i+4]) {
+			case "qt  ":
+				return "video/mov"
+			case "isom", "iso2", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "M4V ", "dash":
+				return "video/mp4"
 			}
 		}
+	}
+	return strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0])
+}
 
-		// Generate unique filename
-		uniqueFileName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-		mediaPath := filepath.Join("uploads", uniqueFileName)
+// MediaKey returns the storage key of a file from the SHA-256 of its content, so identical files share one object
+func MediaKey(sum []byte, contentType string) string {
+	return fmt.Sprintf("posts/%s%s", hex.EncodeToString(sum), MediaExtension(contentType))
+}
 
-		// Save file
-		if err := SaveUploadedFile(file, mediaPath); err != nil {
-			return nil, &utils.ServiceError{
-				StatusCode: http.StatusInternalServerError,
-				Message:    "Failed to save file",
-			}
+// InspectedMedia is what InspectMedia learnt about a file
+type InspectedMedia struct {
+	ContentType string
+	Size        int64
+	Key         string
+}
+
+// InspectMedia reads a whole file: it detects its real type from its content, enforces the size limit of
+// that type, scans it for malware and returns the content-addressed key it should be stored under.
+func InspectMedia(ctx context.Context, r io.Reader) (InspectedMedia, *utils.ServiceError) {
+	reader := bufio.NewReaderSize(r, sniffLen)
+	head, err := reader.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to read file"}
+	}
+	if len(head) == 0 {
+		return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "File is empty"}
+	}
+	contentType := DetectMediaType(head)
+	if !IsAllowedMediaType(contentType) {
+		return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: InvalidMediaTypeMessage}
+	}
+
+	// One pass hashes the file, counts it and feeds the scanner, which reads it to the end
+	maxSize := MaxMediaSize(contentType)
+	hash := sha256.New()
+	counter := &byteCounter{}
+	content := io.TeeReader(io.LimitReader(reader, maxSize+1), io.MultiWriter(hash, counter))
+	if err := antivirus.Engine.Scan(ctx, content); err != nil {
+		var infected *antivirus.InfectedError
+		if errors.As(err, &infected) {
+			log.Printf("Rejected an upload: %v", err)
+			return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusUnprocessableEntity, Message: "File was rejected by the malware scan"}
+		}
+		return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to scan file"}
+	}
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to read file"}
+	}
+	if counter.n > maxSize {
+		return InspectedMedia{}, &utils.ServiceError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("%s files can be up to %d bytes", contentType, maxSize),
 		}
+	}
 
-		// Append to saved media list
-		savedMedia = append(savedMedia, posts.Media{Path: mediaPath, Type: fileType})
+	return InspectedMedia{ContentType: contentType, Size: counter.n, Key: MediaKey(hash.Sum(nil), contentType)}, nil
+}
+
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// StoreMedia inspects the files and uploads them to blob storage, returning their Media rows, not yet saved.
+// A file already stored by an earlier upload is not uploaded again. The files are claimed until the caller
+// releases them with ReleaseStoredMedia once the rows are committed or given up. If any file fails the new ones
+// are released and removed.
+func StoreMedia(ctx context.Context, files []*multipart.FileHeader) ([]posts.Media, *utils.ServiceError) {
+	var savedMedia []posts.Media
+
+	for _, file := range files {
+		media, err := storeUploadedFile(ctx, file)
+		if err != nil {
+			ReleaseStoredMedia(savedMedia)
+			DeleteStoredMedia(savedMedia)
+			return nil, err
+		}
+		savedMedia = append(savedMedia, media)
 	}
 
 	return savedMedia, nil
 }
 
-// SaveUploadedFile saves the uploaded file to disk
-func SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+func storeUploadedFile(ctx context.Context, file *multipart.FileHeader) (posts.Media, *utils.ServiceError) {
 	src, err := file.Open()
 	if err != nil {
-		return err
+		return posts.Media{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Failed to read file"}
 	}
 	defer src.Close()
 
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
+	inspected, serviceErr := InspectMedia(ctx, src)
+	if serviceErr != nil {
+		return posts.Media{}, serviceErr
 	}
-	defer out.Close()
+	media := posts.Media{Path: inspected.Key, Type: inspected.ContentType}
 
-	_, err = io.Copy(out, src)
-	return err
+	// Once claimed, an object already stored under the key cannot be deleted before the media row references it
+	if err := ClaimStoredFiles([]string{inspected.Key}); err != nil {
+		return posts.Media{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save file"}
+	}
+	if serviceErr := putContentAddressed(ctx, inspected, src); serviceErr != nil {
+		ReleaseStoredFiles([]string{inspected.Key})
+		return posts.Media{}, serviceErr
+	}
+	return media, nil
 }
 
-// DeleteUploadedFile deletes a file from disk
-func DeleteUploadedFile(dst string) error {
-	if err := os.Remove(dst); err != nil {
-		return err
+// putContentAddressed uploads src under its content-addressed key, unless the same file is stored there already
+func putContentAddressed(ctx context.Context, inspected InspectedMedia, src multipart.File) *utils.ServiceError {
+	_, statErr := storage.Blob.Stat(ctx, inspected.Key)
+	if statErr == nil {
+		return nil
+	}
+	if !errors.Is(statErr, storage.ErrNotFound) {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save file"}
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save file"}
+	}
+	if err := storage.Blob.Put(ctx, inspected.Key, src, inspected.Size, inspected.ContentType); err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to save file"}
 	}
 	return nil
 }
+
+// AttachUploadSessions consumes the user's completed upload sessions and returns their Media rows, not yet saved.
+// The Media rows take over the files, so the sessions are deleted; that is undone if tx rolls back.
+func AttachUploadSessions(tx *gorm.DB, userID uuid.UUID, uploadIDs []uuid.UUID) ([]posts.Media, *utils.ServiceError) {
+	if len(uploadIDs) == 0 {
+		return nil, nil
+	}
+
+	var sessions []posts.UploadSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND user_id = ? AND status = ? AND expires_at > ?", uploadIDs, userID, posts.UploadStatusCompleted, time.Now()).
+		Find(&sessions).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get uploads"}
+	}
+	if len(sessions) != len(uploadIDs) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Some uploads are not completed, expired or already attached"}
+	}
+	if err := tx.Delete(&posts.UploadSession{}, "id IN ?", uploadIDs).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to attach uploads"}
+	}
+
+	media := make([]posts.Media, len(sessions))
+	for i, session := range sessions {
+		media[i] = posts.Media{Path: session.ObjectKey, Type: session.ContentType}
+	}
+	return media, nil
+}
+
+// MediaProcessingStatus is the processing status a new media row of contentType starts with.
+// Images wait for the pipeline that strips their metadata and generates their variants.
+func MediaProcessingStatus(contentType string) string {
+	if strings.HasPrefix(contentType, "image/") {
+		return posts.MediaProcessingPending
+	}
+	return posts.MediaProcessingReady
+}
+
+// SelectMediaFilePaths returns the files of the media matching query, with the files of their variants.
+// The rows are locked so the processing pipeline cannot swap files under the caller before it deletes them.
+func SelectMediaFilePaths(tx *gorm.DB, query string, args ...interface{}) ([]string, *utils.ServiceError) {
+	var media []posts.Media
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "path").
+		Where(query, args...).
+		Find(&media).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to retrieve existing media"}
+	}
+	if len(media) == 0 {
+		return nil, nil
+	}
+
+	paths := make([]string, 0, len(media))
+	mediaIDs := make([]uuid.UUID, 0, len(media))
+	for _, m := range media {
+		paths = append(paths, m.Path)
+		mediaIDs = append(mediaIDs, m.ID)
+	}
+	var variantPaths []string
+	if err := tx.Model(&posts.MediaVariant{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("media_id IN ?", mediaIDs).
+		Pluck("path", &variantPaths).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to retrieve media variants"}
+	}
+	return append(paths, variantPaths...), nil
+}
+
+// ClaimStoredFiles keeps content-addressed files from being deleted while the caller writes or reuses them,
+// until the rows referencing them are committed. It waits for a delete of the same file in progress, so a
+// file found in storage after claiming it stays there. Every claim is released with ReleaseStoredFiles.
+func ClaimStoredFiles(paths []string) error {
+	paths = uniquePaths(paths)
+	if len(paths) == 0 {
+		return nil
+	}
+	now := time.Now()
+	// Paths are claimed in order so two uploads sharing files cannot deadlock
+	return mysql.DB.Transaction(func(tx *gorm.DB) error {
+		for _, path := range paths {
+			if err := tx.Exec(`
+				INSERT INTO stored_files (path, claims, claimed_until) VALUES (?, 1, ?)
+				ON DUPLICATE KEY UPDATE claims = IF(claimed_until > ?, claims + 1, 1), claimed_until = VALUES(claimed_until)
+			`, path, now.Add(StoredFileClaimTTL), now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseStoredFiles gives up the claims taken by ClaimStoredFiles, logging when it fails.
+// Claims that cannot be released lapse after StoredFileClaimTTL.
+func ReleaseStoredFiles(paths []string) {
+	paths = uniquePaths(paths)
+	if len(paths) == 0 {
+		return
+	}
+	if err := mysql.DB.Model(&posts.StoredFile{}).
+		Where("path IN ?", paths).
+		Update("claims", gorm.Expr("GREATEST(claims - 1, 0)")).Error; err != nil {
+		log.Printf("Failed to release media files, they are kept until their claims lapse: %v", err)
+	}
+}
+
+// ReleaseStoredMedia releases the files StoreMedia claimed for media
+func ReleaseStoredMedia(media []posts.Media) {
+	ReleaseStoredFiles(mediaPaths(media))
+}
+
+// DeleteStoredMedia removes the files of media from storage, logging the ones that fail.
+// Call it once the rows that referenced them are gone or were never saved.
+func DeleteStoredMedia(media []posts.Media) {
+	DeleteStoredFiles(mediaPaths(media))
+}
+
+// DeleteStoredFiles removes files from storage, logging the ones that fail. Files are content-addressed
+// and may be shared, so the ones still referenced by media, as their file or the upload they were processed
+// from, by variants or upload sessions, or claimed by an upload about to reference them, are kept.
+func DeleteStoredFiles(paths []string) {
+	for _, path := range uniquePaths(paths) {
+		if err := deleteUnusedFile(path); err != nil {
+			log.Printf("Failed to delete media file %s: %v", path, err)
+		}
+	}
+}
+
+// deleteUnusedFile removes a file nothing references or claims. Its stored_files row stays locked until the
+// object is gone, so an upload claiming the file meanwhile waits and then finds it missing.
+func deleteUnusedFile(path string) error {
+	return mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("INSERT IGNORE INTO stored_files (path) VALUES (?)", path).Error; err != nil {
+			return err
+		}
+		var file posts.StoredFile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("path = ?", path).Take(&file).Error; err != nil {
+			return err
+		}
+		if file.Claims > 0 && file.ClaimedUntil != nil && file.ClaimedUntil.After(time.Now()) {
+			return nil
+		}
+
+		var found []string
+		if err := tx.Raw(`
+			SELECT path FROM media WHERE path = ?
+			UNION SELECT source_path FROM media WHERE source_path = ?
+			UNION SELECT path FROM media_variants WHERE path = ?
+			UNION SELECT object_key FROM upload_sessions WHERE object_key = ?
+		`, path, path, path, path).Scan(&found).Error; err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			if err := DeleteStoredFile(path); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&posts.StoredFile{}, "path = ?", path).Error
+	})
+}
+
+func mediaPaths(media []posts.Media) []string {
+	paths := make([]string, len(media))
+	for i, m := range media {
+		paths[i] = m.Path
+	}
+	return paths
+}
+
+// uniquePaths returns paths sorted and without duplicates, the order files are locked in
+func uniquePaths(paths []string) []string {
+	unique := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// DeleteStoredFile removes an uploaded file from blob storage, or from the API's disk for legacy uploads.
+// A file that is already gone is not an error.
+func DeleteStoredFile(path string) error {
+	if strings.HasPrefix(path, legacyUploadDir) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return storage.Blob.Delete(context.Background(), path)
+}
+
+// MediaURL returns a presigned URL to read the media at path, or "" when it cannot be signed
+func MediaURL(ctx context.Context, path string) string {
+	if strings.HasPrefix(path, legacyUploadDir) || storage.Blob == nil {
+		return ""
+	}
+	signed, err := storage.Blob.PresignGet(ctx, path, storage.PresignExpiry)
+	if err != nil {
+		log.Printf("Failed to presign media %s: %v", path, err)
+		return ""
+	}
+	return signed
+}
@@ -0,0 +1,133 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/storage"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// storedFilesFixture stores files in a local backend and mocks the database that records their references
+type storedFilesFixture struct {
+	mock sqlmock.Sqlmock
+	dir  string
+}
+
+func newStoredFilesFixture(t *testing.T, keys ...string) *storedFilesFixture {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	gormDB, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: db, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	previousDB, previousBlob := mysql.DB, storage.Blob
+	t.Cleanup(func() {
+		mysql.DB, storage.Blob = previousDB, previousBlob
+		_ = db.Close()
+	})
+	mysql.DB = gormDB
+
+	dir := t.TempDir()
+	t.Setenv("STORAGE_LOCAL_DIR", dir)
+	t.Setenv("STORAGE_SIGNING_KEY", "test")
+	if storage.Blob, err = storage.NewLocalStorage(); err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	for _, key := range keys {
+		if err := storage.Blob.Put(context.Background(), key, strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+			t.Fatalf("storing %s: %v", key, err)
+		}
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("queries: %v", err)
+		}
+	})
+	return &storedFilesFixture{mock: mock, dir: dir}
+}
+
+// expectLock expects the stored_files row of path to be created if missing and locked
+func (f *storedFilesFixture) expectLock(path string, claimed bool) {
+	f.mock.ExpectBegin()
+	f.mock.ExpectExec("INSERT IGNORE INTO stored_files").
+		WithArgs(path).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	rows := sqlmock.NewRows([]string{"path", "claims", "claimed_until"})
+	if claimed {
+		rows.AddRow(path, 1, time.Now().Add(time.Minute))
+	} else {
+		rows.AddRow(path, 0, nil)
+	}
+	f.mock.ExpectQuery("SELECT \\* FROM `stored_files` WHERE path = \\? LIMIT \\? FOR UPDATE").
+		WithArgs(path, 1).
+		WillReturnRows(rows)
+}
+
+// expectReferences expects the lookup of what still references path, returning references rows
+func (f *storedFilesFixture) expectReferences(path string, references int) {
+	rows := sqlmock.NewRows([]string{"path"})
+	for i := 0; i < references; i++ {
+		rows.AddRow(path)
+	}
+	f.mock.ExpectQuery("SELECT path FROM media WHERE path = \\?\\s+UNION SELECT source_path FROM media WHERE source_path = \\?").
+		WithArgs(path, path, path, path).
+		WillReturnRows(rows)
+	f.mock.ExpectExec("DELETE FROM `stored_files` WHERE path = \\?").
+		WithArgs(path).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectCommit()
+}
+
+func (f *storedFilesFixture) stored(key string) bool {
+	_, err := os.Stat(filepath.Join(f.dir, filepath.FromSlash(key)))
+	return err == nil
+}
+
+func TestDeleteStoredFilesRemovesUnusedFiles(t *testing.T) {
+	f := newStoredFilesFixture(t, "posts/photo.jpg")
+	f.expectLock("posts/photo.jpg", false)
+	f.expectReferences("posts/photo.jpg", 0)
+
+	DeleteStoredFiles([]string{"posts/photo.jpg", "posts/photo.jpg"})
+	if f.stored("posts/photo.jpg") {
+		t.Fatal("the unused file is still stored")
+	}
+}
+
+func TestDeleteStoredFilesKeepsClaimedFiles(t *testing.T) {
+	f := newStoredFilesFixture(t, "posts/photo.jpg")
+	// Another upload of the same image is about to reference it
+	f.expectLock("posts/photo.jpg", true)
+	f.mock.ExpectCommit()
+
+	DeleteStoredFiles([]string{"posts/photo.jpg"})
+	if !f.stored("posts/photo.jpg") {
+		t.Fatal("the claimed file was deleted")
+	}
+}
+
+func TestDeleteStoredFilesKeepsReferencedFiles(t *testing.T) {
+	// The upload a processed image came from is referenced through media.source_path,
+	// and reused when the same image is uploaded again
+	f := newStoredFilesFixture(t, "posts/original.jpg")
+	f.expectLock("posts/original.jpg", false)
+	f.expectReferences("posts/original.jpg", 1)
+
+	DeleteStoredFiles([]string{"posts/original.jpg"})
+	if !f.stored("posts/original.jpg") {
+		t.Fatal("the file another media was processed from was deleted")
+	}
+}
@@ -0,0 +1,129 @@
+package functions
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PostVisibilities are the visibilities a post can have
+var PostVisibilities = []string{
+	posts.PostVisibilityPublic,
+	posts.PostVisibilityFollowers,
+	posts.PostVisibilityFriends,
+	posts.PostVisibilityOnlyMe,
+	posts.PostVisibilityTeam,
+}
+
+// postVisibleCondition is when the user @viewer sees a post of the posts table: always their own, and the others
+// by their audience. A private account narrows its public posts to its followers, the other audiences are already
+// narrower. Team posts are seen by the team members while their author is one of them too.
+const postVisibleCondition = `(posts.user_id = @viewer
+	OR (posts.visibility = 'public' AND (
+		NOT EXISTS (SELECT 1 FROM profiles WHERE profiles.user_id = posts.user_id AND profiles.is_private = TRUE)
+		OR EXISTS (SELECT 1 FROM follows WHERE follows.follower_id = @viewer AND follows.following_id = posts.user_id)))
+	OR (posts.visibility = 'followers' AND EXISTS (
+		SELECT 1 FROM follows WHERE follows.follower_id = @viewer AND follows.following_id = posts.user_id))
+	OR (posts.visibility = 'friends' AND EXISTS (
+		SELECT 1 FROM friends WHERE friends.status = TRUE AND (
+			(friends.user_id = @viewer AND friends.friend_to = posts.user_id)
+			OR (friends.user_id = posts.user_id AND friends.friend_to = @viewer))))
+	OR (posts.visibility = 'team'
+		AND EXISTS (SELECT 1 FROM users viewers JOIN roles viewer_roles ON viewer_roles.id = viewers.role_id
+			WHERE viewers.id = @viewer AND viewer_roles.name LIKE 'team%')
+		AND EXISTS (SELECT 1 FROM users authors JOIN roles author_roles ON author_roles.id = authors.role_id
+			WHERE authors.id = posts.user_id AND author_roles.name LIKE 'team%')))`
+
+// publicPostCondition is when a post of the posts table is seen by everyone, for lists that are not anyone's
+const publicPostCondition = `posts.visibility = 'public'
+	AND NOT EXISTS (SELECT 1 FROM profiles WHERE profiles.user_id = posts.user_id AND profiles.is_private = TRUE)`
+
+// PostVisibleSQL is the condition under which the user bound to @viewer sees the post aliased as table
+func PostVisibleSQL(table string) string {
+	if table == "posts" {
+		return postVisibleCondition
+	}
+	return strings.ReplaceAll(postVisibleCondition, "posts.", table+".")
+}
+
+// VisiblePosts restricts query, which selects from posts, to the posts viewerID sees
+func VisiblePosts(query *gorm.DB, viewerID uuid.UUID) *gorm.DB {
+	return query.Where(postVisibleCondition, map[string]interface{}{"viewer": viewerID})
+}
+
+// IsTeamMember reports whether the user holds one of the team roles, who see team posts
+func IsTeamMember(tx *gorm.DB, userID uuid.UUID) (bool, *utils.ServiceError) {
+	var member bool
+	if err := tx.Table("users").
+		Select("count(*) > 0").
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("users.id = ? AND roles.name LIKE 'team%'", userID).
+		Scan(&member).Error; err != nil {
+		return false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check the team role"}
+	}
+	return member, nil
+}
+
+// ValidatePostVisibility checks that the author may give their post the visibility; only team members post to
+// the team
+func ValidatePostVisibility(tx *gorm.DB, authorID uuid.UUID, visibility string) *utils.ServiceError {
+	if !slices.Contains(PostVisibilities, visibility) {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Visibility must be public, followers, friends, only_me or team"}
+	}
+	if visibility != posts.PostVisibilityTeam {
+		return nil
+	}
+	member, serviceErr := IsTeamMember(tx, authorID)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !member {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Only team members can post to the team"}
+	}
+	return nil
+}
+
+// UpdatePostVisibility sets who sees a post
+func UpdatePostVisibility(tx *gorm.DB, postID uuid.UUID, visibility string) *utils.ServiceError {
+	if err := tx.Model(&posts.Post{}).Where("id = ?", postID).Update("visibility", visibility).Error; err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update post visibility"}
+	}
+	return nil
+}
+
+// CanViewPost reports whether the post is published, not deleted and seen by the viewer
+func CanViewPost(tx *gorm.DB, viewerID, postID uuid.UUID) (bool, *utils.ServiceError) {
+	var visible bool
+	if err := VisiblePosts(tx.Table("posts").
+		Select("count(*) > 0").
+		Where("posts.id = ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postID), viewerID).
+		Scan(&visible).Error; err != nil {
+		return false, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check post visibility"}
+	}
+	return visible, nil
+}
+
+// SelectVisiblePostIDs returns which of the posts the viewer sees
+func SelectVisiblePostIDs(viewerID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID]bool, *utils.ServiceError) {
+	visible := make(map[uuid.UUID]bool, len(postIDs))
+	if len(postIDs) == 0 {
+		return visible, nil
+	}
+	var ids []uuid.UUID
+	if err := VisiblePosts(mysql.DB.Table("posts").
+		Where("posts.id IN ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postIDs), viewerID).
+		Pluck("posts.id", &ids).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check post visibility"}
+	}
+	for _, id := range ids {
+		visible[id] = true
+	}
+	return visible, nil
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	messages "github.com/unarya/univia/internal/api/modules/message/services"
+	"github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// ConversationAuthorization ensures the user's role in the conversation from the :id path parameter has the required permission.
+// The membership is stored in the context as "conversation_participant".
+func ConversationAuthorization(requiredPermission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			utils.SendErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+			c.Abort()
+			return
+		}
+		user, ok := userInterface.(*users.User)
+		if !ok {
+			utils.SendErrorResponse(c, http.StatusInternalServerError, "Internal Server Error", nil)
+			c.Abort()
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid conversation id", err)
+			c.Abort()
+			return
+		}
+
+		participant, serviceErr := messages.CheckConversationPermission(conversationID, user.ID, requiredPermission)
+		if serviceErr != nil {
+			utils.SendErrorResponse(c, serviceErr.StatusCode, serviceErr.Message, nil)
+			c.Abort()
+			return
+		}
+		c.Set("conversation_participant", participant)
+		c.Next()
+	}
+}
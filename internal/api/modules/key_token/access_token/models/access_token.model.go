@@ -11,8 +11,10 @@ import (
 
 // AccessToken represents the access token model.
 type AccessToken struct {
-	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	UserID    uuid.UUID  `gorm:"type:uuid;not null"`
+	ID     uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID uuid.UUID `gorm:"type:uuid;not null"`
+	// SessionID is the session the token was issued for, nil for tokens issued before sessions were recorded
+	SessionID *uuid.UUID `gorm:"type:char(36);index"`
 	User      Users.User `gorm:"foreignKey:UserID;references:ID"`
 	Token     string     `gorm:"type:varchar(256);not null;index"`
 	Status    bool       `gorm:"default:true"`
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	AccessTokens "github.com/unarya/univia/internal/api/modules/key_token/access_token/models"
 	Users "github.com/unarya/univia/internal/api/modules/user/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/internal/infrastructure/redis"
+	"gorm.io/gorm"
 )
 
 func VerifyToken(token string) (*Users.User, error) {
@@ -15,7 +17,7 @@ func VerifyToken(token string) (*Users.User, error) {
 	// Proceed with token validation
 	var tokenRecord AccessTokens.AccessToken
 	// Try cache first
-	cacheKey := fmt.Sprintf("access_token_%s", token)
+	cacheKey := tokenCacheKey(token)
 	if result, err := redis.GetJSON[Users.User](redis.Redis, cacheKey); err == nil && result != nil {
 		return result, nil
 	} else if err != nil {
@@ -34,3 +36,42 @@ func VerifyToken(token string) (*Users.User, error) {
 	_ = redis.Redis.SetJSON(cacheKey, user, 2*time.Hour)
 	return &user, nil
 }
+
+// GetActiveToken returns the record of an access token that is enabled and not expired.
+// Unlike VerifyToken it always reads the database, so a token revoked a moment ago is refused.
+func GetActiveToken(token string) (*AccessTokens.AccessToken, error) {
+	var tokenRecord AccessTokens.AccessToken
+	if err := mysql.DB.Where("token = ? AND status = true AND expires_at > ?", token, time.Now()).
+		First(&tokenRecord).Error; err != nil {
+		return nil, err
+	}
+	return &tokenRecord, nil
+}
+
+// BindSession records the session an access token was issued for
+func BindSession(token string, sessionID uuid.UUID) error {
+	return mysql.DB.Model(&AccessTokens.AccessToken{}).
+		Where("token = ?", token).
+		Update("session_id", sessionID).Error
+}
+
+// TokensByUserID returns the user's access tokens, so they can be forgotten once deleted
+func TokensByUserID(db *gorm.DB, userID uuid.UUID) ([]string, error) {
+	var tokens []string
+	if err := db.Model(&AccessTokens.AccessToken{}).Where("user_id = ?", userID).Pluck("token", &tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ForgetTokens drops deleted tokens from the cache VerifyToken reads, so they stop authenticating at once.
+// Call it after the deletion is committed, or a concurrent request could cache them again.
+func ForgetTokens(tokens []string) {
+	for _, token := range tokens {
+		_ = redis.Redis.Delete(tokenCacheKey(token))
+	}
+}
+
+func tokenCacheKey(token string) string {
+	return fmt.Sprintf("access_token_%s", token)
+}
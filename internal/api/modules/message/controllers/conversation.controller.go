@@ -0,0 +1,387 @@
+package messages
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	messages "github.com/unarya/univia/internal/api/modules/message/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// CreateConversation godoc
+// @Summary Create a group conversation
+// @Description Creates a titled group with the current user as owner and the given users as members
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CreateConversationRequest true "Title and members"
+// @Success 201 {object} map[string]interface{} "Conversation created"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations [post]
+func CreateConversation(c *gin.Context) {
+	var request types.CreateConversationRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	conversation, err := messages.CreateGroupConversation(currentUser.ID, request.Title, request.MemberIDs)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to create conversation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Conversation created", conversation)
+}
+
+// GetConversation godoc
+// @Summary Conversation details
+// @Description Returns a conversation with its members and their roles
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} map[string]interface{} "Get conversation successfully"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id} [get]
+func GetConversation(c *gin.Context) {
+	conversation, err := messages.GetConversation(conversationIDParam(c))
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to get conversation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Get conversation successfully", conversation)
+}
+
+// SendConversationMessage godoc
+// @Summary Send a message to a conversation
+// @Description Sends a message to every member of a direct or group conversation
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.ConversationMessageRequest true "Content"
+// @Success 201 {object} map[string]interface{} "Message sent"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/messages [post]
+func SendConversationMessage(c *gin.Context) {
+	var request types.ConversationMessageRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	message, err := messages.SendConversationMessage(currentUser.ID, conversationIDParam(c), request.Body, request.Attachments)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to send message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Message sent", message)
+}
+
+// InviteConversationMembers godoc
+// @Summary Invite members to a group
+// @Description Adds users to a group conversation. Users who are already members are skipped.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.ConversationMembersRequest true "Users to invite"
+// @Success 200 {object} map[string]interface{} "Members invited"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Conversation or user not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/members [post]
+func InviteConversationMembers(c *gin.Context) {
+	var request types.ConversationMembersRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	added, err := messages.InviteMembers(currentUser.ID, conversationIDParam(c), request.UserIDs)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to invite members", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Members invited", gin.H{"added_user_ids": added})
+}
+
+// KickConversationMember godoc
+// @Summary Remove a member from a group
+// @Description Owners can remove admins and members; admins can remove members
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.ConversationUserRequest true "User to remove"
+// @Success 200 {object} map[string]interface{} "Member removed"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Conversation or member not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/members [delete]
+func KickConversationMember(c *gin.Context) {
+	var request types.ConversationUserRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UserID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "user_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := messages.KickMember(currentUser.ID, conversationIDParam(c), request.UserID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to remove member", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Member removed", gin.H{"user_id": request.UserID})
+}
+
+// AssignConversationRole godoc
+// @Summary Change a member's role
+// @Description Owner only. Assigning `owner` hands the group over and makes the current owner an admin.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.AssignConversationRoleRequest true "Member and role"
+// @Success 200 {object} map[string]interface{} "Role updated"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Conversation or member not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/members/role [put]
+func AssignConversationRole(c *gin.Context) {
+	var request types.AssignConversationRoleRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UserID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "user_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := messages.AssignConversationRole(currentUser.ID, conversationIDParam(c), request.UserID, request.Role); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update role", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Role updated", gin.H{"user_id": request.UserID, "role": request.Role})
+}
+
+// LeaveConversation godoc
+// @Summary Leave a group
+// @Description An owner leaving hands the group to the longest-standing admin, or member. The last member leaving deletes the group.
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} map[string]interface{} "Left the conversation"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/leave [post]
+func LeaveConversation(c *gin.Context) {
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	conversationID := conversationIDParam(c)
+	if err := messages.LeaveConversation(currentUser.ID, conversationID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to leave conversation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Left the conversation", gin.H{"conversation_id": conversationID})
+}
+
+// RenameConversation godoc
+// @Summary Rename a group
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.RenameConversationRequest true "New title"
+// @Success 200 {object} map[string]interface{} "Conversation renamed"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id} [put]
+func RenameConversation(c *gin.Context) {
+	var request types.RenameConversationRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	conversationID := conversationIDParam(c)
+	title, err := messages.RenameConversation(currentUser.ID, conversationID, request.Title)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to rename conversation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Conversation renamed", gin.H{"conversation_id": conversationID, "title": title})
+}
+
+// PinMessage godoc
+// @Summary Pin a message
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.PinMessageRequest true "Message ID"
+// @Success 200 {object} map[string]interface{} "Message pinned"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/pins [post]
+func PinMessage(c *gin.Context) {
+	var request types.PinMessageRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.MessageID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "message_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := messages.PinMessage(currentUser.ID, conversationIDParam(c), request.MessageID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to pin message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Message pinned", gin.H{"message_id": request.MessageID})
+}
+
+// UnpinMessage godoc
+// @Summary Unpin a message
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Param request body types.PinMessageRequest true "Message ID"
+// @Success 200 {object} map[string]interface{} "Message unpinned"
+// @Failure 403 {object} types.StatusForbidden "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Message not pinned"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/pins [delete]
+func UnpinMessage(c *gin.Context) {
+	var request types.PinMessageRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := messages.UnpinMessage(currentUser.ID, conversationIDParam(c), request.MessageID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to unpin message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Message unpinned", gin.H{"message_id": request.MessageID})
+}
+
+// ListPinnedMessages godoc
+// @Summary List pinned messages
+// @Description Pinned messages of a conversation, most recently pinned first
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} map[string]interface{} "List pinned messages successfully"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/conversations/{id}/pins [get]
+func ListPinnedMessages(c *gin.Context) {
+	pins, err := messages.ListPinnedMessages(conversationIDParam(c))
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list pinned messages", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List pinned messages successfully", gin.H{"items": pins})
+}
+
+// conversationIDParam reads the :id path parameter, already validated by middlewares.ConversationAuthorization
+func conversationIDParam(c *gin.Context) uuid.UUID {
+	id, _ := uuid.Parse(c.Param("id"))
+	return id
+}
@@ -0,0 +1,254 @@
+package messages
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	messages "github.com/unarya/univia/internal/api/modules/message/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// SendMessage godoc
+// @Summary Send a direct message
+// @Description Sends a message with text and/or attachments to another user. The receiver gets it live over signaling, or on their next connection.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.SendMessageRequest true "Receiver and content"
+// @Success 201 {object} map[string]interface{} "Message sent"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages/send [post]
+func SendMessage(c *gin.Context) {
+	var request types.SendMessageRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.ReceiverID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "receiver_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	message, err := messages.SendMessage(currentUser.ID, request.ReceiverID, request.Body, request.Attachments)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to send message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Message sent", message)
+}
+
+// ListConversations godoc
+// @Summary List conversations
+// @Description Paginates the current user's conversations, most recently active first, with the last message and unread count
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListConversationsRequest true "Pagination"
+// @Success 200 {object} map[string]interface{} "List conversations successfully"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages/conversations [post]
+func ListConversations(c *gin.Context) {
+	var request types.ListConversationsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := messages.ListConversations(currentUser.ID, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list conversations", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List conversations successfully", response)
+}
+
+// GetMessageHistory godoc
+// @Summary Conversation history
+// @Description Cursor-paginated messages by seq. `before` loads older messages; `after` loads the ones missed since a known seq.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.MessageHistoryRequest true "Conversation and cursor"
+// @Success 200 {object} map[string]interface{} "Get messages successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages/history [post]
+func GetMessageHistory(c *gin.Context) {
+	var request types.MessageHistoryRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.ConversationID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "conversation_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	history, err := messages.GetMessageHistory(currentUser.ID, request.ConversationID, request.Before, request.After, request.Limit)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to get messages", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Get messages successfully", history)
+}
+
+// EditMessage godoc
+// @Summary Edit a message
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.EditMessageRequest true "Message ID and new body"
+// @Success 200 {object} map[string]interface{} "Message updated successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Not the sender"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages [put]
+func EditMessage(c *gin.Context) {
+	var request types.EditMessageRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	message, err := messages.EditMessage(currentUser.ID, request.MessageID, request.Body)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Message updated successfully", message)
+}
+
+// DeleteMessage godoc
+// @Summary Delete a message
+// @Description Deletes one of the current user's messages for everyone in the conversation
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.MessageIDRequest true "Message ID"
+// @Success 200 {object} map[string]interface{} "Message deleted successfully"
+// @Failure 403 {object} types.StatusForbidden "Not the sender"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages [delete]
+func DeleteMessage(c *gin.Context) {
+	var request types.MessageIDRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := messages.DeleteMessage(currentUser.ID, request.MessageID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to delete message", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Message deleted successfully", gin.H{"message_id": request.MessageID})
+}
+
+// MarkConversationRead godoc
+// @Summary Mark a conversation read
+// @Description Moves the current user's read marker to `seq`, or to the latest message when omitted
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.MarkConversationReadRequest true "Conversation and seq"
+// @Success 200 {object} map[string]interface{} "Conversation marked read"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages/read [post]
+func MarkConversationRead(c *gin.Context) {
+	var request types.MarkConversationReadRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	lastReadSeq, err := messages.MarkConversationRead(currentUser.ID, request.ConversationID, request.Seq)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to mark conversation read", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Conversation marked read", gin.H{
+		"conversation_id": request.ConversationID,
+		"last_read_seq":   lastReadSeq,
+	})
+}
+
+// CountUnread godoc
+// @Summary Unread message count
+// @Description Total unread messages across the current user's conversations
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Success 200 {object} map[string]interface{} "Count unread successfully"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/messages/unread [get]
+func CountUnread(c *gin.Context) {
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	total, err := messages.CountUnread(currentUser.ID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to count unread messages", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Count unread successfully", gin.H{"unread_count": total})
+}
@@ -0,0 +1,561 @@
+package messages
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxGroupMembers        = 256
+	MaxConversationTitle   = 100
+	MaxPinnedMessages      = 50
+	ConversationActionKey  = "action"
+	conversationEventQueue = "conversation:%s:%s:%d"
+)
+
+// Conversation update actions sent to members over signaling
+const (
+	ConversationCreated       = "created"
+	ConversationMembersAdded  = "members_added"
+	ConversationMemberRemoved = "member_removed"
+	ConversationMemberLeft    = "member_left"
+	ConversationRoleChanged   = "role_changed"
+	ConversationRenamed       = "renamed"
+	ConversationPinned        = "pinned"
+	ConversationUnpinned      = "unpinned"
+)
+
+var conversationRoleRank = map[string]int{
+	Users.ConversationRoleOwner:  3,
+	Users.ConversationRoleAdmin:  2,
+	Users.ConversationRoleMember: 1,
+}
+
+// CheckConversationPermission returns the user's membership when their role in the conversation grants permission.
+// Non-members get a 404 so conversation ids are not disclosed.
+func CheckConversationPermission(conversationID, userID uuid.UUID, permission string) (*Users.ConversationParticipant, *utils.ServiceError) {
+	participant, serviceErr := functions.GetConversationParticipant(mysql.DB, conversationID, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if !utils.ConversationRoleAllows(participant.Role, permission) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "Forbidden: insufficient permissions in this conversation"}
+	}
+	return participant, nil
+}
+
+// CreateGroupConversation starts a group owned by ownerID with the given members
+func CreateGroupConversation(ownerID uuid.UUID, title string, memberIDs []uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	title, serviceErr := validateConversationTitle(title)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	memberIDs = uniqueUserIDs(memberIDs, ownerID)
+	if len(memberIDs)+1 > MaxGroupMembers {
+		return nil, &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("A group can have at most %d members", MaxGroupMembers),
+		}
+	}
+
+	conversation := Users.Conversation{
+		ID:        uuid.New(),
+		Kind:      Users.ConversationGroup,
+		Title:     title,
+		CreatedBy: &ownerID,
+	}
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if serviceErr = checkUsersExist(tx, memberIDs); serviceErr != nil {
+			return serviceErr
+		}
+		if err := tx.Create(&conversation).Error; err != nil {
+			return err
+		}
+		participants := []Users.ConversationParticipant{
+			{ConversationID: conversation.ID, UserID: ownerID, Role: Users.ConversationRoleOwner},
+		}
+		for _, memberID := range memberIDs {
+			participants = append(participants, Users.ConversationParticipant{
+				ConversationID: conversation.ID,
+				UserID:         memberID,
+				Role:           Users.ConversationRoleMember,
+			})
+		}
+		if err := tx.Create(&participants).Error; err != nil {
+			return err
+		}
+		return publishConversationUpdate(tx, ownerID, conversation.ID, memberIDs, ConversationCreated, map[string]interface{}{
+			"title": title,
+		})
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create the conversation"}
+	}
+	return GetConversation(conversation.ID)
+}
+
+// GetConversation returns a conversation with its members
+func GetConversation(conversationID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	var conversation Users.Conversation
+	if err := mysql.DB.Where("id = ?", conversationID).Take(&conversation).Error; err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Conversation not found"}
+	}
+	rows, serviceErr := functions.SelectConversationMembers(conversationID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	members := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, map[string]interface{}{
+			"user":          map[string]interface{}{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"role":          row.Role,
+			"last_read_seq": row.LastReadSeq,
+			"joined_at":     row.JoinedAt,
+		})
+	}
+	return map[string]interface{}{
+		"id":              conversation.ID,
+		"kind":            conversation.Kind,
+		"title":           conversation.Title,
+		"created_by":      conversation.CreatedBy,
+		"last_seq":        conversation.LastSeq,
+		"last_message_at": conversation.LastMessageAt,
+		"created_at":      conversation.CreatedAt,
+		"members":         members,
+	}, nil
+}
+
+// InviteMembers adds users to a group; users already in it are skipped
+func InviteMembers(actorID, conversationID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, *utils.ServiceError) {
+	userIDs = uniqueUserIDs(userIDs, actorID)
+	if len(userIDs) == 0 {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "user_ids is required"}
+	}
+
+	var (
+		added      []uuid.UUID
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if _, serviceErr = lockGroup(tx, conversationID); serviceErr != nil {
+			return serviceErr
+		}
+		if serviceErr = checkUsersExist(tx, userIDs); serviceErr != nil {
+			return serviceErr
+		}
+
+		var existing []uuid.UUID
+		if err := tx.Model(&Users.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id IN ?", conversationID, userIDs).
+			Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+		existingSet := make(map[uuid.UUID]bool, len(existing))
+		for _, id := range existing {
+			existingSet[id] = true
+		}
+		for _, id := range userIDs {
+			if !existingSet[id] {
+				added = append(added, id)
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+
+		total, err := functions.CountConversationMembers(tx, conversationID)
+		if err != nil {
+			return err
+		}
+		if int(total)+len(added) > MaxGroupMembers {
+			serviceErr = &utils.ServiceError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("A group can have at most %d members", MaxGroupMembers),
+			}
+			return serviceErr
+		}
+
+		participants := make([]Users.ConversationParticipant, 0, len(added))
+		for _, id := range added {
+			participants = append(participants, Users.ConversationParticipant{
+				ConversationID: conversationID,
+				UserID:         id,
+				Role:           Users.ConversationRoleMember,
+			})
+		}
+		if err := tx.Create(&participants).Error; err != nil {
+			return err
+		}
+		return publishToMembers(tx, actorID, conversationID, nil, ConversationMembersAdded, map[string]interface{}{
+			"user_ids": added,
+		})
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to invite members"}
+	}
+	if added == nil {
+		added = []uuid.UUID{}
+	}
+	return added, nil
+}
+
+// KickMember removes a participant ranked below the actor
+func KickMember(actorID, conversationID, targetID uuid.UUID) *utils.ServiceError {
+	if actorID == targetID {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Leave the conversation instead of removing yourself"}
+	}
+
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if _, serviceErr = lockGroup(tx, conversationID); serviceErr != nil {
+			return serviceErr
+		}
+		actor, target, lookupErr := getActorAndTarget(tx, conversationID, actorID, targetID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if conversationRoleRank[target.Role] >= conversationRoleRank[actor.Role] {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "You can only remove members ranked below you"}
+			return serviceErr
+		}
+
+		if err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, targetID).
+			Delete(&Users.ConversationParticipant{}).Error; err != nil {
+			return err
+		}
+		// The removed user is told as well
+		return publishToMembers(tx, actorID, conversationID, []uuid.UUID{targetID}, ConversationMemberRemoved, map[string]interface{}{
+			"user_id": targetID,
+		})
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to remove the member"}
+	}
+	return nil
+}
+
+// LeaveConversation removes the user from a group. An owner leaving hands the group to the longest-standing
+// admin, or member when there is no admin; the last member leaving deletes the group.
+func LeaveConversation(userID, conversationID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if _, serviceErr = lockGroup(tx, conversationID); serviceErr != nil {
+			return serviceErr
+		}
+		participant, lookupErr := functions.GetConversationParticipant(tx, conversationID, userID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+			Delete(&Users.ConversationParticipant{}).Error; err != nil {
+			return err
+		}
+
+		var successor Users.ConversationParticipant
+		err := tx.Where("conversation_id = ?", conversationID).
+			Order(clause.Expr{SQL: "FIELD(role, ?, ?), joined_at, user_id", Vars: []interface{}{Users.ConversationRoleAdmin, Users.ConversationRoleMember}}).
+			Take(&successor).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Where("id = ?", conversationID).Delete(&Users.Conversation{}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		details := map[string]interface{}{"user_id": userID}
+		if participant.Role == Users.ConversationRoleOwner {
+			if err := setRole(tx, conversationID, successor.UserID, Users.ConversationRoleOwner); err != nil {
+				return err
+			}
+			details["new_owner_id"] = successor.UserID
+		}
+		return publishToMembers(tx, userID, conversationID, nil, ConversationMemberLeft, details)
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to leave the conversation"}
+	}
+	return nil
+}
+
+// AssignConversationRole makes a participant an admin or a member. Giving away the owner role
+// makes the current owner an admin.
+func AssignConversationRole(actorID, conversationID, targetID uuid.UUID, role string) *utils.ServiceError {
+	if _, known := conversationRoleRank[role]; !known {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "role must be owner, admin or member"}
+	}
+	if actorID == targetID {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You cannot change your own role"}
+	}
+
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if _, serviceErr = lockGroup(tx, conversationID); serviceErr != nil {
+			return serviceErr
+		}
+		actor, target, lookupErr := getActorAndTarget(tx, conversationID, actorID, targetID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if actor.Role != Users.ConversationRoleOwner {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "Only the owner can change roles"}
+			return serviceErr
+		}
+		if target.Role == role {
+			return nil
+		}
+
+		if err := setRole(tx, conversationID, targetID, role); err != nil {
+			return err
+		}
+		details := map[string]interface{}{"user_id": targetID, "role": role}
+		if role == Users.ConversationRoleOwner {
+			if err := setRole(tx, conversationID, actorID, Users.ConversationRoleAdmin); err != nil {
+				return err
+			}
+			details["previous_owner_id"] = actorID
+		}
+		return publishToMembers(tx, actorID, conversationID, nil, ConversationRoleChanged, details)
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to change the role"}
+	}
+	return nil
+}
+
+// RenameConversation changes the title of a group
+func RenameConversation(actorID, conversationID uuid.UUID, title string) (string, *utils.ServiceError) {
+	title, serviceErr := validateConversationTitle(title)
+	if serviceErr != nil {
+		return "", serviceErr
+	}
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		conversation, lockErr := lockGroup(tx, conversationID)
+		if lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+		if err := tx.Model(conversation).Update("title", title).Error; err != nil {
+			return err
+		}
+		return publishToMembers(tx, actorID, conversationID, nil, ConversationRenamed, map[string]interface{}{"title": title})
+	}); err != nil {
+		if serviceErr != nil {
+			return "", serviceErr
+		}
+		return "", &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to rename the conversation"}
+	}
+	return title, nil
+}
+
+// PinMessage pins a message of the conversation
+func PinMessage(actorID, conversationID, messageID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if _, serviceErr = functions.LockConversation(tx, conversationID); serviceErr != nil {
+			return serviceErr
+		}
+		message, lookupErr := functions.LockMessage(tx, messageID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if message.ConversationID != conversationID {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Message not found"}
+			return serviceErr
+		}
+		if message.DeletedAt != nil {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "The message was deleted"}
+			return serviceErr
+		}
+
+		var pinned int64
+		if err := tx.Model(&Users.ConversationPin{}).Where("conversation_id = ?", conversationID).Count(&pinned).Error; err != nil {
+			return err
+		}
+		if pinned >= MaxPinnedMessages {
+			serviceErr = &utils.ServiceError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("A conversation can have at most %d pinned messages", MaxPinnedMessages),
+			}
+			return serviceErr
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Users.ConversationPin{
+			ConversationID: conversationID,
+			MessageID:      messageID,
+			PinnedBy:       &actorID,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return publishToMembers(tx, actorID, conversationID, nil, ConversationPinned, map[string]interface{}{"message_id": messageID})
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to pin the message"}
+	}
+	return nil
+}
+
+// UnpinMessage unpins a message of the conversation
+func UnpinMessage(actorID, conversationID, messageID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("conversation_id = ? AND message_id = ?", conversationID, messageID).Delete(&Users.ConversationPin{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "The message is not pinned"}
+			return serviceErr
+		}
+		return publishToMembers(tx, actorID, conversationID, nil, ConversationUnpinned, map[string]interface{}{"message_id": messageID})
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to unpin the message"}
+	}
+	return nil
+}
+
+// ListPinnedMessages lists the pinned messages of a conversation, most recently pinned first
+func ListPinnedMessages(conversationID uuid.UUID) ([]map[string]interface{}, *utils.ServiceError) {
+	rows, serviceErr := functions.SelectPinnedMessages(conversationID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		item := messageRowToMap(row.MessageRow)
+		item["pinned_at"] = row.PinnedAt
+		item["pinned_by"] = nil
+		if row.PinnedBy.Valid {
+			item["pinned_by"] = row.PinnedBy.UUID
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// lockGroup locks the conversation, refusing direct conversations which have fixed members and no title
+func lockGroup(tx *gorm.DB, conversationID uuid.UUID) (*Users.Conversation, *utils.ServiceError) {
+	conversation, serviceErr := functions.LockConversation(tx, conversationID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if conversation.Kind != Users.ConversationGroup {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Only group conversations can be changed"}
+	}
+	return conversation, nil
+}
+
+func getActorAndTarget(tx *gorm.DB, conversationID, actorID, targetID uuid.UUID) (*Users.ConversationParticipant, *Users.ConversationParticipant, *utils.ServiceError) {
+	actor, serviceErr := functions.GetConversationParticipant(tx, conversationID, actorID)
+	if serviceErr != nil {
+		return nil, nil, serviceErr
+	}
+	target, serviceErr := functions.GetConversationParticipant(tx, conversationID, targetID)
+	if serviceErr != nil {
+		return nil, nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "The user is not a member of this conversation"}
+	}
+	return actor, target, nil
+}
+
+func setRole(tx *gorm.DB, conversationID, userID uuid.UUID, role string) error {
+	return tx.Model(&Users.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Update("role", role).Error
+}
+
+func checkUsersExist(tx *gorm.DB, userIDs []uuid.UUID) *utils.ServiceError {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var found int64
+	if err := tx.Model(&Users.User{}).Where("id IN ?", userIDs).Count(&found).Error; err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get users"}
+	}
+	if int(found) != len(userIDs) {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Some users were not found"}
+	}
+	return nil
+}
+
+// uniqueUserIDs drops duplicates, nil ids and self
+func uniqueUserIDs(userIDs []uuid.UUID, self uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(userIDs))
+	unique := make([]uuid.UUID, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id == uuid.Nil || id == self || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+func validateConversationTitle(title string) (string, *utils.ServiceError) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "A group needs a title"}
+	}
+	if len([]rune(title)) > MaxConversationTitle {
+		return "", &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("The title must be at most %d characters", MaxConversationTitle),
+		}
+	}
+	return title, nil
+}
+
+// publishToMembers tells the current members other than the actor, plus extra users such as a removed member
+func publishToMembers(tx *gorm.DB, actorID, conversationID uuid.UUID, extra []uuid.UUID, action string, details map[string]interface{}) error {
+	peers, err := functions.SelectConversationPeers(tx, conversationID, actorID)
+	if err != nil {
+		return err
+	}
+	return publishConversationUpdate(tx, actorID, conversationID, append(peers, extra...), action, details)
+}
+
+func publishConversationUpdate(tx *gorm.DB, actorID, conversationID uuid.UUID, receiverIDs []uuid.UUID, action string, details map[string]interface{}) error {
+	payload := map[string]interface{}{
+		"conversation_id": conversationID,
+		"actor_id":        actorID,
+	}
+	for key, value := range details {
+		payload[key] = value
+	}
+	payload[ConversationActionKey] = action
+	dedupKey := fmt.Sprintf(conversationEventQueue, conversationID, action, time.Now().UnixNano())
+	return publish(tx, actorID, conversationID, receiverIDs, types.MessageTypeConversationUpdate, outbox.EventConversationUpdated, dedupKey, payload)
+}
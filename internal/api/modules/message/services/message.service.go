@@ -0,0 +1,513 @@
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	kafkaClient "github.com/unarya/univia/internal/infrastructure/kafka"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxMessageLength      = 5000
+	MaxMessageAttachments = 10
+	DefaultHistoryLimit   = 30
+	MaxHistoryLimit       = 100
+)
+
+// SendMessage posts a message in the direct conversation of the two users, creating it on first contact,
+// and pushes it to the receiver over signaling
+func SendMessage(senderID, receiverID uuid.UUID, body string, attachments []types.MessageAttachment) (map[string]interface{}, *utils.ServiceError) {
+	if senderID == receiverID {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You cannot message yourself"}
+	}
+	body = strings.TrimSpace(body)
+	encodedAttachments, serviceErr := validateMessage(body, attachments)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var message *Users.Message
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if serviceErr = functions.CheckUserExists(tx, receiverID); serviceErr != nil {
+			return serviceErr
+		}
+		conversation, lockErr := functions.LockDirectConversation(tx, senderID, receiverID)
+		if lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+		var err error
+		message, err = appendMessage(tx, conversation, senderID, &receiverID, body, encodedAttachments)
+		return err
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to send the message"}
+	}
+	return messageToMap(*message), nil
+}
+
+// SendConversationMessage posts a message in an existing conversation the sender belongs to,
+// and fans it out to every other participant
+func SendConversationMessage(senderID, conversationID uuid.UUID, body string, attachments []types.MessageAttachment) (map[string]interface{}, *utils.ServiceError) {
+	body = strings.TrimSpace(body)
+	encodedAttachments, serviceErr := validateMessage(body, attachments)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var message *Users.Message
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		conversation, lockErr := functions.LockConversation(tx, conversationID)
+		if lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+		// Checked again under the conversation lock, in case the sender was just removed
+		if _, serviceErr = functions.GetConversationParticipant(tx, conversationID, senderID); serviceErr != nil {
+			return serviceErr
+		}
+
+		var receiverID *uuid.UUID
+		if conversation.Kind == Users.ConversationDirect {
+			peers, err := functions.SelectConversationPeers(tx, conversationID, senderID)
+			if err != nil {
+				return err
+			}
+			if len(peers) == 1 {
+				receiverID = &peers[0]
+			}
+		}
+		var err error
+		message, err = appendMessage(tx, conversation, senderID, receiverID, body, encodedAttachments)
+		return err
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to send the message"}
+	}
+	return messageToMap(*message), nil
+}
+
+// appendMessage stores the next message of a conversation locked by tx and queues it for the other participants
+func appendMessage(tx *gorm.DB, conversation *Users.Conversation, senderID uuid.UUID, receiverID *uuid.UUID, body string, attachments datatypes.JSON) (*Users.Message, error) {
+	now := time.Now()
+	message := Users.Message{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		Seq:            conversation.LastSeq + 1,
+		SenderID:       senderID,
+		ReceiverID:     receiverID,
+		Body:           body,
+		Attachments:    attachments,
+		CreatedAt:      now,
+	}
+	if err := tx.Create(&message).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(conversation).Updates(map[string]interface{}{
+		"last_seq":        message.Seq,
+		"last_message_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	// The sender has read everything up to their own message
+	if err := markRead(tx, conversation.ID, senderID, message.Seq); err != nil {
+		return nil, err
+	}
+	if err := publishToPeers(tx, senderID, &message, types.MessageTypeChatMessage, outbox.EventMessageSent,
+		fmt.Sprintf("message:%s", message.ID)); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// EditMessage replaces the text of one of the user's messages
+func EditMessage(userID, messageID uuid.UUID, body string) (map[string]interface{}, *utils.ServiceError) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Message body is required"}
+	}
+	if len([]rune(body)) > MaxMessageLength {
+		return nil, &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("A message must be at most %d characters", MaxMessageLength),
+		}
+	}
+
+	var (
+		message    *Users.Message
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		message, serviceErr = lockOwnMessage(tx, userID, messageID)
+		if serviceErr != nil {
+			return serviceErr
+		}
+
+		now := time.Now()
+		if err := tx.Model(message).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error; err != nil {
+			return err
+		}
+		message.Body = body
+		message.EditedAt = &now
+
+		return publishToPeers(tx, userID, message, types.MessageTypeChatMessageEdited, outbox.EventMessageEdited,
+			fmt.Sprintf("message_edited:%s:%d", message.ID, now.UnixNano()))
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to edit the message"}
+	}
+	return messageToMap(*message), nil
+}
+
+// DeleteMessage removes a message for everyone; the message keeps its place in the history.
+// Senders delete their own messages, and group owners and admins any message of the group.
+func DeleteMessage(userID, messageID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		message, lockErr := lockDeletableMessage(tx, userID, messageID)
+		if lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+
+		now := time.Now()
+		if err := tx.Model(message).Updates(map[string]interface{}{
+			"body":        nil,
+			"attachments": nil,
+			"deleted_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+		message.Body = ""
+		message.Attachments = nil
+		message.DeletedAt = &now
+
+		return publishToPeers(tx, userID, message, types.MessageTypeChatMessageDeleted, outbox.EventMessageDeleted,
+			fmt.Sprintf("message_deleted:%s", message.ID))
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to delete the message"}
+	}
+	return nil
+}
+
+// ListConversations lists the user's conversations with their latest message and unread count
+func ListConversations(userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	if itemsPerPage <= 0 {
+		itemsPerPage = 20
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectConversations(userID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		item := map[string]interface{}{
+			"id":              row.ID,
+			"kind":            row.Kind,
+			"title":           row.Title,
+			"member_count":    row.MemberCount,
+			"last_seq":        row.LastSeq,
+			"last_read_seq":   row.LastReadSeq,
+			"last_message_at": row.LastMessageAt,
+			"unread_count":    row.UnreadCount,
+			"peer":            nil,
+			"last_message":    nil,
+		}
+		if row.PeerID.Valid {
+			item["peer"] = map[string]interface{}{"id": row.PeerID.UUID, "name": row.PeerUsername, "profile_pic": row.PeerProfilePic}
+		}
+		if row.LastMessageID.Valid {
+			item["last_message"] = map[string]interface{}{
+				"id":        row.LastMessageID.UUID,
+				"sender_id": row.LastMessageSender.UUID,
+				"body":      row.LastMessageBody,
+				"deleted":   row.LastMessageGone,
+			}
+		}
+		items = append(items, item)
+	}
+
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
+
+// GetMessageHistory pages through a conversation by seq. `before` walks back in time; `after` returns
+// what the client missed since the last seq it saw, which is how a reconnecting client catches up.
+func GetMessageHistory(userID, conversationID uuid.UUID, before, after int64, limit int) (map[string]interface{}, *utils.ServiceError) {
+	if before < 0 || after < 0 {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Cursors must not be negative"}
+	}
+	if before > 0 && after > 0 {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Use either before or after, not both"}
+	}
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	participant, serviceErr := functions.GetConversationParticipant(mysql.DB, conversationID, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	// One extra row tells whether there is another page
+	rows, serviceErr := functions.SelectMessages(conversationID, before, after, limit+1)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	hasMore := len(rows) > limit
+	if hasMore {
+		if after > 0 {
+			rows = rows[:limit]
+		} else {
+			rows = rows[1:]
+		}
+	}
+
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, messageRowToMap(row))
+	}
+
+	response := map[string]interface{}{
+		"items":         items,
+		"has_more":      hasMore,
+		"last_read_seq": participant.LastReadSeq,
+		"next_before":   nil,
+		"next_after":    nil,
+	}
+	if len(rows) > 0 {
+		response["next_before"] = rows[0].Seq
+		response["next_after"] = rows[len(rows)-1].Seq
+	}
+	return response, nil
+}
+
+// MarkConversationRead moves the user's read marker to seq, or to the latest message when seq is 0
+func MarkConversationRead(userID, conversationID uuid.UUID, seq int64) (int64, *utils.ServiceError) {
+	if seq < 0 {
+		return 0, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "seq must not be negative"}
+	}
+	participant, serviceErr := functions.GetConversationParticipant(mysql.DB, conversationID, userID)
+	if serviceErr != nil {
+		return 0, serviceErr
+	}
+
+	var lastSeq int64
+	if err := mysql.DB.Model(&Users.Conversation{}).Where("id = ?", conversationID).Pluck("last_seq", &lastSeq).Error; err != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get conversation"}
+	}
+	if seq == 0 || seq > lastSeq {
+		seq = lastSeq
+	}
+	if seq <= participant.LastReadSeq {
+		return participant.LastReadSeq, nil
+	}
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := markRead(tx, conversationID, userID, seq); err != nil {
+			return err
+		}
+		// Read receipt: every message up to seq is read by userID
+		peers, err := functions.SelectConversationPeers(tx, conversationID, userID)
+		if err != nil {
+			return err
+		}
+		return publish(tx, userID, conversationID, peers, types.MessageTypeReadReceipt, outbox.EventMessageRead,
+			fmt.Sprintf("message_read:%s:%s:%d", conversationID, userID, seq), map[string]interface{}{
+				"conversation_id": conversationID,
+				"user_id":         userID,
+				"last_read_seq":   seq,
+				"read_at":         time.Now(),
+			})
+	}); err != nil {
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to mark the conversation read"}
+	}
+	return seq, nil
+}
+
+// CountUnread returns the number of unread messages across the user's conversations
+func CountUnread(userID uuid.UUID) (int64, *utils.ServiceError) {
+	return functions.CountUnreadMessages(userID)
+}
+
+func validateMessage(body string, attachments []types.MessageAttachment) (datatypes.JSON, *utils.ServiceError) {
+	if body == "" && len(attachments) == 0 {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "A message needs a body or an attachment"}
+	}
+	if len([]rune(body)) > MaxMessageLength {
+		return nil, &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("A message must be at most %d characters", MaxMessageLength),
+		}
+	}
+	if len(attachments) > MaxMessageAttachments {
+		return nil, &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("A message can have at most %d attachments", MaxMessageAttachments),
+		}
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	for _, attachment := range attachments {
+		parsed, err := url.Parse(attachment.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Attachment urls must be absolute http(s) urls"}
+		}
+	}
+	encoded, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Invalid attachments"}
+	}
+	return encoded, nil
+}
+
+func lockOwnMessage(tx *gorm.DB, userID, messageID uuid.UUID) (*Users.Message, *utils.ServiceError) {
+	message, serviceErr := functions.LockMessage(tx, messageID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if message.SenderID != userID {
+		return nil, &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "You can only change your own messages"}
+	}
+	if message.DeletedAt != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "The message was deleted"}
+	}
+	return message, nil
+}
+
+func lockDeletableMessage(tx *gorm.DB, userID, messageID uuid.UUID) (*Users.Message, *utils.ServiceError) {
+	message, serviceErr := functions.LockMessage(tx, messageID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if message.DeletedAt != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "The message was deleted"}
+	}
+	if message.SenderID == userID {
+		return message, nil
+	}
+	if _, serviceErr := CheckConversationPermission(message.ConversationID, userID, utils.Permissions["ALLOW_DELETE_CONVERSATION_MESSAGE"]); serviceErr != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "You can only delete your own messages"}
+	}
+	return message, nil
+}
+
+func markRead(tx *gorm.DB, conversationID, userID uuid.UUID, seq int64) error {
+	return tx.Model(&Users.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND last_read_seq < ?", conversationID, userID, seq).
+		Update("last_read_seq", seq).Error
+}
+
+// publishToPeers sends a message event to the participants other than actorID, who caused it
+func publishToPeers(tx *gorm.DB, actorID uuid.UUID, message *Users.Message, wsType, eventType, dedupKey string) error {
+	peers, err := functions.SelectConversationPeers(tx, message.ConversationID, actorID)
+	if err != nil {
+		return err
+	}
+	return publish(tx, actorID, message.ConversationID, peers, wsType, eventType, dedupKey, messageToMap(*message))
+}
+
+// publish queues one signaling event for all receivers of a conversation; signaling fans it out through
+// its socket store, so online members get it live and the others on their next connection
+func publish(tx *gorm.DB, senderID, conversationID uuid.UUID, receiverIDs []uuid.UUID, wsType, eventType, dedupKey string, payload interface{}) error {
+	if len(receiverIDs) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(receiverIDs))
+	for _, receiverID := range receiverIDs {
+		recipients = append(recipients, receiverID.String())
+	}
+	return outbox.Enqueue(tx, outbox.Event{
+		Topic: kafkaClient.NotificationsTopic,
+		// Keyed by conversation so its events stay in order
+		Key:      conversationID.String(),
+		DedupKey: dedupKey,
+		Type:     eventType,
+		Payload: types.WebSocketMessage{
+			Type:       wsType,
+			SenderID:   senderID.String(),
+			RoomID:     conversationID.String(),
+			Recipients: recipients,
+			Payload:    raw,
+		},
+	})
+}
+
+func messageToMap(message Users.Message) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              message.ID,
+		"conversation_id": message.ConversationID,
+		"seq":             message.Seq,
+		"sender_id":       message.SenderID,
+		"body":            message.Body,
+		"attachments":     attachmentsOrEmpty(message.Attachments),
+		"edited_at":       message.EditedAt,
+		"deleted":         message.DeletedAt != nil,
+		"created_at":      message.CreatedAt,
+	}
+}
+
+func messageRowToMap(row functions.MessageRow) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              row.ID,
+		"conversation_id": row.ConversationID,
+		"seq":             row.Seq,
+		"sender":          map[string]interface{}{"id": row.SenderID, "name": row.Username, "profile_pic": row.ProfilePic},
+		"body":            row.Body,
+		"attachments":     attachmentsOrEmpty(row.Attachments),
+		"edited_at":       row.EditedAt,
+		"deleted":         row.DeletedAt != nil,
+		"created_at":      row.CreatedAt,
+	}
+}
+
+func attachmentsOrEmpty(raw datatypes.JSON) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("[]")
+	}
+	return json.RawMessage(raw)
+}
@@ -16,7 +16,8 @@ import (
 
 // List godoc
 // @Summary      List all notifications of the current user
-// @Description  Retrieve notifications with filters (pagination, seen/unseen, search)
+// @Description  Retrieve notifications with filters (pagination, seen/unseen, search).
+// @Description  Set pagination to "cursor" to page newest first with next_cursor/prev_cursor instead of current_page.
 // @Tags         Notifications
 // @Accept       json
 // @Produce      json
@@ -37,6 +38,8 @@ func List(c *gin.Context) {
 		SearchValue  string `json:"search_value"`
 		IsSeen       bool   `json:"is_seen"`
 		All          bool   `json:"all"`
+		Pagination   string `json:"pagination"`
+		Cursor       string `json:"cursor"`
 	}
 
 	if bindErr := utils.BindJson(c, &request); bindErr != nil {
@@ -50,23 +53,46 @@ func List(c *gin.Context) {
 		return
 	}
 
+	useCursor := utils.UseCursor(request.Pagination, request.Cursor)
+	var page utils.CursorPage
+	if useCursor {
+		var cursorErr error
+		if page, cursorErr = utils.NewCursorPage(request.Cursor, request.ItemsPerPage); cursorErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid cursor", cursorErr)
+			return
+		}
+	}
+
 	// Try cache
 	cacheKey := fmt.Sprintf("notifications_by_user_%v_%d_%d_%s_%s_%s_%v_%v",
 		currentUser.ID, request.CurrentPage, request.ItemsPerPage, request.OrderBy, request.SortBy, request.SearchValue, request.IsSeen, request.All)
+	if useCursor {
+		cacheKey = fmt.Sprintf("notifications_by_user_%v_cursor_%s_%d_%s_%v_%v",
+			currentUser.ID, request.Cursor, page.Limit, request.SearchValue, request.IsSeen, request.All)
+	}
 	if results, err := redis.GetJSON[map[string]interface{}](redis.Redis, cacheKey); err == nil && results != nil {
 		utils.SendSuccessResponse(c, http.StatusOK, "Successfully list notifications", results)
 		return
 	}
-	response, err := notifications.GetNotificationsByUserID(
-		currentUser.ID,
-		request.CurrentPage,
-		request.ItemsPerPage,
-		request.OrderBy,
-		request.SortBy,
-		request.SearchValue,
-		request.IsSeen,
-		request.All,
+
+	var (
+		response map[string]interface{}
+		err      *utils.ServiceError
 	)
+	if useCursor {
+		response, err = notifications.GetNotificationPageByUserID(currentUser.ID, page, request.SearchValue, request.IsSeen, request.All)
+	} else {
+		response, err = notifications.GetNotificationsByUserID(
+			currentUser.ID,
+			request.CurrentPage,
+			request.ItemsPerPage,
+			request.OrderBy,
+			request.SortBy,
+			request.SearchValue,
+			request.IsSeen,
+			request.All,
+		)
+	}
 	if err != nil {
 		utils.SendErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notifications", err)
 		return
@@ -87,6 +113,8 @@ func List(c *gin.Context) {
 // @Success      200 {object} map[string]interface{}
 // @Failure      400 {object} map[string]interface{}
 // @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/notifications/seen [put]
 func UpdateSeen(c *gin.Context) {
@@ -105,9 +133,9 @@ func UpdateSeen(c *gin.Context) {
 		return
 	}
 
-	serviceErr := notifications.UpdateIsSeen(request.NotificationID, currentUser.ID)
+	serviceErr := notifications.UpdateIsSeen(request.NotificationID, currentUser.ID, currentUser.RoleID)
 	if serviceErr != nil {
-		utils.SendErrorResponse(c, http.StatusInternalServerError, "Failed to update notification", serviceErr)
+		utils.SendErrorResponse(c, serviceErr.StatusCode, "Failed to update notification", serviceErr)
 		return
 	}
 
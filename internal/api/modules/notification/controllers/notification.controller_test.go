@@ -0,0 +1,121 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// notificationFixture is a notification sent to receiver, with the database the seen route updates
+type notificationFixture struct {
+	mock           sqlmock.Sqlmock
+	receiver       uuid.UUID
+	notificationID uuid.UUID
+	moderator      uuid.UUID
+}
+
+func newNotificationFixture(t *testing.T) *notificationFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	gormDB, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: db, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	previousDB, previousCache := mysql.DB, redis.Redis
+	t.Cleanup(func() {
+		mysql.DB, redis.Redis = previousDB, previousCache
+		_ = db.Close()
+	})
+	mysql.DB = gormDB
+	cache := miniredis.RunT(t)
+	redis.Redis = redis.NewRedisCache(goredis.NewClient(&goredis.Options{Addr: cache.Addr()}))
+
+	fixture := &notificationFixture{mock: mock, receiver: uuid.New(), notificationID: uuid.New(), moderator: uuid.New()}
+	for _, permission := range []string{utils.Permissions["ALLOW_MODERATE_POST"], utils.Permissions["ALLOW_MODERATE_COMMENT"]} {
+		if err := redis.Redis.SetJSON("permission:"+fixture.moderator.String()+":"+permission, true, time.Hour); err != nil {
+			t.Fatalf("caching %s: %v", permission, err)
+		}
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("queries: %v", err)
+		}
+	})
+	return fixture
+}
+
+// markSeen calls the route marking the notification as seen as actor
+func (f *notificationFixture) markSeen(actor *Users.User) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) { c.Set("user", actor) }, UpdateSeen)
+	data, _ := json.Marshal(map[string]interface{}{"notification_id": f.notificationID})
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (f *notificationFixture) expectReceiver() {
+	f.mock.ExpectQuery("SELECT notifications.receiver_id FROM `notifications` WHERE notifications.id = \\?").
+		WithArgs(f.notificationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(f.receiver.String()))
+}
+
+func TestMarkSeenByReceiver(t *testing.T) {
+	f := newNotificationFixture(t)
+	f.expectReceiver()
+	f.mock.ExpectBegin()
+	f.mock.ExpectExec("UPDATE `notifications` SET `is_seen`=\\?,`updated_at`=\\? WHERE id = \\? AND receiver_id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), f.notificationID, f.receiver).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectCommit()
+
+	response := f.markSeen(&Users.User{ID: f.receiver, RoleID: uuid.New()})
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+}
+
+func TestMarkSeenOfAnotherUserIsForbidden(t *testing.T) {
+	f := newNotificationFixture(t)
+	f.expectReceiver()
+
+	response := f.markSeen(&Users.User{ID: uuid.New(), RoleID: uuid.New()})
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", response.Code, response.Body)
+	}
+}
+
+func TestMarkSeenByModeratorIsForbidden(t *testing.T) {
+	// Notifications are private to their receiver, moderators included
+	f := newNotificationFixture(t)
+	f.expectReceiver()
+
+	response := f.markSeen(&Users.User{ID: uuid.New(), RoleID: f.moderator})
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", response.Code, response.Body)
+	}
+}
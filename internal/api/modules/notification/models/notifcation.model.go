@@ -12,6 +12,7 @@ type Notification struct {
 	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	SenderID   uuid.UUID  `gorm:"type:uuid;not null"`
 	ReceiverID uuid.UUID  `gorm:"type:uuid;not null"`
+	PostID     *uuid.UUID `gorm:"type:uuid;default:null"` // set when the notification is about a post
 	Sender     Users.User `gorm:"foreignKey:SenderID;references:ID"`
 	Receiver   Users.User `gorm:"foreignKey:ReceiverID;references:ID"`
 	Message    string     `gorm:"type:text;default:null"`
@@ -1,34 +1,52 @@
 package notifications
 
 import (
-	"context"
 	"database/sql"
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/segmentio/kafka-go"
 	"github.com/unarya/univia/internal/api/functions"
 	"github.com/unarya/univia/internal/api/modules/notification/models"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
 	kafkaClient "github.com/unarya/univia/internal/infrastructure/kafka"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/pkg/types"
 	"github.com/unarya/univia/pkg/utils"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func NotificationHandler(senderID, receiverID uuid.UUID, message, notiType string) *utils.ServiceError {
-	db := mysql.DB
+// NotificationHandler stores a notification with tx and queues its real-time event in the outbox,
+// so the event is published only if the caller's transaction commits. eventType is one of the outbox.Event* types.
+func NotificationHandler(tx *gorm.DB, senderID, receiverID uuid.UUID, message, notiType, eventType string) *utils.ServiceError {
+	return createNotification(tx, notifications.Notification{
+		ID:         uuid.New(),
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Message:    message,
+		NotiType:   notiType,
+	}, eventType)
+}
 
-	newNoti := notifications.Notification{
+// PostNotificationHandler is NotificationHandler for a notification about postID; it is removed with the post
+func PostNotificationHandler(tx *gorm.DB, senderID, receiverID, postID uuid.UUID, message, notiType, eventType string) *utils.ServiceError {
+	return createNotification(tx, notifications.Notification{
+		ID:         uuid.New(),
 		SenderID:   senderID,
 		ReceiverID: receiverID,
+		PostID:     &postID,
 		Message:    message,
 		NotiType:   notiType,
-	}
+	}, eventType)
+}
+
+func createNotification(tx *gorm.DB, newNoti notifications.Notification, eventType string) *utils.ServiceError {
+	senderID, receiverID := newNoti.SenderID, newNoti.ReceiverID
 
-	if err := db.Create(&newNoti).Error; err != nil {
+	if err := tx.Create(&newNoti).Error; err != nil {
 		return &utils.ServiceError{
 			StatusCode: http.StatusInternalServerError,
 			Message:    err.Error(),
@@ -39,17 +57,16 @@ func NotificationHandler(senderID, receiverID uuid.UUID, message, notiType strin
 	event := types.WebSocketMessage{
 		Type:       newNoti.NotiType,
 		Message:    newNoti.Message,
+		SenderID:   senderID.String(),
 		ReceiverID: receiverID.String(),
 	}
-	payload, _ := json.Marshal(event)
-
-	err := kafkaClient.KafkaWriter.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte(receiverID.String()),
-			Value: payload,
-		},
-	)
-	if err != nil {
+	if err := outbox.Enqueue(tx, outbox.Event{
+		Topic:    kafkaClient.NotificationsTopic,
+		Key:      receiverID.String(),
+		DedupKey: fmt.Sprintf("notification:%s", newNoti.ID),
+		Type:     eventType,
+		Payload:  event,
+	}); err != nil {
 		return &utils.ServiceError{
 			StatusCode: http.StatusInternalServerError,
 			Message:    err.Error(),
@@ -70,33 +87,97 @@ func GetNotificationsByUserID(userID uuid.UUID, currentPage, itemsPerPage int, o
 	}
 	defer rows.Close()
 
-	notificationMap := make(map[uint]map[string]interface{})
+	notifications, totalCount, err := scanNotifications(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build pagination metadata once there is a page
 	var paginationResult map[string]interface{}
+	if len(notifications) > 0 {
+		paginated, err := utils.Paginate(int64(totalCount), currentPage, itemsPerPage)
+		if err != nil {
+			return nil, &utils.ServiceError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    err.Error(),
+			}
+		}
+		paginationResult = paginated
+	}
+
+	items := make([]map[string]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		items = append(items, notification.item)
+	}
+
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": paginationResult,
+	}, nil
+}
 
+// GetNotificationPageByUserID is GetNotificationsByUserID with cursor pagination, newest notifications first
+func GetNotificationPageByUserID(userID uuid.UUID, page utils.CursorPage, searchValue string, isSeen bool, all bool) (map[string]interface{}, *utils.ServiceError) {
+	rows, err := functions.ListNotificationPage(searchValue, page, isSeen, userID, all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications, _, err := scanNotifications(rows)
+	if err != nil {
+		return nil, err
+	}
+	notifications, hasMore := utils.TrimCursorPage(notifications, page)
+
+	var first, last *utils.Cursor
+	items := make([]map[string]interface{}, 0, len(notifications))
+	for i := range notifications {
+		items = append(items, notifications[i].item)
+	}
+	if len(notifications) > 0 {
+		first = &notifications[0].cursor
+		last = &notifications[len(notifications)-1].cursor
+	}
+
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": utils.CursorPaginate(page, first, last, hasMore),
+	}, nil
+}
+
+type notificationRow struct {
+	item   map[string]interface{}
+	cursor utils.Cursor
+}
+
+// scanNotifications reads the rows of a notification list in order, with the total_count column of the rows
+func scanNotifications(rows *sql.Rows) ([]notificationRow, int, *utils.ServiceError) {
+	var (
+		notifications []notificationRow
+		totalCount    int
+	)
 	for rows.Next() {
 		// Declare variables for scanning
 		var (
-			notificationID, senderID, receiverID uint
+			notificationID, senderID, receiverID uuid.UUID
 			message                              sql.NullString
 			createdAt, updatedAt                 time.Time
 			isSeen                               sql.NullBool
 			notiType                             sql.NullString
-			totalCount                           int
 		)
 		if err := rows.Scan(
 			&notificationID, &senderID, &receiverID,
 			&message, &createdAt, &updatedAt, &isSeen, &notiType,
 			&totalCount); err != nil {
-			return nil, &utils.ServiceError{
+			return nil, 0, &utils.ServiceError{
 				StatusCode: http.StatusInternalServerError,
 				Message:    err.Error(),
 			}
 		}
 
-		// If post doesn't exist in map, initialize it
-		notifications, exists := notificationMap[notificationID]
-		if !exists {
-			notifications = map[string]interface{}{
+		notifications = append(notifications, notificationRow{
+			item: map[string]interface{}{
 				"id":         notificationID,
 				"sender_id":  senderID,
 				"message":    message.String,
@@ -104,37 +185,26 @@ func GetNotificationsByUserID(userID uuid.UUID, currentPage, itemsPerPage int, o
 				"type":       notiType.String,
 				"created_at": createdAt.String(),
 				"updated_at": updatedAt.String(),
-			}
-			notificationMap[notificationID] = notifications
-		}
-
-		// Build pagination metadata once
-		if paginationResult == nil {
-			paginated, err := utils.Paginate(int64(totalCount), currentPage, itemsPerPage)
-			if err != nil {
-				return nil, &utils.ServiceError{
-					StatusCode: http.StatusInternalServerError,
-					Message:    err.Error(),
-				}
-			}
-			paginationResult = paginated
-		}
+			},
+			cursor: utils.Cursor{CreatedAt: createdAt, ID: notificationID},
+		})
 	}
-	// Convert postMap to slice
-	items := make([]map[string]interface{}, 0, len(notificationMap))
-	for _, post := range notificationMap {
-		items = append(items, post)
+	if err := rows.Err(); err != nil {
+		return nil, 0, &utils.ServiceError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
 	}
-
-	return map[string]interface{}{
-		"items":      items,
-		"pagination": paginationResult,
-	}, nil
+	return notifications, totalCount, nil
 }
 
-func UpdateIsSeen(notificationID, userID uuid.UUID) *utils.ServiceError {
+// UpdateIsSeen marks one of the user's notifications as seen
+func UpdateIsSeen(notificationID, userID, roleID uuid.UUID) *utils.ServiceError {
 	db := mysql.DB
 
+	if serviceErr := PermissionServices.AuthorizeResource(db, userID, roleID, PermissionServices.ResourceNotification, PermissionServices.ActionUpdate, notificationID); serviceErr != nil {
+		return serviceErr
+	}
 	if err := db.Model(&notifications.Notification{}).
 		Where("id = ? AND receiver_id = ?", notificationID, userID).
 		Update("is_seen", true).Error; err != nil {
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusPublished = "published"
+	StatusDead      = "dead"
+)
+
+// OutboxEvent is written in the same transaction as the domain change and published to Kafka by the relay
+type OutboxEvent struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Topic       string         `gorm:"type:varchar(255);not null"`
+	EventKey    string         `gorm:"type:varchar(255);default:null"`
+	DedupKey    string         `gorm:"type:varchar(191);not null;uniqueIndex"`
+	EventType   string         `gorm:"type:varchar(50);not null"`
+	Payload     datatypes.JSON `gorm:"type:json;not null"`
+	Status      string         `gorm:"type:varchar(16);not null;default:pending"`
+	Attempts    int            `gorm:"not null;default:0"`
+	LastError   string         `gorm:"type:text;default:null"`
+	LeaseID     *uuid.UUID     `gorm:"type:uuid;default:null"`
+	LeasedUntil *time.Time
+	AvailableAt time.Time `gorm:"not null"`
+	PublishedAt *time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// OutboxDeadLetter keeps an event the relay gave up on, for inspection and manual replay
+type OutboxDeadLetter struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	EventID   uuid.UUID      `gorm:"type:uuid;not null"`
+	Topic     string         `gorm:"type:varchar(255);not null"`
+	EventKey  string         `gorm:"type:varchar(255);default:null"`
+	DedupKey  string         `gorm:"type:varchar(191);not null"`
+	EventType string         `gorm:"type:varchar(50);not null"`
+	Payload   datatypes.JSON `gorm:"type:json;not null"`
+	Attempts  int            `gorm:"not null;default:0"`
+	LastError string         `gorm:"type:text;default:null"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+}
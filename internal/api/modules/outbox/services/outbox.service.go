@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	Outbox "github.com/unarya/univia/internal/api/modules/outbox/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Domain event types carried through the outbox
+const (
+	EventPostLiked             = "post_liked"
+	EventPostShared            = "post_shared"
+	EventCommentCreated        = "comment_created"
+	EventCommentLiked          = "comment_liked"
+	EventUserFollowed          = "user_followed"
+	EventFollowRequested       = "follow_requested"
+	EventFollowRequestAccepted = "follow_request_accepted"
+	EventFriendRequestSent     = "friend_request_sent"
+	EventFriendRequestAccepted = "friend_request_accepted"
+	EventMessageSent           = "message_sent"
+	EventMessageEdited         = "message_edited"
+	EventMessageDeleted        = "message_deleted"
+	EventMessageRead           = "message_read"
+	EventConversationUpdated   = "conversation_updated"
+	EventPostCreated           = "post_created"
+	EventPostUpdated           = "post_updated"
+	EventPostDeleted           = "post_deleted"
+	EventPostRestored          = "post_restored"
+	EventUserRegistered        = "user_registered"
+	EventUserMentioned         = "user_mentioned"
+)
+
+// Event describes a message to publish once the surrounding transaction commits
+type Event struct {
+	Topic string
+	// Key is the Kafka partition key, usually the receiver so their events stay ordered
+	Key string
+	// DedupKey identifies the domain event; enqueueing it twice is a no-op and consumers skip repeats
+	DedupKey string
+	Type     string
+	Payload  interface{}
+}
+
+// Enqueue stores the event with tx, so it is published if and only if the domain change commits
+func Enqueue(tx *gorm.DB, event Event) error {
+	if event.Topic == "" || event.DedupKey == "" || event.Type == "" {
+		return errors.New("outbox event requires a topic, a dedup key and a type")
+	}
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+
+	row := Outbox.OutboxEvent{
+		ID:          uuid.New(),
+		Topic:       event.Topic,
+		EventKey:    event.Key,
+		DedupKey:    event.DedupKey,
+		EventType:   event.Type,
+		Payload:     payload,
+		Status:      Outbox.StatusPending,
+		AvailableAt: time.Now(),
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error
+}
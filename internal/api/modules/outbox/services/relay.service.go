@@ -0,0 +1,231 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	Outbox "github.com/unarya/univia/internal/api/modules/outbox/models"
+	kafkaClient "github.com/unarya/univia/internal/infrastructure/kafka"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RelayInterval  = time.Second
+	RelayBatchSize = 100
+	// RelayLease is how long a relay owns the batch it claimed before another relay may take it over
+	RelayLease = time.Minute
+	// MaxAttempts before an event is moved to the dead-letter table
+	MaxAttempts = 10
+	MaxBackoff  = 10 * time.Minute
+	// PublishedRetention is how long published rows are kept for auditing
+	PublishedRetention = 7 * 24 * time.Hour
+
+	// Kafka headers consumers use to recognise redelivered events
+	HeaderDedupKey  = "dedup-key"
+	HeaderEventType = "event-type"
+)
+
+// StartRelay publishes pending outbox events until ctx is cancelled.
+// Replicas may all run it: each batch is leased to one relay, which publishes it after the lease commits.
+func StartRelay(ctx context.Context) {
+	writer := kafkaClient.NewWriter()
+	defer writer.Close()
+
+	ticker := time.NewTicker(RelayInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Drain the backlog before waiting for the next tick
+		for {
+			n, err := relayBatch(ctx, writer)
+			if err != nil {
+				log.Printf("[Outbox] Relay failed: %v", err)
+				break
+			}
+			if n < RelayBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > time.Hour {
+			purgePublished()
+			lastPurge = time.Now()
+		}
+	}
+}
+
+// relayBatch leases one batch, publishes it and records the outcome of every event in it
+func relayBatch(ctx context.Context, writer *kafka.Writer) (int, error) {
+	leaseID := uuid.New()
+	events, err := leaseBatch(leaseID)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, kafka.Message{
+			Topic: event.Topic,
+			Key:   []byte(event.EventKey),
+			Value: event.Payload,
+			Headers: []kafka.Header{
+				{Key: HeaderDedupKey, Value: []byte(event.DedupKey)},
+				{Key: HeaderEventType, Value: []byte(event.EventType)},
+			},
+		})
+	}
+
+	// The publish must end before the lease does, or another relay could publish the batch at the same time
+	publishCtx, cancel := context.WithTimeout(ctx, RelayLease/2)
+	publishErr := writer.WriteMessages(publishCtx, messages...)
+	cancel()
+	var writeErrors kafka.WriteErrors
+	perMessage := errors.As(publishErr, &writeErrors)
+
+	now := time.Now()
+	for i := range events {
+		err := publishErr
+		if perMessage {
+			err = writeErrors[i]
+		}
+		if err == nil {
+			err = markPublished(&events[i], leaseID, now)
+		} else {
+			err = recordFailure(&events[i], leaseID, err, now)
+		}
+		// Events left unrecorded are retried once their lease expires
+		if err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// leaseBatch claims due events for this relay and commits, so no row lock is held while Kafka is slow
+func leaseBatch(leaseID uuid.UUID) ([]Outbox.OutboxEvent, error) {
+	var events []Outbox.OutboxEvent
+	err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ? AND (leased_until IS NULL OR leased_until < ?)", Outbox.StatusPending, now, now).
+			Order("created_at ASC").
+			Limit(RelayBatchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return tx.Model(&Outbox.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"lease_id":     leaseID,
+				"leased_until": now.Add(RelayLease),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// updateLeased updates the event only while this relay still holds its lease, and releases the lease
+func updateLeased(tx *gorm.DB, event *Outbox.OutboxEvent, leaseID uuid.UUID, values map[string]interface{}) (bool, error) {
+	values["lease_id"] = nil
+	values["leased_until"] = nil
+	result := tx.Model(&Outbox.OutboxEvent{}).
+		Where("id = ? AND lease_id = ?", event.ID, leaseID).
+		Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("[Outbox] Lease on event %s (%s) expired before its outcome was recorded", event.ID, event.EventType)
+		return false, nil
+	}
+	return true, nil
+}
+
+func markPublished(event *Outbox.OutboxEvent, leaseID uuid.UUID, now time.Time) error {
+	_, err := updateLeased(mysql.DB, event, leaseID, map[string]interface{}{
+		"status":       Outbox.StatusPublished,
+		"published_at": now,
+		"attempts":     event.Attempts + 1,
+	})
+	return err
+}
+
+// recordFailure schedules a retry with exponential backoff, or dead-letters the event once it ran out of attempts
+func recordFailure(event *Outbox.OutboxEvent, leaseID uuid.UUID, cause error, now time.Time) error {
+	attempts := event.Attempts + 1
+	if attempts >= MaxAttempts {
+		return mysql.DB.Transaction(func(tx *gorm.DB) error {
+			leased, err := updateLeased(tx, event, leaseID, map[string]interface{}{
+				"status":     Outbox.StatusDead,
+				"attempts":   attempts,
+				"last_error": cause.Error(),
+			})
+			if err != nil || !leased {
+				return err
+			}
+			deadLetter := Outbox.OutboxDeadLetter{
+				ID:        uuid.New(),
+				EventID:   event.ID,
+				Topic:     event.Topic,
+				EventKey:  event.EventKey,
+				DedupKey:  event.DedupKey,
+				EventType: event.EventType,
+				Payload:   event.Payload,
+				Attempts:  attempts,
+				LastError: cause.Error(),
+			}
+			if err := tx.Create(&deadLetter).Error; err != nil {
+				return err
+			}
+			log.Printf("[Outbox] Event %s (%s) dead-lettered after %d attempts: %v", event.ID, event.EventType, attempts, cause)
+			return nil
+		})
+	}
+
+	backoff := time.Second << attempts
+	if backoff > MaxBackoff {
+		backoff = MaxBackoff
+	}
+	_, err := updateLeased(mysql.DB, event, leaseID, map[string]interface{}{
+		"attempts":     attempts,
+		"last_error":   cause.Error(),
+		"available_at": now.Add(backoff),
+	})
+	return err
+}
+
+func purgePublished() {
+	result := mysql.DB.
+		Where("status = ? AND published_at < ?", Outbox.StatusPublished, time.Now().Add(-PublishedRetention)).
+		Delete(&Outbox.OutboxEvent{})
+	if result.Error != nil {
+		log.Printf("[Outbox] Purge failed: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Outbox] Purged %d published events", result.RowsAffected)
+	}
+}
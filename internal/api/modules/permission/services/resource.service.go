@@ -0,0 +1,176 @@
+package permissions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/unarya/univia/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Resources whose ownership AuthorizeResource checks
+const (
+	ResourcePost         = "post"
+	ResourceComment      = "comment"
+	ResourceMedia        = "media"
+	ResourceNotification = "notification"
+)
+
+// Actions a user performs on a resource
+const (
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+type resourceRule struct {
+	table string
+	joins string
+	// scope hides resources that no longer exist for users, such as media of a deleted post
+	scope string
+	// owners lists, per action, the columns of the users allowed to perform it
+	owners map[string][]string
+	// overrides lists, per action, the moderator permission that allows it on anyone's resource.
+	// Team roles hold ALLOW_UPDATE_POST and ALLOW_DELETE_POST for their own content, so those never override.
+	overrides map[string]string
+}
+
+var resourceRules = map[string]resourceRule{
+	ResourcePost: {
+		table: "posts",
+		scope: "posts.deleted_at IS NULL",
+		owners: map[string][]string{
+			ActionUpdate: {"posts.user_id"},
+			ActionDelete: {"posts.user_id"},
+		},
+		overrides: map[string]string{
+			ActionUpdate: utils.Permissions["ALLOW_MODERATE_POST"],
+			ActionDelete: utils.Permissions["ALLOW_MODERATE_POST"],
+		},
+	},
+	ResourceComment: {
+		table: "comments",
+		joins: "JOIN posts ON posts.id = comments.post_id",
+		scope: "posts.deleted_at IS NULL",
+		owners: map[string][]string{
+			ActionUpdate: {"comments.user_id"},
+			// The post owner moderates the comments under their post
+			ActionDelete: {"comments.user_id", "posts.user_id"},
+		},
+		overrides: map[string]string{
+			ActionDelete: utils.Permissions["ALLOW_MODERATE_COMMENT"],
+		},
+	},
+	ResourceMedia: {
+		table: "media",
+		joins: "JOIN posts ON posts.id = media.post_id",
+		scope: "posts.deleted_at IS NULL",
+		owners: map[string][]string{
+			ActionUpdate: {"posts.user_id"},
+			ActionDelete: {"posts.user_id"},
+		},
+		overrides: map[string]string{
+			ActionUpdate: utils.Permissions["ALLOW_MODERATE_POST"],
+			ActionDelete: utils.Permissions["ALLOW_MODERATE_POST"],
+		},
+	},
+	ResourceNotification: {
+		table: "notifications",
+		// Notifications are private to their receiver, no role can act on someone else's
+		owners: map[string][]string{
+			ActionUpdate: {"notifications.receiver_id"},
+			ActionDelete: {"notifications.receiver_id"},
+		},
+	},
+}
+
+// AuthorizeResource checks that the user may perform action on the resource with resourceID: either they own it,
+// or their role holds the permission that overrides ownership for that action.
+// Pass the caller's transaction so the check and the change see the same rows.
+func AuthorizeResource(tx *gorm.DB, userID, roleID uuid.UUID, resource, action string, resourceID uuid.UUID) *utils.ServiceError {
+	rule, ok := resourceRules[resource]
+	if !ok || len(rule.owners[action]) == 0 {
+		return &utils.ServiceError{StatusCode: http.StatusForbidden, Message: fmt.Sprintf("Cannot %s a %s", action, resource)}
+	}
+
+	owners, err := selectResourceOwners(tx, rule, rule.owners[action], resourceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("%s not found", capitalize(resource))}
+	}
+	if err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Failed to get %s", resource)}
+	}
+
+	for _, ownerID := range owners {
+		if ownerID == userID {
+			return nil
+		}
+	}
+	if CanOverrideOwnership(roleID, resource, action) {
+		return nil
+	}
+	return &utils.ServiceError{
+		StatusCode: http.StatusForbidden,
+		Message:    fmt.Sprintf("You are not allowed to %s this %s", action, resource),
+	}
+}
+
+// CanOverrideOwnership reports whether the role may perform action on resources it does not own
+func CanOverrideOwnership(roleID uuid.UUID, resource, action string) bool {
+	permission := resourceRules[resource].overrides[action]
+	if permission == "" {
+		return false
+	}
+	return CheckPermission(roleID, permission)
+}
+
+func selectResourceOwners(tx *gorm.DB, rule resourceRule, columns []string, resourceID uuid.UUID) ([]uuid.UUID, error) {
+	query := tx.Table(rule.table).
+		Select(strings.Join(columns, ", ")).
+		Where(fmt.Sprintf("%s.id = ?", rule.table), resourceID)
+	if rule.joins != "" {
+		query = query.Joins(rule.joins)
+	}
+	if rule.scope != "" {
+		query = query.Where(rule.scope)
+	}
+
+	rows, err := query.Limit(1).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+
+	values := make([]uuid.NullUUID, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	owners := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		if value.Valid {
+			owners = append(owners, value.UUID)
+		}
+	}
+	return owners, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
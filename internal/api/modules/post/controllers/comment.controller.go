@@ -0,0 +1,290 @@
+package posts
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// CreateComment godoc
+// @Summary Comment on a post or reply to a comment
+// @Description Adds a top-level comment, or a reply when parent_id is given. Notifies the post owner and the parent comment author.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CreateCommentRequest true "Comment"
+// @Success 201 {object} map[string]interface{} "Comment created successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Post or parent comment not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments/create [post]
+func CreateComment(c *gin.Context) {
+	var request types.CreateCommentRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	comment, err := posts.CreateComment(currentUser.ID, request.PostID, request.ParentID, request.Text)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to create comment", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Comment created successfully", comment)
+}
+
+// ListComments godoc
+// @Summary List comment threads of a post
+// @Description Paginates the top-level comments of a post, each with its replies down to `depth` levels.
+// @Description Set pagination to "cursor" to page oldest first with next_cursor/prev_cursor instead of current_page.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListCommentsRequest true "Post and pagination"
+// @Success 200 {object} map[string]interface{} "List comments successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments [post]
+func ListComments(c *gin.Context) {
+	var request types.ListCommentsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	depth := -1 // service default
+	if request.Depth != nil {
+		depth = *request.Depth
+	}
+
+	var (
+		response map[string]interface{}
+		err      *utils.ServiceError
+	)
+	if utils.UseCursor(request.Pagination, request.Cursor) {
+		page, cursorErr := utils.NewCursorPage(request.Cursor, request.ItemsPerPage)
+		if cursorErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid cursor", cursorErr)
+			return
+		}
+		response, err = posts.ListCommentPage(currentUser.ID, request.PostID, page, depth)
+	} else {
+		response, err = posts.ListComments(currentUser.ID, request.PostID, request.CurrentPage, request.ItemsPerPage, depth)
+	}
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list comments", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List comments successfully", response)
+}
+
+// GetCommentThread godoc
+// @Summary Get a comment with its replies
+// @Description Returns one comment and its subtree down to `depth` levels below it
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param id query string true "Comment ID"
+// @Param depth query int false "Reply levels to include (default 2)"
+// @Success 200 {object} map[string]interface{} "Successfully get comment thread"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Comment not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments [get]
+func GetCommentThread(c *gin.Context) {
+	commentID, parseErr := uuid.Parse(c.Query("id"))
+	if parseErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "A valid id is required", parseErr)
+		return
+	}
+	depth := -1
+	if raw := c.Query("depth"); raw != "" {
+		parsed, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "depth must be a number", convErr)
+			return
+		}
+		depth = parsed
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	thread, err := posts.GetCommentThread(currentUser.ID, commentID, depth)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to get comment thread", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully get comment thread", thread)
+}
+
+// UpdateComment godoc
+// @Summary Edit a comment
+// @Description Changes the text of one of the current user's comments
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.UpdateCommentRequest true "Comment ID and new text"
+// @Success 200 {object} map[string]interface{} "Comment updated successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} types.StatusForbidden "Not the author"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments [put]
+func UpdateComment(c *gin.Context) {
+	var request types.UpdateCommentRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	comment, err := posts.EditComment(currentUser.ID, currentUser.RoleID, request.CommentID, request.Text)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update comment", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Comment updated successfully", comment)
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Deletes a comment and all of its replies. Allowed for the comment author, the post owner and moderators.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CommentIDRequest true "Comment ID"
+// @Success 200 {object} map[string]interface{} "Comment deleted successfully"
+// @Failure 403 {object} types.StatusForbidden "Not allowed"
+// @Failure 404 {object} map[string]interface{} "Comment not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments [delete]
+func DeleteComment(c *gin.Context) {
+	var request types.CommentIDRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	deleted, err := posts.DeleteComment(currentUser.ID, currentUser.RoleID, request.CommentID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to delete comment", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Comment deleted successfully", gin.H{"deleted": deleted})
+}
+
+// LikeComment godoc
+// @Summary Like a comment
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CommentIDRequest true "Comment ID"
+// @Success 200 {object} map[string]interface{} "Successfully liked comment"
+// @Failure 400 {object} types.StatusBadRequest "Already liked"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments/likes [post]
+func LikeComment(c *gin.Context) {
+	var request types.CommentIDRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	totalLikes, err := posts.LikeComment(currentUser.ID, request.CommentID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "An error occurred during calculation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully liked comment", gin.H{"totalLikes": totalLikes})
+}
+
+// UnlikeComment godoc
+// @Summary Undo a comment like
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CommentIDRequest true "Comment ID"
+// @Success 200 {object} map[string]interface{} "Successfully unliked comment"
+// @Failure 400 {object} types.StatusBadRequest "Not liked"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/comments/likes/undo [post]
+func UnlikeComment(c *gin.Context) {
+	var request types.CommentIDRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	totalLikes, err := posts.UnlikeComment(currentUser.ID, request.CommentID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "An error occurred during calculation", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully unliked comment", gin.H{"totalLikes": totalLikes})
+}
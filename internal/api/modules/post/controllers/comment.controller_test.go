@@ -0,0 +1,161 @@
+package posts
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// commentColumns are the columns of the comments table, with the fixture's comment as a top-level one
+var commentColumns = []string{"id", "post_id", "user_id", "parent_id", "text", "left", "right", "depth", "created_at", "updated_at"}
+
+func (f *ownershipFixture) commenterUser() *Users.User {
+	return &Users.User{ID: f.commenter, RoleID: f.ownerUser().RoleID}
+}
+
+func (f *ownershipFixture) commentRow() *sqlmock.Rows {
+	return sqlmock.NewRows(commentColumns).
+		AddRow(f.commentID.String(), f.postID.String(), f.commenter.String(), nil, "nice", 1, 2, 0, time.Now(), time.Now())
+}
+
+// expectCommentOwners expects the lookup of the users who own the comment for an action:
+// its author to edit it, and also the post owner to delete it
+func (f *ownershipFixture) expectCommentOwners(columns ...string) {
+	rows := sqlmock.NewRows(columns)
+	if len(columns) == 1 {
+		rows.AddRow(f.commenter.String())
+	} else {
+		rows.AddRow(f.commenter.String(), f.owner.String())
+	}
+	f.mock.ExpectQuery("SELECT "+columns[0]+".* FROM `comments` JOIN posts ON posts.id = comments.post_id WHERE comments.id = \\?").
+		WithArgs(f.commentID, 1).
+		WillReturnRows(rows)
+}
+
+func (f *ownershipFixture) expectCommentEdit() {
+	f.expectCommentOwners("comments.user_id")
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery("SELECT \\* FROM `comments` WHERE id = \\?").
+		WithArgs(f.commentID, 1).
+		WillReturnRows(f.commentRow())
+	f.mock.ExpectExec("UPDATE `comments` SET `text`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs("edited", sqlmock.AnyArg(), f.commentID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectQuery("SELECT \\* FROM `post_tags` WHERE post_id = \\? AND comment_id = \\?").
+		WithArgs(f.postID, f.commentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectQuery("SELECT \\* FROM `mentions` WHERE post_id = \\? AND comment_id = \\?").
+		WithArgs(f.postID, f.commentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectCommit()
+	f.mock.ExpectQuery("SELECT .* FROM `comments`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "user_id", "text", "left", "right"}).
+			AddRow(f.commentID.String(), f.postID.String(), f.commenter.String(), "edited", 1, 2))
+}
+
+// expectCommentLock expects the comment to be read and its post locked before authorizing the delete
+func (f *ownershipFixture) expectCommentLock() {
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery("SELECT \\* FROM `comments` WHERE id = \\?").
+		WithArgs(f.commentID, 1).
+		WillReturnRows(f.commentRow())
+	f.mock.ExpectQuery("SELECT `id`,`user_id` FROM `posts` WHERE id = \\? AND deleted_at IS NULL AND status = 'published'").
+		WithArgs(f.postID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(f.postID.String(), f.owner.String()))
+	f.expectCommentOwners("comments.user_id", "posts.user_id")
+}
+
+// expectCommentDelete expects the comment to be removed from the post's comment tree
+func (f *ownershipFixture) expectCommentDelete() {
+	f.expectCommentLock()
+	f.mock.ExpectQuery("SELECT \\* FROM `comments` WHERE id = \\?").
+		WithArgs(f.commentID, 1).
+		WillReturnRows(f.commentRow())
+	f.mock.ExpectExec("DELETE FROM `comments` WHERE post_id = \\? AND `left` BETWEEN \\? AND \\?").
+		WithArgs(f.postID, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectExec("UPDATE `comments` SET `right`=`right` - \\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	f.mock.ExpectExec("UPDATE `comments` SET `left`=`left` - \\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	f.mock.ExpectCommit()
+}
+
+func (f *ownershipFixture) editComment(actor *Users.User) int {
+	return serve(actor, http.MethodPut, UpdateComment, map[string]interface{}{"comment_id": f.commentID, "text": "edited"}).Code
+}
+
+func (f *ownershipFixture) deleteComment(actor *Users.User) int {
+	return serve(actor, http.MethodDelete, DeleteComment, map[string]interface{}{"comment_id": f.commentID}).Code
+}
+
+func TestUpdateCommentByAuthor(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectCommentEdit()
+
+	if status := f.editComment(f.commenterUser()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+}
+
+func TestUpdateCommentOfAnotherUserIsForbidden(t *testing.T) {
+	f := newOwnershipFixture(t)
+	// Not even the post owner rewrites what others said
+	f.expectCommentOwners("comments.user_id")
+
+	if status := f.editComment(f.ownerUser()); status != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", status)
+	}
+}
+
+func TestUpdateCommentByModeratorIsForbidden(t *testing.T) {
+	// Moderators remove comments, they do not put words in their authors' mouths
+	f := newOwnershipFixture(t)
+	f.expectCommentOwners("comments.user_id")
+
+	if status := f.editComment(f.moderatorUser()); status != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", status)
+	}
+}
+
+func TestDeleteCommentByAuthor(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectCommentDelete()
+
+	if status := f.deleteComment(f.commenterUser()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+}
+
+func TestDeleteCommentByPostOwner(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectCommentDelete()
+
+	if status := f.deleteComment(f.ownerUser()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+}
+
+func TestDeleteCommentOfAnotherUserIsForbidden(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectCommentLock()
+	f.expectNoOverride(utils.Permissions["ALLOW_MODERATE_COMMENT"])
+	f.mock.ExpectRollback()
+
+	if status := f.deleteComment(f.otherUser()); status != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", status)
+	}
+}
+
+func TestDeleteCommentByModerator(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectCommentDelete()
+
+	if status := f.deleteComment(f.moderatorUser()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+}
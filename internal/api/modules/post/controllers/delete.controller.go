@@ -0,0 +1,91 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// DeletePost godoc
+// @Summary Delete a post
+// @Description Hides the post; it can be restored within 30 days, after which it is purged with its likes, comments, shares, notifications and media files. Allowed for the owner and moderators.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.DeletePostRequest true "Post ID Required"
+// @Success 200 {object} map[string]interface{} "Post deleted successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Not allowed to delete this post"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts [delete]
+func DeletePost(c *gin.Context) {
+	var request types.DeletePostRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	result, err := posts.DeletePost(currentUser, request.PostID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to delete post", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Post deleted successfully", result)
+}
+
+// RestorePost godoc
+// @Summary Restore a deleted post
+// @Description Restores a post deleted within the last 30 days. A post removed by a moderator can only be restored by a moderator.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.DeletePostRequest true "Post ID Required"
+// @Success 200 {object} map[string]interface{} "Post restored successfully"
+// @Failure 400 {object} types.StatusBadRequest "Post is not deleted"
+// @Failure 403 {object} map[string]interface{} "Not allowed to restore this post"
+// @Failure 410 {object} map[string]interface{} "Restore window has passed"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts/restore [post]
+func RestorePost(c *gin.Context) {
+	var request types.DeletePostRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := posts.RestorePost(currentUser, request.PostID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to restore post", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Post restored successfully", gin.H{"post_id": request.PostID})
+}
@@ -0,0 +1,83 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDrafts godoc
+// @Summary List drafts
+// @Description Lists the current user's drafts and scheduled posts, or archived posts, last edited first.
+// @Description Edit their content with PUT /api/v1/posts and publish, schedule or archive them with PUT /api/v1/posts/status.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListDraftsRequest true "Statuses and page"
+// @Success 200 {object} map[string]interface{} "List drafts successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts/drafts [post]
+func ListDrafts(c *gin.Context) {
+	var request types.ListDraftsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := posts.ListDrafts(currentUser.ID, request.Statuses, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list drafts", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List drafts successfully", response)
+}
+
+// UpdatePostStatus godoc
+// @Summary Publish, schedule or archive a post
+// @Description Schedules a draft for publish_at or publishes it at once, takes a scheduled post back to drafts,
+// @Description and archives or unarchives a published post. Allowed for the owner and moderators.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.UpdatePostStatusRequest true "Post, status and publish time"
+// @Success 200 {object} map[string]interface{} "Post status updated successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Not allowed to update this post"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 409 {object} map[string]interface{} "The post cannot move to this status"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts/status [put]
+func UpdatePostStatus(c *gin.Context) {
+	var request types.UpdatePostStatusRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	result, err := posts.ChangePostStatus(currentUser, request.PostID, request.Status, request.PublishAt)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update post status", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Post status updated successfully", result)
+}
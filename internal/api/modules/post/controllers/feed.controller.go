@@ -0,0 +1,51 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// HomeFeed godoc
+// @Summary Home feed
+// @Description Posts and shares from followed users and friends, with posts in the categories of the user's interests,
+// @Description ranked by recency and engagement. Pages with next_cursor/prev_cursor.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.HomeFeedRequest true "Cursor and page size"
+// @Success 200 {object} map[string]interface{} "Get home feed successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts/feed [post]
+func HomeFeed(c *gin.Context) {
+	var request types.HomeFeedRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	page, cursorErr := utils.NewCursorPage(request.Cursor, request.ItemsPerPage)
+	if cursorErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid cursor", cursorErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := posts.HomeFeed(currentUser.ID, page)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to get home feed", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Get home feed successfully", response)
+}
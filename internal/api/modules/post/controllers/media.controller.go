@@ -0,0 +1,73 @@
+package posts
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/internal/infrastructure/storage"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// ServeMedia godoc
+// @Summary Read a media file
+// @Description Serves a file of the local storage backend through the presigned URL returned with posts. MinIO URLs point at MinIO directly.
+// @Tags Social Routes
+// @Produce octet-stream
+// @Param key path string true "Storage key"
+// @Param expires query string true "Expiry of the URL, unix seconds"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} file "Media file"
+// @Failure 403 {object} map[string]interface{} "Invalid or expired signature"
+// @Failure 404 {object} map[string]interface{} "Media not found"
+// @Router /api/v1/media/{key} [get]
+func ServeMedia(c *gin.Context) {
+	local, ok := storage.Blob.(*storage.LocalStorage)
+	if !ok {
+		utils.SendErrorResponse(c, http.StatusNotFound, "Media not found", nil)
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	path, err := local.Verify(http.MethodGet, key, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		utils.SendErrorResponse(c, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	c.File(path)
+}
+
+// ReceiveMedia godoc
+// @Summary Upload a media file
+// @Description Stores the request body through a presigned PUT URL of the local storage backend. MinIO URLs point at MinIO directly.
+// @Tags Social Routes
+// @Accept octet-stream
+// @Produce json
+// @Param key path string true "Storage key"
+// @Param expires query string true "Expiry of the URL, unix seconds"
+// @Param signature query string true "URL signature"
+// @Success 200 {object} map[string]interface{} "File stored"
+// @Failure 403 {object} map[string]interface{} "Invalid or expired signature"
+// @Failure 404 {object} map[string]interface{} "Not served by this backend"
+// @Router /api/v1/media/{key} [put]
+func ReceiveMedia(c *gin.Context) {
+	local, ok := storage.Blob.(*storage.LocalStorage)
+	if !ok {
+		utils.SendErrorResponse(c, http.StatusNotFound, "Media not found", nil)
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if _, err := local.Verify(http.MethodPut, key, c.Query("expires"), c.Query("signature")); err != nil {
+		utils.SendErrorResponse(c, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, posts.MaxUploadSize)
+	if err := local.Put(c.Request.Context(), key, body, c.Request.ContentLength, c.ContentType()); err != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Failed to store file", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "File stored", gin.H{"key": key})
+}
@@ -25,7 +25,11 @@ import (
 // @Security BearerAuth
 // @Param content formData string true "Post content"
 // @Param category_ids formData []string true "List of category UUIDs"
-// @Param media formData file true "Media files (multiple allowed)"
+// @Param media formData file false "Media files (multiple allowed)"
+// @Param upload_ids formData []string false "Completed upload sessions to attach, for large files uploaded straight to storage"
+// @Param status formData string false "published (default), draft or scheduled"
+// @Param publish_at formData string false "RFC 3339 time a scheduled post gets published"
+// @Param visibility formData string false "public (default), followers, friends, only_me or team"
 // @Success 201 {object} map[string]interface{} "Post Created Successfully"
 // @Failure 400 {object} types.StatusBadRequest "Bad Request"
 // @Failure 401 {object} types.StatusUnauthorized "Unauthorized"
@@ -48,6 +52,22 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 	files := form.File["media"]
+	uploadIDs, err := utils.ParseUUIDs(c.PostFormArray("upload_ids"))
+	if err != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid upload_ids", err)
+		return
+	}
+
+	status := c.PostForm("status")
+	var publishAt *time.Time
+	if raw := c.PostForm("publish_at"); raw != "" {
+		parsed, parseErr := time.Parse(time.RFC3339, raw)
+		if parseErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid publish_at", parseErr)
+			return
+		}
+		publishAt = &parsed
+	}
 
 	// Step 3: Get current user from context
 	user, exists := c.Get("user")
@@ -58,7 +78,7 @@ func CreatePost(c *gin.Context) {
 	currentUser, _ := user.(*model.User)
 
 	// Step 4: Call service to create post
-	result, serviceError := posts.CreatePost(content, categoryIDs, files, currentUser.ID)
+	result, serviceError := posts.CreatePost(content, categoryIDs, files, uploadIDs, currentUser.ID, status, publishAt, c.PostForm("visibility"))
 	if serviceError != nil {
 		utils.SendErrorResponse(c, serviceError.StatusCode, "Failed to create post", serviceError)
 		return
@@ -70,7 +90,8 @@ func CreatePost(c *gin.Context) {
 
 // ListAllPost godoc
 // @Summary List all posts
-// @Description Get all posts with pagination, search, and sorting
+// @Description Get all posts with pagination, search, and sorting.
+// @Description Set pagination to "cursor" to page newest first with next_cursor/prev_cursor instead of current_page.
 // @Tags Social Routes
 // @Accept json
 // @Produce json
@@ -81,6 +102,8 @@ func CreatePost(c *gin.Context) {
 //     OrderBy      string `json:"order_by"`
 //     SortBy       string `json:"sort_by"`
 //     SearchValue  string `json:"search_value"`
+//     Pagination   string `json:"pagination"`
+//     Cursor       string `json:"cursor"`
 // } true "Pagination and filter"
 // @Success 200 {object} map[string]interface{} "List all posts successfully"
 // @Failure 400 {object} types.StatusBadRequest "Invalid input"
@@ -95,6 +118,8 @@ func ListAllPost(c *gin.Context) {
 		OrderBy      string `json:"order_by"`
 		SortBy       string `json:"sort_by"`
 		SearchValue  string `json:"search_value"`
+		Pagination   string `json:"pagination"`
+		Cursor       string `json:"cursor"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
 		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
@@ -106,13 +131,36 @@ func ListAllPost(c *gin.Context) {
 		return
 	}
 
-	// Try cache
-	cacheKey := fmt.Sprintf("listPost_%d_%d_%s_%s_%s:", request.CurrentPage, request.ItemsPerPage, request.OrderBy, request.SortBy, request.SearchValue)
+	useCursor := utils.UseCursor(request.Pagination, request.Cursor)
+	var page utils.CursorPage
+	if useCursor {
+		var cursorErr error
+		if page, cursorErr = utils.NewCursorPage(request.Cursor, request.ItemsPerPage); cursorErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid cursor", cursorErr)
+			return
+		}
+	}
+
+	// Try cache; lists differ by who reads them, and are all dropped when a post changes visibility
+	version := posts.PostListVersion()
+	cacheKey := fmt.Sprintf("listPost_%d_%s_%d_%d_%s_%s_%s:", version, currentUser.ID, request.CurrentPage, request.ItemsPerPage, request.OrderBy, request.SortBy, request.SearchValue)
+	if useCursor {
+		cacheKey = fmt.Sprintf("listPost_%d_%s_cursor_%s_%d_%s:", version, currentUser.ID, request.Cursor, page.Limit, request.SearchValue)
+	}
 	if results, err := redis.GetJSON[map[string]interface{}](redis.Redis, cacheKey); err == nil && results != nil {
 		utils.SendSuccessResponse(c, http.StatusOK, "List all posts successfully", results)
 		return
 	}
-	response, err := posts.List(request.CurrentPage, request.ItemsPerPage, request.OrderBy, request.SortBy, request.SearchValue, currentUser.ID)
+
+	var (
+		response map[string]interface{}
+		err      error
+	)
+	if useCursor {
+		response, err = posts.ListPage(page, request.SearchValue, currentUser.ID)
+	} else {
+		response, err = posts.List(request.CurrentPage, request.ItemsPerPage, request.OrderBy, request.SortBy, request.SearchValue, currentUser.ID)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -131,6 +179,7 @@ func ListAllPost(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "Successfully get details of this post"
 // @Failure 400 {object} types.StatusBadRequest "ID is required"
 // @Failure 401 {object} types.StatusUnauthorized "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Post not found"
 // @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
 // @Router /api/v1/posts [get]
 func GetDetailsPost(c *gin.Context) {
@@ -139,24 +188,43 @@ func GetDetailsPost(c *gin.Context) {
 		utils.SendErrorResponse(c, http.StatusBadRequest, "Id is required", nil)
 		return
 	}
-	// Try cache
-	cacheKey := fmt.Sprintf("detailPost_%s", id)
-	if results, err := redis.GetJSON[map[string]interface{}](redis.Redis, cacheKey); err == nil && results != nil {
-		utils.SendSuccessResponse(c, http.StatusOK, "Successfully get details of this post", results)
+	postID, parseErr := uuid.Parse(id)
+	if parseErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid id", parseErr)
 		return
 	}
-	response, err := posts.GetDetails(id)
-	if err != nil {
-		utils.SendErrorResponse(c, http.StatusInternalServerError, "Failed to get details", err)
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, "An error occurred during execution", getUserErr)
 		return
 	}
-	_ = redis.Redis.SetJSON(cacheKey, response, 3*time.Minute)
-	utils.SendSuccessResponse(c, http.StatusOK, "Successfully get details successfully", response)
+	// The cached details are shared by everyone who sees the post, so who sees it is checked first
+	if serviceErr := functions.CheckPostExits(postID, currentUser.ID); serviceErr != nil {
+		utils.SendErrorResponse(c, serviceErr.StatusCode, serviceErr.Message, serviceErr)
+		return
+	}
+	// Try cache
+	cacheKey := fmt.Sprintf("detailPost_%s", postID)
+	response, err := redis.GetJSON[map[string]interface{}](redis.Redis, cacheKey)
+	if err != nil || response == nil {
+		details, detailsErr := posts.GetDetails(postID.String())
+		if detailsErr != nil {
+			utils.SendErrorResponse(c, http.StatusInternalServerError, "Failed to get details", detailsErr)
+			return
+		}
+		_ = redis.Redis.SetJSON(cacheKey, details, 3*time.Minute)
+		response = &details
+	}
+	if serviceErr := posts.EmbedSharedPost(*response, currentUser.ID); serviceErr != nil {
+		utils.SendErrorResponse(c, serviceErr.StatusCode, "Failed to get details", serviceErr)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully get details successfully", *response)
 }
 
 // UpdatePost godoc
 // @Summary Update a post
-// @Description Update post content, categories, and media files
+// @Description Update post content, categories, and media files. Drafts and scheduled posts are edited the same way.
 // @Tags Social Routes
 // @Accept multipart/form-data
 // @Produce json
@@ -165,9 +233,12 @@ func GetDetailsPost(c *gin.Context) {
 // @Param content formData string true "Post content"
 // @Param category_ids formData []string true "List of category UUIDs"
 // @Param media formData file false "Media files (multiple allowed)"
+// @Param upload_ids formData []string false "Completed upload sessions to attach"
 // @Success 200 {object} map[string]interface{} "Updated post successfully"
 // @Failure 400 {object} types.StatusBadRequest "Bad Request"
 // @Failure 401 {object} types.StatusUnauthorized "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not allowed to update this post"
+// @Failure 404 {object} map[string]interface{} "Post not found"
 // @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
 // @Router /api/v1/posts [put]
 func UpdatePost(c *gin.Context) {
@@ -189,6 +260,11 @@ func UpdatePost(c *gin.Context) {
 	}
 	// Handle Receive multiple media
 	files := form.File["media"]
+	uploadIDs, err := utils.ParseUUIDs(c.PostFormArray("upload_ids"))
+	if err != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid upload_ids", err)
+		return
+	}
 
 	// Step 3: Get user
 	// Retrieve the user from the context (set by Authorization middleware)
@@ -199,10 +275,12 @@ func UpdatePost(c *gin.Context) {
 	}
 	postInfo := posts.PostInfo{
 		UserID:      currentUser.ID,
+		RoleID:      currentUser.RoleID,
 		PostID:      postID,
 		Content:     content,
 		CategoryIDs: categoryIDs,
 		Media:       files,
+		UploadIDs:   uploadIDs,
 	}
 	serviceError := posts.EditPostByUserID(postInfo)
 	if serviceError != nil {
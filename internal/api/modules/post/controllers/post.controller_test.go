@@ -0,0 +1,263 @@
+package posts
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ownershipFixture is a post by owner with a comment by commenter, and the database and cache the
+// routes that change them use. The moderator role holds the permissions overriding ownership.
+type ownershipFixture struct {
+	mock       sqlmock.Sqlmock
+	owner      uuid.UUID
+	commenter  uuid.UUID
+	postID     uuid.UUID
+	commentID  uuid.UUID
+	categoryID uuid.UUID
+	moderator  uuid.UUID
+}
+
+func newOwnershipFixture(t *testing.T) *ownershipFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	gormDB, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: db, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	previousDB, previousCache := mysql.DB, redis.Redis
+	t.Cleanup(func() {
+		mysql.DB, redis.Redis = previousDB, previousCache
+		_ = db.Close()
+	})
+	mysql.DB = gormDB
+	cache := miniredis.RunT(t)
+	redis.Redis = redis.NewRedisCache(goredis.NewClient(&goredis.Options{Addr: cache.Addr()}))
+
+	fixture := &ownershipFixture{
+		mock:       mock,
+		owner:      uuid.New(),
+		commenter:  uuid.New(),
+		postID:     uuid.New(),
+		commentID:  uuid.New(),
+		categoryID: uuid.New(),
+		moderator:  uuid.New(),
+	}
+	for _, permission := range []string{utils.Permissions["ALLOW_MODERATE_POST"], utils.Permissions["ALLOW_MODERATE_COMMENT"]} {
+		if err := redis.Redis.SetJSON("permission:"+fixture.moderator.String()+":"+permission, true, time.Hour); err != nil {
+			t.Fatalf("caching %s: %v", permission, err)
+		}
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("queries: %v", err)
+		}
+	})
+	return fixture
+}
+
+// serve calls handler as actor with a JSON body
+func serve(actor *Users.User, method string, handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	request := httptest.NewRequest(method, "/", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	return serveRequest(actor, handler, request)
+}
+
+func serveRequest(actor *Users.User, handler gin.HandlerFunc, request *http.Request) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(request.Method, "/", func(c *gin.Context) { c.Set("user", actor) }, handler)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (f *ownershipFixture) ownerUser() *Users.User {
+	return &Users.User{ID: f.owner, RoleID: uuid.New()}
+}
+
+func (f *ownershipFixture) moderatorUser() *Users.User {
+	return &Users.User{ID: uuid.New(), RoleID: f.moderator}
+}
+
+func (f *ownershipFixture) otherUser() *Users.User {
+	return &Users.User{ID: uuid.New(), RoleID: uuid.New()}
+}
+
+// expectPostOwner expects the lookup of who owns the post
+func (f *ownershipFixture) expectPostOwner() {
+	f.mock.ExpectQuery("SELECT posts.user_id FROM `posts` WHERE posts.id = \\? AND posts.deleted_at IS NULL").
+		WithArgs(f.postID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(f.owner.String()))
+}
+
+// expectNoOverride expects the role of a user who is not a moderator to be looked up and found wanting
+func (f *ownershipFixture) expectNoOverride(permission string) {
+	f.mock.ExpectQuery("SELECT \\* FROM `permissions` WHERE name = \\?").
+		WithArgs(permission, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+}
+
+// updatePost calls PUT /posts as actor, replacing the content and categories of the post
+func (f *ownershipFixture) updatePost(t *testing.T, actor *Users.User) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("id", f.postID.String())
+	_ = form.WriteField("content", "edited")
+	_ = form.WriteField("category_ids", f.categoryID.String())
+	if err := form.Close(); err != nil {
+		t.Fatalf("form: %v", err)
+	}
+	request := httptest.NewRequest(http.MethodPut, "/", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	return serveRequest(actor, UpdatePost, request)
+}
+
+// expectPostEdit expects the post to be authorized, then its media, categories and content replaced.
+// The post is a draft, so nothing is indexed or notified.
+func (f *ownershipFixture) expectPostEdit() {
+	f.mock.ExpectBegin()
+	f.expectPostOwner()
+	f.mock.ExpectQuery("SELECT `id`,`path` FROM `media` WHERE post_id = \\? FOR UPDATE").
+		WithArgs(f.postID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "path"}))
+	f.mock.ExpectExec("DELETE FROM `media` WHERE post_id = \\?").
+		WithArgs(f.postID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	f.mock.ExpectExec("DELETE FROM `post_categories` WHERE post_id = \\?").
+		WithArgs(f.postID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectExec("UPDATE `posts` SET `content`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs("edited", sqlmock.AnyArg(), f.postID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectExec("INSERT INTO `post_categories`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectQuery("SELECT `id`,`user_id`,`status` FROM `posts` WHERE id = \\?").
+		WithArgs(f.postID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(f.postID.String(), f.owner.String(), "draft"))
+	f.mock.ExpectCommit()
+}
+
+// expectPostLock expects the post to be locked before it is deleted
+func (f *ownershipFixture) expectPostLock() {
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery("SELECT `id`,`user_id`,`content`,`status`,`deleted_at`,`deleted_by` FROM `posts` WHERE id = \\?").
+		WithArgs(f.postID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "status", "deleted_at", "deleted_by"}).
+			AddRow(f.postID.String(), f.owner.String(), "hello", "published", nil, nil))
+	f.expectPostOwner()
+}
+
+// expectPostDelete expects the post to be hidden by actor and removed from search
+func (f *ownershipFixture) expectPostDelete(actor *Users.User) {
+	f.expectPostLock()
+	f.mock.ExpectExec("UPDATE `posts` SET `deleted_at`=\\?,`deleted_by`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(sqlmock.AnyArg(), actor.ID, sqlmock.AnyArg(), f.postID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectExec("INSERT INTO `outbox_events`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectCommit()
+}
+
+func TestUpdatePostByOwner(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectPostEdit()
+
+	if response := f.updatePost(t, f.ownerUser()); response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+}
+
+func TestUpdatePostOfAnotherUserIsForbidden(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.mock.ExpectBegin()
+	f.expectPostOwner()
+	f.expectNoOverride(utils.Permissions["ALLOW_MODERATE_POST"])
+	f.mock.ExpectRollback()
+
+	if response := f.updatePost(t, f.otherUser()); response.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", response.Code, response.Body)
+	}
+}
+
+func TestUpdatePostByModerator(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectPostEdit()
+
+	if response := f.updatePost(t, f.moderatorUser()); response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+}
+
+func TestUpdatePostWithContentPermissionIsForbidden(t *testing.T) {
+	// Team roles hold ALLOW_UPDATE_POST for their own posts, it does not reach anyone else's
+	f := newOwnershipFixture(t)
+	editor := f.otherUser()
+	if err := redis.Redis.SetJSON("permission:"+editor.RoleID.String()+":"+utils.Permissions["ALLOW_UPDATE_POST"], true, time.Hour); err != nil {
+		t.Fatalf("caching permission: %v", err)
+	}
+	f.mock.ExpectBegin()
+	f.expectPostOwner()
+	f.expectNoOverride(utils.Permissions["ALLOW_MODERATE_POST"])
+	f.mock.ExpectRollback()
+
+	if response := f.updatePost(t, editor); response.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", response.Code, response.Body)
+	}
+}
+
+func TestDeletePostByOwner(t *testing.T) {
+	f := newOwnershipFixture(t)
+	owner := f.ownerUser()
+	f.expectPostDelete(owner)
+
+	if response := serve(owner, http.MethodDelete, DeletePost, map[string]interface{}{"post_id": f.postID}); response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+}
+
+func TestDeletePostOfAnotherUserIsForbidden(t *testing.T) {
+	f := newOwnershipFixture(t)
+	f.expectPostLock()
+	f.expectNoOverride(utils.Permissions["ALLOW_MODERATE_POST"])
+	f.mock.ExpectRollback()
+
+	response := serve(f.otherUser(), http.MethodDelete, DeletePost, map[string]interface{}{"post_id": f.postID})
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", response.Code, response.Body)
+	}
+}
+
+func TestDeletePostByModerator(t *testing.T) {
+	f := newOwnershipFixture(t)
+	moderator := f.moderatorUser()
+	f.expectPostDelete(moderator)
+
+	if response := serve(moderator, http.MethodDelete, DeletePost, map[string]interface{}{"post_id": f.postID}); response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+}
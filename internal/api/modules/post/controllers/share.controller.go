@@ -0,0 +1,159 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// Share godoc
+// @Summary Share a post
+// @Description Reposts the post to the current user's followers and notifies the author
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ShareRequest true "Post ID Required"
+// @Success 200 {object} map[string]interface{} "Successfully shared post"
+// @Failure 400 {object} types.StatusBadRequest "Already shared"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/shares [post]
+func Share(c *gin.Context) {
+	var request types.ShareRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	totalShares, err := posts.SharePost(currentUser.ID, request.PostID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to share post", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully shared post", gin.H{"totalShares": totalShares})
+}
+
+// Unshare godoc
+// @Summary Undo a share
+// @Description Removes the current user's share of the post
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ShareRequest true "Post ID Required"
+// @Success 200 {object} map[string]interface{} "Successfully unshared post"
+// @Failure 400 {object} types.StatusBadRequest "Not shared"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/shares/undo [post]
+func Unshare(c *gin.Context) {
+	var request types.ShareRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	totalShares, err := posts.UnsharePost(currentUser.ID, request.PostID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to unshare post", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully unshared post", gin.H{"totalShares": totalShares})
+}
+
+// Quote godoc
+// @Summary Quote repost
+// @Description Creates a post with the current user's own content quoting another post
+// @Description The quote is public unless visibility restricts it; the quoted post is shown to those who see it alone
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.QuotePostRequest true "Quoted post and content"
+// @Success 201 {object} map[string]interface{} "Successfully quoted post"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/shares/quote [post]
+func Quote(c *gin.Context) {
+	var request types.QuotePostRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.PostID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "post_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	quote, err := posts.QuotePost(currentUser.ID, request.PostID, request.Content, request.Visibility)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to quote post", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Successfully quoted post", quote)
+}
+
+// ListFollowingShares godoc
+// @Summary Shares from followed users
+// @Description Lists the posts shared or quoted by the users the current user follows, newest first
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListSharesRequest true "Pagination"
+// @Success 200 {object} map[string]interface{} "List shares successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/shares/feed [post]
+func ListFollowingShares(c *gin.Context) {
+	var request types.ListSharesRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := posts.ListFollowingShares(currentUser.ID, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list shares", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List shares successfully", response)
+}
@@ -0,0 +1,83 @@
+package posts
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTagPosts godoc
+// @Summary List posts of a hashtag
+// @Description The tag page: the tag with its post count and the posts using it, newest first, with cursor pagination
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.TagPostsRequest true "Tag and page"
+// @Success 200 {object} map[string]interface{} "List tag posts successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Tag not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/tags/posts [post]
+func ListTagPosts(c *gin.Context) {
+	var request types.TagPostsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, "An error occurred during execution", getUserErr)
+		return
+	}
+	page, cursorErr := utils.NewCursorPage(request.Cursor, request.ItemsPerPage)
+	if cursorErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid cursor", cursorErr)
+		return
+	}
+
+	response, err := posts.ListTagPage(request.Tag, page, currentUser.ID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list tag posts", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List tag posts successfully", response)
+}
+
+// TrendingTags godoc
+// @Summary Trending hashtags
+// @Description Hashtags ranked by how many people used them in the window and how fast their use grows
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param window query string false "1h, 24h (default) or 7d"
+// @Param limit query int false "Number of tags, 10 by default and at most 50"
+// @Success 200 {object} map[string]interface{} "List trending tags successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/tags/trending [get]
+func TrendingTags(c *gin.Context) {
+	var limit int
+	if raw := c.Query("limit"); raw != "" {
+		var parseErr error
+		if limit, parseErr = strconv.Atoi(raw); parseErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid limit", parseErr)
+			return
+		}
+	}
+
+	tags, err := posts.ListTrendingTags(c.Query("window"), limit)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list trending tags", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List trending tags successfully", gin.H{"items": tags})
+}
@@ -0,0 +1,128 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/internal/infrastructure/storage"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// CreateUploadSession godoc
+// @Summary Start a direct upload
+// @Description Returns presigned URLs to PUT the file straight to storage. The size limit depends on the content type. Files above 64 MiB get one URL per 16 MiB part; keep the ETag header of each part response for the completion call.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CreateUploadSessionRequest true "File to upload"
+// @Success 201 {object} map[string]interface{} "Upload session created"
+// @Failure 400 {object} types.StatusBadRequest "Invalid type or size"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/uploads [post]
+func CreateUploadSession(c *gin.Context) {
+	var request types.CreateUploadSessionRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	session, err := posts.CreateUploadSession(currentUser.ID, request.ContentType, request.Size)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to start upload", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusCreated, "Upload session created", session)
+}
+
+// CompleteUploadSession godoc
+// @Summary Complete a direct upload
+// @Description Assembles the uploaded parts and verifies the stored file. The upload_id can then be attached to a post until attach_until.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.CompleteUploadSessionRequest true "Upload ID and, for multipart uploads, the parts"
+// @Success 200 {object} map[string]interface{} "Upload completed"
+// @Failure 400 {object} types.StatusBadRequest "File missing or different from the announced one"
+// @Failure 404 {object} map[string]interface{} "Upload not found"
+// @Failure 410 {object} map[string]interface{} "Upload has expired"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/uploads/complete [post]
+func CompleteUploadSession(c *gin.Context) {
+	var request types.CompleteUploadSessionRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UploadID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "upload_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	parts := make([]storage.CompletedPart, len(request.Parts))
+	for i, part := range request.Parts {
+		parts[i] = storage.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+	upload, err := posts.CompleteUploadSession(currentUser.ID, request.UploadID, parts)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to complete upload", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Upload completed", upload)
+}
+
+// AbortUploadSession godoc
+// @Summary Abort a direct upload
+// @Description Drops an upload that was not attached to a post, with whatever was already uploaded
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.UploadIDRequest true "Upload ID Required"
+// @Success 200 {object} map[string]interface{} "Upload aborted"
+// @Failure 404 {object} map[string]interface{} "Upload not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/uploads [delete]
+func AbortUploadSession(c *gin.Context) {
+	var request types.UploadIDRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UploadID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "upload_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := posts.AbortUploadSession(currentUser.ID, request.UploadID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to abort upload", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Upload aborted", gin.H{"upload_id": request.UploadID})
+}
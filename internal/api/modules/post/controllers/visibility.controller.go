@@ -0,0 +1,49 @@
+package posts
+
+import (
+	"net/http"
+
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdatePostVisibility godoc
+// @Summary Change who sees a post
+// @Description Sets a post to public, followers, friends, only_me or team. Public posts of private accounts are
+// @Description seen by their followers alone, and only team members post to the team.
+// @Description Allowed for the owner and moderators.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.UpdatePostVisibilityRequest true "Post and visibility"
+// @Success 200 {object} map[string]interface{} "Post visibility updated successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Not allowed to update this post"
+// @Failure 404 {object} map[string]interface{} "Post not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/posts/visibility [put]
+func UpdatePostVisibility(c *gin.Context) {
+	var request types.UpdatePostVisibilityRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	result, err := posts.ChangePostVisibility(currentUser, request.PostID, request.Visibility)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update post visibility", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Post visibility updated successfully", result)
+}
@@ -8,13 +8,16 @@ import (
 	"github.com/google/uuid"
 )
 
+// Comment is a node of its post's nested set: a comment's replies are the rows with Left/Right inside its own
 type Comment struct {
-	ID     uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	PostID uuid.UUID `gorm:"type:uuid;not null"`
-	UserID uuid.UUID `gorm:"type:uuid;not null"`
-	Text   string    `gorm:"type:text;not null"`
-	Left   int       `gorm:"not null"`
-	Right  int       `gorm:"not null"`
+	ID       uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	PostID   uuid.UUID  `gorm:"type:uuid;not null"`
+	UserID   uuid.UUID  `gorm:"type:uuid;not null"`
+	ParentID *uuid.UUID `gorm:"type:uuid;default:null"`
+	Text     string     `gorm:"type:text;not null"`
+	Left     int        `gorm:"not null"`
+	Right    int        `gorm:"not null"`
+	Depth    int        `gorm:"not null;default:0"`
 
 	// References
 	Post Post       `gorm:"foreignKey:PostID;references:ID"`
@@ -6,13 +6,28 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	MediaProcessingPending = "pending"
+	MediaProcessingReady   = "ready"
+	MediaProcessingFailed  = "failed"
+)
+
 type Media struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	PostID    uuid.UUID `gorm:"type:uuid;not null"`
-	Post      Post      `gorm:"foreignKey:PostID;references:ID;constraint:OnDelete:CASCADE;"`
-	Path      string    `gorm:"type:varchar(255);not null"`
-	Type      string    `gorm:"type:varchar(255);not null"`
-	Status    bool      `gorm:"default:true"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	PostID   uuid.UUID `gorm:"type:uuid;not null"`
+	Post     Post      `gorm:"foreignKey:PostID;references:ID;constraint:OnDelete:CASCADE;"`
+	Path     string    `gorm:"type:varchar(255);not null"`
+	// SourcePath is the uploaded file a processed image was re-encoded from
+	SourcePath *string `gorm:"type:varchar(255);index"`
+	Type     string    `gorm:"type:varchar(255);not null"`
+	Width    *int
+	Height   *int
+	Blurhash *string `gorm:"type:varchar(64)"`
+	Status   bool    `gorm:"default:true"`
+	// ProcessingStatus is pending until an image has been re-encoded and its variants generated
+	ProcessingStatus   string         `gorm:"type:varchar(16);not null;default:ready"`
+	ProcessingAttempts int            `gorm:"not null;default:0"`
+	Variants           []MediaVariant `gorm:"foreignKey:MediaID"`
+	CreatedAt          time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,20 @@
+package posts
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MediaVariant is a resized copy of an image Media, so clients can pick the size they display
+type MediaVariant struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	MediaID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:uq_media_variants_media_name"`
+	Name      string    `gorm:"type:varchar(16);not null;uniqueIndex:uq_media_variants_media_name"`
+	Path      string    `gorm:"type:varchar(255);not null"`
+	Type      string    `gorm:"type:varchar(255);not null"`
+	Width     int       `gorm:"not null"`
+	Height    int       `gorm:"not null"`
+	Size      int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
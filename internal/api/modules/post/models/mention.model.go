@@ -0,0 +1,25 @@
+package posts
+
+import (
+	"time"
+
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+
+	"github.com/google/uuid"
+)
+
+// Mention is a user named as @username in a post, or in one of its comments when CommentID is set
+type Mention struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	PostID    uuid.UUID  `gorm:"type:uuid;not null"`
+	CommentID *uuid.UUID `gorm:"type:uuid;default:null"`
+	// UserID is the mentioned user, AuthorID who wrote the mention
+	UserID   uuid.UUID `gorm:"type:uuid;not null"`
+	AuthorID uuid.UUID `gorm:"type:uuid;not null"`
+
+	// References
+	Post Post       `gorm:"foreignKey:PostID;references:ID"`
+	User Users.User `gorm:"foreignKey:UserID;references:ID"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -8,6 +8,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// Post states. Only published posts are listed; drafts, scheduled and archived posts are seen by their author alone.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
+// Post visibilities, who besides the author sees a post. Team posts are seen by the holders of team roles
+// while their author holds one too.
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityFriends   = "friends"
+	PostVisibilityOnlyMe    = "only_me"
+	PostVisibilityTeam      = "team"
+)
+
 type Post struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	UserID    uuid.UUID  `gorm:"type:uuid;not null"`
@@ -15,4 +33,16 @@ type Post struct {
 	Content   string     `gorm:"type:text;default:null"`
 	CreatedAt time.Time  `gorm:"autoCreateTime"`
 	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+
+	// SharedPostID is the quoted post when this post is a quote repost
+	SharedPostID *uuid.UUID `gorm:"type:uuid;default:null"`
+
+	Status string `gorm:"type:varchar(16);not null;default:published"`
+	// PublishAt is when a scheduled post gets published
+	PublishAt  *time.Time
+	Visibility string `gorm:"type:varchar(16);not null;default:public"`
+
+	// DeletedAt hides the post; it is purged once the restore window has passed
+	DeletedAt *time.Time
+	DeletedBy *uuid.UUID `gorm:"type:uuid;default:null"`
 }
@@ -12,6 +12,8 @@ type PostShare struct {
 	ID     uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	PostID uuid.UUID `gorm:"type:uuid;not null"`
 	UserID uuid.UUID `gorm:"type:uuid;not null"`
+	// QuotePostID is the user's own post for a quote repost, nil for a plain share
+	QuotePostID *uuid.UUID `gorm:"type:uuid;default:null"`
 
 	// References
 	Post Post       `gorm:"foreignKey:PostID;references:ID"`
@@ -0,0 +1,14 @@
+package posts
+
+import "time"
+
+// StoredFile is a content-addressed file in blob storage that uploads are claiming while they write or reuse it.
+// A file is only deleted with its row locked and unclaimed, so an upload finding it stored can rely on it staying.
+type StoredFile struct {
+	Path   string `gorm:"type:varchar(255);primaryKey"`
+	Claims int    `gorm:"not null;default:0"`
+	// ClaimedUntil is when the claims lapse, should their holders die before releasing them
+	ClaimedUntil *time.Time
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,28 @@
+package posts
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tag is a hashtag, stored once under its normalized name
+type Tag struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Name      string    `gorm:"type:varchar(191);not null;unique"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// PostTag is a use of a tag in a post, or in one of its comments when CommentID is set
+type PostTag struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	TagID     uuid.UUID  `gorm:"type:uuid;not null"`
+	PostID    uuid.UUID  `gorm:"type:uuid;not null"`
+	CommentID *uuid.UUID `gorm:"type:uuid;default:null"`
+
+	// References
+	Tag  Tag  `gorm:"foreignKey:TagID;references:ID"`
+	Post Post `gorm:"foreignKey:PostID;references:ID"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,31 @@
+package posts
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UploadStatusPending   = "pending"
+	UploadStatusCompleted = "completed"
+)
+
+// UploadSession tracks a file the client uploads straight to storage through presigned URLs.
+// A completed session is replaced by a Media row once it is attached to a post.
+type UploadSession struct {
+	ID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID uuid.UUID `gorm:"type:uuid;not null"`
+	// ObjectKey is a temporary key until the session is completed, then the content-addressed key of the file
+	ObjectKey         string `gorm:"type:varchar(255);not null;index"`
+	ContentType       string `gorm:"type:varchar(255);not null"`
+	Size              int64  `gorm:"not null"`
+	MultipartUploadID string `gorm:"type:varchar(255);default:null"`
+	PartCount         int    `gorm:"not null;default:0"`
+	Status            string `gorm:"type:varchar(16);not null;default:pending"`
+	// ExpiresAt is when the session and its file are cleaned up unless it was attached to a post
+	ExpiresAt   time.Time `gorm:"not null"`
+	CompletedAt *time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,471 @@
+package posts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/notification/services"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxCommentDepth is the deepest reply level; replies to a comment at this depth are rejected
+	MaxCommentDepth = 8
+	// DefaultCommentFetchDepth is how many reply levels a listing returns when the client does not ask
+	DefaultCommentFetchDepth = 2
+	MaxCommentLength         = 5000
+)
+
+// CreateComment adds a top-level comment to the post, or a reply when parentID is set
+func CreateComment(userID, postID uuid.UUID, parentID *uuid.UUID, text string) (map[string]interface{}, *utils.ServiceError) {
+	text = strings.TrimSpace(text)
+	if serviceErr := validateCommentText(text); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	comment := posts.Comment{
+		ID:     uuid.New(),
+		PostID: postID,
+		UserID: userID,
+		Text:   text,
+	}
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		// Serializes every change to this post's comment tree
+		post, lockErr := functions.LockPostForComments(tx, postID)
+		if lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+		if serviceErr = checkPostVisible(tx, userID, postID); serviceErr != nil {
+			return serviceErr
+		}
+
+		var parent *posts.Comment
+		if parentID != nil {
+			parent, serviceErr = functions.GetComment(tx, *parentID)
+			if serviceErr != nil {
+				return serviceErr
+			}
+			if parent.PostID != postID {
+				serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Parent comment belongs to another post"}
+				return serviceErr
+			}
+			if parent.Depth >= MaxCommentDepth {
+				serviceErr = &utils.ServiceError{
+					StatusCode: http.StatusBadRequest,
+					Message:    fmt.Sprintf("Replies cannot be nested deeper than %d levels", MaxCommentDepth),
+				}
+				return serviceErr
+			}
+		}
+
+		if serviceErr = functions.InsertComment(tx, &comment, parent); serviceErr != nil {
+			return serviceErr
+		}
+		if err := saveContentEntities(tx, postID, &comment.ID, userID, text); err != nil {
+			return err
+		}
+		return notifyCommentCreated(tx, userID, post.UserID, parent, comment)
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create comment"}
+	}
+
+	row, serviceErr := functions.SelectComment(mysql.DB, comment.ID, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	return commentToMap(*row), nil
+}
+
+// EditComment changes the text of a comment; only its author may do it
+func EditComment(userID, roleID, commentID uuid.UUID, text string) (map[string]interface{}, *utils.ServiceError) {
+	text = strings.TrimSpace(text)
+	if serviceErr := validateCommentText(text); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	if serviceErr := PermissionServices.AuthorizeResource(mysql.DB, userID, roleID, PermissionServices.ResourceComment, PermissionServices.ActionUpdate, commentID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		comment, getErr := functions.GetComment(tx, commentID)
+		if getErr != nil {
+			serviceErr = getErr
+			return getErr
+		}
+		if err := tx.Model(&posts.Comment{}).Where("id = ?", commentID).Update("text", text).Error; err != nil {
+			return err
+		}
+		return saveContentEntities(tx, comment.PostID, &comment.ID, comment.UserID, text)
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update comment"}
+	}
+
+	row, serviceErr := functions.SelectComment(mysql.DB, commentID, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	return commentToMap(*row), nil
+}
+
+// DeleteComment removes a comment and all of its replies. The comment author, the post owner
+// and roles holding ALLOW_MODERATE_COMMENT may delete it.
+func DeleteComment(userID, roleID, commentID uuid.UUID) (int64, *utils.ServiceError) {
+	var (
+		deleted    int64
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		comment, getErr := functions.GetComment(tx, commentID)
+		if getErr != nil {
+			serviceErr = getErr
+			return getErr
+		}
+		if _, lockErr := functions.LockPostForComments(tx, comment.PostID); lockErr != nil {
+			serviceErr = lockErr
+			return lockErr
+		}
+		if authErr := PermissionServices.AuthorizeResource(tx, userID, roleID, PermissionServices.ResourceComment, PermissionServices.ActionDelete, commentID); authErr != nil {
+			serviceErr = authErr
+			return authErr
+		}
+
+		// Re-read under the lock, a concurrent insert may have shifted the bounds
+		if comment, serviceErr = functions.GetComment(tx, commentID); serviceErr != nil {
+			return serviceErr
+		}
+		deleted, serviceErr = functions.DeleteCommentSubtree(tx, comment)
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return 0, serviceErr
+		}
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to delete comment"}
+	}
+	return deleted, nil
+}
+
+// ListComments returns a page of top-level comments of a post, each with its replies down to depth levels
+func ListComments(viewerID, postID uuid.UUID, currentPage, itemsPerPage, depth int) (map[string]interface{}, *utils.ServiceError) {
+	if serviceErr := functions.CheckPostExits(postID, viewerID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	if itemsPerPage <= 0 {
+		itemsPerPage = 10
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	depth = clampCommentDepth(depth)
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	roots, total, serviceErr := functions.SelectTopLevelComments(mysql.DB, postID, viewerID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	items := make([]map[string]interface{}, 0, len(roots))
+	if len(roots) > 0 && depth > 0 {
+		// Roots are in thread order, so their subtrees are exactly the rows between the first and last root
+		first, last := roots[0], roots[len(roots)-1]
+		replies, serviceErr := functions.SelectCommentDescendants(mysql.DB, postID, viewerID, first.Left, last.Right, depth)
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		items = buildCommentTree(roots, replies)
+	} else {
+		for _, root := range roots {
+			items = append(items, commentToMap(root))
+		}
+	}
+
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
+
+// ListCommentPage is ListComments with cursor pagination over the top-level comments, oldest first
+func ListCommentPage(viewerID, postID uuid.UUID, page utils.CursorPage, depth int) (map[string]interface{}, *utils.ServiceError) {
+	if serviceErr := functions.CheckPostExits(postID, viewerID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	depth = clampCommentDepth(depth)
+
+	roots, hasMore, serviceErr := functions.SelectTopLevelCommentPage(mysql.DB, postID, viewerID, page)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	items := make([]map[string]interface{}, 0, len(roots))
+	if len(roots) > 0 && depth > 0 {
+		// The subtrees of the roots lie between the leftmost and rightmost root
+		left, right := roots[0].Left, roots[0].Right
+		for _, root := range roots[1:] {
+			left, right = min(left, root.Left), max(right, root.Right)
+		}
+		replies, serviceErr := functions.SelectCommentDescendants(mysql.DB, postID, viewerID, left, right, depth)
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		items = buildCommentTree(roots, replies)
+	} else {
+		for _, root := range roots {
+			items = append(items, commentToMap(root))
+		}
+	}
+
+	var first, last *utils.Cursor
+	if len(roots) > 0 {
+		first = &utils.Cursor{CreatedAt: roots[0].CreatedAt, ID: roots[0].ID}
+		last = &utils.Cursor{CreatedAt: roots[len(roots)-1].CreatedAt, ID: roots[len(roots)-1].ID}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": utils.CursorPaginate(page, first, last, hasMore),
+	}, nil
+}
+
+// GetCommentThread returns a comment with its replies down to depth levels below it
+func GetCommentThread(viewerID, commentID uuid.UUID, depth int) (map[string]interface{}, *utils.ServiceError) {
+	root, serviceErr := functions.SelectComment(mysql.DB, commentID, viewerID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if serviceErr = functions.CheckPostExits(root.PostID, viewerID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	depth = clampCommentDepth(depth)
+	if depth == 0 {
+		return commentToMap(*root), nil
+	}
+
+	replies, serviceErr := functions.SelectCommentDescendants(mysql.DB, root.PostID, viewerID, root.Left, root.Right, root.Depth+depth)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	return buildCommentTree([]functions.CommentRow{*root}, replies)[0], nil
+}
+
+// LikeComment records the user's like and notifies the comment author
+func LikeComment(userID, commentID uuid.UUID) (int64, *utils.ServiceError) {
+	var (
+		counts     int64
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		comment, getErr := functions.GetComment(tx, commentID)
+		if getErr != nil {
+			serviceErr = getErr
+			return getErr
+		}
+		if serviceErr = checkPostVisible(tx, userID, comment.PostID); serviceErr != nil {
+			return serviceErr
+		}
+		liked, checkErr := functions.CheckIsCommentLiked(tx, userID, commentID)
+		if checkErr != nil {
+			serviceErr = checkErr
+			return checkErr
+		}
+		if liked {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have already liked this comment"}
+			return serviceErr
+		}
+
+		if err := tx.Create(&posts.CommentLike{ID: uuid.New(), CommentID: commentID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&posts.CommentLike{}).Where("comment_id = ?", commentID).Count(&counts).Error; err != nil {
+			return err
+		}
+
+		if comment.UserID == userID {
+			return nil
+		}
+		username, err := getUsername(tx, userID)
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("%s just liked your comment", username)
+		if notiErr := notifications.PostNotificationHandler(tx, userID, comment.UserID, comment.PostID, message, "personal_comment", outbox.EventCommentLiked); notiErr != nil {
+			log.Printf("Failed to send notification: %v", notiErr.Message)
+			return errors.New(notiErr.Message)
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return counts, serviceErr
+		}
+		return counts, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to like the comment"}
+	}
+	return counts, nil
+}
+
+// UnlikeComment removes the user's like from the comment
+func UnlikeComment(userID, commentID uuid.UUID) (int64, *utils.ServiceError) {
+	var counts int64
+	liked, serviceErr := functions.CheckIsCommentLiked(mysql.DB, userID, commentID)
+	if serviceErr != nil {
+		return counts, serviceErr
+	}
+	if !liked {
+		return counts, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have not liked this comment"}
+	}
+
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ? AND user_id = ?", commentID, userID).Delete(&posts.CommentLike{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&posts.CommentLike{}).Where("comment_id = ?", commentID).Count(&counts).Error
+	}); err != nil {
+		return counts, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to unlike the comment"}
+	}
+	return counts, nil
+}
+
+// notifyCommentCreated tells the parent comment author about a reply and the post owner about any new comment,
+// each person at most once and never the commenter themselves
+func notifyCommentCreated(tx *gorm.DB, commenterID, postOwnerID uuid.UUID, parent *posts.Comment, comment posts.Comment) error {
+	username, err := getUsername(tx, commenterID)
+	if err != nil {
+		return err
+	}
+
+	notified := map[uuid.UUID]bool{commenterID: true}
+	if parent != nil && !notified[parent.UserID] {
+		notified[parent.UserID] = true
+		message := fmt.Sprintf("%s replied to your comment", username)
+		if notiErr := notifications.PostNotificationHandler(tx, commenterID, parent.UserID, comment.PostID, message, "personal_comment", outbox.EventCommentCreated); notiErr != nil {
+			log.Printf("Failed to send notification for comment %s: %v", comment.ID, notiErr.Message)
+			return errors.New(notiErr.Message)
+		}
+	}
+	if !notified[postOwnerID] {
+		message := fmt.Sprintf("%s commented on your post", username)
+		if notiErr := notifications.PostNotificationHandler(tx, commenterID, postOwnerID, comment.PostID, message, "personal_post", outbox.EventCommentCreated); notiErr != nil {
+			log.Printf("Failed to send notification for comment %s: %v", comment.ID, notiErr.Message)
+			return errors.New(notiErr.Message)
+		}
+	}
+	return nil
+}
+
+func getUsername(tx *gorm.DB, userID uuid.UUID) (string, error) {
+	var username string
+	if err := tx.Model(&Users.User{}).
+		Select("username").
+		Where("id = ?", userID).
+		Scan(&username).Error; err != nil {
+		log.Printf("Failed to get username for userID %s: %v", userID, err)
+		return "", err
+	}
+	return username, nil
+}
+
+func validateCommentText(text string) *utils.ServiceError {
+	if text == "" {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Comment text is required"}
+	}
+	if len([]rune(text)) > MaxCommentLength {
+		return &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Comment must be at most %d characters", MaxCommentLength),
+		}
+	}
+	return nil
+}
+
+func clampCommentDepth(depth int) int {
+	if depth < 0 {
+		return DefaultCommentFetchDepth
+	}
+	if depth > MaxCommentDepth {
+		return MaxCommentDepth
+	}
+	return depth
+}
+
+// buildCommentTree nests replies (in thread order) under their roots
+func buildCommentTree(roots, replies []functions.CommentRow) []map[string]interface{} {
+	nodes := make(map[uuid.UUID]map[string]interface{}, len(roots)+len(replies))
+	items := make([]map[string]interface{}, 0, len(roots))
+	for _, root := range roots {
+		node := commentToMap(root)
+		nodes[root.ID] = node
+		items = append(items, node)
+	}
+	for _, reply := range replies {
+		if !reply.ParentID.Valid {
+			continue
+		}
+		parent, ok := nodes[reply.ParentID.UUID]
+		if !ok {
+			continue
+		}
+		node := commentToMap(reply)
+		nodes[reply.ID] = node
+		parent["replies"] = append(parent["replies"].([]map[string]interface{}), node)
+	}
+	return items
+}
+
+func commentToMap(row functions.CommentRow) map[string]interface{} {
+	var parentID interface{}
+	if row.ParentID.Valid {
+		parentID = row.ParentID.UUID
+	}
+	return map[string]interface{}{
+		"id":            row.ID,
+		"post_id":       row.PostID,
+		"parent_id":     parentID,
+		"text":          row.Text,
+		"depth":         row.Depth,
+		"user":          gin.H{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+		"likes_count":   row.LikesCount,
+		"is_liked":      row.IsLiked,
+		"replies_count": (row.Right - row.Left - 1) / 2,
+		"replies":       []map[string]interface{}{},
+		"created_at":    row.CreatedAt,
+		"updated_at":    row.UpdatedAt,
+	}
+}
+
+// checkPostVisible fails as if the post did not exist when the user does not see it
+func checkPostVisible(tx *gorm.DB, userID, postID uuid.UUID) *utils.ServiceError {
+	visible, serviceErr := functions.CanViewPost(tx, userID, postID)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !visible {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// PostRestoreWindow is how long a deleted post can be restored before it is purged
+	PostRestoreWindow  = 30 * 24 * time.Hour
+	PostPurgeInterval  = time.Hour
+	PostPurgeBatchSize = 100
+)
+
+// DeletePost hides the post until it is restored or purged. The owner and users whose role holds
+// ALLOW_MODERATE_POST may delete it.
+func DeletePost(actor *Users.User, postID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	var (
+		deletedAt  time.Time
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		post, lookupErr := lockPost(tx, postID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return errors.New(lookupErr.Message)
+		}
+		if post.DeletedAt != nil {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+			return errors.New(serviceErr.Message)
+		}
+		if authErr := PermissionServices.AuthorizeResource(tx, actor.ID, actor.RoleID, PermissionServices.ResourcePost, PermissionServices.ActionDelete, postID); authErr != nil {
+			serviceErr = authErr
+			return errors.New(authErr.Message)
+		}
+
+		deletedAt = time.Now()
+		if err := tx.Model(&posts.Post{}).Where("id = ?", postID).Updates(map[string]interface{}{
+			"deleted_at": deletedAt,
+			"deleted_by": actor.ID,
+		}).Error; err != nil {
+			return err
+		}
+		if serviceErr = functions.QueueSearchChange(tx, search.KindPost, postID, outbox.EventPostDeleted); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to delete post"}
+	}
+
+	invalidatePostDetails(postID)
+	return map[string]interface{}{
+		"post_id":       postID,
+		"deleted_at":    deletedAt,
+		"restore_until": deletedAt.Add(PostRestoreWindow),
+	}, nil
+}
+
+// RestorePost brings back a deleted post within the restore window. A post removed by a moderator
+// can only be restored by a moderator.
+func RestorePost(actor *Users.User, postID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		post, lookupErr := lockPost(tx, postID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return errors.New(lookupErr.Message)
+		}
+		if post.DeletedAt == nil {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Post is not deleted"}
+			return errors.New(serviceErr.Message)
+		}
+		if time.Since(*post.DeletedAt) > PostRestoreWindow {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusGone, Message: "The restore window for this post has passed"}
+			return errors.New(serviceErr.Message)
+		}
+
+		deletedByOwner := post.DeletedBy != nil && *post.DeletedBy == post.UserID
+		isOwner := post.UserID == actor.ID
+		if !(isOwner && deletedByOwner) && !PermissionServices.CanOverrideOwnership(actor.RoleID, PermissionServices.ResourcePost, PermissionServices.ActionDelete) {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "You are not allowed to restore this post"}
+			return errors.New(serviceErr.Message)
+		}
+
+		if err := tx.Model(&posts.Post{}).Where("id = ?", postID).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if serviceErr = functions.QueueSearchChange(tx, search.KindPost, postID, outbox.EventPostRestored); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to restore post"}
+	}
+
+	invalidatePostDetails(postID)
+	return nil
+}
+
+// StartPostPurge permanently removes posts whose restore window has passed until ctx is cancelled.
+// The database cascades the post's likes, comments, shares, categories, media rows and notifications;
+// the uploaded files are removed here once the rows are gone.
+func StartPostPurge(ctx context.Context) {
+	ticker := time.NewTicker(PostPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := purgeDeletedPosts()
+			if err != nil {
+				log.Printf("[Posts] Purge failed: %v", err)
+				break
+			}
+			if n < PostPurgeBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// purgeDeletedPosts hard-deletes one batch of expired posts and returns how many were removed
+func purgeDeletedPosts() (int, error) {
+	var (
+		postIDs    []uuid.UUID
+		mediaPaths []string
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&posts.Post{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-PostRestoreWindow)).
+			Order("deleted_at ASC").
+			Limit(PostPurgeBatchSize).
+			Pluck("id", &postIDs).Error; err != nil {
+			return err
+		}
+		if len(postIDs) == 0 {
+			return nil
+		}
+
+		var serviceErr *utils.ServiceError
+		if mediaPaths, serviceErr = functions.SelectMediaFilePaths(tx, "post_id IN ?", postIDs); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return tx.Where("id IN ?", postIDs).Delete(&posts.Post{}).Error
+	}); err != nil {
+		return 0, err
+	}
+
+	// Files are removed after the commit so a rolled back purge never loses media
+	functions.DeleteStoredFiles(mediaPaths)
+	for _, postID := range postIDs {
+		invalidatePostDetails(postID)
+	}
+	return len(postIDs), nil
+}
+
+func lockPost(tx *gorm.DB, postID uuid.UUID) (posts.Post, *utils.ServiceError) {
+	var post posts.Post
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "user_id", "content", "status", "deleted_at", "deleted_by").
+		Where("id = ?", postID).
+		Take(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return post, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+	}
+	if err != nil {
+		return post, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get post"}
+	}
+	return post, nil
+}
@@ -0,0 +1,287 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PostSchedulerInterval = 30 * time.Second
+	// MaxScheduleAhead bounds how far ahead a post can be scheduled
+	MaxScheduleAhead = 365 * 24 * time.Hour
+)
+
+// postTransitions are the states a post can move to from each state. Published posts are archived rather
+// than taken back to drafts, they have already been seen.
+var postTransitions = map[string][]string{
+	posts.PostStatusDraft:     {posts.PostStatusScheduled, posts.PostStatusPublished},
+	posts.PostStatusScheduled: {posts.PostStatusDraft, posts.PostStatusScheduled, posts.PostStatusPublished},
+	posts.PostStatusPublished: {posts.PostStatusArchived},
+	posts.PostStatusArchived:  {posts.PostStatusPublished},
+}
+
+// ListDrafts lists the user's unpublished posts in the given states, drafts and scheduled posts by default
+func ListDrafts(userID uuid.UUID, statuses []string, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	if len(statuses) == 0 {
+		statuses = []string{posts.PostStatusDraft, posts.PostStatusScheduled}
+	}
+	for _, status := range statuses {
+		if status != posts.PostStatusDraft && status != posts.PostStatusScheduled && status != posts.PostStatusArchived {
+			return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Unknown draft status %q", status)}
+		}
+	}
+	if itemsPerPage <= 0 {
+		itemsPerPage = 10
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectDrafts(userID, statuses, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		categoryIDs := []string{}
+		if row.CategoryIDs.Valid && row.CategoryIDs.String != "" {
+			categoryIDs = strings.Split(row.CategoryIDs.String, ",")
+		}
+		items = append(items, map[string]interface{}{
+			"id":           row.ID,
+			"content":      row.Content.String,
+			"status":       row.Status,
+			"publish_at":   row.PublishAt,
+			"category_ids": categoryIDs,
+			"media_count":  row.MediaCount,
+			"created_at":   row.CreatedAt,
+			"updated_at":   row.UpdatedAt,
+		})
+	}
+
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
+
+// ChangePostStatus moves a post along postTransitions: schedules or reschedules a draft, takes a scheduled post
+// back to drafts, publishes either at once, or archives and unarchives a published post. The owner and users
+// whose role may update any post may do it.
+func ChangePostStatus(actor *Users.User, postID uuid.UUID, status string, publishAt *time.Time) (map[string]interface{}, *utils.ServiceError) {
+	now := time.Now()
+	if status != posts.PostStatusScheduled {
+		publishAt = nil
+	}
+
+	var (
+		post       posts.Post
+		published  bool
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		var lookupErr *utils.ServiceError
+		// The lock orders this change after the scheduler, or the scheduler after it
+		if post, lookupErr = lockPost(tx, postID); lookupErr != nil {
+			serviceErr = lookupErr
+			return errors.New(lookupErr.Message)
+		}
+		if post.DeletedAt != nil {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+			return errors.New(serviceErr.Message)
+		}
+		if authErr := PermissionServices.AuthorizeResource(tx, actor.ID, actor.RoleID, PermissionServices.ResourcePost, PermissionServices.ActionUpdate, postID); authErr != nil {
+			serviceErr = authErr
+			return errors.New(authErr.Message)
+		}
+		if !canMovePost(post.Status, status) {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusConflict, Message: fmt.Sprintf("A %s post cannot be %s", post.Status, status)}
+			return errors.New(serviceErr.Message)
+		}
+		if status == posts.PostStatusScheduled {
+			if serviceErr = validateSchedule(publishAt, now); serviceErr != nil {
+				return errors.New(serviceErr.Message)
+			}
+		}
+
+		var moved bool
+		switch {
+		case status == posts.PostStatusPublished && post.Status != posts.PostStatusArchived:
+			if moved, serviceErr = functions.PublishPost(tx, postID, now); serviceErr != nil {
+				return errors.New(serviceErr.Message)
+			}
+			if moved {
+				published = true
+				if err := announcePost(tx, postID, post.UserID, post.Content); err != nil {
+					return err
+				}
+			}
+		default:
+			if moved, serviceErr = functions.UpdatePostStatus(tx, postID, post.Status, status, publishAt); serviceErr != nil {
+				return errors.New(serviceErr.Message)
+			}
+			// Archiving drops the post from search, unarchiving brings it back
+			if moved && (status == posts.PostStatusArchived || post.Status == posts.PostStatusArchived) {
+				if serviceErr = functions.QueueSearchChange(tx, search.KindPost, postID, outbox.EventPostUpdated); serviceErr != nil {
+					return errors.New(serviceErr.Message)
+				}
+			}
+		}
+		// Rescheduling to the same time changes no row
+		if !moved && post.Status != status {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusConflict, Message: "The post changed meanwhile, try again"}
+			return errors.New(serviceErr.Message)
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update post status"}
+	}
+
+	invalidatePostDetails(postID)
+	if published {
+		queueFeedFanout(feedFanout{authorID: post.UserID, entry: functions.FeedEntry{PostID: postID, ActivityAt: now}})
+	}
+	return map[string]interface{}{
+		"id":         postID,
+		"status":     status,
+		"publish_at": publishAt,
+	}, nil
+}
+
+// StartPostScheduler publishes scheduled posts once their time has come, until ctx is cancelled
+func StartPostScheduler(ctx context.Context) {
+	ticker := time.NewTicker(PostSchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		publishDuePosts()
+	}
+}
+
+// publishDuePosts publishes the scheduled posts that are due, each in its own transaction so that a post that
+// keeps failing is logged and skipped until the next run instead of holding back the ones due after it
+func publishDuePosts() {
+	now := time.Now()
+	var failed []uuid.UUID
+	for {
+		post, err := publishDuePost(now, failed)
+		if post == nil {
+			if err != nil {
+				log.Printf("[Posts] Claiming scheduled posts failed: %v", err)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("[Posts] Publishing scheduled post %s failed: %v", post.ID, err)
+			failed = append(failed, post.ID)
+		}
+	}
+}
+
+// publishDuePost claims and publishes the earliest due post not in skip, returning nil when none is left. The
+// claim, the new state and the outbox events commit together, so every post is published exactly once.
+func publishDuePost(now time.Time, skip []uuid.UUID) (*posts.Post, error) {
+	var (
+		post  *posts.Post
+		moved bool
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		var serviceErr *utils.ServiceError
+		if post, serviceErr = functions.ClaimDuePost(tx, now, skip); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		if post == nil {
+			return nil
+		}
+		if moved, serviceErr = functions.PublishPost(tx, post.ID, now); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		if !moved {
+			return nil
+		}
+		return announcePost(tx, post.ID, post.UserID, post.Content)
+	}); err != nil {
+		return post, err
+	}
+
+	if moved {
+		queueFeedFanout(feedFanout{authorID: post.UserID, entry: functions.FeedEntry{PostID: post.ID, ActivityAt: now}})
+	}
+	return post, nil
+}
+
+// announcePost tells everyone about a post that was just published: it is indexed for search and the people
+// it mentions are notified
+func announcePost(tx *gorm.DB, postID, authorID uuid.UUID, content string) error {
+	if serviceErr := functions.QueueSearchChange(tx, search.KindPost, postID, outbox.EventPostCreated); serviceErr != nil {
+		return serviceErr
+	}
+	return saveContentEntities(tx, postID, nil, authorID, content)
+}
+
+// validateNewPostStatus checks the state a new post is created in
+func validateNewPostStatus(status string, publishAt *time.Time, now time.Time) *utils.ServiceError {
+	switch status {
+	case posts.PostStatusDraft, posts.PostStatusPublished:
+		return nil
+	case posts.PostStatusScheduled:
+		return validateSchedule(publishAt, now)
+	}
+	return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Status must be draft, scheduled or published"}
+}
+
+func validateSchedule(publishAt *time.Time, now time.Time) *utils.ServiceError {
+	if publishAt == nil {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "A scheduled post needs publish_at"}
+	}
+	if !publishAt.After(now) {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "publish_at must be in the future"}
+	}
+	if publishAt.After(now.Add(MaxScheduleAhead)) {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Posts cannot be scheduled more than a year ahead"}
+	}
+	return nil
+}
+
+func canMovePost(from, to string) bool {
+	for _, allowed := range postTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package posts
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// schedulerFixture mocks the database the post scheduler claims and publishes posts in
+type schedulerFixture struct {
+	mock sqlmock.Sqlmock
+}
+
+func newSchedulerFixture(t *testing.T) *schedulerFixture {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	gormDB, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: db, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	previousDB := mysql.DB
+	t.Cleanup(func() {
+		mysql.DB = previousDB
+		_ = db.Close()
+	})
+	mysql.DB = gormDB
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("queries: %v", err)
+		}
+	})
+	return &schedulerFixture{mock: mock}
+}
+
+// expectClaim expects a due post to be claimed, skipping the ones in skip, and returns it
+func (f *schedulerFixture) expectClaim(skip ...uuid.UUID) uuid.UUID {
+	postID := uuid.New()
+	f.mock.ExpectBegin()
+	f.expectClaimQuery(skip).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "publish_at"}).
+			AddRow(postID.String(), uuid.NewString(), "hello", time.Now().Add(-time.Minute)))
+	return postID
+}
+
+func (f *schedulerFixture) expectClaimQuery(skip []uuid.UUID) *sqlmock.ExpectedQuery {
+	query := "SELECT `id`,`user_id`,`content`,`publish_at` FROM `posts` WHERE \\(?status = \\? AND publish_at <= \\? AND deleted_at IS NULL\\)? "
+	args := []driver.Value{"scheduled", sqlmock.AnyArg()}
+	if len(skip) > 0 {
+		query += "AND id NOT IN \\(.*\\) "
+		for _, id := range skip {
+			args = append(args, id)
+		}
+	}
+	args = append(args, 1)
+	return f.mock.ExpectQuery(query + "ORDER BY publish_at ASC LIMIT \\? FOR UPDATE SKIP LOCKED").WithArgs(args...)
+}
+
+func (f *schedulerFixture) expectPublish(postID uuid.UUID) *sqlmock.ExpectedExec {
+	return f.mock.ExpectExec("UPDATE `posts` SET `created_at`=\\?,`publish_at`=\\?,`status`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(sqlmock.AnyArg(), nil, "published", sqlmock.AnyArg(), postID, "draft", "scheduled")
+}
+
+// expectAnnounce expects the published post to be queued for search, and its tags and mentions saved
+func (f *schedulerFixture) expectAnnounce(postID uuid.UUID) {
+	f.mock.ExpectExec("INSERT INTO `outbox_events`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectQuery("SELECT \\* FROM `post_tags` WHERE post_id = \\? AND comment_id IS NULL").
+		WithArgs(postID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectQuery("SELECT \\* FROM `mentions` WHERE post_id = \\? AND comment_id IS NULL").
+		WithArgs(postID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func TestPublishDuePostsSkipsFailingPosts(t *testing.T) {
+	f := newSchedulerFixture(t)
+	// The earliest due post keeps failing, it must not hold back the one due after it
+	failing := f.expectClaim()
+	f.expectPublish(failing).WillReturnError(errors.New("deadlock"))
+	f.mock.ExpectRollback()
+
+	next := f.expectClaim(failing)
+	f.expectPublish(next).WillReturnResult(sqlmock.NewResult(0, 1))
+	f.expectAnnounce(next)
+	f.mock.ExpectCommit()
+
+	f.mock.ExpectBegin()
+	f.expectClaimQuery([]uuid.UUID{failing}).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectCommit()
+
+	publishDuePosts()
+	drainFeedFanouts()
+}
+
+func TestPublishDuePostsStopsWhenClaimingFails(t *testing.T) {
+	f := newSchedulerFixture(t)
+	f.mock.ExpectBegin()
+	f.expectClaimQuery(nil).WillReturnError(errors.New("connection refused"))
+	f.mock.ExpectRollback()
+
+	publishDuePosts()
+}
+
+// drainFeedFanouts drops the fan-outs the published posts queued, no feed writer runs in tests
+func drainFeedFanouts() {
+	for {
+		select {
+		case <-feedFanouts:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,611 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// FeedTimelineSize is how many entries a Redis timeline keeps, older ones are read from the database
+	FeedTimelineSize = 500
+	// FeedTimelineTTL rebuilds timelines from time to time, which also repairs fan-outs lost on a restart
+	FeedTimelineTTL = 24 * time.Hour
+	// FeedFanoutMaxFollowers is the most followers an author's posts are fanned out to on write.
+	// The posts of more followed authors are read when the feed is.
+	FeedFanoutMaxFollowers = 5000
+	// FeedEngagementStep is the head start a post gets in the feed each time its engagement doubles
+	FeedEngagementStep = time.Hour
+	// FeedEngagementCap bounds the head start, so old posts cannot outrank fresh ones for good
+	FeedEngagementCap = 12 * time.Hour
+	// FeedInterestDelay ranks posts from outside the user's network as if they were this much older
+	FeedInterestDelay = 6 * time.Hour
+	// MaxFeedCandidates bounds the entries read to rank one page
+	MaxFeedCandidates = 1000
+)
+
+// Why an entry is in the feed
+const (
+	FeedReasonNetwork  = "network"
+	FeedReasonShare    = "share"
+	FeedReasonInterest = "interest"
+)
+
+// feedCandidatesPerItem is how many entries a source reads at a time for each item of the page
+const feedCandidatesPerItem = 3
+
+// timelineAdd adds an entry to a timeline that is already cached and drops its oldest entries beyond the size.
+// Missing timelines are left alone, they are rebuilt from the database when read.
+var timelineAdd = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+	redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+end
+return 0
+`)
+
+// feedFanout adds an entry to, or removes it from, the timelines of the author's followers and friends
+type feedFanout struct {
+	authorID uuid.UUID
+	entry    functions.FeedEntry
+	remove   bool
+}
+
+// feedFanouts queues fan-outs of committed posts and shares; they are written inline when it is full
+var feedFanouts = make(chan feedFanout, 1024)
+
+func queueFeedFanout(fanout feedFanout) {
+	select {
+	case feedFanouts <- fanout:
+	default:
+		fanOutFeedEntry(fanout)
+	}
+}
+
+// StartFeedFanout writes new posts and shares into the timelines of their audience until ctx is cancelled.
+// Fan-outs still queued on shutdown are lost, the timelines missing them are repaired when they expire.
+func StartFeedFanout(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fanout := <-feedFanouts:
+			fanOutFeedEntry(fanout)
+		}
+	}
+}
+
+func fanOutFeedEntry(fanout feedFanout) {
+	if redis.Redis == nil {
+		return
+	}
+	recipients, fanned, serviceErr := functions.SelectFeedRecipients(fanout.authorID, FeedFanoutMaxFollowers)
+	if serviceErr != nil {
+		log.Printf("Failed to fan out post %s: %s", fanout.entry.PostID, serviceErr.Message)
+		return
+	}
+	if !fanned {
+		return
+	}
+
+	member := feedMember(fanout.entry)
+	pipe := redis.Redis.Client().Pipeline()
+	for _, recipient := range recipients {
+		key := redis.TimelineCacheKey(recipient)
+		if fanout.remove {
+			pipe.ZRem(redis.Ctx, key, member)
+			continue
+		}
+		timelineAdd.Eval(redis.Ctx, pipe, []string{key}, fanout.entry.ActivityAt.UnixMilli(), member, FeedTimelineSize)
+	}
+	if _, err := pipe.Exec(redis.Ctx); err != nil {
+		log.Printf("Failed to fan out post %s: %v", fanout.entry.PostID, err)
+	}
+}
+
+// feedMember is how an entry is stored in a timeline: the post id, followed by the sharer's for shares
+func feedMember(entry functions.FeedEntry) string {
+	if entry.SharerID.Valid {
+		return fmt.Sprintf("%s:%s", entry.PostID, entry.SharerID.UUID)
+	}
+	return entry.PostID.String()
+}
+
+func parseFeedMember(member string, score float64) (functions.FeedEntry, error) {
+	postID, sharerID, shared := strings.Cut(member, ":")
+	entry := functions.FeedEntry{ActivityAt: time.UnixMilli(int64(score))}
+	var err error
+	if entry.PostID, err = uuid.Parse(postID); err != nil {
+		return entry, err
+	}
+	if shared {
+		if entry.SharerID.UUID, err = uuid.Parse(sharerID); err != nil {
+			return entry, err
+		}
+		entry.SharerID.Valid = true
+	}
+	return entry, nil
+}
+
+// feedCandidate is an entry read for a page of the feed. base is the activity time in milliseconds, delayed for
+// interests, and rank adds the engagement head start to it.
+type feedCandidate struct {
+	entry  functions.FeedEntry
+	reason string
+	base   int64
+	rank   int64
+}
+
+func newFeedCandidate(entry functions.FeedEntry, reason string, delay time.Duration) feedCandidate {
+	if reason == FeedReasonNetwork && entry.SharerID.Valid {
+		reason = FeedReasonShare
+	}
+	return feedCandidate{entry: entry, reason: reason, base: entry.ActivityAt.Add(-delay).UnixMilli()}
+}
+
+// feedSource reads the candidates of one kind in window order, one window at a time
+type feedSource struct {
+	read      func(window functions.FeedWindow) ([]feedCandidate, *utils.ServiceError)
+	window    functions.FeedWindow
+	exhausted bool
+	// last is the base of the last candidate read; entries not read yet are beyond it
+	last *int64
+}
+
+func (s *feedSource) next() ([]feedCandidate, *utils.ServiceError) {
+	read, serviceErr := s.read(s.window)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	s.exhausted = len(read) < s.window.Limit
+	if len(read) == 0 {
+		return nil, nil
+	}
+
+	last := read[len(read)-1].base
+	if s.last != nil && *s.last == last {
+		// A whole window of entries at the same time; give up on the rest rather than reading them again
+		s.exhausted = true
+	}
+	s.last = &last
+	// Bounds are inclusive, so the entries at the last time are read again and dropped as duplicates
+	bound := time.UnixMilli(last)
+	s.window.Bound = &bound
+	return read, nil
+}
+
+// HomeFeed lists the posts and shares of the people the user follows and their friends, with posts from the
+// categories of their interests, ranked by recency with a head start for engagement. Pages are keyed on the rank,
+// so a post whose rank grows past pages already read is not shown again.
+func HomeFeed(userID uuid.UUID, page utils.CursorPage) (map[string]interface{}, *utils.ServiceError) {
+	interests, serviceErr := functions.GetProfileInterests(userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	// Ranks are at most FeedEngagementCap ahead of their base, which bounds where the page can start
+	window := functions.FeedWindow{Newer: page.Backward(), Limit: page.Limit * feedCandidatesPerItem}
+	if page.Cursor != nil {
+		bound := page.Cursor.CreatedAt
+		if window.Newer {
+			bound = bound.Add(-FeedEngagementCap)
+		}
+		window.Bound = &bound
+	}
+	network := networkFeedSource(userID, false)
+	if loadTimeline(userID) {
+		network = timelineFeedSource(userID)
+	}
+	sources := []*feedSource{
+		{read: network, window: window},
+		{read: networkFeedSource(userID, true), window: window},
+		{read: interestFeedSource(userID, interests), window: window},
+	}
+
+	feed := newFeedRanking(page, userID)
+	for _, source := range sources {
+		if serviceErr := feed.read(source); serviceErr != nil {
+			return nil, serviceErr
+		}
+	}
+
+	// Read on until the page is made of candidates that no entry left unread could outrank
+	var ranked []feedCandidate
+	for {
+		ranked = feed.ranked()
+		binding, edge := feedEdge(sources, page.Backward())
+		if binding == nil || countBeyond(ranked, edge, page.Backward()) >= page.Limit || feed.size >= MaxFeedCandidates {
+			break
+		}
+		if serviceErr := feed.read(binding); serviceErr != nil {
+			return nil, serviceErr
+		}
+	}
+
+	taken := min(len(ranked), page.Limit)
+	binding, edge := feedEdge(sources, page.Backward())
+	if binding != nil {
+		// When the page could not be settled within MaxFeedCandidates, the best ranked candidates are used anyway
+		if certain := countBeyond(ranked, edge, page.Backward()); certain > 0 {
+			taken = min(certain, page.Limit)
+		}
+	}
+	hasMore := len(ranked) > taken || binding != nil
+	ranked = ranked[:taken]
+	if page.Backward() {
+		slices.Reverse(ranked)
+	}
+
+	items, serviceErr := hydrateFeed(ranked, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	var first, last *utils.Cursor
+	if len(ranked) > 0 {
+		first = feedCursor(ranked[0])
+		last = feedCursor(ranked[len(ranked)-1])
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": utils.CursorPaginate(page, first, last, hasMore),
+	}, nil
+}
+
+// feedRanking collects the candidates read for a page, ranked by the engagement of their posts
+type feedRanking struct {
+	page       utils.CursorPage
+	viewerID   uuid.UUID
+	candidates []feedCandidate
+	seen       map[string]bool
+	// size counts every entry read, duplicates included
+	size int
+}
+
+func newFeedRanking(page utils.CursorPage, viewerID uuid.UUID) *feedRanking {
+	return &feedRanking{page: page, viewerID: viewerID, seen: make(map[string]bool)}
+}
+
+// read takes the next candidates of source and ranks them
+func (f *feedRanking) read(source *feedSource) *utils.ServiceError {
+	read, serviceErr := source.next()
+	if serviceErr != nil {
+		return serviceErr
+	}
+	f.size += len(read)
+
+	var postIDs []uuid.UUID
+	fresh := read[:0]
+	for _, candidate := range read {
+		key := candidate.reason + "/" + feedMember(candidate.entry)
+		if f.seen[key] {
+			continue
+		}
+		f.seen[key] = true
+		fresh = append(fresh, candidate)
+		postIDs = append(postIDs, candidate.entry.PostID)
+	}
+	engagement, serviceErr := functions.SelectPostEngagement(postIDs, f.viewerID)
+	if serviceErr != nil {
+		return serviceErr
+	}
+
+	for _, candidate := range fresh {
+		counts, found := engagement[candidate.entry.PostID]
+		if !found {
+			// The post was deleted or hidden from the user since it reached the timeline
+			continue
+		}
+		candidate.rank = candidate.base + feedHeadStart(counts).Milliseconds()
+		f.candidates = append(f.candidates, candidate)
+	}
+	return nil
+}
+
+// ranked returns the candidates past the page cursor in page order, each post once at its best rank
+func (f *feedRanking) ranked() []feedCandidate {
+	best := make(map[uuid.UUID]feedCandidate, len(f.candidates))
+	for _, candidate := range f.candidates {
+		if f.page.Cursor != nil && !feedPastCursor(candidate, f.page.Cursor) {
+			continue
+		}
+		if current, found := best[candidate.entry.PostID]; !found || candidate.rank > current.rank {
+			best[candidate.entry.PostID] = candidate
+		}
+	}
+
+	ranked := make([]feedCandidate, 0, len(best))
+	for _, candidate := range best {
+		ranked = append(ranked, candidate)
+	}
+	slices.SortFunc(ranked, func(a, b feedCandidate) int {
+		order := compareFeedRank(b, a.rank, a.entry.PostID)
+		if f.page.Backward() {
+			order = -order
+		}
+		return order
+	})
+	return ranked
+}
+
+// feedPastCursor reports whether the candidate comes after the cursor in the direction of the cursor
+func feedPastCursor(candidate feedCandidate, cursor *utils.Cursor) bool {
+	order := compareFeedRank(candidate, cursor.CreatedAt.UnixMilli(), cursor.ID)
+	if cursor.Backward {
+		return order > 0
+	}
+	return order < 0
+}
+
+// compareFeedRank orders the candidate against a rank and post id, from the bottom of the feed up
+func compareFeedRank(candidate feedCandidate, rank int64, postID uuid.UUID) int {
+	if candidate.rank != rank {
+		if candidate.rank < rank {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(candidate.entry.PostID.String(), postID.String())
+}
+
+// feedHeadStart is how far ahead of its activity time engagement ranks a post
+func feedHeadStart(counts functions.PostEngagement) time.Duration {
+	score := float64(counts.Likes + 2*counts.Comments + 3*counts.Shares)
+	return min(time.Duration(math.Log2(1+score)*float64(FeedEngagementStep)), FeedEngagementCap)
+}
+
+// feedEdge finds the source whose unread entries could rank closest to the page, and how close: entries not read
+// yet rank below edge, or above it for backward pages. It returns no source once they are all exhausted.
+func feedEdge(sources []*feedSource, backward bool) (*feedSource, int64) {
+	var (
+		binding *feedSource
+		edge    int64
+	)
+	for _, source := range sources {
+		if source.exhausted || source.last == nil {
+			continue
+		}
+		reach := *source.last
+		if !backward {
+			reach += FeedEngagementCap.Milliseconds()
+		}
+		if binding == nil || (!backward && reach > edge) || (backward && reach < edge) {
+			binding, edge = source, reach
+		}
+	}
+	return binding, edge
+}
+
+// countBeyond counts the leading candidates ranked strictly beyond edge, which nothing unread can outrank
+func countBeyond(ranked []feedCandidate, edge int64, backward bool) int {
+	for i, candidate := range ranked {
+		if (!backward && candidate.rank <= edge) || (backward && candidate.rank >= edge) {
+			return i
+		}
+	}
+	return len(ranked)
+}
+
+func feedCursor(candidate feedCandidate) *utils.Cursor {
+	return &utils.Cursor{CreatedAt: time.UnixMilli(candidate.rank).UTC(), ID: candidate.entry.PostID}
+}
+
+// hydrateFeed loads the posts of the ranked candidates in the shape of List, with why each is in the feed
+func hydrateFeed(ranked []feedCandidate, userID uuid.UUID) ([]map[string]interface{}, *utils.ServiceError) {
+	items := make([]map[string]interface{}, 0, len(ranked))
+	if len(ranked) == 0 {
+		return items, nil
+	}
+
+	postIDs := make([]uuid.UUID, 0, len(ranked))
+	var sharerIDs []uuid.UUID
+	for _, candidate := range ranked {
+		postIDs = append(postIDs, candidate.entry.PostID)
+		if candidate.entry.SharerID.Valid {
+			sharerIDs = append(sharerIDs, candidate.entry.SharerID.UUID)
+		}
+	}
+	rows, serviceErr := functions.SelectPostsByIDs(postIDs, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	defer rows.Close()
+	loaded, _, err := scanPostList(rows, userID)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	sharers, serviceErr := functions.SelectUserSummaries(sharerIDs)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	byID := make(map[uuid.UUID]map[string]interface{}, len(loaded))
+	for _, post := range loaded {
+		byID[post["id"].(uuid.UUID)] = post
+	}
+	for _, candidate := range ranked {
+		post, found := byID[candidate.entry.PostID]
+		if !found {
+			continue
+		}
+		var sharedBy interface{}
+		if candidate.entry.SharerID.Valid {
+			sharedBy = sharers[candidate.entry.SharerID.UUID]
+		}
+		post["feed"] = gin.H{
+			"reason":      candidate.reason,
+			"shared_by":   sharedBy,
+			"activity_at": candidate.entry.ActivityAt,
+		}
+		items = append(items, post)
+	}
+	return items, nil
+}
+
+// loadTimeline makes sure the user's timeline is cached, rebuilding it from the database when it expired.
+// It reports false when there is none to read, and the network has to be read from the database.
+func loadTimeline(userID uuid.UUID) bool {
+	if redis.Redis == nil {
+		return false
+	}
+	client, key := redis.Redis.Client(), redis.TimelineCacheKey(userID)
+	exists, err := client.Exists(redis.Ctx, key).Result()
+	if err != nil {
+		log.Printf("Failed to read timeline %s: %v", userID, err)
+		return false
+	}
+	if exists == 1 {
+		return true
+	}
+
+	entries, serviceErr := functions.SelectNetworkEntries(userID, functions.FeedWindow{Limit: FeedTimelineSize}, FeedFanoutMaxFollowers, false)
+	if serviceErr != nil || len(entries) == 0 {
+		return false
+	}
+	members := make([]goredis.Z, 0, len(entries))
+	for _, entry := range entries {
+		members = append(members, goredis.Z{Score: float64(entry.ActivityAt.UnixMilli()), Member: feedMember(entry)})
+	}
+	pipe := client.TxPipeline()
+	pipe.ZAdd(redis.Ctx, key, members...)
+	pipe.Expire(redis.Ctx, key, FeedTimelineTTL)
+	if _, err := pipe.Exec(redis.Ctx); err != nil {
+		log.Printf("Failed to rebuild timeline %s: %v", userID, err)
+		return false
+	}
+	return true
+}
+
+// timelineFeedSource reads the network from the cached timeline, and from the database past its oldest entry
+func timelineFeedSource(userID uuid.UUID) func(functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+	database := networkFeedSource(userID, false)
+	key := redis.TimelineCacheKey(userID)
+
+	return func(window functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+		client := redis.Redis.Client()
+		oldest, err := client.ZRangeWithScores(redis.Ctx, key, 0, 0).Result()
+		if err != nil || len(oldest) == 0 {
+			// The timeline expired in the meantime
+			return database(window)
+		}
+		floor := int64(oldest[0].Score)
+
+		if !window.Newer {
+			var candidates []feedCandidate
+			if window.Bound == nil || window.Bound.UnixMilli() >= floor {
+				if candidates, err = readTimeline(key, window, floor); err != nil {
+					return database(window)
+				}
+			}
+			if len(candidates) == window.Limit {
+				return candidates, nil
+			}
+			below := time.UnixMilli(floor - 1)
+			if window.Bound != nil && window.Bound.Before(below) {
+				below = *window.Bound
+			}
+			older, serviceErr := database(functions.FeedWindow{Bound: &below, Limit: window.Limit - len(candidates)})
+			if serviceErr != nil {
+				return nil, serviceErr
+			}
+			return append(candidates, older...), nil
+		}
+
+		// Newer entries: those older than the timeline come from the database, the rest from the timeline
+		var candidates []feedCandidate
+		if window.Bound != nil && window.Bound.UnixMilli() < floor {
+			older, serviceErr := database(window)
+			if serviceErr != nil {
+				return nil, serviceErr
+			}
+			for _, candidate := range older {
+				if candidate.base >= floor {
+					break
+				}
+				candidates = append(candidates, candidate)
+			}
+			if len(candidates) == window.Limit {
+				return candidates, nil
+			}
+		}
+		newer, err := readTimeline(key, functions.FeedWindow{Bound: window.Bound, Newer: true, Limit: window.Limit - len(candidates)}, floor)
+		if err != nil {
+			return database(window)
+		}
+		return append(candidates, newer...), nil
+	}
+}
+
+// readTimeline reads the cached entries of window no older than floor
+func readTimeline(key string, window functions.FeedWindow, floor int64) ([]feedCandidate, error) {
+	args := goredis.ZRangeArgs{Key: key, Start: floor, Stop: "+inf", ByScore: true, Count: int64(window.Limit)}
+	if window.Bound != nil {
+		if window.Newer {
+			args.Start = max(floor, window.Bound.UnixMilli())
+		} else {
+			args.Stop = window.Bound.UnixMilli()
+		}
+	}
+	args.Rev = !window.Newer
+
+	scored, err := redis.Redis.Client().ZRangeArgsWithScores(redis.Ctx, args).Result()
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]feedCandidate, 0, len(scored))
+	for _, z := range scored {
+		entry, err := parseFeedMember(fmt.Sprint(z.Member), z.Score)
+		if err != nil {
+			log.Printf("Skipping malformed timeline entry %v: %v", z.Member, err)
+			continue
+		}
+		candidates = append(candidates, newFeedCandidate(entry, FeedReasonNetwork, 0))
+	}
+	return candidates, nil
+}
+
+// networkFeedSource reads the network from the database, the authors fanned out on write or the popular ones
+func networkFeedSource(userID uuid.UUID, popular bool) func(functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+	return func(window functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+		entries, serviceErr := functions.SelectNetworkEntries(userID, window, FeedFanoutMaxFollowers, popular)
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		candidates := make([]feedCandidate, 0, len(entries))
+		for _, entry := range entries {
+			candidates = append(candidates, newFeedCandidate(entry, FeedReasonNetwork, 0))
+		}
+		return candidates, nil
+	}
+}
+
+// interestFeedSource reads the posts of the user's interests, whose bases are delayed by FeedInterestDelay
+func interestFeedSource(userID uuid.UUID, interests []string) func(functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+	return func(window functions.FeedWindow) ([]feedCandidate, *utils.ServiceError) {
+		if window.Bound != nil {
+			bound := window.Bound.Add(FeedInterestDelay)
+			window.Bound = &bound
+		}
+		entries, serviceErr := functions.SelectInterestEntries(userID, interests, window)
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		candidates := make([]feedCandidate, 0, len(entries))
+		for _, entry := range entries {
+			candidates = append(candidates, newFeedCandidate(entry, FeedReasonInterest, FeedInterestDelay))
+		}
+		return candidates, nil
+	}
+}
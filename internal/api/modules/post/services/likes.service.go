@@ -1,14 +1,15 @@
 package posts
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/unarya/univia/internal/api/functions"
 	"github.com/unarya/univia/internal/api/modules/notification/services"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
 	posts "github.com/unarya/univia/internal/api/modules/post/models"
-	Users "github.com/unarya/univia/internal/api/modules/user/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/pkg/utils"
 
@@ -21,6 +22,10 @@ func Like(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
 	db := mysql.DB
 	var counts int64
 
+	if serviceErr := functions.CheckPostExits(postID, userID); serviceErr != nil {
+		return counts, serviceErr
+	}
+
 	// 1. Check if the user already liked the post
 	liked, err := functions.CheckIsLiked(userID, postID)
 	if err != nil {
@@ -40,8 +45,8 @@ func Like(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
 		PostID: postID,
 	}
 
-	// Insert the like, while also counting the total likes in one query.
-	// Using db.Transaction to ensure atomicity of the operations.
+	// Insert the like, count the total likes and notify the owner in one transaction,
+	// so the notification event is only published if the like is stored
 	if err := db.Transaction(func(tx *gorm.DB) error {
 		// Insert the like
 		if err := tx.Create(&newLike).Error; err != nil {
@@ -55,7 +60,7 @@ func Like(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
 			return err
 		}
 
-		return nil
+		return notifyPostLiked(tx, userID, postID)
 	}); err != nil {
 		return counts, &utils.ServiceError{
 			StatusCode: http.StatusInternalServerError,
@@ -63,46 +68,6 @@ func Like(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
 		}
 	}
 
-	// Send Notification
-
-	// 1. Get username of the user who liked the post
-	var username string
-	usernameErr := db.Model(&Users.User{}).
-		Select("username").
-		Where("id = ?", userID).
-		Scan(&username).Error
-	if usernameErr != nil {
-		log.Printf("Failed to get username for userID %d: %v", userID, usernameErr)
-		return counts, &utils.ServiceError{
-			StatusCode: http.StatusBadRequest,
-			Message:    fmt.Sprintf("Failed to get username for userID %d: %v", userID, usernameErr),
-		}
-	}
-
-	// 2. Get owner of the post
-	var postOwner uuid.UUID
-	selectOwnerErr := db.Model(&posts.Post{}).
-		Select("user_id").
-		Where("id = ?", postID).
-		Scan(&postOwner).Error
-	if selectOwnerErr != nil {
-		log.Printf("Failed to get post owner for postID %d: %v", postID, selectOwnerErr)
-		return counts, &utils.ServiceError{
-			StatusCode: http.StatusBadRequest,
-			Message:    fmt.Sprintf("Failed to get post owner for postID %d: %v", postID, selectOwnerErr),
-		}
-	}
-
-	if postOwner != userID {
-		// 3. Send notification to the post owner
-		message := fmt.Sprintf("%s just liked your post", username)
-		noti_type := "personal_post"
-		sendNotiErr := notifications.NotificationHandler(userID, postOwner, message, noti_type)
-		if sendNotiErr != nil {
-			log.Printf("Failed to send notification: %v", sendNotiErr)
-		}
-	}
-
 	return counts, nil
 }
 
@@ -149,3 +114,35 @@ func DisLike(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
 
 	return counts, nil
 }
+
+// notifyPostLiked tells the post owner who liked their post
+func notifyPostLiked(tx *gorm.DB, userID, postID uuid.UUID) error {
+	// 1. Get username of the user who liked the post
+	username, err := getUsername(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Get owner of the post
+	var postOwner uuid.UUID
+	if err := tx.Model(&posts.Post{}).
+		Select("user_id").
+		Where("id = ?", postID).
+		Scan(&postOwner).Error; err != nil {
+		log.Printf("Failed to get post owner for postID %s: %v", postID, err)
+		return err
+	}
+
+	if postOwner == userID {
+		return nil
+	}
+
+	// 3. Send notification to the post owner
+	message := fmt.Sprintf("%s just liked your post", username)
+	noti_type := "personal_post"
+	if sendNotiErr := notifications.PostNotificationHandler(tx, userID, postOwner, postID, message, noti_type, outbox.EventPostLiked); sendNotiErr != nil {
+		log.Printf("Failed to send notification: %v", sendNotiErr.Message)
+		return errors.New(sendNotiErr.Message)
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+package posts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/storage"
+	"github.com/unarya/univia/pkg/imaging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MediaProcessingInterval  = 30 * time.Second
+	MediaProcessingBatchSize = 10
+	// MediaProcessingLease is how long a claimed image may take before another worker retries it
+	MediaProcessingLease       = 10 * time.Minute
+	MediaProcessingMaxAttempts = 3
+	// MaxProcessedImageSize is the largest image file the pipeline reads into memory
+	MaxProcessedImageSize = 64 << 20
+)
+
+// mediaProcessingActive marks images claimed by a worker, until their lease runs out
+const mediaProcessingActive = "processing"
+
+// ImageVariants are the sizes generated for every image, smallest first. Images are never upscaled.
+var ImageVariants = []struct {
+	Name  string
+	Width int
+}{
+	{Name: "thumbnail", Width: 320},
+	{Name: "small", Width: 640},
+	{Name: "medium", Width: 1280},
+	{Name: "large", Width: 1920},
+}
+
+// errUnprocessable marks images that fail the same way on every attempt
+var errUnprocessable = errors.New("image cannot be processed")
+
+// mediaQueued wakes the pipeline when new images are saved, rather than waiting for the next tick
+var mediaQueued = make(chan struct{}, 1)
+
+func wakeMediaProcessing() {
+	select {
+	case mediaQueued <- struct{}{}:
+	default:
+	}
+}
+
+// StartMediaProcessing re-encodes uploaded images without their metadata and generates their
+// resized variants and blurhash until ctx is cancelled.
+func StartMediaProcessing(ctx context.Context) {
+	ticker := time.NewTicker(MediaProcessingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-mediaQueued:
+		}
+
+		for {
+			n, err := processMediaBatch(ctx)
+			if err != nil {
+				log.Printf("[Media] Processing failed: %v", err)
+				break
+			}
+			if n < MediaProcessingBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// processMediaBatch claims one batch of pending images, processes them and returns how many were claimed
+func processMediaBatch(ctx context.Context) (int, error) {
+	var claimed []posts.Media
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processing_status = ? OR (processing_status = ? AND updated_at < ?)",
+				posts.MediaProcessingPending, mediaProcessingActive, time.Now().Add(-MediaProcessingLease)).
+			Order("created_at ASC").
+			Limit(MediaProcessingBatchSize).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(claimed))
+		for i, media := range claimed {
+			ids[i] = media.ID
+		}
+		return tx.Model(&posts.Media{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"processing_status":   mediaProcessingActive,
+				"processing_attempts": gorm.Expr("processing_attempts + 1"),
+			}).Error
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, media := range claimed {
+		if ctx.Err() != nil {
+			// Left claimed; the lease hands it to the next worker
+			break
+		}
+		err := processImage(ctx, media)
+		if err == nil {
+			invalidatePostDetails(media.PostID)
+			continue
+		}
+
+		// The attempt counter was raised when the image was claimed
+		status := posts.MediaProcessingPending
+		if errors.Is(err, errUnprocessable) || media.ProcessingAttempts+1 >= MediaProcessingMaxAttempts {
+			status = posts.MediaProcessingFailed
+		}
+		log.Printf("[Media] Failed to process %s, now %s: %v", media.ID, status, err)
+		if err := mysql.DB.Model(&posts.Media{}).
+			Where("id = ? AND processing_status = ?", media.ID, mediaProcessingActive).
+			Update("processing_status", status).Error; err != nil {
+			log.Printf("[Media] Failed to update %s: %v", media.ID, err)
+		}
+	}
+	return len(claimed), nil
+}
+
+// processImage re-encodes the stored image without metadata and records it with its variants. The uploaded file
+// may be shared with other media, so every output is stored under the key of its own content and the row is
+// pointed at it; the upload is only deleted once nothing references it.
+func processImage(ctx context.Context, media posts.Media) error {
+	// Identical uploads share one object, which may have been processed for another post already
+	if reused, err := reuseProcessedImage(ctx, media); reused || err != nil {
+		return err
+	}
+
+	data, err := readStoredImage(ctx, media.Path)
+	if err != nil {
+		return err
+	}
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+
+	// Everything written stays claimed until the row references it, or is given up. settle releases
+	// the claims and deletes the paths nothing references.
+	var written []string
+	settle := func(paths []string) {
+		functions.ReleaseStoredFiles(written)
+		functions.DeleteStoredFiles(paths)
+	}
+
+	path, contentType := media.Path, media.Type
+	// GIFs carry no EXIF and re-encoding would drop their animation, so only they are kept as uploaded
+	if format != imaging.FormatGIF {
+		outFormat := imaging.OutputFormat(img, format)
+		if path, _, err = storeImage(ctx, img, outFormat); err != nil {
+			return err
+		}
+		written = append(written, path)
+		contentType = imaging.ContentType(outFormat)
+	}
+
+	bounds := img.Bounds()
+	variantFormat := imaging.OutputFormat(img, "")
+	var variants []posts.MediaVariant
+	for _, size := range ImageVariants {
+		if size.Width >= bounds.Dx() {
+			break
+		}
+		resized := imaging.Resize(img, size.Width)
+		variant := posts.MediaVariant{
+			MediaID: media.ID,
+			Name:    size.Name,
+			Type:    imaging.ContentType(variantFormat),
+			Width:   resized.Bounds().Dx(),
+			Height:  resized.Bounds().Dy(),
+		}
+		if variant.Path, variant.Size, err = storeImage(ctx, resized, variantFormat); err != nil {
+			settle(written)
+			return err
+		}
+		written = append(written, variant.Path)
+		variants = append(variants, variant)
+	}
+
+	hash, err := imaging.Blurhash(img)
+	if err != nil {
+		settle(written)
+		return fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+
+	replaced, removed, err := recordProcessedImage(media.ID, media.Path, path, contentType, bounds.Dx(), bounds.Dy(), hash, variants)
+	if err != nil {
+		settle(written)
+		return err
+	}
+	if removed {
+		// The post's files were deleted while the image was being processed
+		settle(append(written, media.Path))
+		return nil
+	}
+	// The uploaded file and the variants of an earlier attempt are kept if other media still use them
+	settle(append(replaced, media.Path))
+	return nil
+}
+
+// reuseProcessedImage copies the results of a processed media sharing the file of media, if there is one
+func reuseProcessedImage(ctx context.Context, media posts.Media) (bool, error) {
+	var twin posts.Media
+	err := mysql.DB.Preload("Variants").
+		Where("(source_path = ? OR path = ?) AND id <> ? AND processing_status = ?", media.Path, media.Path, media.ID, posts.MediaProcessingReady).
+		Take(&twin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	paths := []string{twin.Path}
+	variants := make([]posts.MediaVariant, len(twin.Variants))
+	for i, variant := range twin.Variants {
+		paths = append(paths, variant.Path)
+		variants[i] = posts.MediaVariant{
+			MediaID: media.ID,
+			Name:    variant.Name,
+			Path:    variant.Path,
+			Type:    variant.Type,
+			Width:   variant.Width,
+			Height:  variant.Height,
+			Size:    variant.Size,
+		}
+	}
+	// The twin may be deleted meanwhile: once claimed its files stay, provided they are still stored
+	if err := functions.ClaimStoredFiles(paths); err != nil {
+		return false, err
+	}
+	for _, path := range paths {
+		if _, err := storage.Blob.Stat(ctx, path); err != nil {
+			functions.ReleaseStoredFiles(paths)
+			if errors.Is(err, storage.ErrNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+
+	var width, height int
+	if twin.Width != nil && twin.Height != nil {
+		width, height = *twin.Width, *twin.Height
+	}
+	var hash string
+	if twin.Blurhash != nil {
+		hash = *twin.Blurhash
+	}
+	replaced, removed, err := recordProcessedImage(media.ID, media.Path, twin.Path, twin.Type, width, height, hash, variants)
+	functions.ReleaseStoredFiles(paths)
+	if err != nil {
+		return true, err
+	}
+	if removed {
+		functions.DeleteStoredFiles(append(paths, media.Path))
+		return true, nil
+	}
+	functions.DeleteStoredFiles(append(replaced, media.Path))
+	return true, nil
+}
+
+// recordProcessedImage points the media row at the processed file uploaded as sourcePath, replacing its variants.
+// It returns the paths of the variants it replaced, and reports whether the row is gone, in which case nothing is recorded.
+func recordProcessedImage(mediaID uuid.UUID, sourcePath, path, contentType string, width, height int, hash string, variants []posts.MediaVariant) ([]string, bool, error) {
+	var (
+		replaced []string
+		removed  bool
+	)
+	err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		// The post may have been edited or purged meanwhile
+		var current posts.Media
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", mediaID).Take(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			removed = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Variants of an earlier attempt that was cut short
+		if err := tx.Model(&posts.MediaVariant{}).Where("media_id = ?", mediaID).Pluck("path", &replaced).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("media_id = ?", mediaID).Delete(&posts.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variants) > 0 {
+			if err := tx.Create(&variants).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&posts.Media{}).Where("id = ?", mediaID).Updates(map[string]interface{}{
+			"path":              path,
+			"source_path":       sourcePath,
+			"type":              contentType,
+			"width":             width,
+			"height":            height,
+			"blurhash":          hash,
+			"processing_status": posts.MediaProcessingReady,
+		}).Error
+	})
+	if err != nil || removed {
+		return nil, removed, err
+	}
+	return replaced, false, nil
+}
+
+func readStoredImage(ctx context.Context, key string) ([]byte, error) {
+	object, err := storage.Blob.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(io.LimitReader(object, MaxProcessedImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxProcessedImageSize {
+		return nil, fmt.Errorf("%w: file is larger than %d bytes", errUnprocessable, MaxProcessedImageSize)
+	}
+	return data, nil
+}
+
+// storeImage encodes img and stores it under the content-addressed key of the encoded file, unless an identical
+// file is stored there already. It returns the key, claimed until the caller releases it, and the size.
+func storeImage(ctx context.Context, img image.Image, format string) (string, int64, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	key := functions.MediaKey(sum[:], imaging.ContentType(format))
+	size := int64(buf.Len())
+
+	if err := functions.ClaimStoredFiles([]string{key}); err != nil {
+		return "", 0, err
+	}
+	_, err := storage.Blob.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = storage.Blob.Put(ctx, key, &buf, size, imaging.ContentType(format))
+	}
+	if err != nil {
+		functions.ReleaseStoredFiles([]string{key})
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// addImageDetails completes the response entry of an image. Images still being processed may carry
+// metadata such as their location, so their URL is only handed out once they are ready.
+func addImageDetails(item map[string]interface{}, width, height sql.NullInt64, blurhash, processingStatus sql.NullString) {
+	status := processingStatus.String
+	if status == mediaProcessingActive {
+		status = posts.MediaProcessingPending
+	}
+	item["processing_status"] = status
+	item["width"] = width.Int64
+	item["height"] = height.Int64
+	item["blurhash"] = blurhash.String
+	item["variants"] = []map[string]interface{}{}
+	item["srcset"] = ""
+	if status != posts.MediaProcessingReady {
+		item["url"] = ""
+	}
+}
+
+// attachImageVariants adds the resized variants of the images to their response entries, with a
+// srcset listing every size the client can choose from.
+func attachImageVariants(images []map[string]interface{}) error {
+	var mediaIDs []string
+	for _, item := range images {
+		if item["processing_status"] == posts.MediaProcessingReady {
+			mediaIDs = append(mediaIDs, item["id"].(string))
+		}
+	}
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	var variants []posts.MediaVariant
+	if err := mysql.DB.Where("media_id IN ?", mediaIDs).Order("width ASC").Find(&variants).Error; err != nil {
+		return err
+	}
+	byMedia := make(map[string][]posts.MediaVariant)
+	for _, variant := range variants {
+		byMedia[variant.MediaID.String()] = append(byMedia[variant.MediaID.String()], variant)
+	}
+
+	ctx := context.Background()
+	for _, item := range images {
+		if item["processing_status"] != posts.MediaProcessingReady {
+			continue
+		}
+		var (
+			items  []map[string]interface{}
+			srcset []string
+		)
+		for _, variant := range byMedia[item["id"].(string)] {
+			url := functions.MediaURL(ctx, variant.Path)
+			if url == "" {
+				continue
+			}
+			items = append(items, map[string]interface{}{
+				"name":   variant.Name,
+				"url":    url,
+				"type":   variant.Type,
+				"width":  variant.Width,
+				"height": variant.Height,
+			})
+			srcset = append(srcset, fmt.Sprintf("%s %dw", url, variant.Width))
+		}
+		if url, _ := item["url"].(string); url != "" && item["width"].(int64) > 0 {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", url, item["width"].(int64)))
+		}
+		if items != nil {
+			item["variants"] = items
+		}
+		item["srcset"] = strings.Join(srcset, ", ")
+	}
+	return nil
+}
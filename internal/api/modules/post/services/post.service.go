@@ -1,6 +1,7 @@
 package posts
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,21 +11,37 @@ import (
 	"time"
 
 	"github.com/unarya/univia/internal/api/functions"
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
 	posts "github.com/unarya/univia/internal/api/modules/post/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/search"
 	"github.com/unarya/univia/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// MaxPostSearchMatches bounds the posts a search value in a post list can match
+const MaxPostSearchMatches = search.MaxLimit
+
 func List(currentPage, itemsPerPage int, orderBy, sortBy, searchValue string, userID uuid.UUID) (map[string]interface{}, error) {
 	// Validate sorting and calculate offset for pagination
 	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, sortBy, orderBy)
 
+	matchIDs, searchErr := searchPostIDs(searchValue)
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	if matchIDs != nil && len(matchIDs) == 0 {
+		return map[string]interface{}{"items": []map[string]interface{}{}, "pagination": nil}, nil
+	}
+
 	// Fetch posts using the SelectPosts function
 	rows, err := functions.SelectPosts(
-		searchValue,
+		matchIDs,
+		userID,
 		offsetData.OrderBy,
 		offsetData.SortBy,
 		offsetData.Offset,
@@ -35,32 +52,120 @@ func List(currentPage, itemsPerPage int, orderBy, sortBy, searchValue string, us
 	}
 	defer rows.Close()
 
-	postMap := make(map[uuid.UUID]map[string]interface{})
+	items, totalCount, scanErr := scanPostList(rows, userID)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	// Build pagination metadata once there is a page
 	var paginationResult map[string]interface{}
+	if len(items) > 0 {
+		paginated, err := utils.Paginate(int64(totalCount), currentPage, itemsPerPage)
+		if err != nil {
+			return nil, err
+		}
+		paginationResult = paginated
+	}
+
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": paginationResult,
+	}, nil
+}
+
+// ListPage is List with cursor pagination, newest posts first. Unlike pages, cursors do not skip or repeat
+// posts when new ones are published between requests.
+func ListPage(page utils.CursorPage, searchValue string, userID uuid.UUID) (map[string]interface{}, error) {
+	matchIDs, searchErr := searchPostIDs(searchValue)
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	if matchIDs != nil && len(matchIDs) == 0 {
+		return map[string]interface{}{"items": []map[string]interface{}{}, "pagination": utils.CursorPaginate(page, nil, nil, false)}, nil
+	}
+
+	rows, hasMore, err := functions.SelectPostPage(matchIDs, userID, page)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items, _, scanErr := scanPostList(rows, userID)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	var first, last *utils.Cursor
+	if len(items) > 0 {
+		first = postCursor(items[0])
+		last = postCursor(items[len(items)-1])
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": utils.CursorPaginate(page, first, last, hasMore),
+	}, nil
+}
+
+// searchPostIDs returns the posts the search index matches for searchValue, at most MaxPostSearchMatches of them
+// by relevance, or nil when there is nothing to search for
+func searchPostIDs(searchValue string) ([]uuid.UUID, *utils.ServiceError) {
+	if len(search.Tokenize(searchValue)) == 0 {
+		return nil, nil
+	}
+	results, err := search.Engine.Search(context.Background(), search.Query{
+		Text:  searchValue,
+		Kinds: []string{search.KindPost},
+		Limit: MaxPostSearchMatches,
+	})
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	postIDs := make([]uuid.UUID, len(results.Hits))
+	for i, hit := range results.Hits {
+		postIDs[i] = hit.ID
+	}
+	return postIDs, nil
+}
+
+func postCursor(post map[string]interface{}) *utils.Cursor {
+	return &utils.Cursor{CreatedAt: post["created_at"].(time.Time), ID: post["id"].(uuid.UUID)}
+}
+
+// scanPostList groups the rows of a post list, one per post and media, into posts in the order of the rows.
+// It returns the total_count column of the rows. userID is the viewer, quoted posts they do not see are left out.
+func scanPostList(rows *sql.Rows, userID uuid.UUID) ([]map[string]interface{}, int, error) {
+	var (
+		postIDs    []uuid.UUID
+		totalCount int
+	)
+	postMap := make(map[uuid.UUID]map[string]interface{})
 
 	for rows.Next() {
 		// Declare variables for scanning
 		var (
-			postID, ownerID                                    uuid.UUID
-			content                                            sql.NullString
-			createdAt, updatedAt                               time.Time
-			categoryIDs, categoryNames                         sql.NullString
-			mediaID                                            sql.NullInt64
-			mediaStatus                                        sql.NullInt64
-			mediaPath, mediaType                               sql.NullString
-			username, profilePic                               sql.NullString
-			commentsCount, likesCount, sharesCount, totalCount int
+			postID, ownerID                        uuid.UUID
+			sharedPostID                           uuid.NullUUID
+			content                                sql.NullString
+			createdAt, updatedAt                   time.Time
+			categoryIDs, categoryNames             sql.NullString
+			mediaID                                sql.NullString
+			mediaStatus, mediaWidth, mediaHeight   sql.NullInt64
+			mediaPath, mediaType                   sql.NullString
+			mediaBlurhash, mediaProcessing         sql.NullString
+			username, profilePic                   sql.NullString
+			commentsCount, likesCount, sharesCount int
 		)
 
 		// Scan values from query result
 		if err := rows.Scan(
-			&postID, &content, &createdAt, &updatedAt,
+			&postID, &content, &createdAt, &updatedAt, &sharedPostID,
 			&ownerID, &username, &profilePic,
 			&categoryIDs, &categoryNames,
 			&mediaID, &mediaPath, &mediaType, &mediaStatus,
+			&mediaWidth, &mediaHeight, &mediaBlurhash, &mediaProcessing,
 			&commentsCount, &likesCount, &sharesCount, &totalCount,
 		); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		// If post doesn't exist in map, initialize it
@@ -97,65 +202,92 @@ func List(currentPage, itemsPerPage int, orderBy, sortBy, searchValue string, us
 				"likes_count":    likesCount,
 				"is_liked":       isLiked,
 				"shares_count":   sharesCount,
+				"shared_post":    nil,
+			}
+			if sharedPostID.Valid {
+				post["shared_post_id"] = sharedPostID.UUID
 			}
 			postMap[postID] = post
+			postIDs = append(postIDs, postID)
 		}
 
 		// Append media to image or video list
 		if mediaID.Valid && mediaPath.Valid && mediaType.Valid && mediaStatus.Valid {
 			mediaItem := map[string]interface{}{
-				"id":     mediaID.Int64,
+				"id":     mediaID.String,
 				"path":   mediaPath.String,
+				"url":    functions.MediaURL(context.Background(), mediaPath.String),
 				"type":   mediaType.String,
 				"status": int(mediaStatus.Int64),
 			}
 			if strings.HasPrefix(mediaType.String, "image/") {
+				addImageDetails(mediaItem, mediaWidth, mediaHeight, mediaBlurhash, mediaProcessing)
 				post["images"] = append(post["images"].([]map[string]interface{}), mediaItem)
 			} else if strings.HasPrefix(mediaType.String, "video/") {
 				post["videos"] = append(post["videos"].([]map[string]interface{}), mediaItem)
 			}
 		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
 
-		// Build pagination metadata once
-		if paginationResult == nil {
-			paginated, err := utils.Paginate(int64(totalCount), currentPage, itemsPerPage)
-			if err != nil {
-				return nil, err
-			}
-			paginationResult = paginated
+	// Embed the posts quoted by quote reposts, and the responsive sizes of the images
+	var (
+		quotedIDs []uuid.UUID
+		images    []map[string]interface{}
+	)
+	for _, post := range postMap {
+		if id, ok := post["shared_post_id"].(uuid.UUID); ok {
+			quotedIDs = append(quotedIDs, id)
 		}
+		images = append(images, post["images"].([]map[string]interface{})...)
+	}
+	if err := attachImageVariants(images); err != nil {
+		return nil, 0, err
+	}
+	quoted, quotedErr := functions.SelectSharedPostSummaries(quotedIDs, userID)
+	if quotedErr != nil {
+		return nil, 0, quotedErr
 	}
 
-	// Convert postMap to slice
-	items := make([]map[string]interface{}, 0, len(postMap))
-	for _, post := range postMap {
+	items := make([]map[string]interface{}, 0, len(postIDs))
+	for _, postID := range postIDs {
+		post := postMap[postID]
+		if id, ok := post["shared_post_id"].(uuid.UUID); ok {
+			if summary, found := quoted[id]; found {
+				post["shared_post"] = summary
+			}
+		}
 		items = append(items, post)
 	}
-
-	return map[string]interface{}{
-		"items":      items,
-		"pagination": paginationResult,
-	}, nil
+	return items, totalCount, nil
 }
 
-// GetDetails is the function to get information details for a post with given postID
+// GetDetails is the function to get information details for a post with given postID. The details are the
+// same for everyone who sees the post: callers check that with functions.CheckPostExits, and embed the post
+// a quote repost quotes with EmbedSharedPost.
 func GetDetails(postID string) (map[string]interface{}, error) {
 	db := mysql.DB
 
 	// **🔹 Query for Post with Associated Media & Categories**
 	rows, err := db.Table("posts").
 		Select(`
-			posts.id, posts.content, posts.created_at, posts.updated_at,
+			posts.id, posts.content, posts.created_at, posts.updated_at, posts.shared_post_id,
 			GROUP_CONCAT(DISTINCT categories.id ORDER BY categories.id ASC SEPARATOR ',') AS category_ids,
 			GROUP_CONCAT(DISTINCT categories.name ORDER BY categories.id ASC SEPARATOR ',') AS category_names,
-			media.id, media.path, media.type, media.status
+			media.id, media.path, media.type, media.status,
+			media.width, media.height, media.blurhash, media.processing_status,
+			(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id) AS comment_count,
+			(SELECT COUNT(*) FROM post_likes WHERE post_likes.post_id = posts.id) AS likes_count,
+			(SELECT COUNT(*) FROM post_shares WHERE post_shares.post_id = posts.id) AS shares_count
 		`).
 		Joins(`
 			LEFT JOIN post_categories ON post_categories.post_id = posts.id
 			LEFT JOIN categories ON categories.id = post_categories.category_id
 			LEFT JOIN media ON media.post_id = posts.id
 		`).
-		Where("posts.id = ?", postID).
+		Where("posts.id = ? AND posts.deleted_at IS NULL AND posts.status = 'published'", postID).
 		Group("posts.id, media.id").
 		Rows()
 
@@ -166,11 +298,13 @@ func GetDetails(postID string) (map[string]interface{}, error) {
 
 	// **🔹 Initialize Storage for Data**
 	var (
-		id                         uint
-		content                    sql.NullString
-		createdAt                  time.Time
-		updatedAt                  time.Time
-		categoryIDs, categoryNames sql.NullString
+		id                                     uuid.UUID
+		sharedPostID                           uuid.NullUUID
+		content                                sql.NullString
+		createdAt                              time.Time
+		updatedAt                              time.Time
+		categoryIDs, categoryNames             sql.NullString
+		commentsCount, likesCount, sharesCount int64
 	)
 
 	var categories []map[string]interface{}
@@ -180,12 +314,16 @@ func GetDetails(postID string) (map[string]interface{}, error) {
 	// **🔹 Iterate Over Rows to Collect Data**
 	for rows.Next() {
 		var (
-			mediaID, mediaStatus int64
-			mediaPath, mediaType sql.NullString
+			mediaID                              sql.NullString
+			mediaStatus, mediaWidth, mediaHeight sql.NullInt64
+			mediaPath, mediaType                 sql.NullString
+			mediaBlurhash, mediaProcessing       sql.NullString
 		)
 
 		// **Scan Data into Variables**
-		err := rows.Scan(&id, &content, &createdAt, &updatedAt, &categoryIDs, &categoryNames, &mediaID, &mediaPath, &mediaType, &mediaStatus)
+		err := rows.Scan(&id, &content, &createdAt, &updatedAt, &sharedPostID, &categoryIDs, &categoryNames,
+			&mediaID, &mediaPath, &mediaType, &mediaStatus, &mediaWidth, &mediaHeight, &mediaBlurhash, &mediaProcessing,
+			&commentsCount, &likesCount, &sharesCount)
 		if err != nil {
 			return nil, err
 		}
@@ -205,13 +343,15 @@ func GetDetails(postID string) (map[string]interface{}, error) {
 		// **Process Media**
 		if mediaPath.Valid && mediaType.Valid {
 			mediaItem := map[string]interface{}{
-				"id":     mediaID,
+				"id":     mediaID.String,
 				"path":   mediaPath.String,
+				"url":    functions.MediaURL(context.Background(), mediaPath.String),
 				"type":   mediaType.String,
-				"status": mediaStatus,
+				"status": mediaStatus.Int64,
 			}
 
 			if strings.HasPrefix(mediaType.String, "image/") {
+				addImageDetails(mediaItem, mediaWidth, mediaHeight, mediaBlurhash, mediaProcessing)
 				images = append(images, mediaItem)
 			} else if strings.HasPrefix(mediaType.String, "video/") {
 				videos = append(videos, mediaItem)
@@ -220,9 +360,12 @@ func GetDetails(postID string) (map[string]interface{}, error) {
 	}
 
 	// **🔹 If No Rows Found, Return Error**
-	if id == 0 {
+	if id == uuid.Nil {
 		return nil, errors.New("post not found")
 	}
+	if err := attachImageVariants(images); err != nil {
+		return nil, err
+	}
 
 	// **🔹 Construct Final Response**
 	postData := map[string]interface{}{
@@ -233,154 +376,202 @@ func GetDetails(postID string) (map[string]interface{}, error) {
 		"videos":     videos,
 		"created_at": createdAt,
 		"updated_at": updatedAt,
+
+		"comments_count": commentsCount,
+		"likes_count":    likesCount,
+		"shares_count":   sharesCount,
+		"shared_post":    nil,
+	}
+
+	if sharedPostID.Valid {
+		postData["shared_post_id"] = sharedPostID.UUID
 	}
 
 	return postData, nil
 }
 
+// EmbedSharedPost gives the details of a quote repost a summary of the post it quotes, when the viewer sees it
+func EmbedSharedPost(postData map[string]interface{}, viewerID uuid.UUID) *utils.ServiceError {
+	postData["shared_post"] = nil
+	raw, quotes := postData["shared_post_id"]
+	if !quotes || raw == nil {
+		return nil
+	}
+	// Details read back from the cache carry the id as a string
+	sharedPostID, err := uuid.Parse(fmt.Sprint(raw))
+	if err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Invalid shared post id"}
+	}
+	quoted, serviceErr := functions.SelectSharedPostSummaries([]uuid.UUID{sharedPostID}, viewerID)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if summary, found := quoted[sharedPostID]; found {
+		postData["shared_post"] = summary
+	}
+	return nil
+}
+
 type PostInfo struct {
-	UserID      uuid.UUID
+	UserID uuid.UUID
+	// RoleID lets moderators edit posts they do not own
+	RoleID      uuid.UUID `json:"-"`
 	PostID      uuid.UUID
 	CategoryIDs []uuid.UUID
 	Media       []*multipart.FileHeader
-	Content     string
+	// UploadIDs are completed upload sessions to attach as media
+	UploadIDs []uuid.UUID
+	Content   string
 }
 
-// CreatePost handles post creation along with media and categories
-func CreatePost(content string, categoryIDs []uuid.UUID, files []*multipart.FileHeader, userID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
-	db := mysql.DB
+// CreatePost handles post creation along with media and categories. Media comes as multipart files,
+// which are uploaded first and removed again if the post cannot be saved, or as completed upload sessions.
+// The post is published at once unless status saves it as a draft or schedules it for publishAt, and it is
+// public unless visibility restricts who sees it.
+func CreatePost(content string, categoryIDs []uuid.UUID, files []*multipart.FileHeader, uploadIDs []uuid.UUID, userID uuid.UUID, status string, publishAt *time.Time, visibility string) (map[string]interface{}, *utils.ServiceError) {
+	if status == "" {
+		status = posts.PostStatusPublished
+	}
+	if visibility == "" {
+		visibility = posts.PostVisibilityPublic
+	}
+	if serviceErr := validateNewPostStatus(status, publishAt, time.Now()); serviceErr != nil {
+		return nil, serviceErr
+	}
+	if status != posts.PostStatusScheduled {
+		publishAt = nil
+	}
 
-	savedMediaResult, err := functions.SaveMediaToServer(files)
+	savedMediaResult, err := functions.StoreMedia(context.Background(), files)
 	if err != nil {
 		return nil, &utils.ServiceError{StatusCode: err.StatusCode, Message: err.Message}
 	}
-	tx := db.Begin()
-	if tx.Error != nil {
-		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to start transaction"}
-	}
 
-	// CreatePost
-	postID, savePostErr := functions.CreatePost(content, userID)
-	if savePostErr != nil {
-		tx.Rollback()
-		return nil, &utils.ServiceError{StatusCode: savePostErr.StatusCode, Message: savePostErr.Message}
-	}
-	// Save media to mysql
-	saveMediaErr := functions.SaveMediaRecords(savedMediaResult, postID)
-	if saveMediaErr != nil {
-		tx.Rollback()
-		return nil, &utils.ServiceError{StatusCode: saveMediaErr.StatusCode, Message: saveMediaErr.Message}
+	var (
+		postID     uuid.UUID
+		serviceErr *utils.ServiceError
+	)
+	txErr := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if serviceErr = functions.ValidatePostVisibility(tx, userID, visibility); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		// CreatePost
+		if postID, serviceErr = functions.CreatePost(tx, content, userID, status, publishAt, visibility); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		uploaded, attachErr := functions.AttachUploadSessions(tx, userID, uploadIDs)
+		if attachErr != nil {
+			serviceErr = attachErr
+			return errors.New(serviceErr.Message)
+		}
+		// Save media to mysql
+		if serviceErr = functions.SaveMediaRecords(tx, append(savedMediaResult, uploaded...), postID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		// Save Categories to Post
+		if serviceErr = functions.SaveCategoriesToPost(tx, categoryIDs, postID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		if status != posts.PostStatusPublished {
+			return nil
+		}
+		return announcePost(tx, postID, userID, content)
+	})
+	// The files are referenced by the committed rows now, or are no longer wanted
+	functions.ReleaseStoredMedia(savedMediaResult)
+	if txErr != nil {
+		functions.DeleteStoredMedia(savedMediaResult)
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create post"}
 	}
 
-	// Save Categories to Post
-	saveCategoriesErr := functions.SaveCategoriesToPost(categoryIDs, postID)
-	if saveCategoriesErr != nil {
-		tx.Rollback()
-		return nil, &utils.ServiceError{StatusCode: saveCategoriesErr.StatusCode, Message: saveCategoriesErr.Message}
+	wakeMediaProcessing()
+	if status == posts.PostStatusPublished {
+		queueFeedFanout(feedFanout{authorID: userID, entry: functions.FeedEntry{PostID: postID, ActivityAt: time.Now()}})
 	}
-	tx.Commit()
-
 	return map[string]interface{}{
 		"id":         postID,
 		"content":    content,
 		"categories": categoryIDs,
+		"status":     status,
+		"publish_at": publishAt,
+		"visibility": visibility,
 	}, nil
 }
 
-// EditPostByUserID is the function to edits a post and updates its media and categories
+// EditPostByUserID is the function to edits a post and replaces its media and categories.
+// The old files are only removed once the new rows are committed, the new ones if they are not.
 func EditPostByUserID(postInfo PostInfo) *utils.ServiceError {
-	db := mysql.DB
-	tx := db.Begin()
-	if tx.Error != nil {
-		return &utils.ServiceError{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to start transaction",
-		}
-	}
-	// Check Post Exits
-	var checkPostErr = functions.CheckPostExits(postInfo.PostID)
-	if checkPostErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: checkPostErr.StatusCode,
-			Message:    checkPostErr.Message,
-		}
+	savedMediaResult, err := functions.StoreMedia(context.Background(), postInfo.Media)
+	if err != nil {
+		return &utils.ServiceError{StatusCode: err.StatusCode, Message: err.Message}
 	}
 
-	// Delete existing media
-	var existingMedia []posts.Media
-	if err := tx.Where("post_id = ?", postInfo.PostID).Find(&existingMedia).Error; err != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to retrieve existing media",
+	var (
+		existingFiles []string
+		serviceErr    *utils.ServiceError
+	)
+	txErr := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the owner, or a role allowed to update any post, may edit it
+		if serviceErr = PermissionServices.AuthorizeResource(tx, postInfo.UserID, postInfo.RoleID, PermissionServices.ResourcePost, PermissionServices.ActionUpdate, postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
 
-	for _, media := range existingMedia {
-		if err := functions.DeleteUploadedFile(media.Path); err != nil {
-			tx.Rollback()
-			return &utils.ServiceError{
-				StatusCode: http.StatusInternalServerError,
-				Message:    "Failed to delete old media",
-			}
+		if existingFiles, serviceErr = functions.SelectMediaFilePaths(tx, "post_id = ?", postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
-	// Delete existing media records
-	deleteMediaErr := functions.DeleteMediaRecords(postInfo.PostID)
-	if deleteMediaErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: deleteMediaErr.StatusCode,
-			Message:    deleteMediaErr.Message,
+		// Delete existing media records
+		if serviceErr = functions.DeleteMediaRecords(tx, postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
-	// Delete existing category associations
-	deleteCategoriesErr := functions.DeleteCategoryRecords(postInfo.PostID)
-	if deleteCategoriesErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: deleteCategoriesErr.StatusCode,
-			Message:    deleteCategoriesErr.Message,
+		// Delete existing category associations
+		if serviceErr = functions.DeleteCategoryRecords(tx, postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
-
-	// Update post content
-	updatePostErr := functions.UpdatePostContent(postInfo.Content, postInfo.PostID)
-	if updatePostErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: updatePostErr.StatusCode,
-			Message:    updatePostErr.Message,
+		// Update post content
+		if serviceErr = functions.UpdatePostContent(tx, postInfo.Content, postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
-	// New Media
-	savedMediaResult, err := functions.SaveMediaToServer(postInfo.Media)
-	if err != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: err.StatusCode, Message: err.Message,
+		// New Media
+		uploaded, attachErr := functions.AttachUploadSessions(tx, postInfo.UserID, postInfo.UploadIDs)
+		if attachErr != nil {
+			serviceErr = attachErr
+			return errors.New(serviceErr.Message)
 		}
-	}
-	saveMediaRecordErr := functions.SaveMediaRecords(savedMediaResult, postInfo.PostID)
-	if saveMediaRecordErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: saveMediaRecordErr.StatusCode,
-			Message:    saveMediaRecordErr.Message,
+		if serviceErr = functions.SaveMediaRecords(tx, append(savedMediaResult, uploaded...), postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
 		}
-	}
-
-	// New Categories
-	savedCategoriesErr := functions.SaveCategoriesToPost(postInfo.CategoryIDs, postInfo.PostID)
-	if savedCategoriesErr != nil {
-		tx.Rollback()
-		return &utils.ServiceError{
-			StatusCode: savedCategoriesErr.StatusCode,
-			Message:    savedCategoriesErr.Message,
+		// New Categories
+		if serviceErr = functions.SaveCategoriesToPost(tx, postInfo.CategoryIDs, postInfo.PostID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		// Drafts are edited quietly, they are indexed and their mentions notified once published.
+		// The author keeps the mentions, a moderator editing the post does not become their sender.
+		var post posts.Post
+		if err := tx.Select("id", "user_id", "status").Where("id = ?", postInfo.PostID).Take(&post).Error; err != nil {
+			return err
+		}
+		if post.Status != posts.PostStatusPublished {
+			return nil
+		}
+		if serviceErr = functions.QueueSearchChange(tx, search.KindPost, postInfo.PostID, outbox.EventPostUpdated); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return saveContentEntities(tx, postInfo.PostID, nil, post.UserID, postInfo.Content)
+	})
+	functions.ReleaseStoredMedia(savedMediaResult)
+	if txErr != nil {
+		functions.DeleteStoredMedia(savedMediaResult)
+		if serviceErr != nil {
+			return serviceErr
 		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update post"}
 	}
-	tx.Commit()
 
+	functions.DeleteStoredFiles(existingFiles)
+	wakeMediaProcessing()
+	invalidatePostDetails(postInfo.PostID)
 	return nil
 }
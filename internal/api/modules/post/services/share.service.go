@@ -0,0 +1,259 @@
+package posts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/notification/services"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SharePost reposts the post to the user's followers; a user shares a post at most once
+func SharePost(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
+	var (
+		counts     int64
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		ownerID, lookupErr := getPostOwner(tx, userID, postID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		shared, checkErr := functions.CheckIsShared(tx, userID, postID)
+		if checkErr != nil {
+			serviceErr = checkErr
+			return checkErr
+		}
+		if shared {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have already shared this post"}
+			return serviceErr
+		}
+
+		// uq_post_shares_plain rejects a concurrent duplicate
+		if err := tx.Create(&posts.PostShare{ID: uuid.New(), PostID: postID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		var err error
+		if counts, err = functions.CountShares(tx, postID); err != nil {
+			return err
+		}
+		return notifyPostShared(tx, userID, ownerID, postID, "shared your post")
+	}); err != nil {
+		if serviceErr != nil {
+			return counts, serviceErr
+		}
+		return counts, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to share the post"}
+	}
+
+	invalidatePostDetails(postID)
+	queueFeedFanout(feedFanout{authorID: userID, entry: sharedEntry(userID, postID)})
+	return counts, nil
+}
+
+// UnsharePost removes the user's plain share; quote reposts are removed by deleting the quoting post
+func UnsharePost(userID, postID uuid.UUID) (int64, *utils.ServiceError) {
+	var counts int64
+	shared, serviceErr := functions.CheckIsShared(mysql.DB, userID, postID)
+	if serviceErr != nil {
+		return counts, serviceErr
+	}
+	if !shared {
+		return counts, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have not shared this post"}
+	}
+
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND post_id = ? AND quote_post_id IS NULL", userID, postID).
+			Delete(&posts.PostShare{}).Error; err != nil {
+			return err
+		}
+		var err error
+		counts, err = functions.CountShares(tx, postID)
+		return err
+	}); err != nil {
+		return counts, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to unshare the post"}
+	}
+
+	invalidatePostDetails(postID)
+	queueFeedFanout(feedFanout{authorID: userID, entry: sharedEntry(userID, postID), remove: true})
+	return counts, nil
+}
+
+// QuotePost creates a post of the user's own that quotes the original, and records it as a share. The quote
+// has a visibility of its own; whoever sees it still sees the original only if they may.
+func QuotePost(userID, postID uuid.UUID, content, visibility string) (map[string]interface{}, *utils.ServiceError) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "A quote needs some content"}
+	}
+	if visibility == "" {
+		visibility = posts.PostVisibilityPublic
+	}
+
+	quote := posts.Post{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Content:      content,
+		SharedPostID: &postID,
+		Visibility:   visibility,
+	}
+	var (
+		counts     int64
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		ownerID, lookupErr := getPostOwner(tx, userID, postID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if serviceErr = functions.ValidatePostVisibility(tx, userID, visibility); serviceErr != nil {
+			return serviceErr
+		}
+		if err := tx.Create(&quote).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&posts.PostShare{
+			ID:          uuid.New(),
+			PostID:      postID,
+			UserID:      userID,
+			QuotePostID: &quote.ID,
+		}).Error; err != nil {
+			return err
+		}
+		if serviceErr = functions.QueueSearchChange(tx, search.KindPost, quote.ID, outbox.EventPostCreated); serviceErr != nil {
+			return serviceErr
+		}
+		if err := saveContentEntities(tx, quote.ID, nil, userID, quote.Content); err != nil {
+			return err
+		}
+		var err error
+		if counts, err = functions.CountShares(tx, postID); err != nil {
+			return err
+		}
+		return notifyPostShared(tx, userID, ownerID, postID, "quoted your post")
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to quote the post"}
+	}
+
+	invalidatePostDetails(postID)
+	queueFeedFanout(feedFanout{authorID: userID, entry: functions.FeedEntry{PostID: quote.ID, ActivityAt: quote.CreatedAt}})
+	return map[string]interface{}{
+		"id":             quote.ID,
+		"content":        quote.Content,
+		"shared_post_id": postID,
+		"visibility":     quote.Visibility,
+		"shares_count":   counts,
+	}, nil
+}
+
+// ListFollowingShares lists what the people the user follows have shared, newest first
+func ListFollowingShares(userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	if itemsPerPage <= 0 {
+		itemsPerPage = 10
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectFollowingShares(userID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		item := map[string]interface{}{
+			"id":        row.ShareID,
+			"shared_at": row.SharedAt,
+			"shared_by": gin.H{"id": row.SharerID, "name": row.SharerUsername, "profile_pic": row.SharerProfilePic},
+			"quote":     nil,
+			"post": gin.H{
+				"id":             row.PostID,
+				"content":        row.PostContent,
+				"created_at":     row.PostCreatedAt,
+				"user":           gin.H{"id": row.AuthorID, "name": row.AuthorUsername, "profile_pic": row.AuthorProfilePic},
+				"shares_count":   row.SharesCount,
+				"likes_count":    row.LikesCount,
+				"comments_count": row.CommentsCount,
+			},
+		}
+		if row.QuotePostID.Valid {
+			item["quote"] = gin.H{"id": row.QuotePostID.UUID, "content": row.QuoteContent}
+		}
+		items = append(items, item)
+	}
+
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
+
+// sharedEntry is the feed entry of a plain share of postID by userID
+func sharedEntry(userID, postID uuid.UUID) functions.FeedEntry {
+	return functions.FeedEntry{PostID: postID, SharerID: uuid.NullUUID{UUID: userID, Valid: true}, ActivityAt: time.Now()}
+}
+
+// getPostOwner returns the author of a post the user may share. Posts the user does not see are not found.
+func getPostOwner(tx *gorm.DB, userID, postID uuid.UUID) (uuid.UUID, *utils.ServiceError) {
+	var post posts.Post
+	err := functions.VisiblePosts(tx.Select("id", "user_id"), userID).
+		Where("id = ? AND deleted_at IS NULL AND status = 'published'", postID).
+		Take(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+	}
+	if err != nil {
+		return uuid.Nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get post"}
+	}
+	return post.UserID, nil
+}
+
+// notifyPostShared tells the original author, unless they shared their own post
+func notifyPostShared(tx *gorm.DB, sharerID, ownerID, postID uuid.UUID, action string) error {
+	if sharerID == ownerID {
+		return nil
+	}
+	username, err := getUsername(tx, sharerID)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s %s", username, action)
+	if notiErr := notifications.PostNotificationHandler(tx, sharerID, ownerID, postID, message, "personal_post", outbox.EventPostShared); notiErr != nil {
+		log.Printf("Failed to send notification: %v", notiErr.Message)
+		return errors.New(notiErr.Message)
+	}
+	return nil
+}
+
+// invalidatePostDetails drops the cached detail so the new share count shows up
+func invalidatePostDetails(postID uuid.UUID) {
+	if redis.Redis != nil {
+		_ = redis.Redis.Delete(fmt.Sprintf("detailPost_%s", postID))
+	}
+}
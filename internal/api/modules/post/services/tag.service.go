@@ -0,0 +1,147 @@
+package posts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	notifications "github.com/unarya/univia/internal/api/modules/notification/services"
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTrendingWindow = "24h"
+	DefaultTrendingLimit  = 10
+	MaxTrendingLimit      = 50
+	// trendingCacheTTL keeps trending lists briefly, they are costly to rank and change slowly
+	trendingCacheTTL = time.Minute
+)
+
+// TrendingWindows are the sliding windows trending tags are ranked over
+var TrendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// saveContentEntities stores the hashtags and mentions of a post, or of a comment on it, and notifies the
+// people mentioned for the first time who see the post
+func saveContentEntities(tx *gorm.DB, postID uuid.UUID, commentID *uuid.UUID, authorID uuid.UUID, text string) error {
+	mentioned, serviceErr := functions.SaveContentEntities(tx, postID, commentID, authorID, text)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	username, err := getUsername(tx, authorID)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s mentioned you in a post", username)
+	if commentID != nil {
+		message = fmt.Sprintf("%s mentioned you in a comment", username)
+	}
+	for _, userID := range mentioned {
+		visible, serviceErr := functions.CanViewPost(tx, userID, postID)
+		if serviceErr != nil {
+			return serviceErr
+		}
+		if !visible {
+			continue
+		}
+		if notiErr := notifications.PostNotificationHandler(tx, authorID, userID, postID, message, "personal_mention", outbox.EventUserMentioned); notiErr != nil {
+			log.Printf("Failed to send mention notification for post %s: %v", postID, notiErr.Message)
+			return errors.New(notiErr.Message)
+		}
+	}
+	return nil
+}
+
+// ListTagPage lists the posts using a hashtag, newest first, with cursor pagination
+func ListTagPage(name string, page utils.CursorPage, userID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	name = utils.NormalizeTag(name)
+	if name == "" {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Tag is required"}
+	}
+	tag, postsCount, serviceErr := functions.GetTag(name, userID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	rows, hasMore, serviceErr := functions.SelectTagPostPage(tag.ID, userID, page)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	defer rows.Close()
+
+	items, _, err := scanPostList(rows, userID)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	var first, last *utils.Cursor
+	if len(items) > 0 {
+		first = postCursor(items[0])
+		last = postCursor(items[len(items)-1])
+	}
+	return map[string]interface{}{
+		"tag": map[string]interface{}{
+			"name":        tag.Name,
+			"posts_count": postsCount,
+		},
+		"items":      items,
+		"pagination": utils.CursorPaginate(page, first, last, hasMore),
+	}, nil
+}
+
+// ListTrendingTags ranks the hashtags of the window, one of TrendingWindows
+func ListTrendingTags(window string, limit int) ([]map[string]interface{}, *utils.ServiceError) {
+	if window == "" {
+		window = DefaultTrendingWindow
+	}
+	duration, ok := TrendingWindows[window]
+	if !ok {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Window must be one of 1h, 24h or 7d"}
+	}
+	if limit <= 0 {
+		limit = DefaultTrendingLimit
+	}
+	if limit > MaxTrendingLimit {
+		limit = MaxTrendingLimit
+	}
+
+	cacheKey := fmt.Sprintf("trendingTags_%s_%d", window, limit)
+	if redis.Redis != nil {
+		if results, err := redis.GetJSON[[]map[string]interface{}](redis.Redis, cacheKey); err == nil && results != nil {
+			return *results, nil
+		}
+	}
+
+	rows, serviceErr := functions.SelectTrendingTags(duration, time.Now(), limit)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	results := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		results[i] = map[string]interface{}{
+			"name":          row.Name,
+			"uses":          row.Uses,
+			"previous_uses": row.PreviousUses,
+			"authors":       row.Authors,
+		}
+	}
+	if redis.Redis != nil {
+		_ = redis.Redis.SetJSON(cacheKey, results, trendingCacheTTL)
+	}
+	return results, nil
+}
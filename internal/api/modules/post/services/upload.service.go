@@ -0,0 +1,350 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/storage"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// UploadSessionTTL is how long the client has to upload the file, and then to attach it to a post
+	UploadSessionTTL = time.Hour
+	// MaxUploadSize is the largest file of any type; each type has its own limit below it
+	MaxUploadSize = 2 << 30
+	// Files above MultipartThreshold are uploaded in parts of UploadPartSize when the backend supports it
+	MultipartThreshold    = 64 << 20
+	UploadPartSize        = 16 << 20
+	UploadCleanupInterval = 10 * time.Minute
+	UploadCleanupBatch    = 100
+)
+
+// incomingUploadPrefix holds direct uploads until their session is completed
+const incomingUploadPrefix = "incoming/"
+
+// CreateUploadSession reserves a storage key for a file of size bytes and returns the presigned URLs to upload it.
+// Small files get one PUT URL; large ones get a URL per part, to be finished with CompleteUploadSession.
+// The file is uploaded under a temporary key; completing the session moves it to its content-addressed key.
+func CreateUploadSession(userID uuid.UUID, contentType string, size int64) (map[string]interface{}, *utils.ServiceError) {
+	if !functions.IsAllowedMediaType(contentType) {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: functions.InvalidMediaTypeMessage}
+	}
+	if maxSize := functions.MaxMediaSize(contentType); size <= 0 || size > maxSize {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("%s files must be between 1 byte and %d bytes", contentType, maxSize)}
+	}
+
+	ctx := context.Background()
+	session := posts.UploadSession{
+		ID:          uuid.New(),
+		UserID:      userID,
+		ObjectKey:   fmt.Sprintf("%s%s%s", incomingUploadPrefix, uuid.New(), functions.MediaExtension(contentType)),
+		ContentType: contentType,
+		Size:        size,
+		Status:      posts.UploadStatusPending,
+		ExpiresAt:   time.Now().Add(UploadSessionTTL),
+	}
+
+	response := map[string]interface{}{
+		"upload_id":  session.ID,
+		"key":        session.ObjectKey,
+		"method":     http.MethodPut,
+		"expires_at": session.ExpiresAt,
+	}
+	multipart, supportsParts := storage.Blob.(storage.MultipartUploader)
+	if size > MultipartThreshold && supportsParts {
+		uploadID, err := multipart.NewMultipartUpload(ctx, session.ObjectKey, contentType)
+		if err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to start the upload"}
+		}
+		session.MultipartUploadID = uploadID
+		session.PartCount = int((size + UploadPartSize - 1) / UploadPartSize)
+
+		parts := make([]map[string]interface{}, 0, session.PartCount)
+		for number := 1; number <= session.PartCount; number++ {
+			partURL, err := multipart.PresignUploadPart(ctx, session.ObjectKey, uploadID, number, UploadSessionTTL)
+			if err != nil {
+				_ = multipart.AbortMultipartUpload(ctx, session.ObjectKey, uploadID)
+				return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to sign the upload"}
+			}
+			parts = append(parts, map[string]interface{}{"part_number": number, "url": partURL})
+		}
+		response["part_size"] = UploadPartSize
+		response["parts"] = parts
+	} else {
+		putURL, err := storage.Blob.PresignPut(ctx, session.ObjectKey, UploadSessionTTL)
+		if err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to sign the upload"}
+		}
+		response["url"] = putURL
+	}
+
+	if err := mysql.DB.Create(&session).Error; err != nil {
+		if session.MultipartUploadID != "" {
+			_ = multipart.AbortMultipartUpload(ctx, session.ObjectKey, session.MultipartUploadID)
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to create upload session"}
+	}
+	return response, nil
+}
+
+// CompleteUploadSession assembles the uploaded parts, if any, checks the stored object matches what was announced
+// and moves it to its content-addressed key. The session can then be attached to a post with its upload id until it expires.
+// Storage is worked on without holding the session's row; the session is only completed if it is still pending by then.
+func CompleteUploadSession(userID, sessionID uuid.UUID, parts []storage.CompletedPart) (map[string]interface{}, *utils.ServiceError) {
+	ctx := context.Background()
+	session, serviceErr := findUploadSession(mysql.DB, userID, sessionID)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	if session.Status != posts.UploadStatusPending {
+		return nil, &utils.ServiceError{StatusCode: http.StatusConflict, Message: "Upload is already completed"}
+	}
+
+	if session.MultipartUploadID != "" {
+		if serviceErr := completeParts(ctx, session, parts); serviceErr != nil {
+			return nil, serviceErr
+		}
+	}
+	inspected, serviceErr := verifyUploadedObject(ctx, session)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	// The content key is claimed until the session references it, so a file already stored there stays
+	if err := functions.ClaimStoredFiles([]string{inspected.Key}); err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to store the uploaded file"}
+	}
+	if serviceErr := moveToContentKey(ctx, session.ObjectKey, inspected.Key); serviceErr != nil {
+		functions.ReleaseStoredFiles([]string{inspected.Key})
+		return nil, serviceErr
+	}
+
+	// An abort, the cleanup or a concurrent completion may have taken the session meanwhile
+	now := time.Now()
+	incomingKey := session.ObjectKey
+	expiresAt := now.Add(UploadSessionTTL)
+	result := mysql.DB.Model(&posts.UploadSession{}).
+		Where("id = ? AND user_id = ? AND status = ? AND expires_at > ?", session.ID, userID, posts.UploadStatusPending, now).
+		Updates(map[string]interface{}{
+			"object_key":   inspected.Key,
+			"status":       posts.UploadStatusCompleted,
+			"completed_at": now,
+			"expires_at":   expiresAt,
+		})
+	functions.ReleaseStoredFiles([]string{inspected.Key})
+	if result.Error != nil || result.RowsAffected == 0 {
+		// A copy made for a session that did not complete is not referenced by anything
+		functions.DeleteStoredFiles([]string{inspected.Key})
+		if result.Error != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to complete upload"}
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusConflict, Message: "Upload is no longer pending"}
+	}
+
+	if incomingKey != inspected.Key {
+		if err := storage.Blob.Delete(ctx, incomingKey); err != nil {
+			log.Printf("[Uploads] Failed to delete %s: %v", incomingKey, err)
+		}
+	}
+
+	return map[string]interface{}{
+		"upload_id":    session.ID,
+		"key":          inspected.Key,
+		"type":         session.ContentType,
+		"size":         session.Size,
+		"url":          functions.MediaURL(ctx, inspected.Key),
+		"attach_until": expiresAt,
+	}, nil
+}
+
+// AbortUploadSession drops an upload that was not attached to a post yet, along with whatever was uploaded
+func AbortUploadSession(userID, sessionID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	var session posts.UploadSession
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if session, serviceErr = lockUploadSession(tx, userID, sessionID); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return tx.Delete(&posts.UploadSession{}, "id = ?", session.ID).Error
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to abort upload"}
+	}
+
+	discardUpload(session)
+	return nil
+}
+
+// StartUploadCleanup removes sessions that were never completed or attached in time, until ctx is cancelled
+func StartUploadCleanup(ctx context.Context) {
+	ticker := time.NewTicker(UploadCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := cleanupExpiredUploads()
+			if err != nil {
+				log.Printf("[Uploads] Cleanup failed: %v", err)
+				break
+			}
+			if n < UploadCleanupBatch {
+				break
+			}
+		}
+	}
+}
+
+// cleanupExpiredUploads deletes one batch of expired sessions and returns how many were removed
+func cleanupExpiredUploads() (int, error) {
+	var sessions []posts.UploadSession
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("expires_at < ?", time.Now()).
+			Order("expires_at ASC").
+			Limit(UploadCleanupBatch).
+			Find(&sessions).Error; err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.ID
+		}
+		return tx.Delete(&posts.UploadSession{}, "id IN ?", ids).Error
+	}); err != nil {
+		return 0, err
+	}
+
+	// Objects are removed after the rows, an attach racing the cleanup either wins or finds no session
+	for _, session := range sessions {
+		discardUpload(session)
+	}
+	return len(sessions), nil
+}
+
+func lockUploadSession(tx *gorm.DB, userID, sessionID uuid.UUID) (posts.UploadSession, *utils.ServiceError) {
+	return findUploadSession(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, sessionID)
+}
+
+// findUploadSession returns the user's session unless it has expired
+func findUploadSession(db *gorm.DB, userID, sessionID uuid.UUID) (posts.UploadSession, *utils.ServiceError) {
+	var session posts.UploadSession
+	err := db.Where("id = ? AND user_id = ?", sessionID, userID).Take(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Upload not found"}
+	}
+	if err != nil {
+		return session, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to get upload"}
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return session, &utils.ServiceError{StatusCode: http.StatusGone, Message: "Upload has expired"}
+	}
+	return session, nil
+}
+
+func completeParts(ctx context.Context, session posts.UploadSession, parts []storage.CompletedPart) *utils.ServiceError {
+	multipart, ok := storage.Blob.(storage.MultipartUploader)
+	if !ok {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Storage does not support multipart uploads"}
+	}
+	if len(parts) != session.PartCount {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Expected %d parts, got %d", session.PartCount, len(parts))}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	for i, part := range parts {
+		if part.PartNumber != i+1 || part.ETag == "" {
+			return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Every part needs its part_number and etag"}
+		}
+	}
+	if err := multipart.CompleteMultipartUpload(ctx, session.ObjectKey, session.MultipartUploadID, parts); err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "Failed to assemble the uploaded parts"}
+	}
+	return nil
+}
+
+// verifyUploadedObject checks the object in storage is the file the session was opened for, reading it
+// through the same inspection as files posted to the API
+func verifyUploadedObject(ctx context.Context, session posts.UploadSession) (functions.InspectedMedia, *utils.ServiceError) {
+	info, err := storage.Blob.Stat(ctx, session.ObjectKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return functions.InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "The file has not been uploaded"}
+	}
+	if err != nil {
+		return functions.InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check the uploaded file"}
+	}
+	if info.Size != session.Size {
+		return functions.InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Uploaded %d bytes, expected %d", info.Size, session.Size)}
+	}
+
+	object, err := storage.Blob.Get(ctx, session.ObjectKey)
+	if err != nil {
+		return functions.InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to check the uploaded file"}
+	}
+	defer object.Close()
+	inspected, serviceErr := functions.InspectMedia(ctx, object)
+	if serviceErr != nil {
+		// Malware is not kept around for the client to retry
+		if serviceErr.StatusCode == http.StatusUnprocessableEntity {
+			if err := storage.Blob.Delete(ctx, session.ObjectKey); err != nil {
+				log.Printf("[Uploads] Failed to delete %s: %v", session.ObjectKey, err)
+			}
+		}
+		return functions.InspectedMedia{}, serviceErr
+	}
+	if inspected.ContentType != session.ContentType {
+		return functions.InspectedMedia{}, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Uploaded a %s file, expected %s", inspected.ContentType, session.ContentType)}
+	}
+	return inspected, nil
+}
+
+// moveToContentKey copies an uploaded object to its content-addressed key, unless the same file is stored there already.
+// The temporary object is removed by the caller once the session points at the new key.
+func moveToContentKey(ctx context.Context, key, contentKey string) *utils.ServiceError {
+	_, err := storage.Blob.Stat(ctx, contentKey)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to store the uploaded file"}
+	}
+	if err := storage.Blob.Copy(ctx, key, contentKey); err != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to store the uploaded file"}
+	}
+	return nil
+}
+
+// discardUpload removes what was uploaded for a session whose row is gone
+func discardUpload(session posts.UploadSession) {
+	ctx := context.Background()
+	if session.Status == posts.UploadStatusPending && session.MultipartUploadID != "" {
+		if multipart, ok := storage.Blob.(storage.MultipartUploader); ok {
+			if err := multipart.AbortMultipartUpload(ctx, session.ObjectKey, session.MultipartUploadID); err != nil {
+				log.Printf("[Uploads] Failed to abort multipart upload %s: %v", session.ObjectKey, err)
+			}
+		}
+	}
+	// Completed sessions point at content-addressed files that other posts may share
+	functions.DeleteStoredFiles([]string{session.ObjectKey})
+}
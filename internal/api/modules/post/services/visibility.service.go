@@ -0,0 +1,99 @@
+package posts
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/unarya/univia/internal/api/functions"
+	PermissionServices "github.com/unarya/univia/internal/api/modules/permission/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChangePostVisibility sets who sees a post. The owner and users whose role may update any post may do it,
+// and only posts of team members can be shown to the team.
+func ChangePostVisibility(actor *Users.User, postID uuid.UUID, visibility string) (map[string]interface{}, *utils.ServiceError) {
+	var (
+		post       posts.Post
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		var lookupErr *utils.ServiceError
+		if post, lookupErr = lockPost(tx, postID); lookupErr != nil {
+			serviceErr = lookupErr
+			return errors.New(lookupErr.Message)
+		}
+		if post.DeletedAt != nil {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Post not found"}
+			return errors.New(serviceErr.Message)
+		}
+		if authErr := PermissionServices.AuthorizeResource(tx, actor.ID, actor.RoleID, PermissionServices.ResourcePost, PermissionServices.ActionUpdate, postID); authErr != nil {
+			serviceErr = authErr
+			return errors.New(authErr.Message)
+		}
+		if serviceErr = functions.ValidatePostVisibility(tx, post.UserID, visibility); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		if serviceErr = functions.UpdatePostVisibility(tx, postID, visibility); serviceErr != nil {
+			return errors.New(serviceErr.Message)
+		}
+		return nil
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update post visibility"}
+	}
+
+	invalidatePostDetails(postID)
+	invalidatePostAudience(post.UserID)
+	return map[string]interface{}{
+		"id":         postID,
+		"visibility": visibility,
+	}, nil
+}
+
+// PostListVersion is the version cached post lists are stored under
+func PostListVersion() int64 {
+	if redis.Redis == nil {
+		return 0
+	}
+	version, _ := redis.Redis.Client().Get(redis.Ctx, redis.PostListVersionKey).Int64()
+	return version
+}
+
+// invalidatePostAudience drops what is cached for the people who may have seen the author's posts: the
+// timelines the posts were fanned out to, rebuilt when next read, and every cached post list
+func invalidatePostAudience(authorID uuid.UUID) {
+	if redis.Redis == nil {
+		return
+	}
+	client := redis.Redis.Client()
+	if err := client.Incr(redis.Ctx, redis.PostListVersionKey).Err(); err != nil {
+		log.Printf("Failed to invalidate post lists: %v", err)
+	}
+
+	recipients, fanned, serviceErr := functions.SelectFeedRecipients(authorID, FeedFanoutMaxFollowers)
+	if serviceErr != nil {
+		log.Printf("Failed to invalidate the timelines of %s's audience: %s", authorID, serviceErr.Message)
+		return
+	}
+	if !fanned {
+		// The posts of popular authors are not in timelines, they are read with the feed
+		return
+	}
+	keys := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		keys = append(keys, redis.TimelineCacheKey(recipient))
+	}
+	if err := client.Del(redis.Ctx, keys...).Err(); err != nil {
+		log.Printf("Failed to invalidate the timelines of %s's audience: %v", authorID, err)
+	}
+}
@@ -0,0 +1,282 @@
+package profiles
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	profiles "github.com/unarya/univia/internal/api/modules/profile/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// Follow godoc
+// @Summary Follow a user
+// @Description Follows a public profile right away; for a private profile a follow request is sent instead
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FollowRequest true "User ID Required"
+// @Success 200 {object} map[string]interface{} "Successfully followed user"
+// @Failure 400 {object} types.StatusBadRequest "Already following"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows [post]
+func Follow(c *gin.Context) {
+	var request types.FollowRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UserID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "user_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	state, err := profiles.FollowUser(currentUser.ID, request.UserID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to follow user", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully followed user", state)
+}
+
+// Unfollow godoc
+// @Summary Unfollow a user
+// @Description Stops following the user, or withdraws a pending follow request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FollowRequest true "User ID Required"
+// @Success 200 {object} map[string]interface{} "Successfully unfollowed user"
+// @Failure 400 {object} types.StatusBadRequest "Not following"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/undo [post]
+func Unfollow(c *gin.Context) {
+	var request types.FollowRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	state, err := profiles.UnfollowUser(currentUser.ID, request.UserID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to unfollow user", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Successfully unfollowed user", state)
+}
+
+// ListFollowers godoc
+// @Summary List followers
+// @Description Paginates the followers of a user (the current user when user_id is omitted), flagging mutual follows
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListFollowsRequest true "User and pagination"
+// @Success 200 {object} map[string]interface{} "List followers successfully"
+// @Failure 403 {object} types.StatusForbidden "Private account"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/followers [post]
+func ListFollowers(c *gin.Context) {
+	listFollows(c, profiles.ListFollowers, "List followers successfully")
+}
+
+// ListFollowings godoc
+// @Summary List followings
+// @Description Paginates the users a user follows (the current user when user_id is omitted), flagging mutual follows
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListFollowsRequest true "User and pagination"
+// @Success 200 {object} map[string]interface{} "List followings successfully"
+// @Failure 403 {object} types.StatusForbidden "Private account"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/followings [post]
+func ListFollowings(c *gin.Context) {
+	listFollows(c, profiles.ListFollowings, "List followings successfully")
+}
+
+func listFollows(
+	c *gin.Context,
+	list func(viewerID, userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError),
+	message string,
+) {
+	var request types.ListFollowsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	userID := request.UserID
+	if userID == uuid.Nil {
+		userID = currentUser.ID
+	}
+	response, err := list(currentUser.ID, userID, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list follows", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, message, response)
+}
+
+// ListFollowRequests godoc
+// @Summary List pending follow requests
+// @Description Paginates the requests to follow the current user, oldest first
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListFollowRequestsRequest true "Pagination"
+// @Success 200 {object} map[string]interface{} "List follow requests successfully"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/requests [post]
+func ListFollowRequests(c *gin.Context) {
+	var request types.ListFollowRequestsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := profiles.ListFollowRequests(currentUser.ID, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list follow requests", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List follow requests successfully", response)
+}
+
+// AcceptFollowRequest godoc
+// @Summary Accept a follow request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FollowRequest true "Requester ID"
+// @Success 200 {object} map[string]interface{} "Follow request accepted"
+// @Failure 404 {object} map[string]interface{} "Follow request not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/requests/accept [post]
+func AcceptFollowRequest(c *gin.Context) {
+	var request types.FollowRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	state, err := profiles.AcceptFollowRequest(currentUser.ID, request.UserID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to accept follow request", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Follow request accepted", state)
+}
+
+// RejectFollowRequest godoc
+// @Summary Reject a follow request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FollowRequest true "Requester ID"
+// @Success 200 {object} map[string]interface{} "Follow request rejected"
+// @Failure 404 {object} map[string]interface{} "Follow request not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/requests/reject [post]
+func RejectFollowRequest(c *gin.Context) {
+	var request types.FollowRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := profiles.RejectFollowRequest(currentUser.ID, request.UserID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to reject follow request", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Follow request rejected", nil)
+}
+
+// UpdatePrivacy godoc
+// @Summary Make the profile private or public
+// @Description A private profile approves its followers; switching back to public accepts every pending request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.UpdatePrivacyRequest true "Privacy"
+// @Success 200 {object} map[string]interface{} "Privacy updated successfully"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/follows/privacy [put]
+func UpdatePrivacy(c *gin.Context) {
+	var request types.UpdatePrivacyRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	accepted, err := profiles.SetProfilePrivacy(currentUser.ID, request.IsPrivate)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to update privacy", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Privacy updated successfully", gin.H{
+		"is_private":        request.IsPrivate,
+		"accepted_requests": accepted,
+	})
+}
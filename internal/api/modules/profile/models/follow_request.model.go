@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+
+	"github.com/google/uuid"
+)
+
+// FollowRequest is a pending follow of a private profile; accepting it moves the pair into follows
+type FollowRequest struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	RequesterID uuid.UUID  `gorm:"type:uuid;not null"`
+	TargetID    uuid.UUID  `gorm:"type:uuid;not null"`
+	Requester   Users.User `gorm:"foreignKey:RequesterID;references:ID"`
+	Target      Users.User `gorm:"foreignKey:TargetID;references:ID"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+}
@@ -22,6 +22,7 @@ type Profile struct {
 	Bio             string         `gorm:"type:text;default:null"`
 	Interests       datatypes.JSON `gorm:"type:json;default:null"`
 	SocialLinks     datatypes.JSON `gorm:"type:json;default:null"`
+	IsPrivate       bool           `gorm:"default:false"` // follows need the owner's approval
 
 	// Many-to-Many Relationship (Followers & Followings)
 	Followers  []Users.User `gorm:"many2many:follows;joinForeignKey:FollowerID;joinReferences:FollowingID"`
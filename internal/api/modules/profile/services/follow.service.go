@@ -0,0 +1,360 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/notification/services"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	Profiles "github.com/unarya/univia/internal/api/modules/profile/models"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Relationship states returned by the follow endpoints
+const (
+	FollowStatusNone      = "none"
+	FollowStatusRequested = "requested"
+	FollowStatusFollowing = "following"
+)
+
+// FollowUser follows a public profile right away, or files a follow request for a private one
+func FollowUser(followerID, targetID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	if followerID == targetID {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You cannot follow yourself"}
+	}
+
+	var (
+		status     string
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the profile serializes this with a concurrent privacy change
+		profile, lookupErr := functions.GetProfileByUserID(tx, targetID, true)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		following, checkErr := functions.CheckIsFollowing(tx, followerID, targetID)
+		if checkErr != nil {
+			serviceErr = checkErr
+			return checkErr
+		}
+		if following {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You are already following this user"}
+			return serviceErr
+		}
+
+		if profile.IsPrivate {
+			status = FollowStatusRequested
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Profiles.FollowRequest{
+				ID:          uuid.New(),
+				RequesterID: followerID,
+				TargetID:    targetID,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have already requested to follow this user"}
+				return serviceErr
+			}
+			return notifyFollow(tx, followerID, targetID, "requested to follow you", "follow_request", outbox.EventFollowRequested)
+		}
+
+		status = FollowStatusFollowing
+		if err := insertFollow(tx, followerID, targetID); err != nil {
+			return err
+		}
+		return notifyFollow(tx, followerID, targetID, "started following you", "follow", outbox.EventUserFollowed)
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to follow the user"}
+	}
+
+	invalidateUserInfo(followerID, targetID)
+	if status == FollowStatusFollowing {
+		functions.InvalidateTimelines(followerID)
+	}
+	return followState(targetID, status)
+}
+
+// UnfollowUser stops following the user, or withdraws a pending follow request
+func UnfollowUser(followerID, targetID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	var removed bool
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM follows WHERE follower_id = ? AND following_id = ?", followerID, targetID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			removed = true
+			return nil
+		}
+		result = tx.Where("requester_id = ? AND target_id = ?", followerID, targetID).Delete(&Profiles.FollowRequest{})
+		removed = result.RowsAffected > 0
+		return result.Error
+	}); err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to unfollow the user"}
+	}
+	if !removed {
+		return nil, &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You are not following this user"}
+	}
+
+	invalidateUserInfo(followerID, targetID)
+	functions.InvalidateTimelines(followerID)
+	return followState(targetID, FollowStatusNone)
+}
+
+// ListFollowers lists who follows userID; private profiles only show them to the owner and followers
+func ListFollowers(viewerID, userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	return listFollows(viewerID, userID, currentPage, itemsPerPage, functions.SelectFollowers)
+}
+
+// ListFollowings lists who userID follows, with the same visibility as ListFollowers
+func ListFollowings(viewerID, userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	return listFollows(viewerID, userID, currentPage, itemsPerPage, functions.SelectFollowings)
+}
+
+func listFollows(
+	viewerID, userID uuid.UUID,
+	currentPage, itemsPerPage int,
+	selectRows func(userID, viewerID uuid.UUID, offset, limit int) ([]functions.FollowRow, *utils.ServiceError),
+) (map[string]interface{}, *utils.ServiceError) {
+	if serviceErr := checkCanViewFollows(viewerID, userID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	currentPage, itemsPerPage = normalizePage(currentPage, itemsPerPage)
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := selectRows(userID, viewerID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		items = append(items, map[string]interface{}{
+			"user":         map[string]interface{}{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"followed_at":  row.FollowedAt,
+			"is_following": row.IsFollowing,
+			"follows_you":  row.FollowsYou,
+			"is_mutual":    row.IsFollowing && row.FollowsYou,
+		})
+	}
+	return paginated(items, total, currentPage, itemsPerPage)
+}
+
+// ListFollowRequests lists the pending requests to follow the user, oldest first
+func ListFollowRequests(userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	currentPage, itemsPerPage = normalizePage(currentPage, itemsPerPage)
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectFollowRequests(userID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		items = append(items, map[string]interface{}{
+			"user":         map[string]interface{}{"id": row.RequesterID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"requested_at": row.CreatedAt,
+		})
+	}
+	return paginated(items, total, currentPage, itemsPerPage)
+}
+
+// AcceptFollowRequest turns the requester's pending request into a follow
+func AcceptFollowRequest(userID, requesterID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("requester_id = ? AND target_id = ?", requesterID, userID).Delete(&Profiles.FollowRequest{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Follow request not found"}
+			return serviceErr
+		}
+		if err := insertFollow(tx, requesterID, userID); err != nil {
+			return err
+		}
+		return notifyFollow(tx, userID, requesterID, "accepted your follow request", "follow", outbox.EventFollowRequestAccepted)
+	}); err != nil {
+		if serviceErr != nil {
+			return nil, serviceErr
+		}
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to accept the follow request"}
+	}
+
+	invalidateUserInfo(userID, requesterID)
+	functions.InvalidateTimelines(requesterID)
+	return followState(userID, FollowStatusFollowing)
+}
+
+// RejectFollowRequest drops the requester's pending request without telling them
+func RejectFollowRequest(userID, requesterID uuid.UUID) *utils.ServiceError {
+	result := mysql.DB.Where("requester_id = ? AND target_id = ?", requesterID, userID).Delete(&Profiles.FollowRequest{})
+	if result.Error != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to reject the follow request"}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Follow request not found"}
+	}
+	return nil
+}
+
+// SetProfilePrivacy switches the profile between public and private; going public accepts every pending request
+func SetProfilePrivacy(userID uuid.UUID, isPrivate bool) (int64, *utils.ServiceError) {
+	var (
+		accepted   int64
+		requesters []uuid.UUID
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		profile, lookupErr := functions.GetProfileByUserID(tx, userID, true)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if err := tx.Model(profile).Update("is_private", isPrivate).Error; err != nil {
+			return err
+		}
+		if isPrivate {
+			return nil
+		}
+
+		if err := tx.Model(&Profiles.FollowRequest{}).Where("target_id = ?", userID).Pluck("requester_id", &requesters).Error; err != nil {
+			return err
+		}
+		result := tx.Exec(`
+			INSERT IGNORE INTO follows (follower_id, following_id, created_at)
+			SELECT requester_id, target_id, NOW() FROM follow_requests WHERE target_id = ?
+		`, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		accepted = result.RowsAffected
+		return tx.Where("target_id = ?", userID).Delete(&Profiles.FollowRequest{}).Error
+	}); err != nil {
+		if serviceErr != nil {
+			return 0, serviceErr
+		}
+		return 0, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to update privacy"}
+	}
+
+	invalidateUserInfo(userID)
+	functions.InvalidateTimelines(requesters...)
+	return accepted, nil
+}
+
+func checkCanViewFollows(viewerID, userID uuid.UUID) *utils.ServiceError {
+	if viewerID == userID {
+		return nil
+	}
+	profile, serviceErr := functions.GetProfileByUserID(mysql.DB, userID, false)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !profile.IsPrivate {
+		return nil
+	}
+	following, serviceErr := functions.CheckIsFollowing(mysql.DB, viewerID, userID)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !following {
+		return &utils.ServiceError{StatusCode: http.StatusForbidden, Message: "This account is private"}
+	}
+	return nil
+}
+
+func insertFollow(tx *gorm.DB, followerID, followingID uuid.UUID) error {
+	return tx.Table("follows").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(map[string]interface{}{
+			"follower_id":  followerID,
+			"following_id": followingID,
+			"created_at":   time.Now(),
+		}).Error
+}
+
+func notifyFollow(tx *gorm.DB, senderID, receiverID uuid.UUID, action, notiType, eventType string) error {
+	var username string
+	if err := tx.Model(&Users.User{}).Select("username").Where("id = ?", senderID).Scan(&username).Error; err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s %s", username, action)
+	if notiErr := notifications.NotificationHandler(tx, senderID, receiverID, message, notiType, eventType); notiErr != nil {
+		log.Printf("Failed to send notification: %v", notiErr.Message)
+		return errors.New(notiErr.Message)
+	}
+	return nil
+}
+
+// followState reports the relationship and the target's fresh follower count
+func followState(targetID uuid.UUID, status string) (map[string]interface{}, *utils.ServiceError) {
+	followers, followings, err := functions.CountFollows(mysql.DB, targetID)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to count follows"}
+	}
+	return map[string]interface{}{
+		"user_id":          targetID,
+		"status":           status,
+		"followers_count":  followers,
+		"followings_count": followings,
+	}, nil
+}
+
+// invalidateUserInfo drops the cached user-info of the users so their counts refresh
+func invalidateUserInfo(userIDs ...uuid.UUID) {
+	if redis.Redis == nil {
+		return
+	}
+	var emails []string
+	if err := mysql.DB.Model(&Users.User{}).Where("id IN ?", userIDs).Pluck("email", &emails).Error; err != nil {
+		log.Printf("Failed to load emails for cache invalidation: %v", err)
+		return
+	}
+	for _, email := range emails {
+		_ = redis.Redis.Delete(fmt.Sprintf("userInfo:%s", email))
+	}
+}
+
+func normalizePage(currentPage, itemsPerPage int) (int, int) {
+	if itemsPerPage <= 0 {
+		itemsPerPage = 10
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	return currentPage, itemsPerPage
+}
+
+func paginated(items []map[string]interface{}, total int64, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
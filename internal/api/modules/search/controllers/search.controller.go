@@ -0,0 +1,84 @@
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unarya/univia/internal/api/functions"
+	search "github.com/unarya/univia/internal/api/modules/search/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// Search godoc
+// @Summary Search
+// @Description Full-text search across posts, usernames, bios and categories, ranked by relevance.
+// @Description Matched words are wrapped in <mark></mark> in title_highlight and body_highlight.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.SearchRequest true "Query, kinds and page"
+// @Success 200 {object} map[string]interface{} "Search successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/search [post]
+func Search(c *gin.Context) {
+	var request types.SearchRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := search.Search(request.Query, request.Kinds, request.CurrentPage, request.ItemsPerPage, currentUser.ID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to search", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Search successfully", response)
+}
+
+// Suggest godoc
+// @Summary Autocomplete
+// @Description Completes the last word of q with matching usernames and categories, or the given kinds
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param q query string true "Text typed so far"
+// @Param kinds query string false "Comma separated kinds: post, user, category"
+// @Success 200 {object} map[string]interface{} "Suggest successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/search/suggest [get]
+func Suggest(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("q"))
+	if prefix == "" {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "q is required", nil)
+		return
+	}
+	var kinds []string
+	if raw := c.Query("kinds"); raw != "" {
+		kinds = strings.Split(raw, ",")
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	hits, err := search.Suggest(prefix, kinds, currentUser.ID)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to suggest", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Suggest successfully", gin.H{"items": hits})
+}
@@ -0,0 +1,210 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/infrastructure/kafka"
+	"github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	kafkaGo "github.com/segmentio/kafka-go"
+)
+
+const (
+	// IndexerConsumerGroup is shared by the API replicas, so each change is indexed once
+	IndexerConsumerGroup = "api-search-indexer"
+	indexerRetryInterval = 2 * time.Second
+	// BackfillBatchSize is how many documents are read at a time to fill an empty index
+	BackfillBatchSize = 500
+	// SuggestLimit is how many completions autocomplete returns
+	SuggestLimit = 8
+)
+
+// Search ranks the posts, users and categories matching text by relevance, a page at a time. Posts the viewer
+// does not see are left out of the page and the total.
+func Search(text string, kinds []string, currentPage, itemsPerPage int, viewerID uuid.UUID) (map[string]interface{}, *utils.ServiceError) {
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	query := search.Query{Text: text, Kinds: kinds, Limit: itemsPerPage}.Normalize()
+	query.Offset = (currentPage - 1) * query.Limit
+
+	results, serviceErr := runQuery(query)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	hidden := len(results.Hits)
+	if results.Hits, serviceErr = visibleHits(results.Hits, viewerID); serviceErr != nil {
+		return nil, serviceErr
+	}
+	hidden -= len(results.Hits)
+	results.Total = max(results.Total-hidden, len(results.Hits))
+
+	var paginationResult map[string]interface{}
+	if len(results.Hits) > 0 {
+		paginated, err := utils.Paginate(int64(results.Total), currentPage, query.Limit)
+		if err != nil {
+			return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+		}
+		paginationResult = paginated
+	}
+	return map[string]interface{}{
+		"items":      results.Hits,
+		"pagination": paginationResult,
+	}, nil
+}
+
+func runQuery(query search.Query) (search.Results, *utils.ServiceError) {
+	results, err := search.Engine.Search(context.Background(), query)
+	if err != nil {
+		return search.Results{}, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return results, nil
+}
+
+// Suggest completes the last word of prefix, with usernames and categories unless kinds says otherwise
+func Suggest(prefix string, kinds []string, viewerID uuid.UUID) ([]search.Hit, *utils.ServiceError) {
+	if len(kinds) == 0 {
+		kinds = []string{search.KindUser, search.KindCategory}
+	}
+	results, serviceErr := runQuery(search.Query{Text: prefix, Kinds: kinds, Prefix: true, Limit: SuggestLimit})
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	return visibleHits(results.Hits, viewerID)
+}
+
+// visibleHits drops the posts the viewer does not see. The index holds every published post, who sees one
+// depends on the viewer and changes without the post changing.
+func visibleHits(hits []search.Hit, viewerID uuid.UUID) ([]search.Hit, *utils.ServiceError) {
+	var postIDs []uuid.UUID
+	for _, hit := range hits {
+		if hit.Kind == search.KindPost {
+			postIDs = append(postIDs, hit.ID)
+		}
+	}
+	if len(postIDs) == 0 {
+		return hits, nil
+	}
+	visible, serviceErr := functions.SelectVisiblePostIDs(viewerID, postIDs)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	kept := hits[:0]
+	for _, hit := range hits {
+		if hit.Kind != search.KindPost || visible[hit.ID] {
+			kept = append(kept, hit)
+		}
+	}
+	return kept, nil
+}
+
+// StartIndexer applies the changes published on the search topic to the index until ctx is cancelled.
+// A message is committed once the document was reloaded and written; until then it is retried.
+func StartIndexer(ctx context.Context) {
+	// The embedded index lives in memory, so it starts empty
+	if _, embedded := search.Engine.(*search.MemoryIndex); embedded {
+		if err := backfill(ctx); err != nil {
+			log.Printf("[Search] Backfill failed: %v", err)
+		}
+	}
+
+	reader := kafka.NewConsumer(kafka.SearchTopic, IndexerConsumerGroup)
+	defer reader.Close()
+	log.Printf("[Search] Consuming %s as %s", kafka.SearchTopic, IndexerConsumerGroup)
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[Search] Fetch failed: %v", err)
+			if !sleepContext(ctx, indexerRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		for {
+			err := applyChange(ctx, msg)
+			if err == nil {
+				break
+			}
+			log.Printf("[Search] Offset %d not indexed, retrying: %v", msg.Offset, err)
+			if !sleepContext(ctx, indexerRetryInterval) {
+				return
+			}
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			log.Printf("[Search] Commit of offset %d failed: %v", msg.Offset, err)
+		}
+	}
+}
+
+// applyChange reindexes the document named by msg, or removes it when it is gone or hidden.
+// It returns an error only when the change must be retried.
+func applyChange(ctx context.Context, msg kafkaGo.Message) error {
+	var change functions.SearchChange
+	if err := json.Unmarshal(msg.Value, &change); err != nil || change.ID == uuid.Nil {
+		log.Printf("[Search] Skipping undecodable message at offset %d", msg.Offset)
+		return nil
+	}
+
+	doc, serviceErr := functions.LoadSearchDocument(change.Kind, change.ID)
+	if serviceErr != nil {
+		if serviceErr.StatusCode == http.StatusBadRequest {
+			log.Printf("[Search] Skipping message at offset %d: %s", msg.Offset, serviceErr.Message)
+			return nil
+		}
+		return serviceErr
+	}
+	if doc == nil {
+		return search.Engine.Delete(ctx, change.Kind, change.ID)
+	}
+	return search.Engine.Put(ctx, *doc)
+}
+
+// backfill indexes every searchable document
+func backfill(ctx context.Context) error {
+	count := 0
+	for _, kind := range []string{search.KindCategory, search.KindUser, search.KindPost} {
+		after := uuid.Nil
+		for {
+			docs, serviceErr := functions.SelectSearchDocuments(kind, after, BackfillBatchSize)
+			if serviceErr != nil {
+				return serviceErr
+			}
+			for _, doc := range docs {
+				if err := search.Engine.Put(ctx, doc); err != nil {
+					return err
+				}
+			}
+			count += len(docs)
+			if len(docs) < BackfillBatchSize {
+				break
+			}
+			after = docs[len(docs)-1].ID
+		}
+	}
+	log.Printf("[Search] Indexed %d documents", count)
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
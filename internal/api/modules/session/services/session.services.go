@@ -1,6 +1,7 @@
 package sessions
 
 import (
+	"errors"
 	"log"
 	"time"
 
@@ -8,17 +9,82 @@ import (
 	"github.com/unarya/univia/internal/api/modules/session/model"
 	"github.com/unarya/univia/internal/api/modules/user/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
 	"gorm.io/gorm"
 )
 
 func RevokeSession(db *gorm.DB, sessionID string) error {
 	now := time.Now()
-	return db.Model(&sessions.UserSession{}).
+	if err := db.Model(&sessions.UserSession{}).
 		Where("session_id = ?", sessionID).
 		Updates(map[string]interface{}{
 			"status":     "revoked",
 			"revoked_at": now,
-		}).Error
+		}).Error; err != nil {
+		return err
+	}
+
+	// Drop the handshake cache so new signaling connections are refused right away
+	if id, err := uuid.Parse(sessionID); err == nil && redis.Redis != nil {
+		_ = redis.Redis.Delete(redis.SessionCacheKey(id))
+	}
+	return nil
+}
+
+// IsSessionActive reports whether the session exists and has not been revoked
+func IsSessionActive(sessionID uuid.UUID) bool {
+	active, _ := CheckSessionActive(sessionID)
+	return active
+}
+
+// CheckSessionActive is IsSessionActive for callers that must tell a revoked session from a failed lookup,
+// which is returned as the error
+func CheckSessionActive(sessionID uuid.UUID) (bool, error) {
+	var session sessions.UserSession
+	err := mysql.DB.Select("status", "revoked_at").
+		Where("session_id = ?", sessionID).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session.Status != "revoked" && session.RevokedAt == nil, nil
+}
+
+// GetSessionIDByUserID returns the id of the user's session, a user has at most one
+func GetSessionIDByUserID(userID uuid.UUID) (uuid.UUID, error) {
+	var session sessions.UserSession
+	if err := mysql.DB.Select("session_id").Where("user_id = ?", userID).Take(&session).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return session.SessionID, nil
+}
+
+// TouchLastActive records when the session was last seen active
+func TouchLastActive(sessionID uuid.UUID, at time.Time) error {
+	return mysql.DB.Model(&sessions.UserSession{}).
+		Where("session_id = ?", sessionID).
+		Update("last_active", at).Error
+}
+
+// GetLastActiveByUserIDs returns the last activity of each user's session; users without one are left out
+func GetLastActiveByUserIDs(userIDs []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	lastActive := make(map[uuid.UUID]time.Time, len(userIDs))
+	if len(userIDs) == 0 {
+		return lastActive, nil
+	}
+	var rows []sessions.UserSession
+	if err := mysql.DB.Select("user_id", "last_active").
+		Where("user_id IN ? AND last_active IS NOT NULL", userIDs).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		lastActive[row.UserID] = *row.LastActive
+	}
+	return lastActive, nil
 }
 
 func CheckValidDevice(email string, sessionID uuid.UUID) bool {
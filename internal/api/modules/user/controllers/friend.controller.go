@@ -0,0 +1,242 @@
+package users
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unarya/univia/internal/api/functions"
+	usersService "github.com/unarya/univia/internal/api/modules/user/services"
+	"github.com/unarya/univia/pkg/types"
+	"github.com/unarya/univia/pkg/utils"
+)
+
+// SendFriendRequest godoc
+// @Summary Send a friend request
+// @Description Sends a friend request. If the other user already sent one, it is accepted instead.
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.SendFriendRequestRequest true "User ID and optional note"
+// @Success 200 {object} map[string]interface{} "Friend request sent"
+// @Failure 400 {object} types.StatusBadRequest "Already friends or already requested"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 409 {object} map[string]interface{} "Crossing request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/requests/send [post]
+func SendFriendRequest(c *gin.Context) {
+	var request types.SendFriendRequestRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+	if request.UserID == uuid.Nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "user_id is required", nil)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	status, err := usersService.SendFriendRequest(currentUser.ID, request.UserID, request.Description)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to send friend request", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "Friend request sent", gin.H{"user_id": request.UserID, "status": status})
+}
+
+// CancelFriendRequest godoc
+// @Summary Cancel a sent friend request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FriendUserRequest true "User the request was sent to"
+// @Success 200 {object} map[string]interface{} "Friend request cancelled"
+// @Failure 404 {object} map[string]interface{} "Friend request not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/requests/cancel [post]
+func CancelFriendRequest(c *gin.Context) {
+	handleFriendAction(c, usersService.CancelFriendRequest, "Failed to cancel friend request", "Friend request cancelled")
+}
+
+// AcceptFriendRequest godoc
+// @Summary Accept a friend request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FriendUserRequest true "Requester ID"
+// @Success 200 {object} map[string]interface{} "Friend request accepted"
+// @Failure 404 {object} map[string]interface{} "Friend request not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/requests/accept [post]
+func AcceptFriendRequest(c *gin.Context) {
+	handleFriendAction(c, usersService.AcceptFriendRequest, "Failed to accept friend request", "Friend request accepted")
+}
+
+// DeclineFriendRequest godoc
+// @Summary Decline a friend request
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FriendUserRequest true "Requester ID"
+// @Success 200 {object} map[string]interface{} "Friend request declined"
+// @Failure 404 {object} map[string]interface{} "Friend request not found"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/requests/decline [post]
+func DeclineFriendRequest(c *gin.Context) {
+	handleFriendAction(c, usersService.DeclineFriendRequest, "Failed to decline friend request", "Friend request declined")
+}
+
+// Unfriend godoc
+// @Summary Remove a friend
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.FriendUserRequest true "Friend ID"
+// @Success 200 {object} map[string]interface{} "Successfully unfriended"
+// @Failure 404 {object} map[string]interface{} "Not friends"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends [delete]
+func Unfriend(c *gin.Context) {
+	handleFriendAction(c, usersService.Unfriend, "Failed to unfriend", "Successfully unfriended")
+}
+
+func handleFriendAction(c *gin.Context, action func(userID, otherID uuid.UUID) *utils.ServiceError, failure, success string) {
+	var request types.FriendUserRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	if err := action(currentUser.ID, request.UserID); err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, failure, err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, success, gin.H{"user_id": request.UserID})
+}
+
+// ListFriends godoc
+// @Summary List friends
+// @Description Paginates the current user's friends, most recently accepted first
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListFriendsRequest true "Pagination"
+// @Success 200 {object} map[string]interface{} "List friends successfully"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends [post]
+func ListFriends(c *gin.Context) {
+	var request types.ListFriendsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	response, err := usersService.ListFriends(currentUser.ID, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list friends", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List friends successfully", response)
+}
+
+// ListFriendRequests godoc
+// @Summary List pending friend requests
+// @Description Paginates the requests sent to the current user (incoming, the default) or sent by them (outgoing)
+// @Tags Social Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param request body types.ListFriendRequestsRequest true "Direction and pagination"
+// @Success 200 {object} map[string]interface{} "List friend requests successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/requests [post]
+func ListFriendRequests(c *gin.Context) {
+	var request types.ListFriendRequestsRequest
+	if bindErr := utils.BindJson(c, &request); bindErr != nil {
+		utils.SendErrorResponse(c, http.StatusBadRequest, "Invalid Input", bindErr)
+		return
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	incoming := request.Direction != "outgoing"
+	response, err := usersService.ListFriendRequests(currentUser.ID, incoming, request.CurrentPage, request.ItemsPerPage)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list friend requests", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List friend requests successfully", response)
+}
+
+// SuggestFriends godoc
+// @Summary Friend suggestions
+// @Description Friends of the current user's friends, ranked by mutual friend count
+// @Tags Social Routes
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer <access_token>"
+// @Param limit query int false "Number of suggestions (default 10, max 50)"
+// @Success 200 {object} map[string]interface{} "List friend suggestions successfully"
+// @Failure 400 {object} types.StatusBadRequest "Bad Request"
+// @Failure 500 {object} types.StatusInternalError "Internal orchestrator error"
+// @Router /api/v1/friends/suggestions [get]
+func SuggestFriends(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			utils.SendErrorResponse(c, http.StatusBadRequest, "limit must be a number", convErr)
+			return
+		}
+		limit = parsed
+	}
+
+	currentUser, getUserErr := functions.GetCurrentUser(c)
+	if getUserErr != nil {
+		utils.SendErrorResponse(c, getUserErr.StatusCode, getUserErr.Message, nil)
+		return
+	}
+
+	suggestions, err := usersService.SuggestFriends(currentUser.ID, limit)
+	if err != nil {
+		utils.SendErrorResponse(c, err.StatusCode, "Failed to list friend suggestions", err)
+		return
+	}
+	utils.SendSuccessResponse(c, http.StatusOK, "List friend suggestions successfully", suggestions)
+}
@@ -0,0 +1,52 @@
+package users
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation kinds
+const (
+	ConversationDirect = "direct"
+	ConversationGroup  = "group"
+)
+
+// Participant roles, from most to least privileged
+const (
+	ConversationRoleOwner  = "owner"
+	ConversationRoleAdmin  = "admin"
+	ConversationRoleMember = "member"
+)
+
+// Conversation groups the messages exchanged by its participants
+type Conversation struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Kind          string     `gorm:"type:varchar(16);not null;default:direct"`
+	DirectKey     *string    `gorm:"type:varchar(73);unique"` // both user ids in order, set for direct conversations only
+	Title         string     `gorm:"type:varchar(100);default:null"`
+	CreatedBy     *uuid.UUID `gorm:"type:uuid"`
+	LastSeq       int64      `gorm:"not null;default:0"`
+	LastMessageAt *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// ConversationParticipant is a member of a conversation and how far they have read
+type ConversationParticipant struct {
+	ConversationID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Role           string    `gorm:"type:varchar(16);not null;default:member"`
+	LastReadSeq    int64     `gorm:"not null;default:0"`
+	JoinedAt       time.Time `gorm:"autoCreateTime"`
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// ConversationPin marks a message pinned in its conversation
+type ConversationPin struct {
+	ConversationID uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	MessageID      uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	PinnedBy       *uuid.UUID `gorm:"type:uuid"`
+	PinnedAt       time.Time  `gorm:"autoCreateTime"`
+}
@@ -4,12 +4,21 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 )
 
 type Message struct {
-	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	SenderID   uuid.UUID `gorm:"type:uuid;not null"`
-	ReceiverID uuid.UUID `gorm:"type:uuid;not null"`
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	SenderID   uuid.UUID  `gorm:"type:uuid;not null"`
+	ReceiverID *uuid.UUID `gorm:"type:uuid"` // nil for group messages
+
+	// Conversation and position in it; Seq increases by one per message
+	ConversationID uuid.UUID      `gorm:"type:uuid"`
+	Seq            int64          `gorm:"not null;default:0"`
+	Body           string         `gorm:"type:text;default:null"`
+	Attachments    datatypes.JSON `gorm:"type:json;default:null"`
+	EditedAt       *time.Time
+	DeletedAt      *time.Time // soft delete; body and attachments are cleared
 
 	// References
 	Sender   User `gorm:"foreignKey:SenderID"`
@@ -0,0 +1,278 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/unarya/univia/internal/api/functions"
+	"github.com/unarya/univia/internal/api/modules/notification/services"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/pkg/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Friendship states between the current user and another user
+const (
+	FriendStatusOutgoing = "request_sent"
+	FriendStatusFriends  = "friends"
+)
+
+const (
+	MaxFriendRequestNoteLength = 500
+	DefaultSuggestionLimit     = 10
+	MaxSuggestionLimit         = 50
+)
+
+// SendFriendRequest asks targetID to be friends. When targetID already asked the user, the
+// crossing request accepts theirs instead of creating a second row.
+func SendFriendRequest(userID, targetID uuid.UUID, description string) (string, *utils.ServiceError) {
+	if userID == targetID {
+		return "", &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You cannot send a friend request to yourself"}
+	}
+	description = strings.TrimSpace(description)
+	if len([]rune(description)) > MaxFriendRequestNoteLength {
+		return "", &utils.ServiceError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("The note must be at most %d characters", MaxFriendRequestNoteLength),
+		}
+	}
+
+	var (
+		status     string
+		serviceErr *utils.ServiceError
+	)
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if serviceErr = functions.CheckUserExists(tx, targetID); serviceErr != nil {
+			return serviceErr
+		}
+		existing, lookupErr := functions.LockFriendship(tx, userID, targetID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+
+		switch {
+		case existing == nil:
+			status = FriendStatusOutgoing
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Users.Friend{
+				ID:          uuid.New(),
+				UserID:      userID,
+				FriendTo:    targetID,
+				RequestedOn: time.Now(),
+				Description: description,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// uq_friends_pair: the other user's request landed first
+				serviceErr = &utils.ServiceError{StatusCode: http.StatusConflict, Message: "A friend request between you already exists"}
+				return serviceErr
+			}
+			return notifyFriend(tx, userID, targetID, "sent you a friend request", "friend_request", outbox.EventFriendRequestSent)
+		case existing.Status:
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You are already friends"}
+			return serviceErr
+		case existing.UserID == userID:
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusBadRequest, Message: "You have already sent a friend request"}
+			return serviceErr
+		default:
+			status = FriendStatusFriends
+			if err := acceptFriendship(tx, existing.ID); err != nil {
+				return err
+			}
+			return notifyFriend(tx, userID, targetID, "accepted your friend request", "friend_request", outbox.EventFriendRequestAccepted)
+		}
+	}); err != nil {
+		if serviceErr != nil {
+			return "", serviceErr
+		}
+		return "", &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to send the friend request"}
+	}
+	if status == FriendStatusFriends {
+		functions.InvalidateTimelines(userID, targetID)
+	}
+	return status, nil
+}
+
+// CancelFriendRequest withdraws a pending request the user sent
+func CancelFriendRequest(userID, targetID uuid.UUID) *utils.ServiceError {
+	return deletePendingRequest(userID, targetID, "Failed to cancel the friend request")
+}
+
+// DeclineFriendRequest drops a pending request sent to the user, without telling the requester
+func DeclineFriendRequest(userID, requesterID uuid.UUID) *utils.ServiceError {
+	return deletePendingRequest(requesterID, userID, "Failed to decline the friend request")
+}
+
+// AcceptFriendRequest accepts the pending request requesterID sent to the user
+func AcceptFriendRequest(userID, requesterID uuid.UUID) *utils.ServiceError {
+	var serviceErr *utils.ServiceError
+	if err := mysql.DB.Transaction(func(tx *gorm.DB) error {
+		existing, lookupErr := functions.LockFriendship(tx, userID, requesterID)
+		if lookupErr != nil {
+			serviceErr = lookupErr
+			return lookupErr
+		}
+		if existing == nil || existing.Status || existing.UserID != requesterID {
+			serviceErr = &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Friend request not found"}
+			return serviceErr
+		}
+		if err := acceptFriendship(tx, existing.ID); err != nil {
+			return err
+		}
+		return notifyFriend(tx, userID, requesterID, "accepted your friend request", "friend_request", outbox.EventFriendRequestAccepted)
+	}); err != nil {
+		if serviceErr != nil {
+			return serviceErr
+		}
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to accept the friend request"}
+	}
+	functions.InvalidateTimelines(userID, requesterID)
+	return nil
+}
+
+// Unfriend removes an accepted friendship, whichever side asked originally
+func Unfriend(userID, friendID uuid.UUID) *utils.ServiceError {
+	result := mysql.DB.
+		Where("((user_id = ? AND friend_to = ?) OR (user_id = ? AND friend_to = ?)) AND status = TRUE", userID, friendID, friendID, userID).
+		Delete(&Users.Friend{})
+	if result.Error != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: "Failed to unfriend"}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "You are not friends"}
+	}
+	functions.InvalidateTimelines(userID, friendID)
+	return nil
+}
+
+// ListFriends lists the user's friends, most recently accepted first
+func ListFriends(userID uuid.UUID, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	currentPage, itemsPerPage = normalizeFriendPage(currentPage, itemsPerPage)
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectFriends(userID, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		items = append(items, map[string]interface{}{
+			"user":          map[string]interface{}{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"friends_since": row.AcceptedOn,
+		})
+	}
+	return paginateFriends(items, total, currentPage, itemsPerPage)
+}
+
+// ListFriendRequests lists the pending requests sent to the user when incoming, or sent by the user otherwise
+func ListFriendRequests(userID uuid.UUID, incoming bool, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	currentPage, itemsPerPage = normalizeFriendPage(currentPage, itemsPerPage)
+	offsetData := utils.CalculateOffset(currentPage, itemsPerPage, "", "")
+
+	rows, serviceErr := functions.SelectFriendRequests(userID, incoming, offsetData.Offset, itemsPerPage)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	var total int64
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		total = row.TotalCount
+		items = append(items, map[string]interface{}{
+			"user":         map[string]interface{}{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"requested_on": row.RequestedOn,
+			"description":  row.Description,
+		})
+	}
+	return paginateFriends(items, total, currentPage, itemsPerPage)
+}
+
+// SuggestFriends returns friends of the user's friends, most mutual friends first
+func SuggestFriends(userID uuid.UUID, limit int) ([]map[string]interface{}, *utils.ServiceError) {
+	if limit <= 0 {
+		limit = DefaultSuggestionLimit
+	}
+	if limit > MaxSuggestionLimit {
+		limit = MaxSuggestionLimit
+	}
+
+	rows, serviceErr := functions.SelectFriendSuggestions(userID, limit)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+	suggestions := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		suggestions = append(suggestions, map[string]interface{}{
+			"user":                 map[string]interface{}{"id": row.UserID, "name": row.Username, "profile_pic": row.ProfilePic},
+			"mutual_friends_count": row.MutualCount,
+		})
+	}
+	return suggestions, nil
+}
+
+func deletePendingRequest(requesterID, targetID uuid.UUID, failure string) *utils.ServiceError {
+	result := mysql.DB.
+		Where("user_id = ? AND friend_to = ? AND status = FALSE", requesterID, targetID).
+		Delete(&Users.Friend{})
+	if result.Error != nil {
+		return &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: failure}
+	}
+	if result.RowsAffected == 0 {
+		return &utils.ServiceError{StatusCode: http.StatusNotFound, Message: "Friend request not found"}
+	}
+	return nil
+}
+
+func acceptFriendship(tx *gorm.DB, friendshipID uuid.UUID) error {
+	return tx.Model(&Users.Friend{}).
+		Where("id = ?", friendshipID).
+		Updates(map[string]interface{}{"status": true, "accepted_on": time.Now()}).Error
+}
+
+func notifyFriend(tx *gorm.DB, senderID, receiverID uuid.UUID, action, notiType, eventType string) error {
+	var username string
+	if err := tx.Model(&Users.User{}).Select("username").Where("id = ?", senderID).Scan(&username).Error; err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s %s", username, action)
+	if notiErr := notifications.NotificationHandler(tx, senderID, receiverID, message, notiType, eventType); notiErr != nil {
+		log.Printf("Failed to send notification: %v", notiErr.Message)
+		return errors.New(notiErr.Message)
+	}
+	return nil
+}
+
+func normalizeFriendPage(currentPage, itemsPerPage int) (int, int) {
+	if itemsPerPage <= 0 {
+		itemsPerPage = 10
+	}
+	if currentPage <= 0 {
+		currentPage = 1
+	}
+	return currentPage, itemsPerPage
+}
+
+func paginateFriends(items []map[string]interface{}, total int64, currentPage, itemsPerPage int) (map[string]interface{}, *utils.ServiceError) {
+	pagination, err := utils.Paginate(total, currentPage, itemsPerPage)
+	if err != nil {
+		return nil, &utils.ServiceError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"items":      items,
+		"pagination": pagination,
+	}, nil
+}
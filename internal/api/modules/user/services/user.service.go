@@ -12,15 +12,19 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/unarya/univia/internal/api/functions"
 	AccessTokens "github.com/unarya/univia/internal/api/modules/key_token/access_token/models"
+	access_token "github.com/unarya/univia/internal/api/modules/key_token/access_token/services"
 	RefreshTokens "github.com/unarya/univia/internal/api/modules/key_token/refresh_token/models"
 	refresh_token "github.com/unarya/univia/internal/api/modules/key_token/refresh_token/services"
+	outbox "github.com/unarya/univia/internal/api/modules/outbox/services"
 	Profiles "github.com/unarya/univia/internal/api/modules/profile/models"
 	roles "github.com/unarya/univia/internal/api/modules/role/services"
 	sessions "github.com/unarya/univia/internal/api/modules/session/model"
 	"github.com/unarya/univia/internal/api/modules/session/queries"
 	Users "github.com/unarya/univia/internal/api/modules/user/models"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/search"
 	"github.com/unarya/univia/pkg/types"
 	"github.com/unarya/univia/pkg/utils"
 
@@ -47,6 +51,13 @@ func GetUserInfo(userID uuid.UUID) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("no results found")
 	}
 
+	followers, followings, err := functions.CountFollows(db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count follows: %v", err)
+	}
+	results[0]["followers_count"] = followers
+	results[0]["followings_count"] = followings
+
 	return results[0], nil
 }
 
@@ -82,20 +93,27 @@ func RegisterUser(user Users.User) (map[string]interface{}, error) {
 		Password: user.Password,
 		RoleID:   userRoleID,
 	}
-	if err := db.Create(&newUser).Error; err != nil {
-		return nil, fmt.Errorf("failed to create user: %v", err)
-	}
-
 	// Step 5: Create a default profile for the new user
 	defaultProfile := Profiles.Profile{
-		UserID:     newUser.ID,
 		ProfilePic: "/default-avatar.png",
 		Birthday:   nil, // Default birthday (not set)
 	}
 
-	// Step 6: Save the profile to the mysql
-	if err := db.Create(&defaultProfile).Error; err != nil {
-		return nil, fmt.Errorf("failed to create profile: %v", err)
+	// Step 6: Save the user and the profile to the mysql, and make the user searchable
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return fmt.Errorf("failed to create user: %v", err)
+		}
+		defaultProfile.UserID = newUser.ID
+		if err := tx.Create(&defaultProfile).Error; err != nil {
+			return fmt.Errorf("failed to create profile: %v", err)
+		}
+		if serviceErr := functions.QueueSearchChange(tx, search.KindUser, newUser.ID, outbox.EventUserRegistered); serviceErr != nil {
+			return serviceErr
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	// Step 7: Format the response to exclude sensitive data
@@ -239,6 +257,9 @@ func LoginGoogle(c *gin.Context, googleToken string) (types.ResponseSession, err
 			if err := db.Create(&newProfile).Error; err != nil {
 				return types.ResponseSession{}, fmt.Errorf("failed to create profile: %v", err)
 			}
+			if serviceErr := functions.QueueSearchChange(db, search.KindUser, newUser.ID, outbox.EventUserRegistered); serviceErr != nil {
+				return types.ResponseSession{}, serviceErr
+			}
 			existingUser = newUser // Assign the newly created user to `existingUser`
 		} else {
 			return types.ResponseSession{}, fmt.Errorf("failed to query user: %v", err)
@@ -322,6 +343,12 @@ func LoginGoogle(c *gin.Context, googleToken string) (types.ResponseSession, err
 
 	// Set cookies HttpOnly
 	sID, err := utils.SetSessionToRedisByUserID(c, db, existingUser)
+	if err != nil {
+		return types.ResponseSession{}, fmt.Errorf("failed to cache session: %v", err)
+	}
+	if err := access_token.BindSession(accessToken, sID); err != nil {
+		return types.ResponseSession{}, fmt.Errorf("failed to bind access token: %v", err)
+	}
 	utils.SetHttpOnlyCookieForSession(c, sID)
 	utils.SetHttpOnlyCookieForUser(c, existingUser.ID.String())
 
@@ -371,6 +398,9 @@ func LoginBySessionID(sessionID string, user Users.User, meta types.SessionMetad
 	if err != nil {
 		return types.ResponseSession{}, http.StatusInternalServerError, fmt.Errorf("failed to generate tokens: %v", err)
 	}
+	if err := access_token.BindSession(accessToken, session.SessionID); err != nil {
+		return types.ResponseSession{}, http.StatusInternalServerError, fmt.Errorf("failed to bind access token: %v", err)
+	}
 
 	// Store session in Redis for signaling handshake
 	if err := utils.SetSessionToRedis(db, session, user, meta); err != nil {
@@ -395,6 +425,11 @@ func DeleteAllTokensByUserID(userID uuid.UUID) error {
 	}
 
 	// Delete all AccessTokens for the given UserID
+	tokens, err := access_token.TokensByUserID(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to find access tokens: %v", err)
+	}
 	if err := tx.Where("user_id = ?", userID).Delete(&AccessTokens.AccessToken{}).Error; err != nil {
 		tx.Rollback() // Rollback the transaction if deletion fails
 		return fmt.Errorf("failed to delete access tokens: %v", err)
@@ -411,6 +446,7 @@ func DeleteAllTokensByUserID(userID uuid.UUID) error {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	access_token.ForgetTokens(tokens)
 	return nil
 }
 
@@ -429,22 +465,35 @@ func RefreshAccessToken(token, clientID string) (map[string]interface{}, error)
 	}
 
 	// Delete old access tokens for the user
+	oldTokens, err := access_token.TokensByUserID(tx, refreshToken.UserID)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to find old access tokens: %v", err)
+	}
 	if err := tx.Where("user_id = ?", refreshToken.UserID).Delete(&AccessTokens.AccessToken{}).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to delete old access tokens: %v", err)
 	}
 
 	// Generate a new access token
-	token, err := GenerateAccessToken()
+	token, err = GenerateAccessToken()
 	if err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to generate new access token: %v", err)
 	}
 
+	// The new token belongs to the session the refresh token was issued for
+	var session sessions.UserSession
+	if err := tx.Select("session_id").Where("refresh_token_id = ?", refreshToken.ID).Take(&session).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to find the session of the refresh token: %v", err)
+	}
+
 	newAccessToken := AccessTokens.AccessToken{
-		Token:  token,
-		UserID: refreshToken.UserID,
-		Status: true,
+		Token:     token,
+		UserID:    refreshToken.UserID,
+		SessionID: &session.SessionID,
+		Status:    true,
 	}
 
 	if err := tx.Create(&newAccessToken).Error; err != nil {
@@ -457,6 +506,7 @@ func RefreshAccessToken(token, clientID string) (map[string]interface{}, error)
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
+	access_token.ForgetTokens(oldTokens)
 
 	// Return the new access token
 	response := map[string]interface{}{
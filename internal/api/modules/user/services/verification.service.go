@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	access_token "github.com/unarya/univia/internal/api/modules/key_token/access_token/services"
 	RefreshTokens "github.com/unarya/univia/internal/api/modules/key_token/refresh_token/models"
 	refreshTokenService "github.com/unarya/univia/internal/api/modules/key_token/refresh_token/services"
 	"github.com/unarya/univia/internal/api/modules/session/queries"
@@ -139,6 +140,12 @@ func VerifyCodeAndGenerateTokens(c *gin.Context, code users.VerificationCode, me
 		return types.ResponseSession{}, http.StatusInternalServerError, fmt.Errorf("failed to insert new session: %v", err)
 	}
 	sID, err := utils.SetSessionToRedisByUserID(c, db, user)
+	if err != nil {
+		return types.ResponseSession{}, http.StatusInternalServerError, fmt.Errorf("failed to cache session: %v", err)
+	}
+	if err := access_token.BindSession(accessToken, sID); err != nil {
+		return types.ResponseSession{}, http.StatusInternalServerError, fmt.Errorf("failed to bind access token: %v", err)
+	}
 
 	// Delete verification record
 	db.Delete(&verification)
@@ -5,10 +5,13 @@ import (
 
 	"github.com/unarya/univia/api/swagger"
 	"github.com/unarya/univia/internal/api/middlewares"
+	MessageControllers "github.com/unarya/univia/internal/api/modules/message/controllers"
 	NotificationControllers "github.com/unarya/univia/internal/api/modules/notification/controllers"
 	PermissionController "github.com/unarya/univia/internal/api/modules/permission/controllers"
 	PostControllers "github.com/unarya/univia/internal/api/modules/post/controllers"
+	ProfileControllers "github.com/unarya/univia/internal/api/modules/profile/controllers"
 	RoleControllers "github.com/unarya/univia/internal/api/modules/role/controllers"
+	SearchControllers "github.com/unarya/univia/internal/api/modules/search/controllers"
 	UserControllers "github.com/unarya/univia/internal/api/modules/user/controllers"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/pkg/utils"
@@ -25,6 +28,7 @@ func RegisterRoutes(router *gin.Engine) {
 	// Middlewares
 	authMiddleware := middlewares.AuthMiddleware
 	authzMiddleware := middlewares.Authorization
+	conversationAuthz := middlewares.ConversationAuthorization
 	needPermission := utils.Permissions
 
 	api.GET("/hello", HelloHandler)
@@ -55,11 +59,43 @@ func RegisterRoutes(router *gin.Engine) {
 	// Post Group APIs
 	postsRoutes := api.Group("/posts")
 	{
-		postsRoutes.GET("categories", authMiddleware(), PostControllers.ListCategories) // 11
-		postsRoutes.POST("", authMiddleware(), PostControllers.ListAllPost)             // 12
-		postsRoutes.POST("create", authMiddleware(), PostControllers.CreatePost)        // 13
-		postsRoutes.GET("", authMiddleware(), PostControllers.GetDetailsPost)           // 14
-		postsRoutes.PUT("", authMiddleware(), PostControllers.UpdatePost)               // 15
+		postsRoutes.GET("categories", authMiddleware(), PostControllers.ListCategories)       // 11
+		postsRoutes.POST("", authMiddleware(), PostControllers.ListAllPost)                   // 12
+		postsRoutes.POST("create", authMiddleware(), PostControllers.CreatePost)              // 13
+		postsRoutes.GET("", authMiddleware(), PostControllers.GetDetailsPost)                 // 14
+		postsRoutes.PUT("", authMiddleware(), PostControllers.UpdatePost)                     // 15
+		postsRoutes.DELETE("", authMiddleware(), PostControllers.DeletePost)                  // 71
+		postsRoutes.POST("restore", authMiddleware(), PostControllers.RestorePost)            // 72
+		postsRoutes.POST("feed", authMiddleware(), PostControllers.HomeFeed)                  // 78
+		postsRoutes.POST("drafts", authMiddleware(), PostControllers.ListDrafts)              // 83
+		postsRoutes.PUT("status", authMiddleware(), PostControllers.UpdatePostStatus)         // 84
+		postsRoutes.PUT("visibility", authMiddleware(), PostControllers.UpdatePostVisibility) // 85
+	}
+
+	// Search Group APIs
+	searchRoutes := api.Group("/search")
+	{
+		searchRoutes.POST("", authMiddleware(), SearchControllers.Search)        // 79
+		searchRoutes.GET("suggest", authMiddleware(), SearchControllers.Suggest) // 80
+	}
+
+	// Tags Group APIs
+	tagsRoutes := api.Group("/tags")
+	{
+		tagsRoutes.POST("posts", authMiddleware(), PostControllers.ListTagPosts)   // 81
+		tagsRoutes.GET("trending", authMiddleware(), PostControllers.TrendingTags) // 82
+	}
+
+	// Media APIs, the presigned signature in the query authorizes the request
+	api.GET("/media/*key", PostControllers.ServeMedia)   // 73
+	api.PUT("/media/*key", PostControllers.ReceiveMedia) // 74
+
+	// Uploads Group APIs, large files go straight to storage and are attached to posts by upload id
+	uploadsRoutes := api.Group("/uploads")
+	{
+		uploadsRoutes.POST("", authMiddleware(), PostControllers.CreateUploadSession)           // 75
+		uploadsRoutes.POST("complete", authMiddleware(), PostControllers.CompleteUploadSession) // 76
+		uploadsRoutes.DELETE("", authMiddleware(), PostControllers.AbortUploadSession)          // 77
 	}
 
 	// Role Group APIs
@@ -83,6 +119,81 @@ func RegisterRoutes(router *gin.Engine) {
 		likesRoutes.POST("/undo", authMiddleware(), PostControllers.DisLike) // 22
 	}
 
+	// Friends Group APIs
+	friendsRoutes := api.Group("/friends")
+	{
+		friendsRoutes.POST("", authMiddleware(), UserControllers.ListFriends)                          // 45
+		friendsRoutes.DELETE("", authMiddleware(), UserControllers.Unfriend)                           // 46
+		friendsRoutes.GET("suggestions", authMiddleware(), UserControllers.SuggestFriends)             // 47
+		friendsRoutes.POST("requests", authMiddleware(), UserControllers.ListFriendRequests)           // 48
+		friendsRoutes.POST("requests/send", authMiddleware(), UserControllers.SendFriendRequest)       // 49
+		friendsRoutes.POST("requests/cancel", authMiddleware(), UserControllers.CancelFriendRequest)   // 50
+		friendsRoutes.POST("requests/accept", authMiddleware(), UserControllers.AcceptFriendRequest)   // 51
+		friendsRoutes.POST("requests/decline", authMiddleware(), UserControllers.DeclineFriendRequest) // 52
+	}
+
+	// Follows Group APIs
+	followsRoutes := api.Group("/follows")
+	{
+		followsRoutes.POST("", authMiddleware(), ProfileControllers.Follow)                             // 37
+		followsRoutes.POST("undo", authMiddleware(), ProfileControllers.Unfollow)                       // 38
+		followsRoutes.POST("followers", authMiddleware(), ProfileControllers.ListFollowers)             // 39
+		followsRoutes.POST("followings", authMiddleware(), ProfileControllers.ListFollowings)           // 40
+		followsRoutes.POST("requests", authMiddleware(), ProfileControllers.ListFollowRequests)         // 41
+		followsRoutes.POST("requests/accept", authMiddleware(), ProfileControllers.AcceptFollowRequest) // 42
+		followsRoutes.POST("requests/reject", authMiddleware(), ProfileControllers.RejectFollowRequest) // 43
+		followsRoutes.PUT("privacy", authMiddleware(), ProfileControllers.UpdatePrivacy)                // 44
+	}
+
+	// Shares Group APIs
+	sharesRoutes := api.Group("/shares")
+	{
+		sharesRoutes.POST("", authMiddleware(), PostControllers.Share)                   // 33
+		sharesRoutes.POST("undo", authMiddleware(), PostControllers.Unshare)             // 34
+		sharesRoutes.POST("quote", authMiddleware(), PostControllers.Quote)              // 35
+		sharesRoutes.POST("feed", authMiddleware(), PostControllers.ListFollowingShares) // 36
+	}
+
+	// Comments Group APIs
+	commentsRoutes := api.Group("/comments")
+	{
+		commentsRoutes.POST("create", authMiddleware(), PostControllers.CreateComment)     // 26
+		commentsRoutes.POST("", authMiddleware(), PostControllers.ListComments)            // 27
+		commentsRoutes.GET("", authMiddleware(), PostControllers.GetCommentThread)         // 28
+		commentsRoutes.PUT("", authMiddleware(), PostControllers.UpdateComment)            // 29
+		commentsRoutes.DELETE("", authMiddleware(), PostControllers.DeleteComment)         // 30
+		commentsRoutes.POST("likes", authMiddleware(), PostControllers.LikeComment)        // 31
+		commentsRoutes.POST("likes/undo", authMiddleware(), PostControllers.UnlikeComment) // 32
+	}
+
+	// Messages Group APIs
+	messagesRoutes := api.Group("/messages")
+	{
+		messagesRoutes.POST("send", authMiddleware(), MessageControllers.SendMessage)                // 53
+		messagesRoutes.POST("conversations", authMiddleware(), MessageControllers.ListConversations) // 54
+		messagesRoutes.POST("history", authMiddleware(), MessageControllers.GetMessageHistory)       // 55
+		messagesRoutes.PUT("", authMiddleware(), MessageControllers.EditMessage)                     // 56
+		messagesRoutes.DELETE("", authMiddleware(), MessageControllers.DeleteMessage)                // 57
+		messagesRoutes.POST("read", authMiddleware(), MessageControllers.MarkConversationRead)       // 58
+		messagesRoutes.GET("unread", authMiddleware(), MessageControllers.CountUnread)               // 59
+	}
+
+	// Conversations Group APIs
+	conversationsRoutes := api.Group("/conversations")
+	{
+		conversationsRoutes.POST("", authMiddleware(), MessageControllers.CreateConversation)                                                                                          // 60
+		conversationsRoutes.GET(":id", authMiddleware(), conversationAuthz(needPermission["ALLOW_VIEW_CONVERSATION"]), MessageControllers.GetConversation)                             // 61
+		conversationsRoutes.POST(":id/messages", authMiddleware(), conversationAuthz(needPermission["ALLOW_SEND_CONVERSATION_MESSAGE"]), MessageControllers.SendConversationMessage)   // 62
+		conversationsRoutes.POST(":id/members", authMiddleware(), conversationAuthz(needPermission["ALLOW_INVITE_CONVERSATION_MEMBER"]), MessageControllers.InviteConversationMembers) // 63
+		conversationsRoutes.DELETE(":id/members", authMiddleware(), conversationAuthz(needPermission["ALLOW_KICK_CONVERSATION_MEMBER"]), MessageControllers.KickConversationMember)    // 64
+		conversationsRoutes.PUT(":id/members/role", authMiddleware(), conversationAuthz(needPermission["ALLOW_ASSIGN_CONVERSATION_ROLE"]), MessageControllers.AssignConversationRole)  // 65
+		conversationsRoutes.POST(":id/leave", authMiddleware(), conversationAuthz(needPermission["ALLOW_VIEW_CONVERSATION"]), MessageControllers.LeaveConversation)                    // 66
+		conversationsRoutes.PUT(":id", authMiddleware(), conversationAuthz(needPermission["ALLOW_RENAME_CONVERSATION"]), MessageControllers.RenameConversation)                        // 67
+		conversationsRoutes.POST(":id/pins", authMiddleware(), conversationAuthz(needPermission["ALLOW_PIN_CONVERSATION_MESSAGE"]), MessageControllers.PinMessage)                     // 68
+		conversationsRoutes.DELETE(":id/pins", authMiddleware(), conversationAuthz(needPermission["ALLOW_PIN_CONVERSATION_MESSAGE"]), MessageControllers.UnpinMessage)                 // 69
+		conversationsRoutes.GET(":id/pins", authMiddleware(), conversationAuthz(needPermission["ALLOW_VIEW_CONVERSATION"]), MessageControllers.ListPinnedMessages)                     // 70
+	}
+
 	// Notifications Group APIs
 	notificationsRoutes := api.Group("/notifications")
 	{
@@ -0,0 +1,56 @@
+package antivirus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Scanner checks uploaded files for malware before they are stored for good
+type Scanner interface {
+	// Scan reads r to the end. It returns an *InfectedError naming the threat when the content is malicious,
+	// or another error when the file could not be scanned.
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+// InfectedError is returned by Scan for malicious content
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("malware detected: %s", e.Signature)
+}
+
+// Engine is the scanner selected by MALWARE_SCANNER
+var Engine Scanner
+
+// ConnectScanner sets up Engine. MALWARE_SCANNER is "local" (the default), a stand-in that only
+// knows a few signatures such as the EICAR test file, or "none" to accept every file.
+func ConnectScanner() Scanner {
+	driver := os.Getenv("MALWARE_SCANNER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	switch driver {
+	case "local":
+		Engine = NewSignatureScanner()
+	case "none":
+		Engine = NoopScanner{}
+	default:
+		log.Fatalf("Unknown MALWARE_SCANNER %q", driver)
+	}
+	fmt.Println("Malware scanner ready:", driver)
+	return Engine
+}
+
+// NoopScanner accepts every file
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
@@ -0,0 +1,54 @@
+package antivirus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// eicar is the industry standard test file every scanner must flag
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// SignatureScanner is a local stand-in for a real engine: it looks for known byte signatures anywhere in the file
+type SignatureScanner struct {
+	signatures map[string][]byte
+	longest    int
+}
+
+func NewSignatureScanner() *SignatureScanner {
+	s := &SignatureScanner{signatures: map[string][]byte{
+		"EICAR-Test-File": []byte(eicar),
+	}}
+	for _, signature := range s.signatures {
+		s.longest = max(s.longest, len(signature))
+	}
+	return s
+}
+
+func (s *SignatureScanner) Scan(ctx context.Context, r io.Reader) error {
+	buf := make([]byte, 64<<10)
+	// Keep the tail of the previous chunk so signatures split across reads are still found
+	var carry []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			window := append(carry, buf[:n]...)
+			for name, signature := range s.signatures {
+				if bytes.Contains(window, signature) {
+					return &InfectedError{Signature: name}
+				}
+			}
+			carry = append(carry[:0], window[max(0, len(window)-s.longest+1):]...)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
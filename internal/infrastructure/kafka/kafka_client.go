@@ -5,15 +5,23 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+const (
+	Broker             = "kafka:9092"
+	NotificationsTopic = "notifications"
+	// SearchTopic carries the changes of posts and users that the search index follows
+	SearchTopic = "search"
+)
+
 var KafkaWriter *kafka.Writer
 
 func InitKafkaProducer() {
-	topic := "notifications"
-	broker := "kafka:9092"
+	topic := NotificationsTopic
+	broker := Broker
 
 	// Tạo Kafka writer
 	KafkaWriter = &kafka.Writer{
@@ -44,6 +52,10 @@ func InitKafkaProducer() {
 		Topic:             topic,
 		NumPartitions:     1,
 		ReplicationFactor: 1,
+	}, kafka.TopicConfig{
+		Topic:             SearchTopic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
 	})
 	if err != nil {
 		log.Println("⚠️  Topic may already exist:", err)
@@ -64,3 +76,27 @@ func InitKafkaProducer() {
 
 	fmt.Println("✅ Kafka producer connected and test message sent successfully!")
 }
+
+// NewWriter returns a producer that takes the topic from each message and waits for all in-sync replicas
+func NewWriter() *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(Broker),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+// NewConsumer returns a consumer group reader. Offsets are only committed through CommitMessages,
+// so a message is redelivered if the process stops before handling it.
+func NewConsumer(topic, groupID string) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        []string{Broker},
+		GroupID:        groupID,
+		Topic:          topic,
+		MinBytes:       1,
+		MaxBytes:       10e6,
+		CommitInterval: 0,
+		StartOffset:    kafka.FirstOffset,
+	})
+}
@@ -20,14 +20,6 @@ func ConnectMinio() *minio.Client {
 	if endpoint == "" {
 		endpoint = "minio:9000"
 	}
-	accessKeyID := os.Getenv("MINIO_ACCESS_KEY")
-	if accessKeyID == "" {
-		accessKeyID = "admin"
-	}
-	secretAccessKey := os.Getenv("MINIO_SECRET_KEY")
-	if secretAccessKey == "" {
-		secretAccessKey = "password"
-	}
 	bucketName := os.Getenv("MINIO_BUCKET_NAME")
 	if bucketName == "" {
 		bucketName = "local"
@@ -35,7 +27,7 @@ func ConnectMinio() *minio.Client {
 	useSSL := false
 
 	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Creds:  Credentials(),
 		Secure: useSSL,
 	})
 	if err != nil {
@@ -46,3 +38,16 @@ func ConnectMinio() *minio.Client {
 	BucketName = bucketName
 	return MinioClient
 }
+
+// Credentials returns the MinIO access key pair from the environment
+func Credentials() *credentials.Credentials {
+	accessKeyID := os.Getenv("MINIO_ACCESS_KEY")
+	if accessKeyID == "" {
+		accessKeyID = "admin"
+	}
+	secretAccessKey := os.Getenv("MINIO_SECRET_KEY")
+	if secretAccessKey == "" {
+		secretAccessKey = "password"
+	}
+	return credentials.NewStaticV4(accessKeyID, secretAccessKey, "")
+}
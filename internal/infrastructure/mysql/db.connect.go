@@ -145,7 +145,7 @@ func seedCategories(db *gorm.DB) error {
 
 // seedRoles creates system and team roles, returns a map of role names to role objects
 func seedRoles(db *gorm.DB) (map[string]Roles.Role, error) {
-	systemRoles := []string{"super_admin", "general_admin", "billing_manager", "support"}
+	systemRoles := []string{"super_admin", "general_admin", "moderator", "billing_manager", "support"}
 	teamRoles := []string{"team_owner", "team_admin", "team_editor", "team_viewer", "team_guest"}
 	allRoles := append(systemRoles, teamRoles...)
 
@@ -212,6 +212,7 @@ func assignRolePermissions(db *gorm.DB, roles map[string]Roles.Role, permissions
 	permissionSets := map[string][]string{
 		"super_admin":     getAllPermissionNames(permissions),
 		"general_admin":   getGeneralAdminPermissions(),
+		"moderator":       getModeratorPermissions(),
 		"billing_manager": getBillingManagerPermissions(),
 		"support":         getSupportPermissions(),
 		"team_owner":      getTeamOwnerPermissions(),
@@ -411,6 +412,17 @@ func getGeneralAdminPermissions() []string {
 	return perms
 }
 
+// getModeratorPermissions returns permissions for content moderators
+// Edits and removes other users' posts and comments
+func getModeratorPermissions() []string {
+	return []string{
+		utils.Permissions["ALLOW_GET_USER"],
+		utils.Permissions["ALLOW_LIST_POSTS"],
+		utils.Permissions["ALLOW_MODERATE_POST"],
+		utils.Permissions["ALLOW_MODERATE_COMMENT"],
+	}
+}
+
 // getBillingManagerPermissions returns permissions for billing manager
 func getBillingManagerPermissions() []string {
 	return []string{
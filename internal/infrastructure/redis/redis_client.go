@@ -77,6 +77,11 @@ func NewRedisCache(client *redis.Client) *RedisCache {
 	}
 }
 
+// Client exposes the underlying client for pub/sub and commands the JSON helpers do not cover
+func (rc *RedisCache) Client() *redis.Client {
+	return rc.client
+}
+
 // SetJSON marshal object to JSON and set to Redis
 func (rc *RedisCache) SetJSON(key string, value interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(value)
@@ -115,3 +120,16 @@ func UserCacheKey(userID uuid.UUID) string {
 func RolePermissionsCacheKey(roleID uint) string {
 	return fmt.Sprintf("role:%d:permissions", roleID)
 }
+
+// SessionCacheKey is the key the signaling handshake reads the session from
+func SessionCacheKey(sessionID uuid.UUID) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+// TimelineCacheKey is the sorted set of a user's home timeline, scored by activity time in milliseconds
+func TimelineCacheKey(userID uuid.UUID) string {
+	return fmt.Sprintf("timeline:%s", userID)
+}
+
+// PostListVersionKey counts the changes that invalidate every cached post list at once
+const PostListVersionKey = "listPost:version"
@@ -0,0 +1,185 @@
+package search
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// BM25 parameters of the embedded index
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type docKey struct {
+	kind string
+	id   uuid.UUID
+}
+
+// field is a tokenized title or body
+type field struct {
+	length int
+	terms  map[string]int
+}
+
+type memoryDoc struct {
+	doc   Document
+	title field
+	body  field
+}
+
+// MemoryIndex is an inverted index kept in the process and ranked with BM25. It starts empty and is not
+// shared between replicas, so it suits a single node and tests.
+type MemoryIndex struct {
+	mu   sync.RWMutex
+	docs map[docKey]*memoryDoc
+	// postings lists the documents that contain each term, in their title or body
+	postings map[string]map[docKey]struct{}
+	// titleLength and bodyLength sum the lengths of the fields, for their average
+	titleLength int
+	bodyLength  int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[docKey]*memoryDoc),
+		postings: make(map[string]map[docKey]struct{}),
+	}
+}
+
+func newField(text string) field {
+	tokens := Tokenize(text)
+	f := field{length: len(tokens), terms: make(map[string]int, len(tokens))}
+	for _, token := range tokens {
+		f.terms[token]++
+	}
+	return f
+}
+
+func (m *MemoryIndex) Put(ctx context.Context, doc Document) error {
+	key := docKey{doc.Kind, doc.ID}
+	stored := &memoryDoc{doc: doc, title: newField(doc.Title), body: newField(doc.Body)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+	m.docs[key] = stored
+	m.titleLength += stored.title.length
+	m.bodyLength += stored.body.length
+	for _, f := range []field{stored.title, stored.body} {
+		for term := range f.terms {
+			if m.postings[term] == nil {
+				m.postings[term] = make(map[docKey]struct{})
+			}
+			m.postings[term][key] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Delete(ctx context.Context, kind string, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(docKey{kind, id})
+	return nil
+}
+
+// remove drops a document from the index; the caller holds the write lock
+func (m *MemoryIndex) remove(key docKey) {
+	stored, ok := m.docs[key]
+	if !ok {
+		return
+	}
+	delete(m.docs, key)
+	m.titleLength -= stored.title.length
+	m.bodyLength -= stored.body.length
+	for _, f := range []field{stored.title, stored.body} {
+		for term := range f.terms {
+			delete(m.postings[term], key)
+			if len(m.postings[term]) == 0 {
+				delete(m.postings, term)
+			}
+		}
+	}
+}
+
+func (m *MemoryIndex) Search(ctx context.Context, query Query) (Results, error) {
+	query = query.Normalize()
+	terms := Tokenize(query.Text)
+	if len(terms) == 0 {
+		return Results{Hits: []Hit{}}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := float64(len(m.docs))
+	avgTitle := math.Max(float64(m.titleLength)/math.Max(total, 1), 1)
+	avgBody := math.Max(float64(m.bodyLength)/math.Max(total, 1), 1)
+
+	scores := make(map[docKey]float64)
+	for i, term := range terms {
+		// The last term of an autocomplete query stands for every indexed word it starts
+		expanded := []string{term}
+		if query.Prefix && i == len(terms)-1 {
+			expanded = expanded[:0]
+			for indexed := range m.postings {
+				if strings.HasPrefix(indexed, term) {
+					expanded = append(expanded, indexed)
+				}
+			}
+		}
+
+		for _, word := range expanded {
+			matching := m.postings[word]
+			idf := math.Log(1 + (total-float64(len(matching))+0.5)/(float64(len(matching))+0.5))
+			for key := range matching {
+				stored := m.docs[key]
+				if !kindSelected(key.kind, query.Kinds) {
+					continue
+				}
+				scores[key] += idf * (TitleWeight*bm25(stored.title, word, avgTitle) + bm25(stored.body, word, avgBody))
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, hit(m.docs[key].doc, score, terms, query.Prefix))
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	results := Results{Hits: []Hit{}, Total: len(hits)}
+	if query.Offset < len(hits) {
+		results.Hits = hits[query.Offset:min(query.Offset+query.Limit, len(hits))]
+	}
+	return results, nil
+}
+
+func bm25(f field, term string, avgLength float64) float64 {
+	tf := float64(f.terms[term])
+	if tf == 0 {
+		return 0
+	}
+	return tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(f.length)/avgLength))
+}
+
+func kindSelected(kind string, kinds []string) bool {
+	return len(kinds) == 0 || slices.Contains(kinds, kind)
+}
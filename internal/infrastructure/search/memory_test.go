@@ -0,0 +1,161 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func putAll(t *testing.T, index Index, docs ...Document) {
+	t.Helper()
+	for _, doc := range docs {
+		if err := index.Put(context.Background(), doc); err != nil {
+			t.Fatalf("Put(%s %s): %v", doc.Kind, doc.ID, err)
+		}
+	}
+}
+
+func TestMemoryIndexRanksTitleMatchesFirst(t *testing.T) {
+	index := NewMemoryIndex()
+	now := time.Now()
+	user := Document{Kind: KindUser, ID: uuid.New(), Title: "anime_fan", Body: "I review shows", CreatedAt: now}
+	category := Document{Kind: KindCategory, ID: uuid.New(), Title: "Anime Reviews", CreatedAt: now}
+	post := Document{Kind: KindPost, ID: uuid.New(), Body: "My anime reviews of the season, with a few more anime picks", CreatedAt: now}
+	unrelated := Document{Kind: KindPost, ID: uuid.New(), Body: "Cooking with friends", CreatedAt: now}
+	putAll(t, index, user, category, post, unrelated)
+
+	results, err := index.Search(context.Background(), Query{Text: "anime reviews"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 3 {
+		t.Fatalf("Total = %d, want 3", results.Total)
+	}
+	if results.Hits[0].ID != category.ID {
+		t.Errorf("first hit = %s %q, want the category", results.Hits[0].Kind, results.Hits[0].Title)
+	}
+	for _, hit := range results.Hits {
+		if hit.ID == unrelated.ID {
+			t.Errorf("unrelated post matched")
+		}
+	}
+
+	results, err = index.Search(context.Background(), Query{Text: "anime", Kinds: []string{KindPost}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 1 || results.Hits[0].ID != post.ID {
+		t.Fatalf("post search = %+v, want only the post", results.Hits)
+	}
+	if want := "My <mark>anime</mark> reviews of the season, with a few more <mark>anime</mark> picks"; results.Hits[0].BodyHighlight != want {
+		t.Errorf("BodyHighlight = %q, want %q", results.Hits[0].BodyHighlight, want)
+	}
+}
+
+func TestMemoryIndexPrefix(t *testing.T) {
+	index := NewMemoryIndex()
+	alice := Document{Kind: KindUser, ID: uuid.New(), Title: "alice", CreatedAt: time.Now()}
+	alicia := Document{Kind: KindUser, ID: uuid.New(), Title: "alicia", CreatedAt: time.Now()}
+	bob := Document{Kind: KindUser, ID: uuid.New(), Title: "bob", CreatedAt: time.Now()}
+	putAll(t, index, alice, alicia, bob)
+
+	results, err := index.Search(context.Background(), Query{Text: "ali", Prefix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 2 {
+		t.Fatalf("Total = %d, want 2", results.Total)
+	}
+	for _, hit := range results.Hits {
+		if hit.TitleHighlight != "<mark>"+hit.Title+"</mark>" {
+			t.Errorf("TitleHighlight = %q", hit.TitleHighlight)
+		}
+	}
+
+	// Without Prefix the term has to match a whole word
+	results, err = index.Search(context.Background(), Query{Text: "ali"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 0 {
+		t.Errorf("exact search for a prefix matched %d documents", results.Total)
+	}
+}
+
+func TestMemoryIndexUpdatesIncrementally(t *testing.T) {
+	index := NewMemoryIndex()
+	post := Document{Kind: KindPost, ID: uuid.New(), Body: "first draft", CreatedAt: time.Now()}
+	putAll(t, index, post)
+
+	post.Body = "edited text"
+	putAll(t, index, post)
+
+	count := func(text string) int {
+		results, err := index.Search(context.Background(), Query{Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results.Total
+	}
+	if n := count("draft"); n != 0 {
+		t.Errorf("old content still matches %d documents", n)
+	}
+	if n := count("edited"); n != 1 {
+		t.Errorf("new content matches %d documents, want 1", n)
+	}
+
+	if err := index.Delete(context.Background(), KindPost, post.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("edited"); n != 0 {
+		t.Errorf("deleted post still matches")
+	}
+	if len(index.postings) != 0 {
+		t.Errorf("postings left after delete: %v", index.postings)
+	}
+}
+
+func TestMemoryIndexPages(t *testing.T) {
+	index := NewMemoryIndex()
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		putAll(t, index, Document{Kind: KindPost, ID: uuid.New(), Body: "same words", CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	first, err := index.Search(context.Background(), Query{Text: "words", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := index.Search(context.Background(), Query{Text: "words", Limit: 2, Offset: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 5 || len(first.Hits) != 2 || len(rest.Hits) != 1 {
+		t.Fatalf("pages = %d and %d hits of %d", len(first.Hits), len(rest.Hits), first.Total)
+	}
+	// Equal scores rank newer documents first
+	if !first.Hits[0].CreatedAt.After(first.Hits[1].CreatedAt) {
+		t.Errorf("equal scores are not ordered newest first")
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	long := ""
+	for i := 0; i < 50; i++ {
+		long += "filler "
+	}
+	long += "needle " + long
+
+	got := Highlight(long, []string{"needle"}, false)
+	if len([]rune(got)) > SnippetLength+len("<mark></mark>")+2 {
+		t.Errorf("snippet is %d runes long", len([]rune(got)))
+	}
+	if got[:len("…")] != "…" {
+		t.Errorf("snippet %q does not mark the cut start", got)
+	}
+	if Highlight("nothing here", []string{"needle"}, false) != "" {
+		t.Errorf("text without a match is highlighted")
+	}
+}
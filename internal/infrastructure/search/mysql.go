@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// searchDocument is a row of search_documents, which carries a FULLTEXT index on title and one on body
+type searchDocument struct {
+	Kind      string    `gorm:"type:varchar(16);primaryKey"`
+	DocID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Title     string    `gorm:"type:varchar(255);default:null"`
+	Body      string    `gorm:"type:text;default:null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (searchDocument) TableName() string {
+	return "search_documents"
+}
+
+// MySQLIndex ranks documents with MySQL FULLTEXT search in boolean mode. Words shorter than
+// innodb_ft_min_token_size and stopwords are not indexed, so they never match.
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+func NewMySQLIndex(db *gorm.DB) *MySQLIndex {
+	return &MySQLIndex{db: db}
+}
+
+func (m *MySQLIndex) Put(ctx context.Context, doc Document) error {
+	row := searchDocument{
+		Kind:      doc.Kind,
+		DocID:     doc.ID,
+		Title:     doc.Title,
+		Body:      doc.Body,
+		CreatedAt: doc.CreatedAt,
+	}
+	return m.db.WithContext(ctx).Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&row).Error
+}
+
+func (m *MySQLIndex) Delete(ctx context.Context, kind string, id uuid.UUID) error {
+	return m.db.WithContext(ctx).Where("kind = ? AND doc_id = ?", kind, id).Delete(&searchDocument{}).Error
+}
+
+func (m *MySQLIndex) Search(ctx context.Context, query Query) (Results, error) {
+	query = query.Normalize()
+	terms := Tokenize(query.Text)
+	if len(terms) == 0 {
+		return Results{Hits: []Hit{}}, nil
+	}
+	against := booleanQuery(terms, query.Prefix)
+
+	var rows []struct {
+		searchDocument
+		Score      float64
+		TotalCount int
+	}
+	db := m.db.WithContext(ctx).Table("search_documents").
+		Select(`search_documents.*,
+			MATCH(title) AGAINST(@q IN BOOLEAN MODE) * @weight + MATCH(body) AGAINST(@q IN BOOLEAN MODE) AS score,
+			COUNT(*) OVER() AS total_count`,
+			map[string]interface{}{"q": against, "weight": TitleWeight}).
+		Where("(MATCH(title) AGAINST(? IN BOOLEAN MODE) OR MATCH(body) AGAINST(? IN BOOLEAN MODE))", against, against)
+	if len(query.Kinds) > 0 {
+		db = db.Where("kind IN ?", query.Kinds)
+	}
+	if err := db.Order("score DESC, created_at DESC").
+		Offset(query.Offset).
+		Limit(query.Limit).
+		Scan(&rows).Error; err != nil {
+		return Results{}, err
+	}
+
+	results := Results{Hits: make([]Hit, 0, len(rows))}
+	for _, row := range rows {
+		doc := Document{Kind: row.Kind, ID: row.DocID, Title: row.Title, Body: row.Body, CreatedAt: row.CreatedAt}
+		results.Hits = append(results.Hits, hit(doc, row.Score, terms, query.Prefix))
+		results.Total = row.TotalCount
+	}
+	return results, nil
+}
+
+// booleanQuery matches any of the terms, the last one as a prefix for autocomplete.
+// Terms hold letters and digits only, so they cannot inject boolean operators.
+func booleanQuery(terms []string, prefix bool) string {
+	words := make([]string, len(terms))
+	copy(words, terms)
+	if prefix {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
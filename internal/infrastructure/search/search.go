@@ -0,0 +1,207 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of indexed documents
+const (
+	KindPost     = "post"
+	KindUser     = "user"
+	KindCategory = "category"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+	// TitleWeight ranks a match in the title above the same match in the body
+	TitleWeight = 2.0
+	// SnippetLength is roughly how many characters of a long body a highlight keeps around the first match
+	SnippetLength = 160
+)
+
+// Document is what the index keeps of a post, user or category
+type Document struct {
+	Kind string
+	ID   uuid.UUID
+	// Title is the username or the category name; posts have none
+	Title string
+	// Body is the content of a post or the bio of a user
+	Body      string
+	CreatedAt time.Time
+}
+
+// Query searches Text in the documents of Kinds, or of every kind when Kinds is empty
+type Query struct {
+	Text  string
+	Kinds []string
+	// Prefix matches the last term as the start of a word, for autocomplete
+	Prefix bool
+	Limit  int
+	Offset int
+}
+
+// Hit is a matching document, best first. The highlights mark the matched terms with <mark></mark>.
+type Hit struct {
+	Kind           string    `json:"kind"`
+	ID             uuid.UUID `json:"id"`
+	Title          string    `json:"title,omitempty"`
+	Score          float64   `json:"score"`
+	TitleHighlight string    `json:"title_highlight,omitempty"`
+	BodyHighlight  string    `json:"body_highlight,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Results struct {
+	Hits  []Hit `json:"hits"`
+	Total int   `json:"total"`
+}
+
+// Index keeps searchable documents up to date and ranks them by relevance
+type Index interface {
+	// Put adds doc, or replaces the document of the same kind and id
+	Put(ctx context.Context, doc Document) error
+	// Delete removes a document; deleting a missing one is not an error
+	Delete(ctx context.Context, kind string, id uuid.UUID) error
+	Search(ctx context.Context, query Query) (Results, error)
+}
+
+// Engine is the index selected by SEARCH_DRIVER
+var Engine Index
+
+// ConnectSearch sets up Engine. SEARCH_DRIVER is "mysql" (the default), which ranks with FULLTEXT indexes on db,
+// or "memory" for an index embedded in the process, which suits a single node and tests.
+func ConnectSearch(db *gorm.DB) Index {
+	driver := os.Getenv("SEARCH_DRIVER")
+	if driver == "" {
+		driver = "mysql"
+	}
+
+	switch driver {
+	case "mysql":
+		Engine = NewMySQLIndex(db)
+	case "memory":
+		Engine = NewMemoryIndex()
+	default:
+		log.Fatalf("Unknown SEARCH_DRIVER %q", driver)
+	}
+	fmt.Println("Search ready:", driver)
+	return Engine
+}
+
+// Normalize bounds the page of a query and drops unknown kinds
+func (q Query) Normalize() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	q.Limit = min(q.Limit, MaxLimit)
+	q.Offset = max(q.Offset, 0)
+
+	var kinds []string
+	for _, kind := range q.Kinds {
+		if kind == KindPost || kind == KindUser || kind == KindCategory {
+			kinds = append(kinds, kind)
+		}
+	}
+	q.Kinds = kinds
+	return q
+}
+
+// Tokenize splits text into lower-cased words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight marks the words of text that match terms. The last term matches as a prefix when prefix is set.
+// Text longer than SnippetLength is cut down to a snippet around the first match.
+func Highlight(text string, terms []string, prefix bool) string {
+	if text == "" || len(terms) == 0 {
+		return ""
+	}
+	runes := []rune(text)
+
+	type span struct{ start, end int }
+	var spans []span
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if matchesTerm(strings.ToLower(string(runes[start:end])), terms, prefix) {
+			spans = append(spans, span{start, end})
+		}
+		start = end
+	}
+	if len(spans) == 0 {
+		return ""
+	}
+
+	// Keep a window of the text around the first match
+	from, to := 0, len(runes)
+	if len(runes) > SnippetLength {
+		from = max(spans[0].start-SnippetLength/4, 0)
+		to = min(from+SnippetLength, len(runes))
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	last := from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		b.WriteString(string(runes[last:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(string(runes[s.start:s.end]))
+		b.WriteString("</mark>")
+		last = s.end
+	}
+	b.WriteString(string(runes[last:to]))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func matchesTerm(word string, terms []string, prefix bool) bool {
+	for i, term := range terms {
+		if word == term || (prefix && i == len(terms)-1 && strings.HasPrefix(word, term)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// hit builds the hit of a matching document
+func hit(doc Document, score float64, terms []string, prefix bool) Hit {
+	return Hit{
+		Kind:           doc.Kind,
+		ID:             doc.ID,
+		Title:          doc.Title,
+		Score:          score,
+		TitleHighlight: Highlight(doc.Title, terms, prefix),
+		BodyHighlight:  Highlight(doc.Body, terms, prefix),
+		CreatedAt:      doc.CreatedAt,
+	}
+}
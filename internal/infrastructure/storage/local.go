@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalMediaRoute is where the API serves files of the local backend
+const LocalMediaRoute = "/api/v1/media/"
+
+var ErrInvalidSignature = errors.New("invalid or expired media signature")
+
+// LocalStorage keeps files on disk and signs its own URLs, which the API serves from LocalMediaRoute.
+// Files are not shared between replicas, so it suits a single node and development.
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStorage stores files under STORAGE_LOCAL_DIR (default "uploads").
+// STORAGE_PUBLIC_URL prefixes the presigned URLs and STORAGE_SIGNING_KEY signs them.
+func NewLocalStorage() (*LocalStorage, error) {
+	root := os.Getenv("STORAGE_LOCAL_DIR")
+	if root == "" {
+		root = "uploads"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	secret := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+	if len(secret) == 0 {
+		log.Println("[Storage] STORAGE_SIGNING_KEY is not set, media URLs stop working after a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimSuffix(os.Getenv("STORAGE_PUBLIC_URL"), "/"),
+		secret:  secret,
+	}, nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	dst, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("wrote %d of %d bytes", written, size)
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	dst, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	src, err := s.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return s.Put(ctx, dstKey, src, -1, "")
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	dst, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.presign(http.MethodGet, key, expiry)
+}
+
+func (s *LocalStorage) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.presign(http.MethodPut, key, expiry)
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	dst, err := s.resolve(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	file, err := os.Open(dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	// Files on disk carry no metadata, so the type is sniffed from the content
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: stat.Size(), ContentType: http.DetectContentType(head[:n])}, nil
+}
+
+// Verify checks a presigned URL's query for method on key and returns the file path it grants
+func (s *LocalStorage) Verify(method, key, expires, signature string) (string, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(method, key, expires))) {
+		return "", ErrInvalidSignature
+	}
+	return s.resolve(key)
+}
+
+func (s *LocalStorage) presign(method, key string, expiry time.Duration) (string, error) {
+	if _, err := s.resolve(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.sign(method, key, expires)}}
+	return s.baseURL + LocalMediaRoute + key + "?" + query.Encode(), nil
+}
+
+func (s *LocalStorage) sign(method, key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolve maps key to a path under root, rejecting keys that would escape it
+func (s *LocalStorage) resolve(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	t.Setenv("STORAGE_LOCAL_DIR", filepath.Join(t.TempDir(), "media"))
+	t.Setenv("STORAGE_SIGNING_KEY", "test")
+	t.Setenv("STORAGE_PUBLIC_URL", "http://api.test/")
+	s, err := NewLocalStorage()
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	return s
+}
+
+func TestResolveKeepsKeysUnderRoot(t *testing.T) {
+	s := newTestLocalStorage(t)
+
+	got, err := s.resolve("posts/photo.jpg")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if want := filepath.Join(s.root, "posts", "photo.jpg"); got != want {
+		t.Fatalf("resolve = %s, want %s", got, want)
+	}
+
+	for _, key := range []string{
+		"",
+		"..",
+		"../secret",
+		"posts/../../secret",
+		"posts/../photo.jpg",
+		"/etc/passwd",
+		"posts//photo.jpg",
+		"posts/./photo.jpg",
+		"posts/",
+	} {
+		if got, err := s.resolve(key); err == nil {
+			t.Errorf("resolve(%q) = %s, want an error", key, got)
+		}
+	}
+}
+
+func TestTraversalKeysCannotReachOutsideRoot(t *testing.T) {
+	s := newTestLocalStorage(t)
+	ctx := context.Background()
+	// A file next to the root that a traversing key would land on
+	outside := filepath.Join(filepath.Dir(s.root), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("writing %s: %v", outside, err)
+	}
+
+	key := "../secret"
+	if err := s.Put(ctx, key, strings.NewReader("overwritten"), 11, "text/plain"); err == nil {
+		t.Errorf("Put accepted %q", key)
+	}
+	if _, err := s.Get(ctx, key); err == nil {
+		t.Errorf("Get accepted %q", key)
+	}
+	if _, err := s.Stat(ctx, key); err == nil {
+		t.Errorf("Stat accepted %q", key)
+	}
+	if err := s.Delete(ctx, key); err == nil {
+		t.Errorf("Delete accepted %q", key)
+	}
+	if err := s.Copy(ctx, "../secret", "posts/copy"); err == nil {
+		t.Errorf("Copy accepted %q", key)
+	}
+	if _, err := s.PresignGet(ctx, key, time.Minute); err == nil {
+		t.Errorf("PresignGet accepted %q", key)
+	}
+
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "secret" {
+		t.Fatalf("the file outside the root is now %q, %v", data, err)
+	}
+}
+
+func TestVerifyRejectsTamperedKeys(t *testing.T) {
+	s := newTestLocalStorage(t)
+	signed, err := s.PresignGet(context.Background(), "posts/photo.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("presigned URL %s: %v", signed, err)
+	}
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+
+	if _, err := s.Verify(http.MethodGet, "posts/photo.jpg", expires, signature); err != nil {
+		t.Fatalf("Verify of the signed key: %v", err)
+	}
+	if _, err := s.Verify(http.MethodGet, "posts/../../secret", expires, signature); err == nil {
+		t.Fatal("Verify accepted the signature for another key")
+	}
+	if _, err := s.Verify(http.MethodPut, "posts/photo.jpg", expires, signature); err == nil {
+		t.Fatal("Verify accepted a GET signature for a PUT")
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/unarya/univia/internal/infrastructure/minio"
+)
+
+// MinioStorage keeps files in a MinIO or S3 bucket
+type MinioStorage struct {
+	client *miniogo.Client
+	// presigner signs URLs for the public endpoint; the API reaches MinIO by its internal host name
+	presigner *miniogo.Client
+	bucket    string
+}
+
+// NewMinioStorage connects to MinIO and creates the bucket if needed.
+// Set MINIO_PUBLIC_ENDPOINT when clients reach MinIO at another address than the API does.
+func NewMinioStorage() (*MinioStorage, error) {
+	client := minio.ConnectMinio()
+	region := os.Getenv("MINIO_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, minio.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, minio.BucketName, miniogo.MakeBucketOptions{Region: region}); err != nil {
+			return nil, err
+		}
+	}
+
+	presigner := client
+	if endpoint := os.Getenv("MINIO_PUBLIC_ENDPOINT"); endpoint != "" {
+		publicURL, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		// The region is fixed so presigning never has to look up the bucket location
+		presigner, err = miniogo.New(publicURL.Host, &miniogo.Options{
+			Creds:  minio.Credentials(),
+			Secure: publicURL.Scheme == "https",
+			Region: region,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MinioStorage{client: client, presigner: presigner, bucket: minio.BucketName}, nil
+}
+
+func (s *MinioStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, miniogo.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *MinioStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	// GetObject is lazy, so a missing key only shows up on Stat or the first read
+	object, err := s.client.GetObject(ctx, s.bucket, key, miniogo.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *MinioStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		miniogo.CopyDestOptions{Bucket: s.bucket, Object: dstKey},
+		miniogo.CopySrcOptions{Bucket: s.bucket, Object: srcKey},
+	)
+	if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *MinioStorage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, miniogo.RemoveObjectOptions{})
+}
+
+func (s *MinioStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	signed, err := s.presigner.PresignedGetObject(ctx, s.bucket, key, expiry, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
+
+func (s *MinioStorage) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	signed, err := s.presigner.PresignedPutObject(ctx, s.bucket, key, expiry)
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
+
+func (s *MinioStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, miniogo.StatObjectOptions{})
+	if err != nil {
+		if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ObjectInfo{}, ErrNotFound
+		}
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+func (s *MinioStorage) NewMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	core := miniogo.Core{Client: s.client}
+	return core.NewMultipartUpload(ctx, s.bucket, key, miniogo.PutObjectOptions{ContentType: contentType})
+}
+
+func (s *MinioStorage) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+	signed, err := s.presigner.Presign(ctx, http.MethodPut, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
+
+func (s *MinioStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	completed := make([]miniogo.CompletePart, len(parts))
+	for i, part := range parts {
+		completed[i] = miniogo.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+	core := miniogo.Core{Client: s.client}
+	_, err := core.CompleteMultipartUpload(ctx, s.bucket, key, uploadID, completed, miniogo.PutObjectOptions{})
+	return err
+}
+
+func (s *MinioStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	core := miniogo.Core{Client: s.client}
+	return core.AbortMultipartUpload(ctx, s.bucket, key, uploadID)
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestMinioStorage connects to the MinIO configured by MINIO_ENDPOINT, as for the API, and skips without one.
+// Objects are written under a prefix of their own and removed when the test ends.
+func newTestMinioStorage(t *testing.T) (*MinioStorage, string) {
+	t.Helper()
+	if os.Getenv("MINIO_ENDPOINT") == "" {
+		t.Skip("MINIO_ENDPOINT is not set")
+	}
+	s, err := NewMinioStorage()
+	if err != nil {
+		t.Fatalf("NewMinioStorage: %v", err)
+	}
+	prefix := "test/" + uuid.NewString() + "/"
+	t.Cleanup(func() {
+		for _, name := range []string{"photo.jpg", "copy.jpg", "presigned.jpg"} {
+			_ = s.Delete(context.Background(), prefix+name)
+		}
+	})
+	return s, prefix
+}
+
+func TestMinioPutGetStatDelete(t *testing.T) {
+	s, prefix := newTestMinioStorage(t)
+	ctx := context.Background()
+	key := prefix + "photo.jpg"
+	content := []byte("not really a jpeg")
+
+	if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(content)) || info.ContentType != "image/jpeg" {
+		t.Fatalf("Stat = %+v, want %d bytes of image/jpeg", info, len(content))
+	}
+	object, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(object)
+	object.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Get read %q, %v; want %q", got, err, content)
+	}
+
+	if err := s.Copy(ctx, key, prefix+"copy.jpg"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if info, err := s.Stat(ctx, prefix+"copy.jpg"); err != nil || info.Size != int64(len(content)) {
+		t.Fatalf("Stat of the copy = %+v, %v", info, err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+}
+
+func TestMinioPresignedURLs(t *testing.T) {
+	s, prefix := newTestMinioStorage(t)
+	ctx := context.Background()
+	key := prefix + "presigned.jpg"
+	content := "uploaded through a presigned URL"
+
+	putURL, err := s.PresignPut(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	request, err := http.NewRequest(http.MethodPut, putURL, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("PUT request: %v", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("PUT %s: %v", putURL, err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200", response.StatusCode)
+	}
+	if info, err := s.Stat(ctx, key); err != nil || info.Size != int64(len(content)) {
+		t.Fatalf("Stat of the uploaded object = %+v, %v", info, err)
+	}
+
+	getURL, err := s.PresignGet(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	response, err = http.Get(getURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", getURL, err)
+	}
+	defer response.Body.Close()
+	got, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || string(got) != content {
+		t.Fatalf("GET = %d %q, want 200 %q", response.StatusCode, got, content)
+	}
+}
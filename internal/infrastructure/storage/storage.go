@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// PresignExpiry is how long a presigned GET URL stays valid. It must outlive the
+// cached API responses that embed the URLs.
+const PresignExpiry = 15 * time.Minute
+
+// Storage keeps uploaded files under keys such as "posts/<uuid>.jpg"
+type Storage interface {
+	// Put writes size bytes from body under key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key, or returns ErrNotFound. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Copy stores a copy of the object under srcKey as dstKey, replacing any existing object
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that lets anyone holding it read key until expiry
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignPut returns a URL that lets its holder upload key with a single PUT until expiry
+	PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// Stat describes the object stored under key, or returns ErrNotFound
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+}
+
+// MultipartUploader is implemented by backends that accept a large file as separately uploaded parts
+type MultipartUploader interface {
+	NewMultipartUpload(ctx context.Context, key, contentType string) (uploadID string, err error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// CompletedPart is a part the client uploaded, identified by the ETag storage returned for it
+type CompletedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+var ErrNotFound = errors.New("object not found")
+
+// Blob is the storage backend selected by STORAGE_DRIVER
+var Blob Storage
+
+// ConnectStorage sets up Blob. STORAGE_DRIVER is "minio" (the default, S3 compatible)
+// or "local" for a single node that keeps files on disk.
+func ConnectStorage() Storage {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "minio"
+	}
+
+	var err error
+	switch driver {
+	case "minio":
+		Blob, err = NewMinioStorage()
+	case "local":
+		Blob, err = NewLocalStorage()
+	default:
+		err = fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+	if err != nil {
+		log.Fatalf("Cannot set up %s storage: %v", driver, err)
+	}
+	fmt.Println("Storage ready:", driver)
+	return Blob
+}
@@ -0,0 +1,218 @@
+package sfu
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// Peer is one participant's PeerConnection with the SFU. It carries both what the participant
+// publishes and everything it is subscribed to.
+type Peer struct {
+	UserID uuid.UUID
+	RoomID string
+	PC     *webrtc.PeerConnection
+
+	signaler Signaler
+
+	mu                sync.Mutex
+	pendingCandidates []webrtc.ICECandidateInit
+	negotiationNeeded bool
+	closed            bool
+}
+
+func newPeer(api *webrtc.API, config webrtc.Configuration, roomID string, userID uuid.UUID, signaler Signaler) (*Peer, error) {
+	pc, err := api.NewPeerConnection(config)
+	if err != nil {
+		return nil, err
+	}
+	peer := &Peer{
+		UserID:   userID,
+		RoomID:   roomID,
+		PC:       pc,
+		signaler: signaler,
+	}
+
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		if err := peer.send(types.MessageTypeIceCandidate, candidate.ToJSON()); err != nil {
+			log.Printf("[SFU] Failed to send ICE candidate to %s: %v", userID, err)
+		}
+	})
+	return peer, nil
+}
+
+// HandleOffer answers an offer from the participant. The SFU is the polite peer and rolls back its own offer on glare.
+func (p *Peer) HandleOffer(offer webrtc.SessionDescription) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.PC.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+		if err := p.PC.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+			return err
+		}
+		p.negotiationNeeded = true
+	}
+	if err := p.PC.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+	p.flushCandidatesLocked()
+
+	answer, err := p.PC.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := p.PC.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	if err := p.send(types.MessageTypeAnswer, p.PC.LocalDescription()); err != nil {
+		return err
+	}
+	return p.negotiateLocked()
+}
+
+// HandleAnswer applies the participant's answer to an SFU-initiated offer
+func (p *Peer) HandleAnswer(answer webrtc.SessionDescription) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.PC.SignalingState() != webrtc.SignalingStateHaveLocalOffer {
+		return errors.New("no pending offer to answer")
+	}
+	if err := p.PC.SetRemoteDescription(answer); err != nil {
+		return err
+	}
+	p.flushCandidatesLocked()
+	return p.negotiateLocked()
+}
+
+// HandleCandidate adds a trickled candidate, queueing it until a remote description exists
+func (p *Peer) HandleCandidate(candidate webrtc.ICECandidateInit) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.PC.RemoteDescription() == nil {
+		p.pendingCandidates = append(p.pendingCandidates, candidate)
+		return nil
+	}
+	return p.PC.AddICECandidate(candidate)
+}
+
+func (p *Peer) flushCandidatesLocked() {
+	for _, candidate := range p.pendingCandidates {
+		if err := p.PC.AddICECandidate(candidate); err != nil {
+			log.Printf("[SFU] Failed to add queued candidate for %s: %v", p.UserID, err)
+		}
+	}
+	p.pendingCandidates = nil
+}
+
+// Negotiate sends a new offer after tracks were added or removed
+func (p *Peer) Negotiate() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.negotiationNeeded = true
+	return p.negotiateLocked()
+}
+
+func (p *Peer) negotiateLocked() error {
+	if !p.negotiationNeeded || p.closed {
+		return nil
+	}
+	// Only offer from a stable state; the pending flag is picked up after the current exchange
+	if p.PC.SignalingState() != webrtc.SignalingStateStable || p.PC.RemoteDescription() == nil {
+		return nil
+	}
+	p.negotiationNeeded = false
+
+	offer, err := p.PC.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := p.PC.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	return p.send(types.MessageTypeOffer, p.PC.LocalDescription())
+}
+
+// Subscribe attaches a publication to this peer and returns the subscription
+func (p *Peer) Subscribe(pub *Publication) (*Subscription, error) {
+	local, err := webrtc.NewTrackLocalStaticRTP(pub.Codec, pub.TrackID, pub.PublisherID.String())
+	if err != nil {
+		return nil, err
+	}
+	sender, err := p.PC.AddTrack(local)
+	if err != nil {
+		return nil, err
+	}
+	sub := newSubscription(p.UserID, local, sender)
+	pub.addSubscriber(sub)
+
+	// Forward the subscriber's keyframe requests to the publisher
+	go func() {
+		for {
+			packets, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, packet := range packets {
+				switch packet.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					pub.RequestKeyframe(sub.preferredLayer())
+				}
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// Unsubscribe detaches a publication from this peer
+func (p *Peer) Unsubscribe(pub *Publication) {
+	sub := pub.removeSubscriber(p.UserID)
+	if sub == nil {
+		return
+	}
+	if err := p.PC.RemoveTrack(sub.Sender); err != nil && !errors.Is(err, webrtc.ErrConnectionClosed) {
+		log.Printf("[SFU] Failed to remove track %s from %s: %v", pub.TrackID, p.UserID, err)
+	}
+}
+
+// RequestPLI asks this peer, as a publisher, for a keyframe on one SSRC
+func (p *Peer) RequestPLI(ssrc webrtc.SSRC) {
+	if err := p.PC.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(ssrc)}}); err != nil {
+		log.Printf("[SFU] Failed to send PLI to %s: %v", p.UserID, err)
+	}
+}
+
+func (p *Peer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	return p.PC.Close()
+}
+
+func (p *Peer) send(messageType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return p.signaler.Send(types.WebSocketMessage{
+		Type:       messageType,
+		RoomID:     p.RoomID,
+		TargetID:   p.UserID.String(),
+		ReceiverID: p.UserID.String(),
+		Payload:    data,
+	})
+}
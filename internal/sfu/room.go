@@ -0,0 +1,197 @@
+package sfu
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+)
+
+// Room holds the peers and publications of one call
+type Room struct {
+	ID string
+
+	mu           sync.RWMutex
+	peers        map[uuid.UUID]*Peer
+	publications map[string]*Publication
+}
+
+func newRoom(id string) *Room {
+	return &Room{
+		ID:           id,
+		peers:        make(map[uuid.UUID]*Peer),
+		publications: make(map[string]*Publication),
+	}
+}
+
+func (r *Room) getPeer(userID uuid.UUID) *Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.peers[userID]
+}
+
+func (r *Room) peerIDs() []uuid.UUID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]uuid.UUID, 0, len(r.peers))
+	for id := range r.peers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (r *Room) isEmpty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.peers) == 0
+}
+
+// addPeer registers a new participant and subscribes it to everything already published
+func (r *Room) addPeer(peer *Peer) {
+	r.mu.Lock()
+	r.peers[peer.UserID] = peer
+	existing := make([]*Publication, 0, len(r.publications))
+	for _, pub := range r.publications {
+		if pub.PublisherID != peer.UserID {
+			existing = append(existing, pub)
+		}
+	}
+	r.mu.Unlock()
+
+	peer.PC.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		r.onTrack(peer, track)
+	})
+	peer.PC.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			r.removePeer(peer.UserID)
+		}
+	})
+
+	for _, pub := range existing {
+		if _, err := peer.Subscribe(pub); err != nil {
+			log.Printf("[SFU] Failed to subscribe %s to %s: %v", peer.UserID, pub.Key(), err)
+		}
+	}
+	if len(existing) > 0 {
+		if err := peer.Negotiate(); err != nil {
+			log.Printf("[SFU] Negotiation with %s failed: %v", peer.UserID, err)
+		}
+	}
+}
+
+// onTrack is called once per received track, and once per simulcast layer
+func (r *Room) onTrack(publisher *Peer, track *webrtc.TrackRemote) {
+	key := publicationKey(publisher.UserID, track.ID())
+
+	r.mu.Lock()
+	pub, exists := r.publications[key]
+	if !exists {
+		pub = newPublication(publisher.UserID, track, publisher.RequestPLI)
+		r.publications[key] = pub
+	}
+	subscribers := make([]*Peer, 0, len(r.peers))
+	if !exists {
+		for id, peer := range r.peers {
+			if id != publisher.UserID {
+				subscribers = append(subscribers, peer)
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	pub.addLayer(track)
+	log.Printf("[SFU] Room %s: %s published %s track %s (rid=%q screen=%v)",
+		r.ID, publisher.UserID, track.Kind(), track.ID(), track.RID(), pub.IsScreen())
+
+	for _, peer := range subscribers {
+		if _, err := peer.Subscribe(pub); err != nil {
+			log.Printf("[SFU] Failed to subscribe %s to %s: %v", peer.UserID, key, err)
+			continue
+		}
+		if err := peer.Negotiate(); err != nil {
+			log.Printf("[SFU] Negotiation with %s failed: %v", peer.UserID, err)
+		}
+	}
+
+	// Read loop for this layer; exits when the publisher goes away
+	rid := track.RID()
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("[SFU] Read error on %s rid=%q: %v", key, rid, err)
+			}
+			return
+		}
+		pub.forward(rid, pkt)
+	}
+}
+
+// removePeer closes a participant and withdraws its publications from everyone else
+func (r *Room) removePeer(userID uuid.UUID) {
+	r.mu.Lock()
+	peer, exists := r.peers[userID]
+	if !exists {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.peers, userID)
+
+	var withdrawn []*Publication
+	for key, pub := range r.publications {
+		if pub.PublisherID == userID {
+			withdrawn = append(withdrawn, pub)
+			delete(r.publications, key)
+		}
+	}
+	remaining := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		remaining = append(remaining, p)
+	}
+	subscribedTo := make([]*Publication, 0, len(r.publications))
+	for _, pub := range r.publications {
+		subscribedTo = append(subscribedTo, pub)
+	}
+	r.mu.Unlock()
+
+	for _, pub := range subscribedTo {
+		pub.removeSubscriber(userID)
+	}
+	for _, other := range remaining {
+		for _, pub := range withdrawn {
+			other.Unsubscribe(pub)
+		}
+		if len(withdrawn) > 0 {
+			if err := other.Negotiate(); err != nil {
+				log.Printf("[SFU] Negotiation with %s failed: %v", other.UserID, err)
+			}
+		}
+	}
+
+	if err := peer.Close(); err != nil {
+		log.Printf("[SFU] Failed to close peer %s: %v", userID, err)
+	}
+	log.Printf("[SFU] Room %s: %s left", r.ID, userID)
+}
+
+// selectLayer changes which simulcast layer of a publication a subscriber receives
+func (r *Room) selectLayer(subscriberID, publisherID uuid.UUID, trackID, rid string) error {
+	r.mu.RLock()
+	pub := r.publications[publicationKey(publisherID, trackID)]
+	r.mu.RUnlock()
+	if pub == nil {
+		return errors.New("publication not found")
+	}
+	pub.SelectLayer(subscriberID, rid)
+	return nil
+}
+
+func (r *Room) close() {
+	for _, id := range r.peerIDs() {
+		r.removePeer(id)
+	}
+}
@@ -0,0 +1,219 @@
+package sfu
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pion/interceptor"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// SFU forwards every published track of a room to the other participants of that room.
+// Negotiation happens over the signaling server's room protocol, the SFU being addressed as a peer.
+type SFU struct {
+	api      *webrtc.API
+	config   webrtc.Configuration
+	signaler Signaler
+
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+// NewSFU builds the WebRTC API with simulcast header extensions and the default interceptors
+func NewSFU(signaler Signaler, iceServers []webrtc.ICEServer) (*SFU, error) {
+	api, err := NewAPI()
+	if err != nil {
+		return nil, err
+	}
+	return &SFU{
+		api:      api,
+		config:   webrtc.Configuration{ICEServers: iceServers},
+		signaler: signaler,
+		rooms:    make(map[string]*Room),
+	}, nil
+}
+
+// NewAPI returns a pion API able to receive simulcast (RID based) video
+func NewAPI() (*webrtc.API, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	for _, extension := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI, sdp.SDESRepairRTPStreamIDURI} {
+		if err := mediaEngine.RegisterHeaderExtension(
+			webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, registry); err != nil {
+		return nil, err
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine), webrtc.WithInterceptorRegistry(registry)), nil
+}
+
+// HandleSignal dispatches a message relayed to the SFU by the signaling server
+func (s *SFU) HandleSignal(msg types.WebSocketMessage) error {
+	switch msg.Type {
+	case types.MessageTypeOffer:
+		senderID, err := uuid.Parse(msg.SenderID)
+		if err != nil {
+			return fmt.Errorf("invalid sender id: %w", err)
+		}
+		var offer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &offer); err != nil {
+			return fmt.Errorf("invalid offer: %w", err)
+		}
+		peer, err := s.getOrCreatePeer(msg.RoomID, senderID)
+		if err != nil {
+			return err
+		}
+		return peer.HandleOffer(offer)
+
+	case types.MessageTypeAnswer:
+		peer, err := s.existingPeer(msg.RoomID, msg.SenderID)
+		if err != nil {
+			return err
+		}
+		var answer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+			return fmt.Errorf("invalid answer: %w", err)
+		}
+		return peer.HandleAnswer(answer)
+
+	case types.MessageTypeIceCandidate:
+		peer, err := s.existingPeer(msg.RoomID, msg.SenderID)
+		if err != nil {
+			return err
+		}
+		var candidate webrtc.ICECandidateInit
+		if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+			return fmt.Errorf("invalid candidate: %w", err)
+		}
+		return peer.HandleCandidate(candidate)
+
+	case types.MessageTypeLayer:
+		subscriberID, err := uuid.Parse(msg.SenderID)
+		if err != nil {
+			return fmt.Errorf("invalid sender id: %w", err)
+		}
+		var selection types.LayerSelection
+		if err := json.Unmarshal(msg.Payload, &selection); err != nil {
+			return fmt.Errorf("invalid layer selection: %w", err)
+		}
+		publisherID, err := uuid.Parse(selection.PublisherID)
+		if err != nil {
+			return fmt.Errorf("invalid publisher id: %w", err)
+		}
+		room := s.getRoom(msg.RoomID)
+		if room == nil {
+			return fmt.Errorf("room %s not found", msg.RoomID)
+		}
+		return room.selectLayer(subscriberID, publisherID, selection.TrackID, selection.RID)
+
+	case types.MessageTypeParticipants:
+		var snapshot types.RoomSnapshot
+		if err := json.Unmarshal(msg.Payload, &snapshot); err != nil {
+			return fmt.Errorf("invalid participants snapshot: %w", err)
+		}
+		s.syncParticipants(snapshot)
+		return nil
+	}
+	return nil
+}
+
+// syncParticipants drops peers that are no longer listed in the signaling room
+func (s *SFU) syncParticipants(snapshot types.RoomSnapshot) {
+	room := s.getRoom(snapshot.RoomID)
+	if room == nil {
+		return
+	}
+	listed := make(map[uuid.UUID]struct{}, len(snapshot.Participants))
+	for _, raw := range snapshot.Participants {
+		if id, err := uuid.Parse(raw); err == nil {
+			listed[id] = struct{}{}
+		}
+	}
+	for _, id := range room.peerIDs() {
+		if _, ok := listed[id]; !ok {
+			room.removePeer(id)
+		}
+	}
+	s.dropRoomIfEmpty(room)
+}
+
+func (s *SFU) getRoom(roomID string) *Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rooms[roomID]
+}
+
+func (s *SFU) getOrCreatePeer(roomID string, userID uuid.UUID) (*Peer, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("room id is required")
+	}
+	s.mu.Lock()
+	room, exists := s.rooms[roomID]
+	if !exists {
+		room = newRoom(roomID)
+		s.rooms[roomID] = room
+	}
+	s.mu.Unlock()
+
+	if peer := room.getPeer(userID); peer != nil {
+		return peer, nil
+	}
+	peer, err := newPeer(s.api, s.config, roomID, userID, s.signaler)
+	if err != nil {
+		return nil, err
+	}
+	room.addPeer(peer)
+	log.Printf("[SFU] Room %s: %s joined", roomID, userID)
+	return peer, nil
+}
+
+func (s *SFU) existingPeer(roomID, rawUserID string) (*Peer, error) {
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender id: %w", err)
+	}
+	room := s.getRoom(roomID)
+	if room == nil {
+		return nil, fmt.Errorf("room %s not found", roomID)
+	}
+	peer := room.getPeer(userID)
+	if peer == nil {
+		return nil, fmt.Errorf("peer %s not found in room %s", userID, roomID)
+	}
+	return peer, nil
+}
+
+func (s *SFU) dropRoomIfEmpty(room *Room) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if room.isEmpty() {
+		delete(s.rooms, room.ID)
+	}
+}
+
+// Close tears down every room
+func (s *SFU) Close() {
+	s.mu.Lock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.rooms = make(map[string]*Room)
+	s.mu.Unlock()
+
+	for _, room := range rooms {
+		room.close()
+	}
+}
@@ -0,0 +1,267 @@
+package sfu
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// loopbackSignaler stands in for the signaling server, handing what the SFU sends to the test clients.
+// Messages go through a queue: the SFU sends while holding a peer's lock, and a client answering in
+// place would call back into that peer.
+type loopbackSignaler struct {
+	queue chan types.WebSocketMessage
+
+	mu      sync.Mutex
+	clients map[string]*testClient
+}
+
+func newLoopbackSignaler() *loopbackSignaler {
+	return &loopbackSignaler{
+		queue:   make(chan types.WebSocketMessage, 64),
+		clients: make(map[string]*testClient),
+	}
+}
+
+func (s *loopbackSignaler) Send(msg types.WebSocketMessage) error {
+	s.queue <- msg
+	return nil
+}
+
+func (s *loopbackSignaler) run(t *testing.T, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-s.queue:
+			s.mu.Lock()
+			client := s.clients[msg.TargetID]
+			s.mu.Unlock()
+			if client == nil {
+				t.Errorf("%s sent to unknown participant %s", msg.Type, msg.TargetID)
+				continue
+			}
+			if err := client.handle(msg); err != nil {
+				t.Errorf("%s handling %s: %v", client.userID, msg.Type, err)
+			}
+		}
+	}
+}
+
+// testClient is a participant's browser, talking to the SFU through the loopback signaler
+type testClient struct {
+	userID uuid.UUID
+	roomID string
+	pc     *webrtc.PeerConnection
+	sfu    *SFU
+
+	mu                sync.Mutex
+	pendingCandidates []webrtc.ICECandidateInit
+}
+
+func newTestClient(t *testing.T, s *SFU, signaler *loopbackSignaler, roomID string) *testClient {
+	t.Helper()
+	api, err := NewAPI()
+	if err != nil {
+		t.Fatalf("NewAPI: %v", err)
+	}
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("NewPeerConnection: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	client := &testClient{userID: uuid.New(), roomID: roomID, pc: pc, sfu: s}
+	signaler.mu.Lock()
+	signaler.clients[client.userID.String()] = client
+	signaler.mu.Unlock()
+	return client
+}
+
+// join offers to the SFU with every candidate in the offer, so the client never trickles
+func (c *testClient) join(t *testing.T) {
+	t.Helper()
+	offer, err := c.pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("CreateOffer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(c.pc)
+	if err := c.pc.SetLocalDescription(offer); err != nil {
+		t.Fatalf("SetLocalDescription: %v", err)
+	}
+	<-gathered
+	if err := c.signal(types.MessageTypeOffer, c.pc.LocalDescription()); err != nil {
+		t.Fatalf("offer from %s: %v", c.userID, err)
+	}
+}
+
+func (c *testClient) signal(messageType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.sfu.HandleSignal(types.WebSocketMessage{
+		Type:     messageType,
+		RoomID:   c.roomID,
+		SenderID: c.userID.String(),
+		Payload:  data,
+	})
+}
+
+func (c *testClient) handle(msg types.WebSocketMessage) error {
+	switch msg.Type {
+	case types.MessageTypeAnswer:
+		var answer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+			return err
+		}
+		return c.setRemoteDescription(answer)
+
+	case types.MessageTypeOffer:
+		var offer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &offer); err != nil {
+			return err
+		}
+		if err := c.setRemoteDescription(offer); err != nil {
+			return err
+		}
+		answer, err := c.pc.CreateAnswer(nil)
+		if err != nil {
+			return err
+		}
+		if err := c.pc.SetLocalDescription(answer); err != nil {
+			return err
+		}
+		return c.signal(types.MessageTypeAnswer, c.pc.LocalDescription())
+
+	case types.MessageTypeIceCandidate:
+		var candidate webrtc.ICECandidateInit
+		if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// The SFU trickles as soon as it gathers, which can be before its answer arrives
+		if c.pc.RemoteDescription() == nil {
+			c.pendingCandidates = append(c.pendingCandidates, candidate)
+			return nil
+		}
+		return c.pc.AddICECandidate(candidate)
+	}
+	return nil
+}
+
+func (c *testClient) setRemoteDescription(description webrtc.SessionDescription) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.pc.SetRemoteDescription(description); err != nil {
+		return err
+	}
+	for _, candidate := range c.pendingCandidates {
+		if err := c.pc.AddICECandidate(candidate); err != nil {
+			return err
+		}
+	}
+	c.pendingCandidates = nil
+	return nil
+}
+
+// vp8Keyframe is the payload of a packet starting a VP8 keyframe
+var vp8Keyframe = []byte{0x10, 0x00, 0x9d, 0x01, 0x2a}
+
+// received is the first packet a subscriber got on a track
+type received struct {
+	trackID  string
+	streamID string
+	packet   *rtp.Packet
+}
+
+func TestPublishedTrackReachesSubscriber(t *testing.T) {
+	signaler := newLoopbackSignaler()
+	s, err := NewSFU(signaler, nil)
+	if err != nil {
+		t.Fatalf("NewSFU: %v", err)
+	}
+	defer s.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go signaler.run(t, done)
+
+	// The subscriber is already connected when the publisher starts sending
+	subscriber := newTestClient(t, s, signaler, "call-1")
+	if _, err := subscriber.pc.CreateDataChannel("control", nil); err != nil {
+		t.Fatalf("CreateDataChannel: %v", err)
+	}
+	tracks := make(chan received, 1)
+	subscriber.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		select {
+		case tracks <- received{trackID: track.ID(), streamID: track.StreamID(), packet: packet}:
+		default:
+		}
+	})
+	subscriber.join(t)
+
+	publisher := newTestClient(t, s, signaler, "call-1")
+	local, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "camera", "camera-stream")
+	if err != nil {
+		t.Fatalf("NewTrackLocalStaticRTP: %v", err)
+	}
+	sender, err := publisher.pc.AddTrack(local)
+	if err != nil {
+		t.Fatalf("AddTrack: %v", err)
+	}
+	go func() {
+		for {
+			if _, _, err := sender.ReadRTCP(); err != nil {
+				return
+			}
+		}
+	}()
+	publisher.join(t)
+
+	// Keep sending until the subscriber has its first packet
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for seq := uint16(0); ; seq++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			_ = local.WriteRTP(&rtp.Packet{
+				Header:  rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: uint32(seq) * 3000},
+				Payload: vp8Keyframe,
+			})
+		}
+	}()
+
+	select {
+	case got := <-tracks:
+		if got.trackID != "camera" || got.streamID != publisher.userID.String() {
+			t.Fatalf("subscriber got track %s of stream %s, want camera of %s", got.trackID, got.streamID, publisher.userID)
+		}
+		if !bytes.Equal(got.packet.Payload, vp8Keyframe) {
+			t.Fatalf("subscriber got payload %x, want %x", got.packet.Payload, vp8Keyframe)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("no RTP reached the subscriber")
+	}
+
+	room := s.getRoom("call-1")
+	if room == nil || len(room.peerIDs()) != 2 {
+		t.Fatalf("room call-1 should hold both peers")
+	}
+}
@@ -0,0 +1,105 @@
+package sfu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// Signaler is how the SFU talks back to participants
+type Signaler interface {
+	Send(msg types.WebSocketMessage) error
+}
+
+// SubprotocolSFU is the Sec-WebSocket-Protocol marker the SFU authenticates with
+const SubprotocolSFU = "sfu"
+
+var ErrNotConnected = errors.New("sfu is not connected to signaling")
+
+// SignalingClient keeps a WebSocket connection to the signaling server open and reconnects when it drops
+type SignalingClient struct {
+	URL    string
+	Secret string
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func NewSignalingClient(url, secret string) *SignalingClient {
+	return &SignalingClient{URL: url, Secret: secret}
+}
+
+func (c *SignalingClient) Send(msg types.WebSocketMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Run connects and feeds every received message to handle until the context is cancelled
+func (c *SignalingClient) Run(ctx context.Context, handle func(types.WebSocketMessage) error) {
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		Subprotocols:     []string{SubprotocolSFU, c.Secret},
+	}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, _, err := dialer.DialContext(ctx, c.URL, nil)
+		if err != nil {
+			log.Printf("[SFU] Signaling dial failed: %v (retrying in %s)", err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+		log.Printf("[SFU] Connected to signaling at %s", c.URL)
+
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+
+		c.readLoop(ctx, conn, handle)
+
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		_ = conn.Close()
+	}
+}
+
+func (c *SignalingClient) readLoop(ctx context.Context, conn *websocket.Conn, handle func(types.WebSocketMessage) error) {
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	for {
+		var msg types.WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[SFU] Signaling read error: %v", err)
+			}
+			return
+		}
+		if err := handle(msg); err != nil {
+			log.Printf("[SFU] Failed to handle %s from %s: %v", msg.Type, msg.SenderID, err)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package sfu
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// Simulcast layer RIDs, lowest to highest. An empty RID means the track is not simulcast.
+const (
+	LayerLow    = "q"
+	LayerMedium = "h"
+	LayerHigh   = "f"
+)
+
+var layerRank = map[string]int{"": 0, LayerLow: 1, LayerMedium: 2, LayerHigh: 3}
+
+// Publication is one track published by a participant, with all of its simulcast layers
+type Publication struct {
+	PublisherID uuid.UUID
+	TrackID     string
+	StreamID    string
+	Kind        webrtc.RTPCodecType
+	Codec       webrtc.RTPCodecCapability
+
+	mu          sync.RWMutex
+	layers      map[string]*webrtc.TrackRemote // rid -> remote track
+	subscribers map[uuid.UUID]*Subscription
+	requestPLI  func(ssrc webrtc.SSRC)
+}
+
+func newPublication(publisherID uuid.UUID, track *webrtc.TrackRemote, requestPLI func(webrtc.SSRC)) *Publication {
+	return &Publication{
+		PublisherID: publisherID,
+		TrackID:     track.ID(),
+		StreamID:    track.StreamID(),
+		Kind:        track.Kind(),
+		Codec:       track.Codec().RTPCodecCapability,
+		layers:      make(map[string]*webrtc.TrackRemote),
+		subscribers: make(map[uuid.UUID]*Subscription),
+		requestPLI:  requestPLI,
+	}
+}
+
+// Key identifies the publication inside a room
+func (p *Publication) Key() string {
+	return publicationKey(p.PublisherID, p.TrackID)
+}
+
+func publicationKey(publisherID uuid.UUID, trackID string) string {
+	return publisherID.String() + "/" + trackID
+}
+
+// IsScreen reports whether the client labelled the track as a screen share
+func (p *Publication) IsScreen() bool {
+	return strings.Contains(strings.ToLower(p.TrackID), "screen") ||
+		strings.Contains(strings.ToLower(p.StreamID), "screen")
+}
+
+func (p *Publication) addLayer(track *webrtc.TrackRemote) {
+	p.mu.Lock()
+	p.layers[track.RID()] = track
+	subscribers := make([]*Subscription, 0, len(p.subscribers))
+	for _, sub := range p.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	p.mu.Unlock()
+
+	// A higher layer showing up may be what subscribers were waiting for
+	for _, sub := range subscribers {
+		p.SelectLayer(sub.SubscriberID, sub.preferredLayer())
+	}
+}
+
+// bestLayer returns the highest available layer not above the wanted one
+func (p *Publication) bestLayer(wanted string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	best, bestRank := "", -1
+	for rid := range p.layers {
+		rank := layerRank[rid]
+		if rank <= layerRank[wanted] && rank > bestRank {
+			best, bestRank = rid, rank
+		}
+	}
+	if bestRank == -1 {
+		// Nothing at or below the wanted layer, fall back to the lowest we have
+		for rid := range p.layers {
+			if bestRank == -1 || layerRank[rid] < bestRank {
+				best, bestRank = rid, layerRank[rid]
+			}
+		}
+	}
+	return best
+}
+
+func (p *Publication) addSubscriber(sub *Subscription) {
+	p.mu.Lock()
+	p.subscribers[sub.SubscriberID] = sub
+	p.mu.Unlock()
+	p.SelectLayer(sub.SubscriberID, sub.preferredLayer())
+}
+
+func (p *Publication) removeSubscriber(subscriberID uuid.UUID) *Subscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sub := p.subscribers[subscriberID]
+	delete(p.subscribers, subscriberID)
+	return sub
+}
+
+func (p *Publication) snapshotSubscribers() []*Subscription {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	subs := make([]*Subscription, 0, len(p.subscribers))
+	for _, sub := range p.subscribers {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// SelectLayer switches a subscriber to the given simulcast layer (or the closest available one)
+func (p *Publication) SelectLayer(subscriberID uuid.UUID, rid string) {
+	p.mu.RLock()
+	sub := p.subscribers[subscriberID]
+	p.mu.RUnlock()
+	if sub == nil {
+		return
+	}
+
+	sub.setPreferredLayer(rid)
+	target := p.bestLayer(rid)
+	if !sub.setTargetLayer(target) {
+		return
+	}
+	p.RequestKeyframe(target)
+}
+
+// RequestKeyframe asks the publisher for a keyframe on one layer
+func (p *Publication) RequestKeyframe(rid string) {
+	p.mu.RLock()
+	track := p.layers[rid]
+	p.mu.RUnlock()
+	if track != nil && p.requestPLI != nil {
+		p.requestPLI(track.SSRC())
+	}
+}
+
+// forward fans a packet of one layer out to every subscriber reading that layer
+func (p *Publication) forward(rid string, pkt *rtp.Packet) {
+	keyframe := p.Kind == webrtc.RTPCodecTypeVideo && isKeyframe(p.Codec.MimeType, pkt.Payload)
+	for _, sub := range p.snapshotSubscribers() {
+		sub.write(rid, pkt, keyframe)
+	}
+}
+
+// Subscription is one publication forwarded to one subscriber
+type Subscription struct {
+	SubscriberID uuid.UUID
+	Local        *webrtc.TrackLocalStaticRTP
+	Sender       *webrtc.RTPSender
+
+	mu        sync.Mutex
+	preferred string
+	current   string
+	target    string
+	switching bool
+	started   bool
+
+	// Sequence/timestamp rewriting keeps the outgoing stream continuous across layer switches
+	lastSeq   uint16
+	lastTS    uint32
+	seqOffset uint16
+	tsOffset  uint32
+}
+
+func newSubscription(subscriberID uuid.UUID, local *webrtc.TrackLocalStaticRTP, sender *webrtc.RTPSender) *Subscription {
+	return &Subscription{
+		SubscriberID: subscriberID,
+		Local:        local,
+		Sender:       sender,
+		preferred:    LayerHigh,
+	}
+}
+
+func (s *Subscription) preferredLayer() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.preferred
+}
+
+func (s *Subscription) setPreferredLayer(rid string) {
+	s.mu.Lock()
+	s.preferred = rid
+	s.mu.Unlock()
+}
+
+// setTargetLayer returns true when a switch (and therefore a keyframe) is needed
+func (s *Subscription) setTargetLayer(rid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started && s.current == rid && !s.switching {
+		return false
+	}
+	if !s.started {
+		s.current = rid
+	}
+	s.target = rid
+	s.switching = s.started && s.current != rid
+	return true
+}
+
+func (s *Subscription) write(rid string, pkt *rtp.Packet, keyframe bool) {
+	s.mu.Lock()
+	switch {
+	case s.switching && rid == s.target && keyframe:
+		// Switch exactly on a keyframe of the new layer so the decoder never sees a broken reference
+		s.current = rid
+		s.switching = false
+		s.seqOffset = s.lastSeq + 1 - pkt.SequenceNumber
+		s.tsOffset = s.lastTS + 1 - pkt.Timestamp
+	case rid != s.current:
+		s.mu.Unlock()
+		return
+	case !s.started:
+		s.started = true
+		s.seqOffset = 0
+		s.tsOffset = 0
+	}
+
+	out := *pkt
+	out.SequenceNumber = pkt.SequenceNumber + s.seqOffset
+	out.Timestamp = pkt.Timestamp + s.tsOffset
+	s.lastSeq = out.SequenceNumber
+	s.lastTS = out.Timestamp
+	s.mu.Unlock()
+
+	_ = s.Local.WriteRTP(&out)
+}
+
+// isKeyframe inspects the RTP payload for the start of a keyframe
+func isKeyframe(mimeType string, payload []byte) bool {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return isVP8Keyframe(payload)
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return isH264Keyframe(payload)
+	default:
+		// Codecs we do not parse switch on the first packet after the PLI
+		return true
+	}
+}
+
+func isVP8Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	// S bit set and partition index 0: this packet starts a frame
+	if payload[0]&0x10 == 0 || payload[0]&0x0f != 0 {
+		return false
+	}
+	idx := 1
+	if payload[0]&0x80 != 0 { // X: extension byte present
+		if len(payload) <= idx {
+			return false
+		}
+		ext := payload[idx]
+		idx++
+		if ext&0x80 != 0 { // I: picture id
+			if len(payload) <= idx {
+				return false
+			}
+			if payload[idx]&0x80 != 0 { // M: 15-bit picture id
+				idx++
+			}
+			idx++
+		}
+		if ext&0x40 != 0 { // L: tl0picidx
+			idx++
+		}
+		if ext&0x30 != 0 { // T or K: tid/keyidx
+			idx++
+		}
+	}
+	if len(payload) <= idx {
+		return false
+	}
+	// VP8 payload header: P bit 0 means keyframe
+	return payload[idx]&0x01 == 0
+}
+
+func isH264Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	switch nalType := payload[0] & 0x1f; nalType {
+	case 5, 7: // IDR slice, SPS
+		return true
+	case 24: // STAP-A
+		for idx := 1; idx+2 < len(payload); {
+			size := int(payload[idx])<<8 | int(payload[idx+1])
+			idx += 2
+			if idx >= len(payload) {
+				return false
+			}
+			if t := payload[idx] & 0x1f; t == 5 || t == 7 {
+				return true
+			}
+			idx += size
+		}
+	case 28: // FU-A, start bit set on an IDR fragment
+		return len(payload) > 1 && payload[1]&0x80 != 0 && payload[1]&0x1f == 5
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/internal/signaling/store"
+)
+
+// Every signaling replica registers itself as a node. A user's presence points at the node holding
+// their socket, and messages for users on another node are published on that node's channel.
+//
+//	signaling:nodes                  set of known node ids
+//	signaling:node:<id>:alive        heartbeat, expires when the node dies
+//	signaling:node:<id>:users        users connected to the node, used to clean up after it
+//	signaling:presence:<userID>      node id currently holding the user's socket
+//	signaling:node:<id>              pub/sub channel of the node
+//
+// Room memberships are kept by store.RoomRegistry, refreshed with the heartbeat and released with the node.
+const (
+	HeartbeatInterval = 5 * time.Second
+	NodeTTL           = 3 * HeartbeatInterval
+	PresenceTTL       = 60 * time.Second
+
+	nodesKey = "signaling:nodes"
+)
+
+var ErrUserOffline = errors.New("user is not connected to any signaling node")
+
+// compare-and-delete so a node never evicts a presence another node has since taken over
+var releasePresence = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Envelope is what travels between nodes: the target user and the already encoded message
+type Envelope struct {
+	UserID uuid.UUID       `json:"userId"`
+	Data   json.RawMessage `json:"data"`
+	// Durable messages are kept for the user by the receiving node when their socket is gone by then
+	Durable bool `json:"durable,omitempty"`
+}
+
+// DeliverFunc writes a routed message to a socket held by this node, keeping a durable one if it cannot
+type DeliverFunc func(userID uuid.UUID, data []byte, durable bool) error
+
+// ReleasedFunc is told about the participants a dead node's rooms lost, so the others can be updated
+type ReleasedFunc func(changes []store.RoomChange)
+
+type Node struct {
+	ID    string
+	Rooms *store.RoomRegistry
+
+	client   *goredis.Client
+	deliver  DeliverFunc
+	released ReleasedFunc
+
+	mu    sync.Mutex
+	users map[uuid.UUID]struct{}
+}
+
+var current *Node
+
+// NodeID is the id of this replica, taken from SIGNALING_NODE_ID or derived from the hostname
+func NodeID() string {
+	if id := os.Getenv("SIGNALING_NODE_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "signaling"
+	}
+	// A restarted pod keeps its hostname, the suffix keeps it from adopting the previous run's presence
+	return fmt.Sprintf("%s-%s", host, uuid.NewString()[:8])
+}
+
+func PresenceKey(userID uuid.UUID) string {
+	return fmt.Sprintf("signaling:presence:%s", userID)
+}
+
+func nodeAliveKey(nodeID string) string {
+	return fmt.Sprintf("signaling:node:%s:alive", nodeID)
+}
+
+func nodeUsersKey(nodeID string) string {
+	return fmt.Sprintf("signaling:node:%s:users", nodeID)
+}
+
+func nodeChannel(nodeID string) string {
+	return fmt.Sprintf("signaling:node:%s", nodeID)
+}
+
+// Start joins the cluster and keeps the node alive until ctx is cancelled. Rooms move to Redis, shared with
+// the other nodes. Without Redis the server keeps working as a single node.
+func Start(ctx context.Context, deliver DeliverFunc, released ReleasedFunc) (*Node, error) {
+	if redis.Redis == nil {
+		log.Printf("[Cluster] Redis unavailable, running as a single signaling node")
+		return nil, nil
+	}
+	node := newNode(redis.Redis.Client(), NodeID(), deliver, released)
+	if err := node.join(ctx); err != nil {
+		return nil, err
+	}
+	go node.run(ctx)
+
+	store.UseRoomRegistry(node.Rooms)
+	current = node
+	log.Printf("[Cluster] Joined as node %s", node.ID)
+	return node, nil
+}
+
+func newNode(client *goredis.Client, id string, deliver DeliverFunc, released ReleasedFunc) *Node {
+	return &Node{
+		ID:       id,
+		Rooms:    store.NewRoomRegistry(client, id),
+		client:   client,
+		deliver:  deliver,
+		released: released,
+		users:    make(map[uuid.UUID]struct{}),
+	}
+}
+
+// join announces the node and starts consuming its channel
+func (n *Node) join(ctx context.Context) error {
+	if err := n.heartbeat(ctx); err != nil {
+		return err
+	}
+	pubsub := n.client.Subscribe(ctx, nodeChannel(n.ID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	go n.consume(ctx, pubsub)
+	return nil
+}
+
+// Current is the node started by Start, nil when running without Redis
+func Current() *Node {
+	return current
+}
+
+// Register records that the user's socket lives on this node
+func (n *Node) Register(userID uuid.UUID) error {
+	n.mu.Lock()
+	n.users[userID] = struct{}{}
+	n.mu.Unlock()
+
+	pipe := n.client.TxPipeline()
+	pipe.Set(redis.Ctx, PresenceKey(userID), n.ID, PresenceTTL)
+	pipe.SAdd(redis.Ctx, nodeUsersKey(n.ID), userID.String())
+	_, err := pipe.Exec(redis.Ctx)
+	return err
+}
+
+// Unregister drops the user's presence if it still points at this node
+func (n *Node) Unregister(userID uuid.UUID) error {
+	n.mu.Lock()
+	delete(n.users, userID)
+	n.mu.Unlock()
+
+	if err := releasePresence.Run(redis.Ctx, n.client, []string{PresenceKey(userID)}, n.ID).Err(); err != nil {
+		return err
+	}
+	return n.client.SRem(redis.Ctx, nodeUsersKey(n.ID), userID.String()).Err()
+}
+
+// Locate returns the node holding the user's socket
+func (n *Node) Locate(userID uuid.UUID) (string, error) {
+	nodeID, err := n.client.Get(redis.Ctx, PresenceKey(userID)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", ErrUserOffline
+	}
+	return nodeID, err
+}
+
+// Route publishes an encoded message to the node holding the user's socket
+func (n *Node) Route(userID uuid.UUID, data []byte) error {
+	return n.route(userID, data, false)
+}
+
+// RouteDurable is Route for a message the user must not lose: once published, the receiving node
+// delivers it or keeps it for the user's next connection.
+func (n *Node) RouteDurable(userID uuid.UUID, data []byte) error {
+	return n.route(userID, data, true)
+}
+
+func (n *Node) route(userID uuid.UUID, data []byte, durable bool) error {
+	nodeID, err := n.Locate(userID)
+	if err != nil {
+		return err
+	}
+	if nodeID == n.ID {
+		// Presence says here but the socket is gone; the caller already checked locally
+		_ = n.Unregister(userID)
+		return ErrUserOffline
+	}
+
+	payload, err := json.Marshal(Envelope{UserID: userID, Data: data, Durable: durable})
+	if err != nil {
+		return err
+	}
+	receivers, err := n.client.Publish(redis.Ctx, nodeChannel(nodeID), payload).Result()
+	if err != nil {
+		return err
+	}
+	if receivers == 0 {
+		// Nobody listens on that channel; clean up now if the node's heartbeat is gone too
+		if alive, err := n.client.Exists(redis.Ctx, nodeAliveKey(nodeID)).Result(); err == nil && alive == 0 {
+			n.reapNode(nodeID)
+		}
+		return ErrUserOffline
+	}
+	return nil
+}
+
+func (n *Node) consume(ctx context.Context, pubsub *goredis.PubSub) {
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				log.Printf("[Cluster] Invalid envelope: %v", err)
+				continue
+			}
+			if err := n.deliver(envelope.UserID, envelope.Data, envelope.Durable); err != nil {
+				log.Printf("[Cluster] Failed to deliver to %s: %v", envelope.UserID, err)
+			}
+		}
+	}
+}
+
+func (n *Node) run(ctx context.Context) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.leave()
+			return
+		case <-ticker.C:
+			if err := n.heartbeat(ctx); err != nil {
+				log.Printf("[Cluster] Heartbeat failed: %v", err)
+				continue
+			}
+			n.reapDeadNodes()
+		}
+	}
+}
+
+// heartbeat refreshes the node, the presence of every user connected to it and the rooms they are in
+func (n *Node) heartbeat(ctx context.Context) error {
+	n.mu.Lock()
+	users := make([]uuid.UUID, 0, len(n.users))
+	for id := range n.users {
+		users = append(users, id)
+	}
+	n.mu.Unlock()
+
+	pipe := n.client.Pipeline()
+	pipe.SAdd(ctx, nodesKey, n.ID)
+	pipe.Set(ctx, nodeAliveKey(n.ID), time.Now().Unix(), NodeTTL)
+	for _, id := range users {
+		pipe.Expire(ctx, PresenceKey(id), PresenceTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	return n.Rooms.Refresh(ctx)
+}
+
+// reapDeadNodes cleans up after nodes whose heartbeat expired. Any node may do it, the cleanup is idempotent.
+func (n *Node) reapDeadNodes() {
+	nodes, err := n.client.SMembers(redis.Ctx, nodesKey).Result()
+	if err != nil {
+		log.Printf("[Cluster] Failed to list nodes: %v", err)
+		return
+	}
+	for _, nodeID := range nodes {
+		if nodeID == n.ID {
+			continue
+		}
+		alive, err := n.client.Exists(redis.Ctx, nodeAliveKey(nodeID)).Result()
+		if err != nil || alive > 0 {
+			continue
+		}
+		n.reapNode(nodeID)
+	}
+}
+
+func (n *Node) reapNode(nodeID string) {
+	// Rooms first, the node stays listed until its memberships are released
+	changes, err := n.Rooms.ReleaseNode(nodeID)
+	if err != nil {
+		log.Printf("[Cluster] Failed to release the rooms of node %s: %v", nodeID, err)
+		return
+	}
+	if len(changes) > 0 && n.released != nil {
+		n.released(changes)
+	}
+
+	users, err := n.client.SMembers(redis.Ctx, nodeUsersKey(nodeID)).Result()
+	if err != nil {
+		log.Printf("[Cluster] Failed to list users of node %s: %v", nodeID, err)
+		return
+	}
+	for _, raw := range users {
+		if id, err := uuid.Parse(raw); err == nil {
+			_ = releasePresence.Run(redis.Ctx, n.client, []string{PresenceKey(id)}, nodeID).Err()
+		}
+	}
+	pipe := n.client.TxPipeline()
+	pipe.Del(redis.Ctx, nodeUsersKey(nodeID), nodeAliveKey(nodeID))
+	pipe.SRem(redis.Ctx, nodesKey, nodeID)
+	if _, err := pipe.Exec(redis.Ctx); err != nil {
+		log.Printf("[Cluster] Failed to remove node %s: %v", nodeID, err)
+		return
+	}
+	log.Printf("[Cluster] Removed dead node %s and %d presences", nodeID, len(users))
+}
+
+// leave removes this node from the cluster on shutdown
+func (n *Node) leave() {
+	n.mu.Lock()
+	n.users = make(map[uuid.UUID]struct{})
+	n.mu.Unlock()
+	n.reapNode(n.ID)
+}
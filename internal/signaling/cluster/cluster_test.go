@@ -0,0 +1,303 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/unarya/univia/internal/signaling/store"
+)
+
+// delivery is a message a test node delivered to one of its sockets
+type delivery struct {
+	userID  uuid.UUID
+	data    []byte
+	durable bool
+}
+
+// testNode is a signaling node on the shared Redis, recording what it delivers and releases
+type testNode struct {
+	*Node
+	delivered chan delivery
+
+	mu       sync.Mutex
+	released []store.RoomChange
+}
+
+func startTestNode(t *testing.T, ctx context.Context, addr, id string) *testNode {
+	t.Helper()
+	client := goredis.NewClient(&goredis.Options{Addr: addr, Password: os.Getenv("REDIS_PASSWORD")})
+	t.Cleanup(func() { _ = client.Close() })
+
+	node := &testNode{delivered: make(chan delivery, 8)}
+	node.Node = newNode(client, id,
+		func(userID uuid.UUID, data []byte, durable bool) error {
+			node.delivered <- delivery{userID: userID, data: data, durable: durable}
+			return nil
+		},
+		func(changes []store.RoomChange) {
+			node.mu.Lock()
+			node.released = append(node.released, changes...)
+			node.mu.Unlock()
+		})
+	if err := node.join(ctx); err != nil {
+		t.Fatalf("join %s: %v", id, err)
+	}
+	return node
+}
+
+func (n *testNode) nextDelivery(t *testing.T) delivery {
+	t.Helper()
+	select {
+	case got := <-n.delivered:
+		return got
+	case <-time.After(2 * time.Second):
+		t.Fatalf("nothing reached %s", n.ID)
+		return delivery{}
+	}
+}
+
+func (n *testNode) connect(t *testing.T, userID uuid.UUID) {
+	t.Helper()
+	if err := n.Register(userID); err != nil {
+		t.Fatalf("Register on %s: %v", n.ID, err)
+	}
+}
+
+func (n *testNode) joinRoom(t *testing.T, roomID string, userID uuid.UUID) (store.RoomState, []uuid.UUID) {
+	t.Helper()
+	state, participants, err := n.Rooms.Join(roomID, userID)
+	if err != nil {
+		t.Fatalf("Join on %s: %v", n.ID, err)
+	}
+	return state, participants
+}
+
+func sameParticipants(got []uuid.UUID, want ...uuid.UUID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(got))
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoomIsSharedAcrossNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodeA := startTestNode(t, ctx, server.Addr(), "node-a")
+	nodeB := startTestNode(t, ctx, server.Addr(), "node-b")
+
+	checkRoomIsShared(t, nodeA, nodeB, "call-1")
+}
+
+// checkRoomIsShared has a user on each node meet in roomID, relay messages to each other and leave
+func checkRoomIsShared(t *testing.T, nodeA, nodeB *testNode, roomID string) {
+	t.Helper()
+	alice, bob := uuid.New(), uuid.New()
+	nodeA.connect(t, alice)
+	nodeB.connect(t, bob)
+
+	if state, _ := nodeA.joinRoom(t, roomID, alice); state != store.RoomStateWaiting {
+		t.Fatalf("state after the first join = %s, want waiting", state)
+	}
+	state, participants := nodeB.joinRoom(t, roomID, bob)
+	if state != store.RoomStateActive || !sameParticipants(participants, alice, bob) {
+		t.Fatalf("after the second join: state %s, participants %v", state, participants)
+	}
+
+	// Each node sees the member the other one holds
+	if joined, err := nodeA.Rooms.IsMember(roomID, bob); err != nil || !joined {
+		t.Fatalf("node-a sees bob in the room = %v, %v", joined, err)
+	}
+	if _, _, err := nodeA.Rooms.Join(roomID, bob); err != store.ErrAlreadyInRoom {
+		t.Fatalf("joining twice: err = %v, want ErrAlreadyInRoom", err)
+	}
+
+	// A relay from a user on node-a reaches the socket on node-b
+	offer, _ := json.Marshal(map[string]string{"type": "offer", "targetId": bob.String()})
+	if err := nodeA.Route(bob, offer); err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if got := nodeB.nextDelivery(t); got.userID != bob || string(got.data) != string(offer) || got.durable {
+		t.Fatalf("node-b delivered %s to %s, durable %v", got.data, got.userID, got.durable)
+	}
+
+	// A notification is handed over to be kept for bob should his socket be gone
+	notification, _ := json.Marshal(map[string]string{"type": "notification", "receiverId": bob.String()})
+	if err := nodeA.RouteDurable(bob, notification); err != nil {
+		t.Fatalf("RouteDurable: %v", err)
+	}
+	if got := nodeB.nextDelivery(t); string(got.data) != string(notification) || !got.durable {
+		t.Fatalf("node-b delivered %s, durable %v; want the notification, durable", got.data, got.durable)
+	}
+
+	// Leaving through either node updates the room for both
+	state, participants, err := nodeB.Rooms.Leave(roomID, bob)
+	if err != nil || state != store.RoomStateWaiting || !sameParticipants(participants, alice) {
+		t.Fatalf("after leaving: state %s, participants %v, err %v", state, participants, err)
+	}
+	if rooms, _ := nodeB.Rooms.UserRooms(bob); len(rooms) != 0 {
+		t.Fatalf("bob still has rooms on node-b: %v", rooms)
+	}
+}
+
+func TestDeadNodeReleasesItsRooms(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodeA := startTestNode(t, ctx, server.Addr(), "node-a")
+	nodeB := startTestNode(t, ctx, server.Addr(), "node-b")
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	nodeA.connect(t, alice)
+	nodeB.connect(t, bob)
+	nodeB.connect(t, carol)
+	nodeA.joinRoom(t, "call-1", alice)
+	nodeB.joinRoom(t, "call-1", bob)
+	nodeB.joinRoom(t, "call-1", carol)
+	// carol reconnects to node-a before node-b dies; her membership moves with her
+	nodeA.connect(t, carol)
+	nodeA.Rooms.Join("call-1", carol)
+
+	// node-b stops beating; node-a keeps its own heartbeat alive
+	server.FastForward(NodeTTL + time.Second)
+	if err := nodeA.heartbeat(ctx); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	nodeA.reapDeadNodes()
+
+	participants, err := nodeA.Rooms.Participants("call-1")
+	if err != nil || !sameParticipants(participants, alice, carol) {
+		t.Fatalf("participants after node-b died = %v, %v; want alice and carol", participants, err)
+	}
+	nodeA.mu.Lock()
+	released := nodeA.released
+	nodeA.mu.Unlock()
+	if len(released) != 1 || released[0].UserID != bob || released[0].RoomID != "call-1" ||
+		!sameParticipants(released[0].Participants, alice, carol) {
+		t.Fatalf("released = %+v, want bob leaving call-1", released)
+	}
+	if _, err := nodeA.Locate(bob); err != ErrUserOffline {
+		t.Fatalf("bob's presence after node-b died: err = %v, want ErrUserOffline", err)
+	}
+	if nodes, _ := nodeA.client.SMembers(ctx, nodesKey).Result(); len(nodes) != 1 || nodes[0] != "node-a" {
+		t.Fatalf("nodes = %v, want node-a alone", nodes)
+	}
+}
+
+func TestRoomsExpireWithoutHeartbeat(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node := startTestNode(t, ctx, server.Addr(), "node-a")
+
+	alice := uuid.New()
+	node.connect(t, alice)
+	node.joinRoom(t, "call-1", alice)
+
+	// The heartbeat keeps the room past its TTL
+	server.FastForward(store.RoomTTL - time.Second)
+	if err := node.heartbeat(ctx); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	server.FastForward(2 * time.Second)
+	if participants, _ := node.Rooms.Participants("call-1"); !sameParticipants(participants, alice) {
+		t.Fatalf("participants with a heartbeat = %v, want alice", participants)
+	}
+
+	// Without one, nothing outlives the TTL
+	server.FastForward(store.RoomTTL)
+	if participants, _ := node.Rooms.Participants("call-1"); len(participants) != 0 {
+		t.Fatalf("participants without a heartbeat = %v, want none", participants)
+	}
+}
+
+// startRedisNodes starts two nodes on the Redis at REDIS_ADDR, as the signaling replicas share it, and skips
+// without one. Their ids are unique to the test, and they leave the cluster when it ends.
+func startRedisNodes(t *testing.T, ctx context.Context) (*testNode, *testNode) {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	suffix := uuid.NewString()[:8]
+	nodeA := startTestNode(t, ctx, addr, "test-a-"+suffix)
+	nodeB := startTestNode(t, ctx, addr, "test-b-"+suffix)
+	t.Cleanup(func() {
+		nodeA.leave()
+		nodeB.leave()
+	})
+	return nodeA, nodeB
+}
+
+func TestRoomIsSharedAcrossNodesOnRedis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodeA, nodeB := startRedisNodes(t, ctx)
+
+	checkRoomIsShared(t, nodeA, nodeB, "call-"+uuid.NewString())
+}
+
+func TestDeadNodeReleasesItsRoomsOnRedis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodeA, nodeB := startRedisNodes(t, ctx)
+	roomID := "call-" + uuid.NewString()
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	nodeA.connect(t, alice)
+	nodeB.connect(t, bob)
+	nodeB.connect(t, carol)
+	nodeA.joinRoom(t, roomID, alice)
+	nodeB.joinRoom(t, roomID, bob)
+	nodeB.joinRoom(t, roomID, carol)
+	nodeA.connect(t, carol)
+	nodeA.Rooms.Join(roomID, carol)
+
+	// node-b's heartbeat expires; waiting out NodeTTL would slow the test down for nothing
+	if err := nodeB.client.Del(ctx, nodeAliveKey(nodeB.ID)).Err(); err != nil {
+		t.Fatalf("expiring node-b: %v", err)
+	}
+	nodeA.reapDeadNodes()
+
+	participants, err := nodeA.Rooms.Participants(roomID)
+	if err != nil || !sameParticipants(participants, alice, carol) {
+		t.Fatalf("participants after node-b died = %v, %v; want alice and carol", participants, err)
+	}
+	// Other dead nodes sharing the Redis may be released along with node-b
+	nodeA.mu.Lock()
+	var released []store.RoomChange
+	for _, change := range nodeA.released {
+		if change.RoomID == roomID {
+			released = append(released, change)
+		}
+	}
+	nodeA.mu.Unlock()
+	if len(released) != 1 || released[0].UserID != bob || !sameParticipants(released[0].Participants, alice, carol) {
+		t.Fatalf("released = %+v, want bob leaving %s", released, roomID)
+	}
+	if _, err := nodeA.Locate(bob); err != ErrUserOffline {
+		t.Fatalf("bob's presence after node-b died: err = %v, want ErrUserOffline", err)
+	}
+	if node, err := nodeA.Locate(carol); err != nil || node != nodeA.ID {
+		t.Fatalf("carol's presence after node-b died = %s, %v; want %s", node, err, nodeA.ID)
+	}
+	if listed, _ := nodeA.client.SIsMember(ctx, nodesKey, nodeB.ID).Result(); listed {
+		t.Fatalf("node-b is still listed")
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	access_token "github.com/unarya/univia/internal/api/modules/key_token/access_token/services"
+	sessionServices "github.com/unarya/univia/internal/api/modules/session/services"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/internal/signaling/store"
+	"github.com/unarya/univia/pkg/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// SubprotocolAccessToken and SubprotocolSessionID are the Sec-WebSocket-Protocol
+	// markers a browser client sends in front of its credential, e.g. "access_token, <token>"
+	SubprotocolAccessToken = "access_token"
+	SubprotocolSessionID   = "session_id"
+	// SubprotocolSFU is used by the SFU binary together with SFU_SECRET
+	SubprotocolSFU = "sfu"
+
+	// authFrameTimeout is how long a client may wait before sending the first-frame auth message
+	authFrameTimeout = 10 * time.Second
+)
+
+// sessionCheckInterval is how often an open socket re-validates its token and session
+var sessionCheckInterval = 30 * time.Second
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Credentials holds whatever the client presented during the handshake
+type Credentials struct {
+	AccessToken string
+	SessionID   string
+	SFUSecret   string
+}
+
+func (c Credentials) Empty() bool {
+	return c.AccessToken == "" && c.SessionID == "" && c.SFUSecret == ""
+}
+
+// AuthContext is the identity bound to a socket once authentication succeeds
+type AuthContext struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	// AccessToken is the token the socket authenticated with, empty when it presented a session id
+	AccessToken string
+	Service     bool // internal service (SFU), not bound to a user session
+}
+
+// ExtractCredentials reads the credential from the subprotocol header, then from the query string
+func ExtractCredentials(r *http.Request) Credentials {
+	var creds Credentials
+
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) >= 2 {
+		switch protocols[0] {
+		case SubprotocolAccessToken:
+			creds.AccessToken = protocols[1]
+		case SubprotocolSessionID:
+			creds.SessionID = protocols[1]
+		case SubprotocolSFU:
+			creds.SFUSecret = protocols[1]
+		}
+	}
+	if !creds.Empty() {
+		return creds
+	}
+
+	query := r.URL.Query()
+	creds.AccessToken = query.Get("token")
+	creds.SessionID = query.Get("session_id")
+	return creds
+}
+
+// ReadAuthFrame waits for the first frame to be an auth message carrying the credential
+func ReadAuthFrame(conn *websocket.Conn) (Credentials, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(authFrameTimeout)); err != nil {
+		return Credentials{}, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	var msg types.AuthMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return Credentials{}, fmt.Errorf("failed to read auth frame: %w", err)
+	}
+	if msg.Type != types.MessageTypeAuth {
+		return Credentials{}, fmt.Errorf("expected %q frame, got %q", types.MessageTypeAuth, msg.Type)
+	}
+	return Credentials{AccessToken: msg.Token, SessionID: msg.SessionID}, nil
+}
+
+// Authenticate resolves the user behind the credentials and makes sure the session is not revoked
+func Authenticate(creds Credentials) (*AuthContext, error) {
+	switch {
+	case creds.SFUSecret != "":
+		return authenticateSFU(creds.SFUSecret)
+	case creds.AccessToken != "":
+		return authenticateByToken(strings.TrimSpace(creds.AccessToken))
+	case creds.SessionID != "":
+		return authenticateBySession(strings.TrimSpace(creds.SessionID))
+	default:
+		return nil, ErrUnauthorized
+	}
+}
+
+func authenticateSFU(secret string) (*AuthContext, error) {
+	expected := os.Getenv("SFU_SECRET")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		return nil, ErrUnauthorized
+	}
+	return &AuthContext{UserID: store.SFUUserID, Service: true}, nil
+}
+
+// authenticateByToken validates the token like the API does, then binds the socket to the session it was issued
+// for. The token record is also read from the database, as VerifyToken may answer from its cache for a token
+// revoked or expired since.
+func authenticateByToken(token string) (*AuthContext, error) {
+	user, err := access_token.VerifyToken(token)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	tokenRecord, err := access_token.GetActiveToken(token)
+	if err != nil || tokenRecord.UserID != user.ID {
+		return nil, ErrUnauthorized
+	}
+
+	sessionID := uuid.Nil
+	if tokenRecord.SessionID != nil {
+		sessionID = *tokenRecord.SessionID
+	} else if sessionID, err = sessionServices.GetSessionIDByUserID(user.ID); err != nil {
+		// Tokens are bound when issued, an unbound one comes from an API not updated yet and belongs to
+		// the user's only session
+		log.Printf("Access token of user %s has no session: %v", user.ID, err)
+		return nil, ErrUnauthorized
+	}
+	if active, err := sessionServices.CheckSessionActive(sessionID); err != nil || !active {
+		return nil, ErrUnauthorized
+	}
+	return &AuthContext{UserID: user.ID, SessionID: sessionID, AccessToken: token}, nil
+}
+
+func authenticateBySession(rawSessionID string) (*AuthContext, error) {
+	sessionID, err := uuid.Parse(rawSessionID)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	cached, err := redis.GetJSON[types.CachedSession](redis.Redis, redis.SessionCacheKey(sessionID))
+	if err != nil {
+		log.Printf("Redis get session failed: %v", err)
+		return nil, ErrUnauthorized
+	}
+	if cached == nil || cached.UserID == uuid.Nil {
+		return nil, ErrUnauthorized
+	}
+
+	if !sessionServices.IsSessionActive(sessionID) {
+		return nil, ErrUnauthorized
+	}
+	return &AuthContext{UserID: cached.UserID, SessionID: sessionID}, nil
+}
+
+// WatchSession closes the socket as soon as the bound session is revoked, or the access token it authenticated
+// with is revoked, deleted or expires. A failed check is retried on the next tick rather than taken as a revocation.
+func WatchSession(conn *websocket.Conn, auth *AuthContext, done <-chan struct{}) {
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			reason, err := revocationReason(auth)
+			if err != nil {
+				log.Printf("Session check failed, retrying: userID=%s sessionID=%s err=%v", auth.UserID, auth.SessionID, err)
+				continue
+			}
+			if reason == "" {
+				continue
+			}
+			log.Printf("Closing socket, %s: userID=%s sessionID=%s", reason, auth.UserID, auth.SessionID)
+			closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+			_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+// revocationReason tells why the socket may no longer stay open, or "" while its token and session are valid
+func revocationReason(auth *AuthContext) (string, error) {
+	if auth.AccessToken != "" {
+		_, err := access_token.GetActiveToken(auth.AccessToken)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "token revoked", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	active, err := sessionServices.CheckSessionActive(auth.SessionID)
+	if err != nil {
+		return "", err
+	}
+	if !active {
+		return "session revoked", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	goredis "github.com/redis/go-redis/v9"
+	Users "github.com/unarya/univia/internal/api/modules/user/models"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/pkg/types"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// authFixture is a user signed in with an access token bound to a session, and the database and cache
+// the handshake reads them from
+type authFixture struct {
+	mock      sqlmock.Sqlmock
+	userID    uuid.UUID
+	sessionID uuid.UUID
+	token     string
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	gormDB, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: db, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	previousDB, previousCache := mysql.DB, redis.Redis
+	t.Cleanup(func() {
+		mysql.DB, redis.Redis = previousDB, previousCache
+		_ = db.Close()
+	})
+	mysql.DB = gormDB
+	cache := miniredis.RunT(t)
+	redis.Redis = redis.NewRedisCache(goredis.NewClient(&goredis.Options{Addr: cache.Addr()}))
+
+	fixture := &authFixture{mock: mock, userID: uuid.New(), sessionID: uuid.New(), token: "token-" + uuid.NewString()}
+	// VerifyToken answers from its cache, as it does for a token the API used a moment ago
+	if err := redis.Redis.SetJSON("access_token_"+fixture.token, Users.User{ID: fixture.userID}, time.Hour); err != nil {
+		t.Fatalf("caching the token: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("queries: %v", err)
+		}
+	})
+	return fixture
+}
+
+// expectToken expects the token record to be read, bound to sessionID or to no session when it is nil
+func (f *authFixture) expectToken(sessionID *uuid.UUID) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "session_id", "token", "status"})
+	if sessionID != nil {
+		rows.AddRow(uuid.NewString(), f.userID.String(), sessionID.String(), f.token, true)
+	} else {
+		rows.AddRow(uuid.NewString(), f.userID.String(), nil, f.token, true)
+	}
+	f.mock.ExpectQuery("SELECT \\* FROM `access_tokens` WHERE token = \\? AND status = true AND expires_at > \\?").
+		WithArgs(f.token, sqlmock.AnyArg(), 1).
+		WillReturnRows(rows)
+}
+
+// expectNoToken expects the token to be found revoked, deleted or expired
+func (f *authFixture) expectNoToken() {
+	f.mock.ExpectQuery("SELECT \\* FROM `access_tokens` WHERE token = \\?").
+		WithArgs(f.token, sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func (f *authFixture) expectSession(status string) {
+	rows := sqlmock.NewRows([]string{"status", "revoked_at"})
+	if status == "revoked" {
+		rows.AddRow(status, time.Now())
+	} else {
+		rows.AddRow(status, nil)
+	}
+	f.mock.ExpectQuery("SELECT `status`,`revoked_at` FROM `user_sessions` WHERE session_id = \\?").
+		WithArgs(f.sessionID, 1).
+		WillReturnRows(rows)
+}
+
+func TestExtractCredentials(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		protocol string
+		query    string
+		want     Credentials
+	}{
+		{"token subprotocol", "access_token, abc", "", Credentials{AccessToken: "abc"}},
+		{"session subprotocol", "session_id, 123", "", Credentials{SessionID: "123"}},
+		{"sfu subprotocol", "sfu, secret", "", Credentials{SFUSecret: "secret"}},
+		{"token query", "", "token=abc", Credentials{AccessToken: "abc"}},
+		{"session query", "", "session_id=123", Credentials{SessionID: "123"}},
+		{"subprotocol over query", "access_token, abc", "token=other", Credentials{AccessToken: "abc"}},
+		{"unknown subprotocol", "chat, abc", "", Credentials{}},
+		{"nothing", "", "", Credentials{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/ws?"+test.query, nil)
+			if test.protocol != "" {
+				request.Header.Set("Sec-WebSocket-Protocol", test.protocol)
+			}
+			if got := ExtractCredentials(request); got != test.want {
+				t.Fatalf("ExtractCredentials = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// serveSocket upgrades a connection and hands it to handle, returning the client's end
+func serveSocket(t *testing.T, handle func(conn *websocket.Conn)) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}))
+	t.Cleanup(server.Close)
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestReadAuthFrame(t *testing.T) {
+	type result struct {
+		creds Credentials
+		err   error
+	}
+	for _, test := range []struct {
+		name    string
+		frame   types.AuthMessage
+		want    Credentials
+		wantErr bool
+	}{
+		{"token", types.AuthMessage{Type: types.MessageTypeAuth, Token: "abc"}, Credentials{AccessToken: "abc"}, false},
+		{"session", types.AuthMessage{Type: types.MessageTypeAuth, SessionID: "123"}, Credentials{SessionID: "123"}, false},
+		{"not an auth frame", types.AuthMessage{Type: types.MessageTypeJoin, Token: "abc"}, Credentials{}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			results := make(chan result, 1)
+			client := serveSocket(t, func(conn *websocket.Conn) {
+				creds, err := ReadAuthFrame(conn)
+				results <- result{creds, err}
+			})
+			if err := client.WriteJSON(test.frame); err != nil {
+				t.Fatalf("writing the frame: %v", err)
+			}
+			got := <-results
+			if (got.err != nil) != test.wantErr || got.creds != test.want {
+				t.Fatalf("ReadAuthFrame = %+v, %v; want %+v, error %v", got.creds, got.err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateBindsTheTokenSession(t *testing.T) {
+	f := newAuthFixture(t)
+	f.expectToken(&f.sessionID)
+	f.expectSession("active")
+
+	auth, err := Authenticate(Credentials{AccessToken: f.token})
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if auth.UserID != f.userID || auth.SessionID != f.sessionID || auth.AccessToken != f.token {
+		t.Fatalf("authenticated %+v, want user %s in session %s", auth, f.userID, f.sessionID)
+	}
+}
+
+func TestAuthenticateUnboundTokenUsesTheUserSession(t *testing.T) {
+	f := newAuthFixture(t)
+	f.expectToken(nil)
+	f.mock.ExpectQuery("SELECT `session_id` FROM `user_sessions` WHERE user_id = \\?").
+		WithArgs(f.userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(f.sessionID.String()))
+	f.expectSession("active")
+
+	auth, err := Authenticate(Credentials{AccessToken: f.token})
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if auth.SessionID != f.sessionID {
+		t.Fatalf("bound to session %s, want %s", auth.SessionID, f.sessionID)
+	}
+}
+
+func TestAuthenticateRejectsRevokedCredentials(t *testing.T) {
+	t.Run("revoked session", func(t *testing.T) {
+		f := newAuthFixture(t)
+		f.expectToken(&f.sessionID)
+		f.expectSession("revoked")
+		if _, err := Authenticate(Credentials{AccessToken: f.token}); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("err = %v, want ErrUnauthorized", err)
+		}
+	})
+	t.Run("revoked token still cached", func(t *testing.T) {
+		f := newAuthFixture(t)
+		f.expectNoToken()
+		if _, err := Authenticate(Credentials{AccessToken: f.token}); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("err = %v, want ErrUnauthorized", err)
+		}
+	})
+	t.Run("unknown token", func(t *testing.T) {
+		f := newAuthFixture(t)
+		f.mock.ExpectQuery("SELECT \\* FROM `access_tokens` WHERE token = \\? and status = true").
+			WithArgs("unknown", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		if _, err := Authenticate(Credentials{AccessToken: "unknown"}); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("err = %v, want ErrUnauthorized", err)
+		}
+	})
+}
+
+// watch runs WatchSession over a socket authenticated as the fixture's user and returns the client's end
+func (f *authFixture) watch(t *testing.T) *websocket.Conn {
+	t.Helper()
+	previous := sessionCheckInterval
+	sessionCheckInterval = 20 * time.Millisecond
+	t.Cleanup(func() { sessionCheckInterval = previous })
+
+	auth := &AuthContext{UserID: f.userID, SessionID: f.sessionID, AccessToken: f.token}
+	return serveSocket(t, func(conn *websocket.Conn) {
+		done := make(chan struct{})
+		defer close(done)
+		go WatchSession(conn, auth, done)
+		// Keep the connection open until WatchSession closes it
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// expectClosed waits for the server to close the socket with reason
+func expectClosed(t *testing.T, client *websocket.Conn, reason string) {
+	t.Helper()
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reason {
+		t.Fatalf("socket ended with %v, want a policy violation close: %s", err, reason)
+	}
+}
+
+func TestWatchSessionClosesRevokedSession(t *testing.T) {
+	f := newAuthFixture(t)
+	f.expectToken(&f.sessionID)
+	f.expectSession("active")
+	f.expectToken(&f.sessionID)
+	f.expectSession("revoked")
+
+	expectClosed(t, f.watch(t), "session revoked")
+}
+
+func TestWatchSessionClosesRevokedToken(t *testing.T) {
+	// A token deleted on refresh or logout, or expired, ends the socket it opened
+	f := newAuthFixture(t)
+	f.expectNoToken()
+
+	expectClosed(t, f.watch(t), "token revoked")
+}
+
+func TestWatchSessionRetriesFailedChecks(t *testing.T) {
+	// The database being unreachable for a moment is not a revocation
+	f := newAuthFixture(t)
+	f.mock.ExpectQuery("SELECT \\* FROM `access_tokens`").WillReturnError(errors.New("connection refused"))
+	f.expectToken(&f.sessionID)
+	f.mock.ExpectQuery("SELECT `status`,`revoked_at` FROM `user_sessions`").WillReturnError(errors.New("connection refused"))
+	f.expectToken(&f.sessionID)
+	f.expectSession("revoked")
+
+	expectClosed(t, f.watch(t), "session revoked")
+}
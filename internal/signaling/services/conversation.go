@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/internal/api/functions"
+	messages "github.com/unarya/univia/internal/api/modules/message/services"
+	"github.com/unarya/univia/internal/infrastructure/mysql"
+	"github.com/unarya/univia/internal/signaling/store"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// HandleTyping relays typing-start and typing-stop to the other members of the conversation in RoomID.
+// Typing is ephemeral: members who are offline never get it.
+func HandleTyping(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	conversationID, err := uuid.Parse(msg.RoomID)
+	if err != nil {
+		return sendError(conn, msg.RoomID, fmt.Errorf("invalid conversation id"))
+	}
+
+	if msg.Type == types.MessageTypeTypingStop {
+		if store.StopTyping(userID, conversationID) {
+			broadcastTyping(userID, conversationID, types.MessageTypeTypingStop)
+		}
+		return nil
+	}
+
+	if _, serviceErr := functions.GetConversationParticipant(mysql.DB, conversationID, userID); serviceErr != nil {
+		return sendError(conn, msg.RoomID, fmt.Errorf("%s", serviceErr.Message))
+	}
+	if store.StartTyping(userID, conversationID) {
+		broadcastTyping(userID, conversationID, types.MessageTypeTypingStart)
+	}
+	return nil
+}
+
+// HandleRead moves the sender's read marker in the conversation in RoomID; the other members get a
+// read receipt through the notifications topic
+func HandleRead(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	conversationID, err := uuid.Parse(msg.RoomID)
+	if err != nil {
+		return sendError(conn, msg.RoomID, fmt.Errorf("invalid conversation id"))
+	}
+	var marker types.ReadMarker
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &marker); err != nil {
+			return sendError(conn, msg.RoomID, fmt.Errorf("invalid read payload"))
+		}
+	}
+
+	// Reading a conversation ends typing in it
+	if store.StopTyping(userID, conversationID) {
+		broadcastTyping(userID, conversationID, types.MessageTypeTypingStop)
+	}
+
+	lastReadSeq, serviceErr := messages.MarkConversationRead(userID, conversationID, marker.Seq)
+	if serviceErr != nil {
+		return sendError(conn, msg.RoomID, fmt.Errorf("%s", serviceErr.Message))
+	}
+	payload, err := json.Marshal(types.ReadMarker{Seq: lastReadSeq})
+	if err != nil {
+		return err
+	}
+	return sendJSONMessage(conn, websocket.TextMessage, types.WebSocketMessage{
+		Type:    types.MessageTypeRead,
+		RoomID:  msg.RoomID,
+		Payload: payload,
+	})
+}
+
+func broadcastTyping(userID, conversationID uuid.UUID, wsType string) {
+	peers, err := functions.SelectConversationPeers(mysql.DB, conversationID, userID)
+	if err != nil {
+		log.Printf("[Typing] Failed to get members of %s: %v", conversationID, err)
+		return
+	}
+	update := types.TypingUpdate{ConversationID: conversationID.String(), UserID: userID.String()}
+	if wsType == types.MessageTypeTypingStart {
+		update.ExpiresIn = int(store.TypingTTL.Seconds())
+	}
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+	for _, peerID := range peers {
+		_ = SendMessageToUser(peerID, types.WebSocketMessage{
+			Type:       wsType,
+			RoomID:     conversationID.String(),
+			SenderID:   userID.String(),
+			ReceiverID: peerID.String(),
+			Payload:    payload,
+		})
+	}
+}
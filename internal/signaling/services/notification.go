@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	kafkaGo "github.com/segmentio/kafka-go"
+	"github.com/unarya/univia/internal/infrastructure/kafka"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/internal/signaling/cluster"
+	"github.com/unarya/univia/internal/signaling/store"
+	"github.com/unarya/univia/pkg/types"
+)
+
+const (
+	NotificationsConsumerGroup = "signaling-notifications"
+
+	// Offline buffer per user, replayed on the next connection
+	PendingNotificationsTTL   = 7 * 24 * time.Hour
+	MaxPendingNotifications   = 200
+	notificationRetryInterval = 2 * time.Second
+
+	// DedupKeyHeader is set by the API's outbox relay; handled keys are remembered for a day
+	DedupKeyHeader  = "dedup-key"
+	handledEventTTL = 24 * time.Hour
+)
+
+func PendingNotificationsKey(userID uuid.UUID) string {
+	return fmt.Sprintf("signaling:pending:%s", userID)
+}
+
+// ConsumeNotifications pushes events from the notifications topic to their receivers until ctx is cancelled.
+// A message is committed once it was delivered or buffered; until then it is retried.
+func ConsumeNotifications(ctx context.Context) {
+	reader := kafka.NewConsumer(kafka.NotificationsTopic, NotificationsConsumerGroup)
+	defer reader.Close()
+	log.Printf("[Notifications] Consuming %s as %s", kafka.NotificationsTopic, NotificationsConsumerGroup)
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[Notifications] Fetch failed: %v", err)
+			if !sleepContext(ctx, notificationRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		for {
+			err := handleNotification(msg)
+			if err == nil {
+				break
+			}
+			log.Printf("[Notifications] Offset %d not handled, retrying: %v", msg.Offset, err)
+			if !sleepContext(ctx, notificationRetryInterval) {
+				return
+			}
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			log.Printf("[Notifications] Commit of offset %d failed: %v", msg.Offset, err)
+		}
+	}
+}
+
+// handleNotification returns an error only when the event must be retried
+func handleNotification(msg kafkaGo.Message) error {
+	var event types.WebSocketMessage
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		log.Printf("[Notifications] Skipping undecodable message at offset %d", msg.Offset)
+		return nil
+	}
+	if event.ReceiverID == "" && len(event.Recipients) == 0 {
+		log.Printf("[Notifications] Skipping message without receiver at offset %d", msg.Offset)
+		return nil
+	}
+
+	// The outbox relay publishes at least once; skip events already handled
+	dedupKey := headerValue(msg, DedupKeyHeader)
+	if dedupKey != "" && alreadyHandled(dedupKey) {
+		return nil
+	}
+
+	if len(event.Recipients) == 0 {
+		receiverID, err := uuid.Parse(event.ReceiverID)
+		if err != nil {
+			log.Printf("[Notifications] Skipping message with invalid receiver at offset %d", msg.Offset)
+			return nil
+		}
+		if err := deliverNotification(receiverID, msg.Value); err != nil {
+			return err
+		}
+	} else {
+		// Conversation events list every member to reach; each gets a copy addressed to them.
+		// A retry may deliver again to members already reached, clients drop repeats by seq.
+		for _, recipient := range event.Recipients {
+			receiverID, err := uuid.Parse(recipient)
+			if err != nil {
+				continue
+			}
+			single := event
+			single.ReceiverID = recipient
+			single.Recipients = nil
+			data, err := json.Marshal(single)
+			if err != nil {
+				return err
+			}
+			if err := deliverNotification(receiverID, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dedupKey != "" {
+		markHandled(dedupKey)
+	}
+	return nil
+}
+
+// deliverNotification sends the event to the receiver's socket, or buffers it until they connect.
+// An event routed to another node is buffered there if the socket is gone when it arrives.
+func deliverNotification(receiverID uuid.UUID, data []byte) error {
+	if conn, exists := store.GetUserSocket(receiverID); exists {
+		if err := store.WriteMessage(conn, websocket.TextMessage, data); err == nil {
+			return nil
+		}
+	} else if node := cluster.Current(); node != nil {
+		if err := node.RouteDurable(receiverID, data); err == nil {
+			return nil
+		}
+	}
+	return bufferNotification(receiverID, data)
+}
+
+func headerValue(msg kafkaGo.Message, key string) string {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func alreadyHandled(dedupKey string) bool {
+	if redis.Redis == nil {
+		return false
+	}
+	exists, err := redis.Redis.Client().Exists(redis.Ctx, handledEventKey(dedupKey)).Result()
+	return err == nil && exists > 0
+}
+
+func markHandled(dedupKey string) {
+	if redis.Redis == nil {
+		return
+	}
+	if err := redis.Redis.Client().Set(redis.Ctx, handledEventKey(dedupKey), 1, handledEventTTL).Err(); err != nil {
+		log.Printf("[Notifications] Failed to record %s as handled: %v", dedupKey, err)
+	}
+}
+
+func handledEventKey(dedupKey string) string {
+	return fmt.Sprintf("signaling:handled:%s", dedupKey)
+}
+
+// bufferNotification keeps an event for the user's next connection
+func bufferNotification(userID uuid.UUID, data []byte) error {
+	if redis.Redis == nil {
+		return errors.New("redis unavailable")
+	}
+	key := PendingNotificationsKey(userID)
+	pipe := redis.Redis.Client().TxPipeline()
+	pipe.RPush(redis.Ctx, key, data)
+	pipe.LTrim(redis.Ctx, key, -MaxPendingNotifications, -1)
+	pipe.Expire(redis.Ctx, key, PendingNotificationsTTL)
+	if _, err := pipe.Exec(redis.Ctx); err != nil {
+		return err
+	}
+	// The user may have connected here since the event could not be written
+	if _, connected := store.GetUserSocket(userID); connected {
+		ReplayPendingNotifications(userID)
+	}
+	return nil
+}
+
+// ReplayPendingNotifications sends the events buffered while the user was offline to their socket on this node
+func ReplayPendingNotifications(userID uuid.UUID) {
+	if redis.Redis == nil {
+		return
+	}
+	conn, exists := store.GetUserSocket(userID)
+	if !exists {
+		return
+	}
+
+	key := PendingNotificationsKey(userID)
+	for {
+		data, err := redis.Redis.Client().LPop(redis.Ctx, key).Bytes()
+		if err != nil {
+			// redis.Nil once the buffer is drained
+			return
+		}
+		if err := store.WriteMessage(conn, websocket.TextMessage, data); err != nil {
+			// Put it back for the next connection
+			redis.Redis.Client().LPush(redis.Ctx, key, data)
+			log.Printf("[Notifications] Replay to %s interrupted: %v", userID, err)
+			return
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/internal/api/functions"
+	sessionServices "github.com/unarya/univia/internal/api/modules/session/services"
+	"github.com/unarya/univia/internal/signaling/store"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// MaxPresenceSubscriptions caps how many users one presence-subscribe may watch
+const MaxPresenceSubscriptions = 200
+
+// GoOnline marks a freshly connected user online and tells their watchers
+func GoOnline(auth *AuthContext) {
+	setPresence(auth, types.PresenceOnline)
+}
+
+// GoOffline is called when the user's socket closes: it persists the last-seen time, stops their typing
+// indicators, drops their subscriptions and tells their watchers
+func GoOffline(auth *AuthContext) {
+	for _, conversationID := range store.StopAllTyping(auth.UserID) {
+		broadcastTyping(auth.UserID, conversationID, types.MessageTypeTypingStop)
+	}
+	if err := store.ClearPresenceWatches(auth.UserID); err != nil {
+		log.Printf("[Presence] Failed to clear subscriptions of %s: %v", auth.UserID, err)
+	}
+	setPresence(auth, types.PresenceOffline)
+}
+
+// HandlePresence lets a client switch between online and away
+func HandlePresence(conn *websocket.Conn, auth *AuthContext, msg types.WebSocketMessage) error {
+	if msg.Message != types.PresenceOnline && msg.Message != types.PresenceAway {
+		return sendError(conn, "", fmt.Errorf("presence must be %q or %q", types.PresenceOnline, types.PresenceAway))
+	}
+	setPresence(auth, msg.Message)
+	return nil
+}
+
+// HandlePresenceSubscribe watches the presence of the given friends and followed users, and answers with their
+// current status. Users the sender may not watch are silently left out.
+func HandlePresenceSubscribe(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	requested, err := parseSubscription(msg)
+	if err != nil {
+		return sendError(conn, "", err)
+	}
+	visible, serviceErr := functions.SelectPresenceVisible(userID, requested)
+	if serviceErr != nil {
+		return sendError(conn, "", fmt.Errorf("%s", serviceErr.Message))
+	}
+	if err := store.AddPresenceWatches(userID, visible); err != nil {
+		return sendError(conn, "", err)
+	}
+
+	snapshot, err := json.Marshal(presenceSnapshot(visible))
+	if err != nil {
+		return err
+	}
+	return sendJSONMessage(conn, websocket.TextMessage, types.WebSocketMessage{
+		Type:    types.MessageTypePresenceSnapshot,
+		Payload: snapshot,
+	})
+}
+
+// HandlePresenceUnsubscribe stops watching the given users
+func HandlePresenceUnsubscribe(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	targets, err := parseSubscription(msg)
+	if err != nil {
+		return sendError(conn, "", err)
+	}
+	if err := store.RemovePresenceWatches(userID, targets); err != nil {
+		return sendError(conn, "", err)
+	}
+	return nil
+}
+
+// setPresence stores the status, persists the last-seen time on the session and notifies the watchers
+func setPresence(auth *AuthContext, status string) {
+	now := time.Now()
+	if err := store.SetPresenceStatus(auth.UserID, status, now); err != nil {
+		log.Printf("[Presence] Failed to store status of %s: %v", auth.UserID, err)
+	}
+	if err := sessionServices.TouchLastActive(auth.SessionID, now); err != nil {
+		log.Printf("[Presence] Failed to persist last activity of %s: %v", auth.UserID, err)
+	}
+
+	watchers, err := store.GetPresenceWatchers(auth.UserID)
+	if err != nil {
+		log.Printf("[Presence] Failed to get watchers of %s: %v", auth.UserID, err)
+		return
+	}
+	if len(watchers) == 0 {
+		return
+	}
+	payload, err := json.Marshal(types.PresenceUpdate{UserID: auth.UserID.String(), Status: status, LastSeen: &now})
+	if err != nil {
+		return
+	}
+	for _, watcherID := range watchers {
+		// Presence is not buffered: an offline watcher gets a fresh snapshot when subscribing again
+		_ = SendMessageToUser(watcherID, types.WebSocketMessage{
+			Type:       types.MessageTypePresence,
+			SenderID:   auth.UserID.String(),
+			ReceiverID: watcherID.String(),
+			Payload:    payload,
+		})
+	}
+}
+
+// presenceSnapshot resolves the status of each user. A user whose node died without closing their socket
+// has no presence in the cluster and shows offline; users never seen on signaling fall back to their
+// session's last activity.
+func presenceSnapshot(userIDs []uuid.UUID) []types.PresenceUpdate {
+	snapshot := make([]types.PresenceUpdate, 0, len(userIDs))
+	if len(userIDs) == 0 {
+		return snapshot
+	}
+	statuses, err := store.GetPresenceStatuses(userIDs)
+	if err != nil {
+		log.Printf("[Presence] Failed to get statuses: %v", err)
+		statuses = map[uuid.UUID]store.PresenceStatus{}
+	}
+
+	var unseen []uuid.UUID
+	for _, userID := range userIDs {
+		if _, exists := statuses[userID]; !exists {
+			unseen = append(unseen, userID)
+		}
+	}
+	lastActive, err := sessionServices.GetLastActiveByUserIDs(unseen)
+	if err != nil {
+		log.Printf("[Presence] Failed to get last activity: %v", err)
+	}
+
+	for _, userID := range userIDs {
+		update := types.PresenceUpdate{UserID: userID.String(), Status: types.PresenceOffline}
+		if status, exists := statuses[userID]; exists {
+			update.LastSeen = status.LastSeen
+			if status.Status != types.PresenceOffline && IsUserOnline(userID) {
+				update.Status = status.Status
+			}
+		} else if at, exists := lastActive[userID]; exists {
+			update.LastSeen = &at
+		}
+		snapshot = append(snapshot, update)
+	}
+	return snapshot
+}
+
+func parseSubscription(msg types.WebSocketMessage) ([]uuid.UUID, error) {
+	var subscription types.PresenceSubscription
+	if err := json.Unmarshal(msg.Payload, &subscription); err != nil {
+		return nil, fmt.Errorf("%s requires a payload with userIds", msg.Type)
+	}
+	if len(subscription.UserIDs) > MaxPresenceSubscriptions {
+		return nil, fmt.Errorf("at most %d users per %s", MaxPresenceSubscriptions, msg.Type)
+	}
+	userIDs := make([]uuid.UUID, 0, len(subscription.UserIDs))
+	for _, raw := range subscription.UserIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q", raw)
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/internal/signaling/store"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// HandleJoin adds the sender to a room, answers with a snapshot and tells the others
+func HandleJoin(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	state, participants, err := store.JoinRoom(msg.RoomID, userID)
+	if err != nil && !errors.Is(err, store.ErrAlreadyInRoom) {
+		return sendError(conn, msg.RoomID, err)
+	}
+
+	snapshot, err := buildSnapshot(msg.RoomID, state, participants)
+	if err != nil {
+		return err
+	}
+	if err := sendJSONMessage(conn, websocket.TextMessage, types.WebSocketMessage{
+		Type:    types.MessageTypeJoined,
+		RoomID:  msg.RoomID,
+		Payload: snapshot,
+	}); err != nil {
+		return err
+	}
+
+	broadcastParticipants(msg.RoomID, userID, participants, snapshot)
+	return nil
+}
+
+// HandleLeave removes the sender from a room and tells the remaining participants
+func HandleLeave(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	if err := leaveRoom(msg.RoomID, userID); err != nil {
+		return sendError(conn, msg.RoomID, err)
+	}
+	return nil
+}
+
+// HandleRelay forwards an SDP offer/answer or ICE candidate to one peer in the same room.
+// The SFU is not a room member: participants may address it, and it may address participants.
+func HandleRelay(conn *websocket.Conn, userID uuid.UUID, msg types.WebSocketMessage) error {
+	targetID, err := uuid.Parse(msg.TargetID)
+	if err != nil {
+		return sendError(conn, msg.RoomID, fmt.Errorf("invalid target id"))
+	}
+	senderAllowed := userID == store.SFUUserID || store.IsInRoom(msg.RoomID, userID)
+	targetAllowed := targetID == store.SFUUserID || store.IsInRoom(msg.RoomID, targetID)
+	if !senderAllowed || !targetAllowed {
+		return sendError(conn, msg.RoomID, store.ErrNotInRoom)
+	}
+	if len(msg.Payload) == 0 {
+		return sendError(conn, msg.RoomID, fmt.Errorf("%s requires a payload", msg.Type))
+	}
+
+	forward := types.WebSocketMessage{
+		Type:       msg.Type,
+		RoomID:     msg.RoomID,
+		SenderID:   userID.String(),
+		TargetID:   targetID.String(),
+		ReceiverID: targetID.String(),
+		Payload:    msg.Payload,
+	}
+	if err := SendMessageToUser(targetID, forward); err != nil {
+		return sendError(conn, msg.RoomID, err)
+	}
+	return nil
+}
+
+// LeaveAllRooms is called when a socket closes so peers can tear down their connections
+func LeaveAllRooms(userID uuid.UUID) {
+	for _, roomID := range store.GetUserRooms(userID) {
+		state, participants, err := store.ReleaseRoom(roomID, userID)
+		if err == nil {
+			err = announceLeave(roomID, userID, state, participants)
+		}
+		if err != nil {
+			log.Printf("Failed to leave room %s for user %s: %v", roomID, userID, err)
+		}
+	}
+}
+
+// RoomsReleased tells the participants left in the rooms of a dead signaling node who is gone
+func RoomsReleased(changes []store.RoomChange) {
+	for _, change := range changes {
+		if err := announceLeave(change.RoomID, change.UserID, change.State, change.Participants); err != nil {
+			log.Printf("Failed to announce %s leaving room %s: %v", change.UserID, change.RoomID, err)
+		}
+	}
+}
+
+func leaveRoom(roomID string, userID uuid.UUID) error {
+	state, participants, err := store.LeaveRoom(roomID, userID)
+	if err != nil {
+		return err
+	}
+	return announceLeave(roomID, userID, state, participants)
+}
+
+func announceLeave(roomID string, userID uuid.UUID, state store.RoomState, participants []uuid.UUID) error {
+	snapshot, err := buildSnapshot(roomID, state, participants)
+	if err != nil {
+		return err
+	}
+	broadcastParticipants(roomID, userID, participants, snapshot)
+	return nil
+}
+
+// broadcastParticipants sends the participant list to everyone in the room except the actor
+func broadcastParticipants(roomID string, actorID uuid.UUID, participants []uuid.UUID, snapshot json.RawMessage) {
+	for _, participantID := range participants {
+		if participantID == actorID {
+			continue
+		}
+		update := types.WebSocketMessage{
+			Type:       types.MessageTypeParticipants,
+			RoomID:     roomID,
+			SenderID:   actorID.String(),
+			ReceiverID: participantID.String(),
+			Payload:    snapshot,
+		}
+		if err := SendMessageToUser(participantID, update); err != nil {
+			log.Printf("Failed to send participants update to %s: %v", participantID, err)
+		}
+	}
+
+	// Keep the SFU in sync so it can drop the peers of departed participants
+	if IsUserOnline(store.SFUUserID) {
+		_ = SendMessageToUser(store.SFUUserID, types.WebSocketMessage{
+			Type:     types.MessageTypeParticipants,
+			RoomID:   roomID,
+			SenderID: actorID.String(),
+			Payload:  snapshot,
+		})
+	}
+}
+
+func buildSnapshot(roomID string, state store.RoomState, participants []uuid.UUID) (json.RawMessage, error) {
+	ids := make([]string, 0, len(participants))
+	for _, id := range participants {
+		ids = append(ids, id.String())
+	}
+	return json.Marshal(types.RoomSnapshot{
+		RoomID:       roomID,
+		State:        string(state),
+		Participants: ids,
+	})
+}
@@ -2,11 +2,13 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/internal/signaling/cluster"
 	"github.com/unarya/univia/internal/signaling/store"
 	"github.com/unarya/univia/pkg/types"
 )
@@ -14,10 +16,27 @@ import (
 // WebSocketMessage represents the JSON message format
 
 // HandleMessage processes incoming WebSocket messages
-func HandleMessage(conn *websocket.Conn, messageType int, wsMessage types.WebSocketMessage) error {
+func HandleMessage(conn *websocket.Conn, auth *AuthContext, messageType int, wsMessage types.WebSocketMessage) error {
 	var response types.WebSocketMessage
+	userID := auth.UserID
 
 	switch wsMessage.Type {
+	case types.MessageTypeJoin:
+		return HandleJoin(conn, userID, wsMessage)
+	case types.MessageTypeLeave:
+		return HandleLeave(conn, userID, wsMessage)
+	case types.MessageTypeOffer, types.MessageTypeAnswer, types.MessageTypeIceCandidate, types.MessageTypeLayer:
+		return HandleRelay(conn, userID, wsMessage)
+	case types.MessageTypeTypingStart, types.MessageTypeTypingStop:
+		return HandleTyping(conn, userID, wsMessage)
+	case types.MessageTypeRead:
+		return HandleRead(conn, userID, wsMessage)
+	case types.MessageTypePresence:
+		return HandlePresence(conn, auth, wsMessage)
+	case types.MessageTypePresenceSubscribe:
+		return HandlePresenceSubscribe(conn, userID, wsMessage)
+	case types.MessageTypePresenceUnsubscribe:
+		return HandlePresenceUnsubscribe(conn, userID, wsMessage)
 	case "notice":
 		response = types.WebSocketMessage{
 			Type:    "notice",
@@ -54,20 +73,76 @@ func sendJSONMessage(conn *websocket.Conn, messageType int, response types.WebSo
 	if err != nil {
 		return err
 	}
-	return conn.WriteMessage(messageType, responseJSON)
+	return store.WriteMessage(conn, messageType, responseJSON)
 }
 
-// SendMessageToUser is a function using socket to send message
+// sendError reports a protocol error back to the client that caused it
+func sendError(conn *websocket.Conn, roomID string, err error) error {
+	return sendJSONMessage(conn, websocket.TextMessage, types.WebSocketMessage{
+		Type:    types.MessageTypeError,
+		Message: err.Error(),
+		RoomID:  roomID,
+	})
+}
+
+// SendMessageToUser is a function using socket to send message.
+// Users connected to another signaling replica are reached through the cluster.
 func SendMessageToUser(userID uuid.UUID, message types.WebSocketMessage) error {
-	conn, exists := store.GetUserSocket(userID)
-	if !exists {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if conn, exists := store.GetUserSocket(userID); exists {
+		return store.WriteMessage(conn, websocket.TextMessage, data)
+	}
+	node := cluster.Current()
+	if node == nil {
 		return fmt.Errorf("user %s not connected", userID)
 	}
+	if err := node.Route(userID, data); err != nil {
+		if errors.Is(err, cluster.ErrUserOffline) {
+			return fmt.Errorf("user %s not connected", userID)
+		}
+		return err
+	}
+	return nil
+}
 
-	data, err := json.Marshal(message)
-	if err != nil {
+// DeliverLocal writes a message routed from another node to the user's socket on this node.
+// A durable message that cannot be written is buffered for the user's next connection instead.
+func DeliverLocal(userID uuid.UUID, data []byte, durable bool) error {
+	err := writeLocal(userID, data)
+	if err == nil || !durable {
 		return err
 	}
+	if bufferErr := bufferNotification(userID, data); bufferErr != nil {
+		return fmt.Errorf("%v, and buffering failed: %w", err, bufferErr)
+	}
+	return nil
+}
+
+func writeLocal(userID uuid.UUID, data []byte) error {
+	conn, exists := store.GetUserSocket(userID)
+	if !exists {
+		// The socket closed while the message was in flight; make sure presence no longer points here
+		if node := cluster.Current(); node != nil {
+			_ = node.Unregister(userID)
+		}
+		return fmt.Errorf("user %s not connected", userID)
+	}
+	return store.WriteMessage(conn, websocket.TextMessage, data)
+}
 
-	return conn.WriteMessage(websocket.TextMessage, data)
+// IsUserOnline reports whether the user has a socket on this or any other node
+func IsUserOnline(userID uuid.UUID) bool {
+	if _, exists := store.GetUserSocket(userID); exists {
+		return true
+	}
+	node := cluster.Current()
+	if node == nil {
+		return false
+	}
+	_, err := node.Locate(userID)
+	return err == nil
 }
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+)
+
+// Presence status and watch lists are shared by all signaling nodes.
+//
+//	signaling:status:<userID>     hash {status, last_seen}
+//	signaling:watchers:<userID>   users subscribed to userID's presence
+//	signaling:watching:<userID>   users userID is subscribed to, cleared when their socket closes
+const (
+	// WatchTTL bounds watch lists left behind by a node that died before its sockets closed
+	WatchTTL = 24 * time.Hour
+)
+
+var ErrPresenceUnavailable = errors.New("presence store unavailable")
+
+type PresenceStatus struct {
+	Status   string
+	LastSeen *time.Time
+}
+
+func statusKey(userID uuid.UUID) string {
+	return fmt.Sprintf("signaling:status:%s", userID)
+}
+
+func watchersKey(userID uuid.UUID) string {
+	return fmt.Sprintf("signaling:watchers:%s", userID)
+}
+
+func watchingKey(userID uuid.UUID) string {
+	return fmt.Sprintf("signaling:watching:%s", userID)
+}
+
+func presenceClient() (*goredis.Client, error) {
+	if redis.Redis == nil {
+		return nil, ErrPresenceUnavailable
+	}
+	return redis.Redis.Client(), nil
+}
+
+// SetPresenceStatus records the user's status; lastSeen is kept as the moment they were last active
+func SetPresenceStatus(userID uuid.UUID, status string, lastSeen time.Time) error {
+	client, err := presenceClient()
+	if err != nil {
+		return err
+	}
+	return client.HSet(redis.Ctx, statusKey(userID),
+		"status", status,
+		"last_seen", lastSeen.UTC().Format(time.RFC3339),
+	).Err()
+}
+
+// GetPresenceStatuses returns the stored status of each user; users never seen are left out
+func GetPresenceStatuses(userIDs []uuid.UUID) (map[uuid.UUID]PresenceStatus, error) {
+	client, err := presenceClient()
+	if err != nil {
+		return nil, err
+	}
+	pipe := client.Pipeline()
+	commands := make([]*goredis.MapStringStringCmd, len(userIDs))
+	for i, userID := range userIDs {
+		commands[i] = pipe.HGetAll(redis.Ctx, statusKey(userID))
+	}
+	if _, err := pipe.Exec(redis.Ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+
+	statuses := make(map[uuid.UUID]PresenceStatus, len(userIDs))
+	for i, userID := range userIDs {
+		fields := commands[i].Val()
+		if len(fields) == 0 {
+			continue
+		}
+		status := PresenceStatus{Status: fields["status"]}
+		if lastSeen, err := time.Parse(time.RFC3339, fields["last_seen"]); err == nil {
+			status.LastSeen = &lastSeen
+		}
+		statuses[userID] = status
+	}
+	return statuses, nil
+}
+
+// AddPresenceWatches subscribes watcherID to the presence of targets
+func AddPresenceWatches(watcherID uuid.UUID, targets []uuid.UUID) error {
+	client, err := presenceClient()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	pipe := client.TxPipeline()
+	members := make([]interface{}, 0, len(targets))
+	for _, target := range targets {
+		pipe.SAdd(redis.Ctx, watchersKey(target), watcherID.String())
+		pipe.Expire(redis.Ctx, watchersKey(target), WatchTTL)
+		members = append(members, target.String())
+	}
+	pipe.SAdd(redis.Ctx, watchingKey(watcherID), members...)
+	pipe.Expire(redis.Ctx, watchingKey(watcherID), WatchTTL)
+	_, err = pipe.Exec(redis.Ctx)
+	return err
+}
+
+// RemovePresenceWatches unsubscribes watcherID from the presence of targets
+func RemovePresenceWatches(watcherID uuid.UUID, targets []uuid.UUID) error {
+	client, err := presenceClient()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	pipe := client.TxPipeline()
+	members := make([]interface{}, 0, len(targets))
+	for _, target := range targets {
+		pipe.SRem(redis.Ctx, watchersKey(target), watcherID.String())
+		members = append(members, target.String())
+	}
+	pipe.SRem(redis.Ctx, watchingKey(watcherID), members...)
+	_, err = pipe.Exec(redis.Ctx)
+	return err
+}
+
+// ClearPresenceWatches drops every subscription of watcherID
+func ClearPresenceWatches(watcherID uuid.UUID) error {
+	client, err := presenceClient()
+	if err != nil {
+		return err
+	}
+	targets, err := client.SMembers(redis.Ctx, watchingKey(watcherID)).Result()
+	if err != nil {
+		return err
+	}
+	pipe := client.TxPipeline()
+	for _, target := range targets {
+		if targetID, err := uuid.Parse(target); err == nil {
+			pipe.SRem(redis.Ctx, watchersKey(targetID), watcherID.String())
+		}
+	}
+	pipe.Del(redis.Ctx, watchingKey(watcherID))
+	_, err = pipe.Exec(redis.Ctx)
+	return err
+}
+
+// GetPresenceWatchers lists the users subscribed to userID's presence
+func GetPresenceWatchers(userID uuid.UUID) ([]uuid.UUID, error) {
+	client, err := presenceClient()
+	if err != nil {
+		return nil, err
+	}
+	members, err := client.SMembers(redis.Ctx, watchersKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	watchers := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		if id, err := uuid.Parse(member); err == nil {
+			watchers = append(watchers, id)
+		}
+	}
+	return watchers, nil
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomState is the lifecycle of a call room
+//
+//	idle -> waiting (first participant) -> active (two or more) -> waiting (back to one) -> closed (empty)
+type RoomState string
+
+const (
+	RoomStateIdle    RoomState = "idle"
+	RoomStateWaiting RoomState = "waiting"
+	RoomStateActive  RoomState = "active"
+	RoomStateClosed  RoomState = "closed"
+
+	// MaxRoomParticipants caps a mesh room; bigger calls go through the SFU
+	MaxRoomParticipants = 16
+)
+
+var (
+	ErrRoomFull       = errors.New("room is full")
+	ErrNotInRoom      = errors.New("user is not in the room")
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrAlreadyInRoom  = errors.New("user already in the room")
+	ErrInvalidRoomKey = errors.New("invalid room id")
+)
+
+type Room struct {
+	ID           string
+	State        RoomState
+	Participants map[uuid.UUID]time.Time // userID -> joined at
+	CreatedAt    time.Time
+}
+
+// SFUUserID is the fixed identity the SFU connects to signaling with
+var SFUUserID = uuid.NewSHA1(uuid.NameSpaceURL, []byte("univia:sfu"))
+
+// Rooms below are those of a single node running without Redis; a cluster keeps them in a RoomRegistry
+var (
+	Rooms     = make(map[string]*Room)
+	UserRooms = make(map[uuid.UUID]map[string]struct{}) // reverse index used on disconnect
+	RoomMutex = sync.RWMutex{}
+)
+
+// roomStateOf is the state of a room with n participants
+func roomStateOf(n int) RoomState {
+	switch {
+	case n == 0:
+		return RoomStateClosed
+	case n == 1:
+		return RoomStateWaiting
+	default:
+		return RoomStateActive
+	}
+}
+
+// transition recomputes the room state from its participant count
+func (r *Room) transition() {
+	r.State = roomStateOf(len(r.Participants))
+}
+
+func (r *Room) participantIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(r.Participants))
+	for id := range r.Participants {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// JoinRoom adds the user to the room, creating it when needed, and returns the state and participants after joining
+func JoinRoom(roomID string, userID uuid.UUID) (RoomState, []uuid.UUID, error) {
+	if roomID == "" {
+		return "", nil, ErrInvalidRoomKey
+	}
+	if registry != nil {
+		return registry.Join(roomID, userID)
+	}
+	RoomMutex.Lock()
+	defer RoomMutex.Unlock()
+
+	room, exists := Rooms[roomID]
+	if !exists {
+		room = &Room{
+			ID:           roomID,
+			State:        RoomStateIdle,
+			Participants: make(map[uuid.UUID]time.Time),
+			CreatedAt:    time.Now(),
+		}
+		Rooms[roomID] = room
+	}
+	if _, joined := room.Participants[userID]; joined {
+		return room.State, room.participantIDs(), ErrAlreadyInRoom
+	}
+	if len(room.Participants) >= MaxRoomParticipants {
+		return room.State, room.participantIDs(), ErrRoomFull
+	}
+
+	room.Participants[userID] = time.Now()
+	room.transition()
+
+	if UserRooms[userID] == nil {
+		UserRooms[userID] = make(map[string]struct{})
+	}
+	UserRooms[userID][roomID] = struct{}{}
+
+	return room.State, room.participantIDs(), nil
+}
+
+// LeaveRoom removes the user and returns the state and remaining participants; empty rooms are dropped
+func LeaveRoom(roomID string, userID uuid.UUID) (RoomState, []uuid.UUID, error) {
+	if registry != nil {
+		return registry.Leave(roomID, userID)
+	}
+	RoomMutex.Lock()
+	defer RoomMutex.Unlock()
+
+	room, exists := Rooms[roomID]
+	if !exists {
+		return "", nil, ErrRoomNotFound
+	}
+	if _, joined := room.Participants[userID]; !joined {
+		return room.State, room.participantIDs(), ErrNotInRoom
+	}
+
+	delete(room.Participants, userID)
+	room.transition()
+	if room.State == RoomStateClosed {
+		delete(Rooms, roomID)
+	}
+
+	if rooms := UserRooms[userID]; rooms != nil {
+		delete(rooms, roomID)
+		if len(rooms) == 0 {
+			delete(UserRooms, userID)
+		}
+	}
+
+	return room.State, room.participantIDs(), nil
+}
+
+// ReleaseRoom is LeaveRoom for a socket that closed: a membership another node took over since the user
+// reconnected there is kept
+func ReleaseRoom(roomID string, userID uuid.UUID) (RoomState, []uuid.UUID, error) {
+	if registry != nil {
+		return registry.leave(roomID, userID, registry.nodeID)
+	}
+	return LeaveRoom(roomID, userID)
+}
+
+// GetUserRooms lists the rooms the user is currently in through this node
+func GetUserRooms(userID uuid.UUID) []string {
+	if registry != nil {
+		rooms, err := registry.UserRooms(userID)
+		if err != nil {
+			log.Printf("Failed to list the rooms of %s: %v", userID, err)
+		}
+		return rooms
+	}
+	RoomMutex.RLock()
+	defer RoomMutex.RUnlock()
+
+	rooms := make([]string, 0, len(UserRooms[userID]))
+	for roomID := range UserRooms[userID] {
+		rooms = append(rooms, roomID)
+	}
+	return rooms
+}
+
+// IsInRoom reports whether the user is a participant of the room
+func IsInRoom(roomID string, userID uuid.UUID) bool {
+	if registry != nil {
+		joined, err := registry.IsMember(roomID, userID)
+		if err != nil {
+			log.Printf("Failed to check membership of room %s: %v", roomID, err)
+		}
+		return joined
+	}
+	RoomMutex.RLock()
+	defer RoomMutex.RUnlock()
+
+	room, exists := Rooms[roomID]
+	if !exists {
+		return false
+	}
+	_, joined := room.Participants[userID]
+	return joined
+}
+
+// GetRoomSnapshot returns the current state and participants of the room
+func GetRoomSnapshot(roomID string) (RoomState, []uuid.UUID, bool) {
+	if registry != nil {
+		participants, err := registry.Participants(roomID)
+		if err != nil {
+			log.Printf("Failed to read room %s: %v", roomID, err)
+		}
+		return roomStateOf(len(participants)), participants, len(participants) > 0
+	}
+	RoomMutex.RLock()
+	defer RoomMutex.RUnlock()
+
+	room, exists := Rooms[roomID]
+	if !exists {
+		return RoomStateClosed, nil, false
+	}
+	return room.State, room.participantIDs(), true
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/unarya/univia/internal/infrastructure/redis"
+)
+
+// A cluster of signaling nodes shares its rooms through Redis, so participants connected to different
+// nodes meet in the same room. Every membership names the node holding the participant's socket; the node
+// keeps its memberships alive, and when it dies another node releases them.
+//
+//	signaling:room:<roomID>       hash userID -> "<nodeID>|<joined at, unix ms>", expires unless refreshed
+//	signaling:node:<id>:rooms     set of "<roomID>|<userID>" memberships held by the node
+const (
+	// RoomTTL bounds the rooms of nodes that died without anyone noticing
+	RoomTTL = 60 * time.Second
+)
+
+// joinRoomScript adds the member unless the room is full. A member joining again, after reconnecting to
+// another node, is moved to that node. It returns the outcome and the participants.
+var joinRoomScript = goredis.NewScript(`
+local status = 'ok'
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	status = 'joined'
+elseif redis.call('HLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+	return {'full', redis.call('HKEYS', KEYS[1])}
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('SADD', KEYS[2], ARGV[5])
+return {status, redis.call('HKEYS', KEYS[1])}
+`)
+
+// leaveRoomScript removes the member, only while it is held by the node ARGV[3] when one is given.
+// Redis drops the room with its last member. It returns the outcome and the remaining participants.
+var leaveRoomScript = goredis.NewScript(`
+redis.call('SREM', KEYS[2], ARGV[2])
+local member = redis.call('HGET', KEYS[1], ARGV[1])
+if not member then
+	return {'missing', redis.call('HKEYS', KEYS[1])}
+end
+if ARGV[3] ~= '' and string.sub(member, 1, string.len(ARGV[3]) + 1) ~= ARGV[3] .. '|' then
+	return {'moved', redis.call('HKEYS', KEYS[1])}
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return {'ok', redis.call('HKEYS', KEYS[1])}
+`)
+
+// RoomChange is a participant leaving a room, and who is left in it
+type RoomChange struct {
+	RoomID       string
+	UserID       uuid.UUID
+	State        RoomState
+	Participants []uuid.UUID
+}
+
+// RoomRegistry keeps the rooms of a signaling node in Redis
+type RoomRegistry struct {
+	client *goredis.Client
+	nodeID string
+}
+
+var registry *RoomRegistry
+
+func NewRoomRegistry(client *goredis.Client, nodeID string) *RoomRegistry {
+	return &RoomRegistry{client: client, nodeID: nodeID}
+}
+
+// UseRoomRegistry moves the rooms of this process to Redis, shared with the other nodes
+func UseRoomRegistry(r *RoomRegistry) {
+	registry = r
+}
+
+func roomKey(roomID string) string {
+	return fmt.Sprintf("signaling:room:%s", roomID)
+}
+
+func nodeRoomsKey(nodeID string) string {
+	return fmt.Sprintf("signaling:node:%s:rooms", nodeID)
+}
+
+func membership(roomID string, userID uuid.UUID) string {
+	return roomID + "|" + userID.String()
+}
+
+// parseMembership splits a membership at its last separator, room ids may contain one
+func parseMembership(value string) (string, uuid.UUID, error) {
+	i := strings.LastIndex(value, "|")
+	if i < 0 {
+		return "", uuid.Nil, fmt.Errorf("invalid room membership %q", value)
+	}
+	userID, err := uuid.Parse(value[i+1:])
+	return value[:i], userID, err
+}
+
+// Join adds the user, connected to this node, to the room and returns its state and participants after joining
+func (r *RoomRegistry) Join(roomID string, userID uuid.UUID) (RoomState, []uuid.UUID, error) {
+	member := fmt.Sprintf("%s|%d", r.nodeID, time.Now().UnixMilli())
+	status, participants, err := runRoomScript(r.client, joinRoomScript,
+		[]string{roomKey(roomID), nodeRoomsKey(r.nodeID)},
+		userID.String(), member, MaxRoomParticipants, RoomTTL.Milliseconds(), membership(roomID, userID))
+	if err != nil {
+		return "", nil, err
+	}
+	state := roomStateOf(len(participants))
+	switch status {
+	case "full":
+		return state, participants, ErrRoomFull
+	case "joined":
+		return state, participants, ErrAlreadyInRoom
+	}
+	return state, participants, nil
+}
+
+// Leave removes the user from the room and returns its state and remaining participants
+func (r *RoomRegistry) Leave(roomID string, userID uuid.UUID) (RoomState, []uuid.UUID, error) {
+	return r.leave(roomID, userID, "")
+}
+
+// leave removes the user from the room, only while the node holds their membership when nodeID is set
+func (r *RoomRegistry) leave(roomID string, userID uuid.UUID, nodeID string) (RoomState, []uuid.UUID, error) {
+	holder := nodeID
+	if holder == "" {
+		holder = r.nodeID
+	}
+	status, participants, err := runRoomScript(r.client, leaveRoomScript,
+		[]string{roomKey(roomID), nodeRoomsKey(holder)},
+		userID.String(), membership(roomID, userID), nodeID)
+	if err != nil {
+		return "", nil, err
+	}
+	state := roomStateOf(len(participants))
+	switch status {
+	case "missing":
+		if len(participants) == 0 {
+			return state, nil, ErrRoomNotFound
+		}
+		return state, participants, ErrNotInRoom
+	case "moved":
+		return state, participants, ErrNotInRoom
+	}
+	return state, participants, nil
+}
+
+// UserRooms lists the rooms the user joined through this node
+func (r *RoomRegistry) UserRooms(userID uuid.UUID) ([]string, error) {
+	values, err := r.client.SMembers(redis.Ctx, nodeRoomsKey(r.nodeID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]string, 0)
+	for _, value := range values {
+		roomID, memberID, err := parseMembership(value)
+		if err == nil && memberID == userID {
+			rooms = append(rooms, roomID)
+		}
+	}
+	return rooms, nil
+}
+
+// IsMember reports whether the user is in the room, through any node
+func (r *RoomRegistry) IsMember(roomID string, userID uuid.UUID) (bool, error) {
+	return r.client.HExists(redis.Ctx, roomKey(roomID), userID.String()).Result()
+}
+
+// Participants lists who is in the room, through any node
+func (r *RoomRegistry) Participants(roomID string) ([]uuid.UUID, error) {
+	ids, err := r.client.HKeys(redis.Ctx, roomKey(roomID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseParticipants(ids), nil
+}
+
+// Refresh keeps the rooms with participants on this node from expiring
+func (r *RoomRegistry) Refresh(ctx context.Context) error {
+	values, err := r.client.SMembers(ctx, nodeRoomsKey(r.nodeID)).Result()
+	if err != nil {
+		return err
+	}
+	pipe := r.client.Pipeline()
+	refreshed := make(map[string]bool, len(values))
+	for _, value := range values {
+		roomID, _, err := parseMembership(value)
+		if err != nil || refreshed[roomID] {
+			continue
+		}
+		refreshed[roomID] = true
+		pipe.PExpire(ctx, roomKey(roomID), RoomTTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ReleaseNode removes the memberships held by a node that died or left, except those taken over since by
+// another node, and returns the rooms the participants left
+func (r *RoomRegistry) ReleaseNode(nodeID string) ([]RoomChange, error) {
+	values, err := r.client.SMembers(redis.Ctx, nodeRoomsKey(nodeID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var changes []RoomChange
+	for _, value := range values {
+		roomID, userID, err := parseMembership(value)
+		if err != nil {
+			continue
+		}
+		state, participants, err := r.leave(roomID, userID, nodeID)
+		if err != nil {
+			continue
+		}
+		changes = append(changes, RoomChange{RoomID: roomID, UserID: userID, State: state, Participants: participants})
+	}
+	return changes, r.client.Del(redis.Ctx, nodeRoomsKey(nodeID)).Err()
+}
+
+// runRoomScript runs a room script, which answers with its outcome and the participants of the room
+func runRoomScript(client *goredis.Client, script *goredis.Script, keys []string, args ...interface{}) (string, []uuid.UUID, error) {
+	reply, err := script.Run(redis.Ctx, client, keys, args...).Slice()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(reply) != 2 {
+		return "", nil, fmt.Errorf("unexpected room script reply %v", reply)
+	}
+	status, _ := reply[0].(string)
+	raw, _ := reply[1].([]interface{})
+	ids := make([]string, 0, len(raw))
+	for _, id := range raw {
+		if s, ok := id.(string); ok {
+			ids = append(ids, s)
+		}
+	}
+	return status, parseParticipants(ids), nil
+}
+
+func parseParticipants(ids []string) []uuid.UUID {
+	participants := make([]uuid.UUID, 0, len(ids))
+	for _, raw := range ids {
+		if id, err := uuid.Parse(raw); err == nil {
+			participants = append(participants, id)
+		}
+	}
+	return participants
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TypingTTL is how long a typing-start holds without being repeated
+const TypingTTL = 6 * time.Second
+
+var (
+	// userID -> conversationID -> expiry, for the sockets held by this node
+	typing      = make(map[uuid.UUID]map[uuid.UUID]time.Time)
+	typingMutex = sync.Mutex{}
+)
+
+// StartTyping records that the user is typing in the conversation.
+// It reports false when a start was already sent and has not expired, so repeats are not fanned out again.
+func StartTyping(userID, conversationID uuid.UUID) bool {
+	typingMutex.Lock()
+	defer typingMutex.Unlock()
+	now := time.Now()
+	conversations, exists := typing[userID]
+	if !exists {
+		conversations = make(map[uuid.UUID]time.Time)
+		typing[userID] = conversations
+	}
+	expiry, active := conversations[conversationID]
+	conversations[conversationID] = now.Add(TypingTTL)
+	// Announce again shortly before receivers expire the previous start
+	return !active || expiry.Sub(now) < TypingTTL/2
+}
+
+// StopTyping clears the typing state; it reports whether the user was typing
+func StopTyping(userID, conversationID uuid.UUID) bool {
+	typingMutex.Lock()
+	defer typingMutex.Unlock()
+	conversations, exists := typing[userID]
+	if !exists {
+		return false
+	}
+	expiry, active := conversations[conversationID]
+	delete(conversations, conversationID)
+	if len(conversations) == 0 {
+		delete(typing, userID)
+	}
+	return active && time.Now().Before(expiry)
+}
+
+// StopAllTyping clears every conversation the user is typing in and returns them
+func StopAllTyping(userID uuid.UUID) []uuid.UUID {
+	typingMutex.Lock()
+	defer typingMutex.Unlock()
+	now := time.Now()
+	var active []uuid.UUID
+	for conversationID, expiry := range typing[userID] {
+		if now.Before(expiry) {
+			active = append(active, conversationID)
+		}
+	}
+	delete(typing, userID)
+	return active
+}
@@ -10,6 +10,9 @@ import (
 var (
 	UserSocketMap = make(map[uuid.UUID]*websocket.Conn)
 	MapMutex      = sync.RWMutex{} // processing concurrent map access
+
+	// gorilla/websocket allows a single concurrent writer per connection
+	writeLocks = sync.Map{} // *websocket.Conn -> *sync.Mutex
 )
 
 func SetUserSocket(userID uuid.UUID, conn *websocket.Conn) {
@@ -28,5 +31,30 @@ func GetUserSocket(userID uuid.UUID) (*websocket.Conn, bool) {
 func RemoveUserSocket(userID uuid.UUID) {
 	MapMutex.Lock()
 	defer MapMutex.Unlock()
+	if conn, exists := UserSocketMap[userID]; exists {
+		writeLocks.Delete(conn)
+	}
+	delete(UserSocketMap, userID)
+}
+
+// RemoveUserSocketConn removes the mapping only if it still points at conn,
+// so a stale connection closing does not evict the user's newer one
+func RemoveUserSocketConn(userID uuid.UUID, conn *websocket.Conn) bool {
+	MapMutex.Lock()
+	defer MapMutex.Unlock()
+	writeLocks.Delete(conn)
+	if current, exists := UserSocketMap[userID]; !exists || current != conn {
+		return false
+	}
 	delete(UserSocketMap, userID)
+	return true
+}
+
+// WriteMessage serializes writes to the connection
+func WriteMessage(conn *websocket.Conn, messageType int, data []byte) error {
+	lock, _ := writeLocks.LoadOrStore(conn, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+	return conn.WriteMessage(messageType, data)
 }
@@ -0,0 +1,213 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWEBP = "webp"
+
+	// MaxPixels rejects images that would take too much memory to decode
+	MaxPixels = 50_000_000
+	// JPEGQuality is used for re-encoded originals and variants
+	JPEGQuality = 85
+)
+
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Decode reads an image and turns it upright according to its EXIF orientation.
+// It returns the format the image was stored in.
+func Decode(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == FormatJPEG {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+	return img, format, nil
+}
+
+// OutputFormat is the format an image decoded from sourceFormat is re-encoded to.
+// JPEG and PNG keep their format, anything else becomes PNG if it is transparent and JPEG otherwise.
+func OutputFormat(img image.Image, sourceFormat string) string {
+	switch sourceFormat {
+	case FormatJPEG, FormatPNG:
+		return sourceFormat
+	}
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		return FormatPNG
+	}
+	return FormatJPEG
+}
+
+// Encode writes img in format without any of the metadata the source carried
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	}
+	return fmt.Errorf("cannot encode %s images", format)
+}
+
+// ContentType returns the MIME type of format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Extension returns the file extension of format
+func Extension(format string) string {
+	if format == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// Resize scales img to width, keeping its aspect ratio
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := max(1, (bounds.Dy()*width+bounds.Dx()/2)/bounds.Dx())
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Blurhash returns a compact placeholder clients can render while the image loads
+func Blurhash(img image.Image) (string, error) {
+	// The hash only keeps a few components, so a small copy gives the same result much faster
+	if img.Bounds().Dx() > 64 {
+		img = Resize(img, 64)
+	}
+	return blurhash.Encode(4, 3, img)
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 1 when it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// Start of scan: the metadata segments are all behind us
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag of the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation flips and rotates img so that EXIF orientation o displays upright
+func applyOrientation(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dw, dh := w, h
+	// Orientations 5 to 8 swap width and height
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // turn 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // turn 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // turn 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			i, j := src.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+		}
+	}
+	return dst
+}
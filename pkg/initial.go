@@ -1,25 +1,51 @@
 package pkg
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
+	"github.com/unarya/univia/internal/api/modules/outbox/services"
+	posts "github.com/unarya/univia/internal/api/modules/post/services"
+	search "github.com/unarya/univia/internal/api/modules/search/services"
 	"github.com/unarya/univia/internal/api/routes"
+	"github.com/unarya/univia/internal/infrastructure/antivirus"
 	"github.com/unarya/univia/internal/infrastructure/kafka"
-	"github.com/unarya/univia/internal/infrastructure/minio"
 	"github.com/unarya/univia/internal/infrastructure/mysql"
 	"github.com/unarya/univia/internal/infrastructure/redis"
+	searchIndex "github.com/unarya/univia/internal/infrastructure/search"
+	"github.com/unarya/univia/internal/infrastructure/storage"
 )
 
 func InitInfrastructure() {
 	mysql.ConnectDatabase()
 	kafka.InitKafkaProducer()
-	minio.ConnectMinio()
+	storage.ConnectStorage()
+	antivirus.ConnectScanner()
 	redis.ConnectRedis()
+	searchIndex.ConnectSearch(mysql.DB)
+}
+
+// StartWorkers runs the API's background jobs until ctx is cancelled
+func StartWorkers(ctx context.Context) {
+	go outbox.StartRelay(ctx)
+	go posts.StartPostPurge(ctx)
+	go posts.StartUploadCleanup(ctx)
+	go posts.StartMediaProcessing(ctx)
+	go posts.StartFeedFanout(ctx)
+	go posts.StartPostScheduler(ctx)
+	go search.StartIndexer(ctx)
 }
 
 func ConnectRedis() {
 	redis.ConnectRedis()
 }
 
+// InitSignalingInfrastructure connects what the signaling server needs to authenticate sockets
+func InitSignalingInfrastructure() {
+	mysql.ConnectDatabase()
+	redis.ConnectRedis()
+}
+
 func InitRoutes(gin *gin.Engine) {
 	routes.RegisterRoutes(gin)
 }
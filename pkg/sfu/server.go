@@ -0,0 +1,39 @@
+package sfu
+
+import (
+	"context"
+	"log"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/unarya/univia/internal/sfu"
+)
+
+type Server struct {
+	SignalingURL string
+	Secret       string
+	ICEServers   []string
+}
+
+func NewServer(signalingURL, secret string, iceServers []string) *Server {
+	if signalingURL == "" {
+		signalingURL = "ws://signaling:2112/"
+	}
+	if len(iceServers) == 0 {
+		iceServers = []string{"stun:stun.l.google.com:19302"}
+	}
+	return &Server{SignalingURL: signalingURL, Secret: secret, ICEServers: iceServers}
+}
+
+// Start connects to signaling and forwards media until the context is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	client := sfu.NewSignalingClient(s.SignalingURL, s.Secret)
+	forwarder, err := sfu.NewSFU(client, []webrtc.ICEServer{{URLs: s.ICEServers}})
+	if err != nil {
+		return err
+	}
+	defer forwarder.Close()
+
+	log.Printf("[SFU] Negotiating through %s", s.SignalingURL)
+	client.Run(ctx, forwarder.HandleSignal)
+	return nil
+}
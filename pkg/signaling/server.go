@@ -1,15 +1,15 @@
 package signaling
 
 import (
+	"context"
 	"encoding/json"
 	"log"
-	"net"
 	"net/http"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
-	"github.com/unarya/univia/internal/infrastructure/redis"
+	"github.com/unarya/univia/internal/signaling/cluster"
 	"github.com/unarya/univia/internal/signaling/services"
 	"github.com/unarya/univia/internal/signaling/store"
 	"github.com/unarya/univia/pkg/types"
@@ -17,18 +17,23 @@ import (
 
 type Server struct {
 	Port string
+
+	// authenticate resolves who is behind the credentials of a new socket
+	authenticate func(services.Credentials) (*services.AuthContext, error)
 }
 
 func NewServer(port string) *Server {
 	if port == "" {
 		port = "2112"
 	}
-	return &Server{Port: port}
+	return &Server{Port: port, authenticate: services.Authenticate}
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Echo the credential marker back so browsers accept the subprotocol handshake
+	Subprotocols: []string{services.SubprotocolAccessToken, services.SubprotocolSessionID, services.SubprotocolSFU},
 	CheckOrigin: func(r *http.Request) bool {
 		// TODO: Add domain allowlist in production
 		return true
@@ -36,36 +41,87 @@ var upgrader = websocket.Upgrader{
 }
 
 func (s *Server) Start() error {
+	// Join the other replicas so messages reach users connected elsewhere
+	if _, err := cluster.Start(context.Background(), services.DeliverLocal, services.RoomsReleased); err != nil {
+		return err
+	}
+	go services.ConsumeNotifications(context.Background())
+
 	http.HandleFunc("/", s.handleWebSocket)
 	log.Printf("[Signaling] Listening on port %s", s.Port)
 	return http.ListenAndServe(":"+s.Port, nil)
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	creds := services.ExtractCredentials(r)
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
-		http.Error(w, "Could not upgrade connection", http.StatusBadRequest)
 		return
 	}
 	defer conn.Close()
 
-	ip := s.getUserIP(r)
-	userID := s.getUserIDFromRedis(ip)
-	if userID == uuid.Nil {
-		log.Printf("Unauthorized WebSocket attempt: ip=%s", ip)
-		conn.WriteJSON(map[string]string{"error": "unauthorized"})
+	// Fall back to a first-frame auth message when nothing came with the handshake
+	if creds.Empty() {
+		creds, err = services.ReadAuthFrame(conn)
+		if err != nil {
+			log.Printf("WebSocket auth frame rejected: remote=%s err=%v", r.RemoteAddr, err)
+			s.rejectConnection(conn)
+			return
+		}
+	}
+
+	auth, err := s.authenticate(creds)
+	if err != nil {
+		log.Printf("Unauthorized WebSocket attempt: remote=%s", r.RemoteAddr)
+		s.rejectConnection(conn)
 		return
 	}
 
-	store.SetUserSocket(userID, conn)
-	defer store.RemoveUserSocket(userID)
-	log.Printf("Client connected: ip=%s userID=%s", ip, userID.String())
+	store.SetUserSocket(auth.UserID, conn)
+	node := cluster.Current()
+	if node != nil {
+		if err := node.Register(auth.UserID); err != nil {
+			log.Printf("Failed to register presence for %s: %v", auth.UserID, err)
+		}
+	}
+	defer func() {
+		// Only the user's current socket owns their room memberships and presence
+		if store.RemoveUserSocketConn(auth.UserID, conn) {
+			if node != nil {
+				if err := node.Unregister(auth.UserID); err != nil {
+					log.Printf("Failed to release presence for %s: %v", auth.UserID, err)
+				}
+			}
+			services.LeaveAllRooms(auth.UserID)
+			if !auth.Service {
+				services.GoOffline(auth)
+			}
+		}
+	}()
+	log.Printf("Client connected: userID=%s sessionID=%s", auth.UserID, auth.SessionID)
+
+	done := make(chan struct{})
+	defer close(done)
+	if !auth.Service {
+		services.GoOnline(auth)
+		// Deliver what arrived while the user was offline
+		services.ReplayPendingNotifications(auth.UserID)
+		go services.WatchSession(conn, auth, done)
+	}
+
+	s.handleMessages(conn, auth)
+}
 
-	s.handleMessages(conn)
+func (s *Server) rejectConnection(conn *websocket.Conn) {
+	data, _ := json.Marshal(types.WebSocketMessage{Type: types.MessageTypeError, Message: "unauthorized"})
+	_ = store.WriteMessage(conn, websocket.TextMessage, data)
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unauthorized")
+	_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
 }
 
-func (s *Server) handleMessages(conn *websocket.Conn) {
+func (s *Server) handleMessages(conn *websocket.Conn, auth *services.AuthContext) {
 	for {
 		messageType, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -79,29 +135,8 @@ func (s *Server) handleMessages(conn *websocket.Conn) {
 			continue
 		}
 
-		if err := services.HandleMessage(conn, messageType, wsMsg); err != nil {
+		if err := services.HandleMessage(conn, auth, messageType, wsMsg); err != nil {
 			log.Printf("HandleMessage error: %v", err)
 		}
 	}
 }
-
-func (s *Server) getUserIP(r *http.Request) string {
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		return ip
-	}
-	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-	return ip
-}
-
-func (s *Server) getUserIDFromRedis(ip string) uuid.UUID {
-	val, err := redis.GetJSON[uuid.UUID](redis.Redis, "user:"+ip)
-	if err != nil {
-		log.Printf("Redis get user_id failed: %v", err)
-		return uuid.Nil
-	}
-	if val == nil {
-		log.Printf("Redis key not found for IP: %s", ip)
-		return uuid.Nil
-	}
-	return *val
-}
@@ -0,0 +1,230 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/unarya/univia/internal/signaling/services"
+	"github.com/unarya/univia/pkg/types"
+)
+
+// startTestServer serves the signaling handler, authenticating the access token "user-<uuid>" as that user.
+// Presence is persisted to MySQL, which these tests run without, so the sockets are authenticated like the
+// SFU's, which has none.
+func startTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := &Server{authenticate: func(creds services.Credentials) (*services.AuthContext, error) {
+		userID, err := uuid.Parse(strings.TrimPrefix(creds.AccessToken, "user-"))
+		if err != nil {
+			return nil, services.ErrUnauthorized
+		}
+		return &services.AuthContext{UserID: userID, Service: true}, nil
+	}}
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+// testSocket is a client connected to the test server
+type testSocket struct {
+	userID uuid.UUID
+	conn   *websocket.Conn
+}
+
+func connect(t *testing.T, server *httptest.Server) *testSocket {
+	t.Helper()
+	userID := uuid.New()
+	dialer := websocket.Dialer{Subprotocols: []string{services.SubprotocolAccessToken, "user-" + userID.String()}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testSocket{userID: userID, conn: conn}
+}
+
+func (s *testSocket) send(t *testing.T, msg types.WebSocketMessage) {
+	t.Helper()
+	if err := s.conn.WriteJSON(msg); err != nil {
+		t.Fatalf("%s sending %s: %v", s.userID, msg.Type, err)
+	}
+}
+
+func (s *testSocket) join(t *testing.T, roomID string) types.RoomSnapshot {
+	t.Helper()
+	s.send(t, types.WebSocketMessage{Type: types.MessageTypeJoin, RoomID: roomID})
+	return snapshotOf(t, s.expect(t, types.MessageTypeJoined))
+}
+
+// expect reads the socket until a message of the type arrives
+func (s *testSocket) expect(t *testing.T, messageType string) types.WebSocketMessage {
+	t.Helper()
+	_ = s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer s.conn.SetReadDeadline(time.Time{})
+	for {
+		var msg types.WebSocketMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("%s waiting for %s: %v", s.userID, messageType, err)
+		}
+		if msg.Type == messageType {
+			return msg
+		}
+	}
+}
+
+func snapshotOf(t *testing.T, msg types.WebSocketMessage) types.RoomSnapshot {
+	t.Helper()
+	var snapshot types.RoomSnapshot
+	if err := json.Unmarshal(msg.Payload, &snapshot); err != nil {
+		t.Fatalf("invalid snapshot in %s: %v", msg.Type, err)
+	}
+	sort.Strings(snapshot.Participants)
+	return snapshot
+}
+
+func participants(sockets ...*testSocket) []string {
+	ids := make([]string, 0, len(sockets))
+	for _, socket := range sockets {
+		ids = append(ids, socket.userID.String())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sameList(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func TestJoinAnnouncesParticipants(t *testing.T) {
+	server := startTestServer(t)
+	alice, bob := connect(t, server), connect(t, server)
+	roomID := "room-" + uuid.NewString()
+
+	snapshot := alice.join(t, roomID)
+	if snapshot.RoomID != roomID || snapshot.State != "waiting" || !sameList(snapshot.Participants, participants(alice)) {
+		t.Fatalf("alice joined %+v, want a waiting room with alice", snapshot)
+	}
+
+	snapshot = bob.join(t, roomID)
+	if snapshot.State != "active" || !sameList(snapshot.Participants, participants(alice, bob)) {
+		t.Fatalf("bob joined %+v, want an active room with both", snapshot)
+	}
+
+	update := alice.expect(t, types.MessageTypeParticipants)
+	if update.SenderID != bob.userID.String() || update.RoomID != roomID {
+		t.Fatalf("alice got an update from %s in %s, want bob in %s", update.SenderID, update.RoomID, roomID)
+	}
+	if snapshot := snapshotOf(t, update); !sameList(snapshot.Participants, participants(alice, bob)) {
+		t.Fatalf("alice sees participants %v, want both", snapshot.Participants)
+	}
+}
+
+func TestRelayBetweenParticipants(t *testing.T) {
+	server := startTestServer(t)
+	alice, bob, outsider := connect(t, server), connect(t, server), connect(t, server)
+	roomID := "room-" + uuid.NewString()
+	alice.join(t, roomID)
+	bob.join(t, roomID)
+	alice.expect(t, types.MessageTypeParticipants)
+
+	exchange := []struct {
+		from, to    *testSocket
+		messageType string
+		payload     string
+	}{
+		{alice, bob, types.MessageTypeOffer, `{"type":"offer","sdp":"v=0"}`},
+		{bob, alice, types.MessageTypeAnswer, `{"type":"answer","sdp":"v=0"}`},
+		{alice, bob, types.MessageTypeIceCandidate, `{"candidate":"candidate:1 1 udp 1 127.0.0.1 5000 typ host"}`},
+		{bob, alice, types.MessageTypeIceCandidate, `{"candidate":"candidate:2 1 udp 1 127.0.0.1 5001 typ host"}`},
+	}
+	for _, step := range exchange {
+		step.from.send(t, types.WebSocketMessage{
+			Type:     step.messageType,
+			RoomID:   roomID,
+			TargetID: step.to.userID.String(),
+			Payload:  json.RawMessage(step.payload),
+		})
+		got := step.to.expect(t, step.messageType)
+		if got.SenderID != step.from.userID.String() || got.TargetID != step.to.userID.String() || got.RoomID != roomID {
+			t.Fatalf("%s relayed from %s to %s in %s", step.messageType, got.SenderID, got.TargetID, got.RoomID)
+		}
+		if string(got.Payload) != step.payload {
+			t.Fatalf("%s payload = %s, want %s", step.messageType, got.Payload, step.payload)
+		}
+	}
+
+	// Nobody outside the room is relayed to, or relays into it
+	alice.send(t, types.WebSocketMessage{
+		Type: types.MessageTypeOffer, RoomID: roomID, TargetID: outsider.userID.String(), Payload: json.RawMessage(`{}`),
+	})
+	if got := alice.expect(t, types.MessageTypeError); got.RoomID != roomID {
+		t.Fatalf("relaying to an outsider: error for room %s, want %s", got.RoomID, roomID)
+	}
+	outsider.send(t, types.WebSocketMessage{
+		Type: types.MessageTypeOffer, RoomID: roomID, TargetID: alice.userID.String(), Payload: json.RawMessage(`{}`),
+	})
+	outsider.expect(t, types.MessageTypeError)
+
+	// A relay needs something to relay
+	alice.send(t, types.WebSocketMessage{Type: types.MessageTypeOffer, RoomID: roomID, TargetID: bob.userID.String()})
+	if got := alice.expect(t, types.MessageTypeError); !strings.Contains(got.Message, "payload") {
+		t.Fatalf("relaying without a payload: error %q", got.Message)
+	}
+}
+
+func TestLeavingUpdatesParticipants(t *testing.T) {
+	server := startTestServer(t)
+	alice, bob, carol := connect(t, server), connect(t, server), connect(t, server)
+	roomID := "room-" + uuid.NewString()
+	alice.join(t, roomID)
+	bob.join(t, roomID)
+	alice.expect(t, types.MessageTypeParticipants)
+	carol.join(t, roomID)
+	alice.expect(t, types.MessageTypeParticipants)
+	bob.expect(t, types.MessageTypeParticipants)
+
+	bob.send(t, types.WebSocketMessage{Type: types.MessageTypeLeave, RoomID: roomID})
+	update := alice.expect(t, types.MessageTypeParticipants)
+	if snapshot := snapshotOf(t, update); update.SenderID != bob.userID.String() ||
+		snapshot.State != "active" || !sameList(snapshot.Participants, participants(alice, carol)) {
+		t.Fatalf("after bob left alice sees %+v from %s", snapshot, update.SenderID)
+	}
+	carol.expect(t, types.MessageTypeParticipants)
+
+	// Leaving twice is an error
+	bob.send(t, types.WebSocketMessage{Type: types.MessageTypeLeave, RoomID: roomID})
+	bob.expect(t, types.MessageTypeError)
+
+	// Closing the socket leaves every room
+	_ = carol.conn.Close()
+	update = alice.expect(t, types.MessageTypeParticipants)
+	if snapshot := snapshotOf(t, update); update.SenderID != carol.userID.String() ||
+		snapshot.State != "waiting" || !sameList(snapshot.Participants, participants(alice)) {
+		t.Fatalf("after carol disconnected alice sees %+v from %s", snapshot, update.SenderID)
+	}
+}
+
+func TestUnauthenticatedSocketIsRejected(t *testing.T) {
+	server := startTestServer(t)
+	dialer := websocket.Dialer{Subprotocols: []string{services.SubprotocolAccessToken, "not-a-user"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var msg types.WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != types.MessageTypeError || msg.Message != "unauthorized" {
+		t.Fatalf("first message = %+v, %v; want an unauthorized error", msg, err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("after rejecting: %v, want a policy violation close", err)
+	}
+}
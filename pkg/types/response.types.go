@@ -1,6 +1,10 @@
 package types
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // StatusResponse represents the status portion of all responses
 // ================== BASE ==================
@@ -225,6 +229,11 @@ type LikeRequest struct {
 	PostID uuid.UUID `json:"post_id"`
 }
 
+// DeletePostRequest is used to delete and to restore a post
+type DeletePostRequest struct {
+	PostID uuid.UUID `json:"post_id" example:"36byte"`
+}
+
 type SuccessLikeAPostResponse struct {
 	Status StatusOK `json:"status"`
 	Data   struct {
@@ -238,6 +247,245 @@ type SuccessDisLikeAPostResponse struct {
 	}
 }
 
+// ================== FOLLOWS BLOCK CONTROLLER TYPES ==================
+
+type FollowRequest struct {
+	UserID uuid.UUID `json:"user_id" example:"36byte"`
+}
+
+type ListFollowsRequest struct {
+	UserID       uuid.UUID `json:"user_id" example:"36byte"` // defaults to the current user
+	CurrentPage  int       `json:"current_page" example:"1"`
+	ItemsPerPage int       `json:"items_per_page" example:"10"`
+}
+
+type ListFollowRequestsRequest struct {
+	CurrentPage  int `json:"current_page" example:"1"`
+	ItemsPerPage int `json:"items_per_page" example:"10"`
+}
+
+type UpdatePrivacyRequest struct {
+	IsPrivate bool `json:"is_private" example:"true"`
+}
+
+// ================== MESSAGES BLOCK CONTROLLER TYPES ==================
+
+type MessageAttachment struct {
+	URL         string `json:"url" binding:"required" example:"https://cdn.example.com/media/photo.jpg"`
+	ContentType string `json:"content_type" example:"image/jpeg"`
+	Name        string `json:"name" example:"photo.jpg"`
+	Size        int64  `json:"size" example:"204800"`
+}
+
+type SendMessageRequest struct {
+	ReceiverID  uuid.UUID           `json:"receiver_id" example:"36byte"`
+	Body        string              `json:"body" example:"Hey, how are you?"`
+	Attachments []MessageAttachment `json:"attachments" binding:"omitempty,dive"`
+}
+
+type ListConversationsRequest struct {
+	CurrentPage  int `json:"current_page" example:"1"`
+	ItemsPerPage int `json:"items_per_page" example:"20"`
+}
+
+// MessageHistoryRequest pages by message seq: Before loads older messages, After loads the ones missed
+// since a known seq. With neither, the latest messages are returned.
+type MessageHistoryRequest struct {
+	ConversationID uuid.UUID `json:"conversation_id" example:"36byte"`
+	Before         int64     `json:"before" example:"0"`
+	After          int64     `json:"after" example:"0"`
+	Limit          int       `json:"limit" example:"30"`
+}
+
+type EditMessageRequest struct {
+	MessageID uuid.UUID `json:"message_id" example:"36byte"`
+	Body      string    `json:"body" binding:"required" example:"Edited text"`
+}
+
+type MessageIDRequest struct {
+	MessageID uuid.UUID `json:"message_id" example:"36byte"`
+}
+
+type MarkConversationReadRequest struct {
+	ConversationID uuid.UUID `json:"conversation_id" example:"36byte"`
+	Seq            int64     `json:"seq" example:"0"` // defaults to the latest message
+}
+
+// ================== CONVERSATIONS BLOCK CONTROLLER TYPES ==================
+
+type CreateConversationRequest struct {
+	Title     string      `json:"title" binding:"required" example:"Weekend trip"`
+	MemberIDs []uuid.UUID `json:"member_ids" example:"[]"`
+}
+
+type ConversationMessageRequest struct {
+	Body        string              `json:"body" example:"Hello everyone"`
+	Attachments []MessageAttachment `json:"attachments" binding:"omitempty,dive"`
+}
+
+type ConversationMembersRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids" example:"[]"`
+}
+
+type ConversationUserRequest struct {
+	UserID uuid.UUID `json:"user_id" example:"36byte"`
+}
+
+// AssignConversationRoleRequest sets a member's role; assigning owner hands over the group
+type AssignConversationRoleRequest struct {
+	UserID uuid.UUID `json:"user_id" example:"36byte"`
+	Role   string    `json:"role" binding:"required" example:"admin"`
+}
+
+type RenameConversationRequest struct {
+	Title string `json:"title" binding:"required" example:"Weekend trip"`
+}
+
+type PinMessageRequest struct {
+	MessageID uuid.UUID `json:"message_id" example:"36byte"`
+}
+
+// ================== FRIENDS BLOCK CONTROLLER TYPES ==================
+
+type SendFriendRequestRequest struct {
+	UserID      uuid.UUID `json:"user_id" example:"36byte"`
+	Description string    `json:"description" example:"We met at the conference"`
+}
+
+type FriendUserRequest struct {
+	UserID uuid.UUID `json:"user_id" example:"36byte"`
+}
+
+type ListFriendsRequest struct {
+	CurrentPage  int `json:"current_page" example:"1"`
+	ItemsPerPage int `json:"items_per_page" example:"10"`
+}
+
+type ListFriendRequestsRequest struct {
+	Direction    string `json:"direction" binding:"omitempty,oneof=incoming outgoing" example:"incoming"`
+	CurrentPage  int    `json:"current_page" example:"1"`
+	ItemsPerPage int    `json:"items_per_page" example:"10"`
+}
+
+// ================== SHARES BLOCK CONTROLLER TYPES ==================
+
+type ShareRequest struct {
+	PostID uuid.UUID `json:"post_id" example:"36byte"`
+}
+
+type QuotePostRequest struct {
+	PostID  uuid.UUID `json:"post_id" example:"36byte"`
+	Content string    `json:"content" binding:"required" example:"This is worth a read"`
+	// Visibility of the quote: "public" (default), "followers", "friends", "only_me" or "team"
+	Visibility string `json:"visibility" example:"public"`
+}
+
+type ListSharesRequest struct {
+	CurrentPage  int `json:"current_page" example:"1"`
+	ItemsPerPage int `json:"items_per_page" example:"10"`
+}
+
+type HomeFeedRequest struct {
+	ItemsPerPage int `json:"items_per_page" example:"10"`
+	// Cursor is the next_cursor or prev_cursor of an earlier page, empty for the top of the feed
+	Cursor string `json:"cursor" example:""`
+}
+
+// ================== SEARCH BLOCK CONTROLLER TYPES ==================
+
+type SearchRequest struct {
+	Query string `json:"query" binding:"required" example:"anime reviews"`
+	// Kinds narrows the search to "post", "user" and "category" documents, all of them when empty
+	Kinds        []string `json:"kinds" example:"post,user"`
+	CurrentPage  int      `json:"current_page" example:"1"`
+	ItemsPerPage int      `json:"items_per_page" example:"20"`
+}
+
+// ================== DRAFTS BLOCK CONTROLLER TYPES ==================
+
+type ListDraftsRequest struct {
+	CurrentPage  int `json:"current_page" example:"1"`
+	ItemsPerPage int `json:"items_per_page" example:"10"`
+	// Statuses is any of "draft", "scheduled" and "archived", drafts and scheduled posts when empty
+	Statuses []string `json:"statuses" example:"draft,scheduled"`
+}
+
+type UpdatePostStatusRequest struct {
+	PostID uuid.UUID `json:"post_id" binding:"required" example:"36byte"`
+	// Status is "draft", "scheduled", "published" or "archived"
+	Status string `json:"status" binding:"required" example:"scheduled"`
+	// PublishAt is when a scheduled post gets published, required for "scheduled"
+	PublishAt *time.Time `json:"publish_at" example:"2026-01-02T15:04:05Z"`
+}
+
+type UpdatePostVisibilityRequest struct {
+	PostID uuid.UUID `json:"post_id" binding:"required" example:"36byte"`
+	// Visibility is "public", "followers", "friends", "only_me" or "team"
+	Visibility string `json:"visibility" binding:"required" example:"followers"`
+}
+
+// ================== TAGS BLOCK CONTROLLER TYPES ==================
+
+type TagPostsRequest struct {
+	// Tag is matched without its '#' and case insensitively
+	Tag          string `json:"tag" binding:"required" example:"golang"`
+	ItemsPerPage int    `json:"items_per_page" example:"10"`
+	// Cursor is the next_cursor or prev_cursor of an earlier page, empty for the first page
+	Cursor string `json:"cursor" example:""`
+}
+
+// ================== COMMENTS BLOCK CONTROLLER TYPES ==================
+
+type CreateCommentRequest struct {
+	PostID   uuid.UUID  `json:"post_id" example:"36byte"`
+	ParentID *uuid.UUID `json:"parent_id" example:"36byte"`
+	Text     string     `json:"text" binding:"required" example:"Nice shot!"`
+}
+
+type ListCommentsRequest struct {
+	PostID       uuid.UUID `json:"post_id" example:"36byte"`
+	CurrentPage  int       `json:"current_page" example:"1"`
+	ItemsPerPage int       `json:"items_per_page" example:"10"`
+	// Depth is how many reply levels to include under each comment, 2 when omitted
+	Depth *int `json:"depth" example:"2"`
+	// Pagination is "offset" (default) or "cursor". Cursor pages take Cursor and ItemsPerPage only.
+	Pagination string `json:"pagination" example:"cursor"`
+	// Cursor is the next_cursor or prev_cursor of an earlier page, empty for the first page
+	Cursor string `json:"cursor" example:""`
+}
+
+type UpdateCommentRequest struct {
+	CommentID uuid.UUID `json:"comment_id" example:"36byte"`
+	Text      string    `json:"text" binding:"required" example:"Nice shot! (edited)"`
+}
+
+type CommentIDRequest struct {
+	CommentID uuid.UUID `json:"comment_id" example:"36byte"`
+}
+
+// ================== UPLOADS BLOCK CONTROLLER TYPES ==================
+
+type CreateUploadSessionRequest struct {
+	// FileName is only informative, storage keys never derive from it
+	FileName    string `json:"file_name" example:"clip.mp4"`
+	ContentType string `json:"content_type" binding:"required" example:"video/mp4"`
+	Size        int64  `json:"size" binding:"required" example:"104857600"`
+}
+
+type UploadPart struct {
+	PartNumber int    `json:"part_number" example:"1"`
+	ETag       string `json:"etag" example:"9b2cf535f27731c974343645a3985328"`
+}
+
+type CompleteUploadSessionRequest struct {
+	UploadID uuid.UUID    `json:"upload_id" example:"36byte"`
+	Parts    []UploadPart `json:"parts"`
+}
+
+type UploadIDRequest struct {
+	UploadID uuid.UUID `json:"upload_id" example:"36byte"`
+}
+
 // ================== NOTIFICATIONS BLOCK CONTROLLER TYPES ==================
 
 type ListNotificationRequest struct {
@@ -248,6 +496,10 @@ type ListNotificationRequest struct {
 	SearchValue  string `json:"search_value" example:"general"`
 	IsSeen       bool   `json:"is_seen" example:"true"`
 	All          bool   `json:"all" example:"true"`
+	// Pagination is "offset" (default) or "cursor". Cursor pages take Cursor and ItemsPerPage only.
+	Pagination string `json:"pagination" example:"cursor"`
+	// Cursor is the next_cursor or prev_cursor of an earlier page, empty for the first page
+	Cursor string `json:"cursor" example:""`
 }
 
 type UpdateSeenRequest struct {
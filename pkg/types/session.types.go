@@ -13,3 +13,11 @@ type ResponseSession struct {
 	SessionID    uuid.UUID
 	UserID       uuid.UUID
 }
+
+// CachedSession is the `session:<id>` entry written by utils.SetSessionToRedis
+type CachedSession struct {
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"session_id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+}
@@ -1,7 +1,104 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	MessageTypeAuth  = "auth"
+	MessageTypeError = "error"
+
+	// Room signaling protocol
+	MessageTypeJoin         = "join"
+	MessageTypeLeave        = "leave"
+	MessageTypeJoined       = "joined"
+	MessageTypeOffer        = "offer"
+	MessageTypeAnswer       = "answer"
+	MessageTypeIceCandidate = "ice-candidate"
+	MessageTypeParticipants = "participants"
+	MessageTypeLayer        = "layer"
+
+	// Direct messages, pushed by the API through the notifications topic
+	MessageTypeChatMessage        = "message"
+	MessageTypeChatMessageEdited  = "message-edited"
+	MessageTypeChatMessageDeleted = "message-deleted"
+	MessageTypeConversationUpdate = "conversation-updated"
+	MessageTypeReadReceipt        = "message-read"
+
+	// Conversation activity; RoomID carries the conversation id
+	MessageTypeTypingStart = "typing-start"
+	MessageTypeTypingStop  = "typing-stop"
+	MessageTypeRead        = "read"
+
+	// Presence: clients set their own status and subscribe to the users they want to watch
+	MessageTypePresence            = "presence"
+	MessageTypePresenceSubscribe   = "presence-subscribe"
+	MessageTypePresenceUnsubscribe = "presence-unsubscribe"
+	MessageTypePresenceSnapshot    = "presence-snapshot"
+)
+
+// Presence statuses
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
 type WebSocketMessage struct {
-	Type       string `json:"type"`
-	Message    string `json:"message"`
-	ReceiverID string `json:"receiverId"`
+	Type       string          `json:"type"`
+	Message    string          `json:"message"`
+	ReceiverID string          `json:"receiverId"`
+	RoomID     string          `json:"roomId,omitempty"`
+	SenderID   string          `json:"senderId,omitempty"`
+	TargetID   string          `json:"targetId,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+
+	// Recipients fans one event out to several users; signaling sets ReceiverID per recipient
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+// AuthMessage is the first frame a client sends when it cannot pass credentials on the handshake
+type AuthMessage struct {
+	Type      string `json:"type"`
+	Token     string `json:"token,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// RoomSnapshot is the payload of `joined` and `participants` messages
+type RoomSnapshot struct {
+	RoomID       string   `json:"roomId"`
+	State        string   `json:"state"`
+	Participants []string `json:"participants"`
+}
+
+// LayerSelection is the payload of a `layer` message asking the SFU for a simulcast layer
+type LayerSelection struct {
+	PublisherID string `json:"publisherId"`
+	TrackID     string `json:"trackId"`
+	RID         string `json:"rid"`
+}
+
+// PresenceUpdate is the payload of `presence` messages and the items of `presence-snapshot`
+type PresenceUpdate struct {
+	UserID   string     `json:"userId"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
+
+// PresenceSubscription is the payload of `presence-subscribe` and `presence-unsubscribe`
+type PresenceSubscription struct {
+	UserIDs []string `json:"userIds"`
+}
+
+// TypingUpdate is the payload of `typing-start` and `typing-stop`; receivers drop a start after ExpiresIn seconds
+type TypingUpdate struct {
+	ConversationID string `json:"conversationId"`
+	UserID         string `json:"userId"`
+	ExpiresIn      int    `json:"expiresIn,omitempty"`
+}
+
+// ReadMarker is the payload of a client `read` message; a zero Seq marks the whole conversation read
+type ReadMarker struct {
+	Seq int64 `json:"seq"`
 }
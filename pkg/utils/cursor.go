@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PaginationOffset = "offset"
+	PaginationCursor = "cursor"
+
+	DefaultCursorLimit = 10
+	MaxCursorLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered by (created_at, id). Clients only see it encoded, as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	// Backward cursors page towards the start of the list, they come from prev_cursor
+	Backward bool `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor returned by an earlier page. An empty cursor is the first page.
+func DecodeCursor(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// CursorPage is a page requested with keyset pagination
+type CursorPage struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// UseCursor reports whether a list request asked for cursor pagination rather than pages and offsets
+func UseCursor(mode, cursor string) bool {
+	return mode == PaginationCursor || cursor != ""
+}
+
+// NewCursorPage decodes the cursor of a request and bounds its page size
+func NewCursorPage(cursor string, limit int) (CursorPage, error) {
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		return CursorPage{}, err
+	}
+	if limit <= 0 {
+		limit = DefaultCursorLimit
+	}
+	return CursorPage{Cursor: decoded, Limit: min(limit, MaxCursorLimit)}, nil
+}
+
+// Backward reports whether the page goes towards the start of the list
+func (p CursorPage) Backward() bool {
+	return p.Cursor != nil && p.Cursor.Backward
+}
+
+// TrimCursorPage takes rows fetched with one more than the page limit, in the direction of the page.
+// It drops the extra row, which only tells whether the list goes on, and puts backward pages back in list order.
+func TrimCursorPage[T any](rows []T, page CursorPage) ([]T, bool) {
+	hasMore := len(rows) > page.Limit
+	if hasMore {
+		rows = rows[:page.Limit]
+	}
+	if page.Backward() {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	return rows, hasMore
+}
+
+// CursorPaginate builds the pagination metadata of a page from the positions of its first and last items,
+// nil when the page is empty. hasMore tells whether the list goes on in the direction of the page.
+func CursorPaginate(page CursorPage, first, last *Cursor, hasMore bool) map[string]interface{} {
+	var next, prev *string
+	if first != nil && last != nil {
+		// Coming from a cursor means the list goes on behind us
+		if page.Backward() && hasMore || !page.Backward() && page.Cursor != nil {
+			encoded := Cursor{CreatedAt: first.CreatedAt, ID: first.ID, Backward: true}.Encode()
+			prev = &encoded
+		}
+		if !page.Backward() && hasMore || page.Backward() {
+			encoded := Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+			next = &encoded
+		}
+	}
+	return map[string]interface{}{
+		"items_per_page": page.Limit,
+		"next_cursor":    next,
+		"prev_cursor":    prev,
+	}
+}
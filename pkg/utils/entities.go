@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxTagLength bounds a hashtag in characters, longer ones are not tags
+	MaxTagLength = 100
+	// MaxEntitiesPerText bounds the hashtags and the mentions kept from a single post or comment
+	MaxEntitiesPerText = 30
+)
+
+// ParseHashtags returns the distinct hashtags of text, normalized by NormalizeTag, in order of appearance.
+// A hashtag is '#' followed by letters, digits and underscores with at least one letter, not preceded by a word.
+func ParseHashtags(text string) []string {
+	return parseEntities(text, '#', func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || unicode.Is(unicode.Mn, r)
+	}, func(name string) string {
+		if !strings.ContainsFunc(name, unicode.IsLetter) || len([]rune(name)) > MaxTagLength {
+			return ""
+		}
+		return NormalizeTag(name)
+	})
+}
+
+// ParseMentions returns the distinct usernames mentioned as @username in text, lower-cased, in order of
+// appearance. Addresses such as name@example.com are not mentions.
+func ParseMentions(text string) []string {
+	return parseEntities(text, '@', func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+	}, func(name string) string {
+		// A mention at the end of a sentence keeps its full stop out
+		return strings.ToLower(strings.TrimRight(name, "."))
+	})
+}
+
+// NormalizeTag folds the ways of writing a hashtag into one: "#Café", "café" and "CAFÉ" are all "café"
+func NormalizeTag(tag string) string {
+	return strings.ToLower(norm.NFC.String(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+}
+
+func parseEntities(text string, marker rune, inName func(rune) bool, normalize func(string) string) []string {
+	runes := []rune(text)
+	seen := make(map[string]bool)
+	var entities []string
+
+	for i := 0; i < len(runes) && len(entities) < MaxEntitiesPerText; i++ {
+		if runes[i] != marker {
+			continue
+		}
+		// The marker starts an entity only at the start of a word
+		if i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]) || runes[i-1] == '_' || runes[i-1] == marker) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && inName(runes[end]) {
+			end++
+		}
+		name := normalize(string(runes[i+1 : end]))
+		i = end - 1
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		entities = append(entities, name)
+	}
+	return entities
+}
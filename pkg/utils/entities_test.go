@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Loving #GoLang and #golang again", []string{"golang"}},
+		{"#anime_reviews, #KPop!", []string{"anime_reviews", "kpop"}},
+		{"Ranked #1 today", nil},
+		{"Room#42 and ##double", nil},
+		{"Café time #Café", []string{"café"}},
+		{"https://example.com/page#section", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseHashtags(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseHashtags(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Thanks @Alice and @bob.smith.", []string{"alice", "bob.smith"}},
+		{"cc @alice @ALICE", []string{"alice"}},
+		{"Write to me@example.com", nil},
+		{"@ alone", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseMentions(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseMentions(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseEntitiesLimit(t *testing.T) {
+	text := ""
+	for i := 0; i < MaxEntitiesPerText+5; i++ {
+		text += " #tag" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	if got := len(ParseHashtags(text)); got != MaxEntitiesPerText {
+		t.Errorf("kept %d hashtags, want %d", got, MaxEntitiesPerText)
+	}
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"encoding/binary"
-	"fmt"
 	"math"
 	"net/http"
 	"os"
@@ -173,7 +172,7 @@ func NowPtr() *time.Time {
 
 func SetSessionToRedis(db *gorm.DB, session sessions.UserSession, user users.User, meta types.SessionMetadata) error {
 	// Save redis for signal handshaking
-	cacheKey := fmt.Sprintf("session:%s", session.SessionID)
+	cacheKey := redis.SessionCacheKey(session.SessionID)
 	cacheValue := map[string]interface{}{
 		"user_id":     user.ID,
 		"email":       user.Email,
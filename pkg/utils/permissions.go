@@ -27,6 +27,10 @@ var Permissions = map[string]string{
 	"ALLOW_LIKE_POST":   "allow_like_post",
 	"ALLOW_UNDO_LIKE":   "allow_undo_like_post",
 
+	// ===== Moderation (acting on other users' content) =====
+	"ALLOW_MODERATE_POST":    "allow_moderate_post",
+	"ALLOW_MODERATE_COMMENT": "allow_moderate_comment",
+
 	// ===== Team / Workspace =====
 	"ALLOW_CREATE_TEAM":      "allow_create_team",
 	"ALLOW_DELETE_TEAM":      "allow_delete_team",
@@ -34,6 +38,16 @@ var Permissions = map[string]string{
 	"ALLOW_REMOVE_MEMBER":    "allow_remove_member",
 	"ALLOW_ASSIGN_TEAM_ROLE": "allow_assign_team_role",
 
+	// ===== Conversations (checked against ConversationRolePermissions) =====
+	"ALLOW_VIEW_CONVERSATION":           "allow_view_conversation",
+	"ALLOW_SEND_CONVERSATION_MESSAGE":   "allow_send_conversation_message",
+	"ALLOW_DELETE_CONVERSATION_MESSAGE": "allow_delete_conversation_message",
+	"ALLOW_INVITE_CONVERSATION_MEMBER":  "allow_invite_conversation_member",
+	"ALLOW_KICK_CONVERSATION_MEMBER":    "allow_kick_conversation_member",
+	"ALLOW_ASSIGN_CONVERSATION_ROLE":    "allow_assign_conversation_role",
+	"ALLOW_RENAME_CONVERSATION":         "allow_rename_conversation",
+	"ALLOW_PIN_CONVERSATION_MESSAGE":    "allow_pin_conversation_message",
+
 	// ===== Notifications =====
 	"ALLOW_LIST_NOTIFICATIONS":       "allow_list_notifications",
 	"ALLOW_SEEN_SINGLE_NOTIFICATION": "allow_seen_single_notification",
@@ -49,3 +63,41 @@ var Permissions = map[string]string{
 	"ALLOW_VIEW_LOGS":     "allow_view_logs",
 	"ALLOW_MANAGE_SERVER": "allow_manage_server",
 }
+
+// ConversationRolePermissions grants permissions to the roles of a conversation's participants.
+// Unlike Permissions, these are not stored per role in the database: every conversation has the same three roles.
+var ConversationRolePermissions = map[string][]string{
+	"owner": {
+		"allow_view_conversation",
+		"allow_send_conversation_message",
+		"allow_delete_conversation_message",
+		"allow_invite_conversation_member",
+		"allow_kick_conversation_member",
+		"allow_assign_conversation_role",
+		"allow_rename_conversation",
+		"allow_pin_conversation_message",
+	},
+	"admin": {
+		"allow_view_conversation",
+		"allow_send_conversation_message",
+		"allow_delete_conversation_message",
+		"allow_invite_conversation_member",
+		"allow_kick_conversation_member",
+		"allow_rename_conversation",
+		"allow_pin_conversation_message",
+	},
+	"member": {
+		"allow_view_conversation",
+		"allow_send_conversation_message",
+	},
+}
+
+// ConversationRoleAllows reports whether a participant with role holds permission
+func ConversationRoleAllows(role, permission string) bool {
+	for _, granted := range ConversationRolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}